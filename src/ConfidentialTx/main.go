@@ -14,9 +14,9 @@ func main() {
 	y := new(big.Int).SetInt64(20)
 	z := new(big.Int).SetInt64(10)
 	// get blind factor, pedersen commit and zkproof
-	blindFactorX, tX, hX, pX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
+	blindFactorX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
 
-	proofDataX, _ := zkproofs.DumpProof(tX, hX, pX, &proofX)
+	proofDataX, _ := zkproofs.DumpProof(&proofX)
 
 	verifier, proof, _ := zkproofs.LoadProof(proofDataX)
 
@@ -30,24 +30,24 @@ func main() {
 		fmt.Println("proofX verified >0.")
 	}
 
-	blindFactorY, tY, hY, pY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
+	blindFactorY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
 
-	proofDataY, _ := zkproofs.DumpProof(tY, hY, pY, &proofY)
+	proofDataY, _ := zkproofs.DumpProof(&proofY)
 
 	verifier, proof, _ = zkproofs.LoadProof(proofDataY)
-	ok, _ = verifier.Verify(proofY)
+	ok, _ = verifier.Verify(*proof)
 	if !ok {
 		fmt.Println("proofY failed!!!")
 	} else {
 		fmt.Println("proofY verified >0.")
 	}
 
-	blindFactorZ, tZ, hZ, pZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
+	blindFactorZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
 
-	proofDataZ, _ := zkproofs.DumpProof(tZ, hZ, pZ, &proofZ)
+	proofDataZ, _ := zkproofs.DumpProof(&proofZ)
 
 	verifier, proof, _ = zkproofs.LoadProof(proofDataZ)
-	ok, _ = verifier.Verify(proofZ)
+	ok, _ = verifier.Verify(*proof)
 	if !ok {
 		fmt.Println("proofZ failed!!!")
 	} else {
@@ -58,13 +58,12 @@ func main() {
 	blindOut := new(big.Int).Add(blindFactorY, blindFactorZ)
 	blindDiff := new(big.Int).Sub(blindFactorX, blindOut)
 
-	check := zkproofs.VerifyPedersenCommitment([]*zkproofs.PedersenCommitment{proofX.V}, []*zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
+	check := zkproofs.VerifyPedersenCommitment([]zkproofs.PedersenCommitment{proofX.V}, []zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
 	fmt.Println("pedersen verify result:", check)
 
 }
 
-// 最好能抽象出一个独立的 zkrp.Bp 出来，然后
-// 主要有两个 challenge值 y 和 z 应该是由 verifier 那边根据A 和 S 生成的，这边把这个交互过程省略了，所以最后应该把
-// zkrp.Zkip.Hh = hprime
-// zkrp.Zkip.Cc = tprime
-// 这两个值也放在 proof 中发过去，有个问题：G 和 H 用户是否应该知道？
+// verifier 只依赖公共参数：G、H、Gg、Hh 和 Uu 都由公开的种子生成，双方都知道，不需要由 prover 提供。
+// challenge 值 y、z 和 x 由 verifier 根据 A、S、T1、T2 通过 Fiat-Shamir 自己计算，
+// hprime 和内积证明的承诺 P 也由 verifier 自己重新计算，内积证明的 c 就是 proof 中的 tprime，它由等式 (65) 约束，
+// 所以 DumpProof 生成的 proof 中只包含 V、A、S、T1、T2、taux、mu、tprime 以及内积证明的 a、b、L、R。
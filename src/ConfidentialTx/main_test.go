@@ -15,19 +15,19 @@ func TestCTx(t *testing.T) {
 	y := new(big.Int).SetInt64(20)
 	z := new(big.Int).SetInt64(10)
 
-	blindFactorX, tX, hX, pX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
+	blindFactorX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
 	var ok bool
-	verifier := zkproofs.GetVerifier(tX, hX, pX)
+	verifier := zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofX)
 	assert.True(t, ok)
 
-	blindFactorY, tY, hY, pY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
-	verifier = zkproofs.GetVerifier(tY, hY, pY)
+	blindFactorY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
+	verifier = zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofY)
 	assert.True(t, ok)
 
-	blindFactorZ, tZ, hZ, pZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
-	verifier = zkproofs.GetVerifier(tZ, hZ, pZ)
+	blindFactorZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
+	verifier = zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofZ)
 	assert.True(t, ok)
 
@@ -35,7 +35,7 @@ func TestCTx(t *testing.T) {
 	blindOut := new(big.Int).Add(blindFactorY, blindFactorZ)
 	blindDiff := new(big.Int).Sub(blindFactorX, blindOut)
 
-	check := zkproofs.VerifyPedersenCommitment([]*zkproofs.PedersenCommitment{proofX.V}, []*zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
+	check := zkproofs.VerifyPedersenCommitment([]zkproofs.PedersenCommitment{proofX.V}, []zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
 	fmt.Println("pedersen verify result:", check)
 	assert.True(t, check)
 }
@@ -46,19 +46,19 @@ func TestCTx2(t *testing.T) {
 	y := new(big.Int).SetInt64(40)
 	z := new(big.Int).SetInt64(10)
 
-	blindFactorX, tX, hX, pX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
+	blindFactorX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
 	var ok bool
-	verifier := zkproofs.GetVerifier(tX, hX, pX)
+	verifier := zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofX)
 	assert.True(t, ok)
 
-	blindFactorY, tY, hY, pY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
-	verifier = zkproofs.GetVerifier(tY, hY, pY)
+	blindFactorY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
+	verifier = zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofY)
 	assert.True(t, ok)
 
-	blindFactorZ, tZ, hZ, pZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
-	verifier = zkproofs.GetVerifier(tZ, hZ, pZ)
+	blindFactorZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
+	verifier = zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofZ)
 	assert.True(t, ok)
 
@@ -66,7 +66,7 @@ func TestCTx2(t *testing.T) {
 	blindOut := new(big.Int).Add(blindFactorY, blindFactorZ)
 	blindDiff := new(big.Int).Sub(blindFactorX, blindOut)
 
-	check := zkproofs.VerifyPedersenCommitment([]*zkproofs.PedersenCommitment{proofX.V}, []*zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
+	check := zkproofs.VerifyPedersenCommitment([]zkproofs.PedersenCommitment{proofX.V}, []zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
 	fmt.Println("pedersen verify result:", check)
 	assert.False(t, check)
 }
@@ -77,19 +77,19 @@ func TestCTx3(t *testing.T) {
 	y := new(big.Int).SetInt64(40)
 	z := new(big.Int).SetInt64(-10)
 
-	blindFactorX, tX, hX, pX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
+	blindFactorX, proofX, _ := zkproofs.GetZkrp().GenerateProof(x)
 	var ok bool
-	verifier := zkproofs.GetVerifier(tX, hX, pX)
+	verifier := zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofX)
 	assert.True(t, ok)
 
-	blindFactorY, tY, hY, pY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
-	verifier = zkproofs.GetVerifier(tY, hY, pY)
+	blindFactorY, proofY, _ := zkproofs.GetZkrp().GenerateProof(y)
+	verifier = zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofY)
 	assert.True(t, ok)
 
-	blindFactorZ, tZ, hZ, pZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
-	verifier = zkproofs.GetVerifier(tZ, hZ, pZ)
+	blindFactorZ, proofZ, _ := zkproofs.GetZkrp().GenerateProof(z)
+	verifier = zkproofs.GetVerifier()
 	ok, _ = verifier.Verify(proofZ)
 	assert.False(t, ok)
 
@@ -97,7 +97,7 @@ func TestCTx3(t *testing.T) {
 	blindOut := new(big.Int).Add(blindFactorY, blindFactorZ)
 	blindDiff := new(big.Int).Sub(blindFactorX, blindOut)
 
-	check := zkproofs.VerifyPedersenCommitment([]*zkproofs.PedersenCommitment{proofX.V}, []*zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
+	check := zkproofs.VerifyPedersenCommitment([]zkproofs.PedersenCommitment{proofX.V}, []zkproofs.PedersenCommitment{proofY.V, proofZ.V}, blindDiff)
 	fmt.Println("pedersen verify result:", check)
 	assert.True(t, check)
 }
@@ -0,0 +1,727 @@
+/*
+This file contains the aggregated version of the Bulletproofs range proof, which
+convinces the verifier that m committed values belong to [0, 2^n) with a single
+proof. Range proofs of a single secret over [a, b] are built on top of it as well.
+It follows section 4.3 of the paper:
+Bulletproofs: Short Proofs for Confidential Transactions and More
+Benedikt Bunz, Jonathan Bootle, Dan Boneh, Andrew Poelstra, Pieter Wuille and Greg Maxwell
+*/
+
+package zkproofs
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+/*
+Aggregated Bulletproofs proof. Besides the commitments V, it is composed by
+2.log2(n.m)+9 elements: A, S, T1, T2, Taux, Mu, Tprime and the inner product proof,
+made of Ls, Rs and the final scalars A and B.
+*/
+type proofAggBP struct {
+	V       []Element
+	A       Element
+	S       Element
+	T1      Element
+	T2      Element
+	Taux    Scalar
+	Mu      Scalar
+	Tprime  Scalar
+	Proofip proofBip
+}
+
+/*
+rangeBlinds contains the blinding factors alpha and rho of A and S, and tau1 and tau2
+of T1 and T2.
+*/
+type rangeBlinds struct {
+	alpha Scalar
+	rho   Scalar
+	tau1  Scalar
+	tau2  Scalar
+}
+
+/*
+randomBlinds returns uniformly random blinding factors.
+*/
+func (zkrp *Bp) randomBlinds() (rangeBlinds, error) {
+	var (
+		blinds rangeBlinds
+	)
+	random, err := zkrp.randomScalars(4)
+	if err != nil {
+		return blinds, err
+	}
+	blinds.alpha = random[0]
+	blinds.rho = random[1]
+	blinds.tau1 = random[2]
+	blinds.tau2 = random[3]
+	return blinds, nil
+}
+
+/*
+isPowerOfTwo returns true if and only if n is a positive power of two.
+*/
+func isPowerOfTwo(n int64) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+/*
+log2 returns the base 2 logarithm of n, which must be a power of two.
+*/
+func log2(n int64) int64 {
+	var result int64
+	for n > 1 {
+		n = n / 2
+		result = result + 1
+	}
+	return result
+}
+
+/*
+nextPowerOfTwo returns the smallest power of two greater than or equal to n.
+*/
+func nextPowerOfTwo(n int64) int64 {
+	var (
+		result int64
+	)
+	result = 1
+	for result < n {
+		result = 2 * result
+	}
+	return result
+}
+
+/*
+paddedSize returns the size of the vectors of the inner product argument for m values
+of n bits, that is n.m rounded up to a power of two.
+*/
+func (zkrp *Bp) paddedSize(m int64) int64 {
+	return nextPowerOfTwo(zkrp.N * m)
+}
+
+/*
+padVector appends zeros to a up to the size n. The padded entries of l and r do not
+change <l, r>, and their generators get the exponent 0 in the commitment P, so that
+the verifier does not have to account for them in P.
+*/
+func padVector(group Group, a []Scalar, n int64) []Scalar {
+	var (
+		i int64
+	)
+	result := make([]Scalar, n)
+	copy(result, a)
+	i = int64(len(a))
+	for i < n {
+		result[i] = group.NewScalar()
+		i = i + 1
+	}
+	return result
+}
+
+/*
+checkRange validates the number of values m proven in [0, 2^n) against the parameters.
+*/
+func (zkrp *Bp) checkRange(m int64) error {
+	if m < 1 || zkrp.paddedSize(m) > int64(len(zkrp.Gg)) {
+		return errors.New("number of values must be between 1 and the M given to SetupAggregate")
+	}
+	return nil
+}
+
+/*
+powersOfTwoZ returns the vector z^2.2^n || z^3.2^n || ... || z^(m+1).2^n, which
+binds each block of n bits to its own power of the challenge z.
+*/
+func (zkrp *Bp) powersOfTwoZ(z Scalar, m int64) []Scalar {
+	var (
+		i, j   int64
+		result []Scalar
+	)
+	group := zkrp.Group()
+	two := group.NewScalar().SetInt64(2)
+	zj := group.NewScalar().Mul(z, z)
+	result = make([]Scalar, 0, zkrp.N*m)
+	j = 0
+	for j < m {
+		current := group.NewScalar().Set(zj)
+		i = 0
+		for i < zkrp.N {
+			result = append(result, group.NewScalar().Set(current))
+			current.Mul(current, two)
+			i = i + 1
+		}
+		zj.Mul(zj, z)
+		j = j + 1
+	}
+	return result
+}
+
+/*
+switchGenerators computes h' = h^(y^-i), the generators used by the inner product. The
+vector h must not be empty.
+*/
+func switchGenerators(pool *Pool, h []Element, y Scalar) []Element {
+	var (
+		hprime []Element
+	)
+	group := h[0].Group()
+	hprime = make([]Element, len(h))
+	yinv := group.NewScalar().Inverse(y)
+	expy := scalarPowers(group, yinv, int64(len(h)))
+	n := int64(len(h))
+	pool.parallelFor(n, pool.workers(n), func(chunk, lo, hi int64) {
+		for i := lo; i < hi; i++ {
+			if i == 0 {
+				hprime[0] = h[0]
+				continue
+			}
+			hprime[i] = group.NewElement().ScalarMult(h[i], expy[i])
+		}
+	})
+	return hprime
+}
+
+/*
+GenerateAggregateProof computes a single ZK proof that every secret belongs to [A, B].
+The number of secrets must not exceed the M given to SetupAggregate. It returns the
+blinding factors used in the commitments proof.V.
+*/
+func (zkrp *Bp) GenerateAggregateProof(secrets []*big.Int) ([]*big.Int, proofAggBP, error) {
+	return zkrp.GenerateAggregateProofWithTranscript(NewTranscript(DOMAIN), secrets)
+}
+
+/*
+GenerateAggregateProofWithTranscript is GenerateAggregateProof with the Fiat-Shamir
+challenges bound to the given transcript.
+*/
+func (zkrp *Bp) GenerateAggregateProofWithTranscript(transcript *Transcript, secrets []*big.Int) ([]*big.Int, proofAggBP, error) {
+	var (
+		j, m  int64
+		proof proofAggBP
+	)
+	m = int64(len(secrets))
+	if m > zkrp.M {
+		return nil, proof, errors.New("number of values must be between 1 and the M given to SetupAggregate")
+	}
+
+	// commitments to v_j and gamma_j
+	gammas, err := zkrp.randomScalars(m)
+	if err != nil {
+		return nil, proof, err
+	}
+	V := make([]Element, m)
+	j = 0
+	for j < m {
+		V[j], _ = CommitG1(zkrp.Group().NewScalar().SetBigInt(secrets[j]), gammas[j], zkrp.H)
+		j = j + 1
+	}
+
+	masks, err := zkrp.randomBlinds()
+	if err != nil {
+		return nil, proof, err
+	}
+	values, blinds := zkrp.shiftValues(secrets, gammas)
+	proof, err = zkrp.proveRange(transcript, zkrp.shiftCommitments(V), values, blinds, masks)
+	if err != nil {
+		return nil, proof, err
+	}
+	proof.V = V
+	return bigInts(gammas), proof, nil
+}
+
+/*
+VerifyAggregate returns true if and only if the aggregated proof is valid, i.e. every
+secret committed in proof.V belongs to [A, B].
+*/
+func (zkrp *Bp) VerifyAggregate(proof proofAggBP) (bool, error) {
+	return zkrp.VerifyAggregateWithTranscript(NewTranscript(DOMAIN), proof)
+}
+
+/*
+VerifyAggregateWithTranscript is VerifyAggregate with the Fiat-Shamir challenges bound
+to the given transcript, which must be in the same state as the prover's one.
+*/
+func (zkrp *Bp) VerifyAggregateWithTranscript(transcript *Transcript, proof proofAggBP) (bool, error) {
+	if int64(len(proof.V)) > zkrp.M {
+		return false, errors.New("number of values must be between 1 and the M given to SetupAggregate")
+	}
+	if !sameGroup(zkrp.Group(), proof.V...) {
+		return false, errors.New("proof is not over the group of the parameters")
+	}
+	return zkrp.verifyRange(transcript, zkrp.shiftCommitments(proof.V), proof)
+}
+
+/*
+rangeDomainSep appends the domain separator of range proofs and the statement to the
+transcript: the parameters, the generators and the commitments V.
+*/
+func (zkrp *Bp) rangeDomainSep(transcript *Transcript, V []Element) {
+	var (
+		i, nm int64
+	)
+	nm = zkrp.N * int64(len(V))
+	transcript.AppendMessage("dom-sep", []byte("rangeproof v1"))
+	transcript.AppendUint64("n", uint64(zkrp.N))
+	transcript.AppendUint64("m", uint64(len(V)))
+	transcript.AppendUint64("a", uint64(zkrp.A))
+	transcript.AppendUint64("b", uint64(zkrp.B))
+	transcript.AppendPoint("G", zkrp.G)
+	transcript.AppendPoint("H", zkrp.H)
+	transcript.AppendPoint("U", zkrp.Zkip.Uu)
+	i = 0
+	for i < nm {
+		transcript.AppendPoint("Gg", zkrp.Gg[i])
+		transcript.AppendPoint("Hh", zkrp.Hh[i])
+		i = i + 1
+	}
+	i = 0
+	for i < int64(len(V)) {
+		transcript.AppendPoint("V", V[i])
+		i = i + 1
+	}
+}
+
+/*
+proveRange computes the aggregated ZK proof that every value belongs to [0, 2^n), where
+values[j] is committed in V[j] with the blinding factor gammas[j]. The inner product
+proof only keeps what the verifier cannot recompute from the public parameters.
+*/
+func (zkrp *Bp) proveRange(transcript *Transcript, V []Element, values []*big.Int, gammas []Scalar, blinds rangeBlinds) (proofAggBP, error) {
+	var (
+		i, j, m, nm int64
+		proof       proofAggBP
+	)
+	group := zkrp.Group()
+	m = int64(len(values))
+	if err := zkrp.checkRange(m); err != nil {
+		return proof, err
+	}
+	nm = zkrp.N * m
+	gg := zkrp.Gg[:nm]
+	hh := zkrp.Hh[:nm]
+	zkrp.rangeDomainSep(transcript, V)
+
+	//////////////////////////////////////////////////////////////////////////////
+	// First phase
+	//////////////////////////////////////////////////////////////////////////////
+
+	// aL is the concatenation of the bits of the values and aR = aL - 1^nm
+	one := group.NewScalar().SetInt64(1)
+	aL := make([]Scalar, nm)
+	aR := make([]Scalar, nm)
+	j = 0
+	for j < m {
+		bits, _ := Decompose(values[j], 2, zkrp.N)
+		i = 0
+		for i < zkrp.N {
+			aL[j*zkrp.N+i] = group.NewScalar().SetInt64(bits[i])
+			aR[j*zkrp.N+i] = group.NewScalar().Sub(aL[j*zkrp.N+i], one)
+			i = i + 1
+		}
+		j = j + 1
+	}
+	A, _ := commitVector(zkrp.pool, aL, aR, blinds.alpha, zkrp.H, gg, hh, nm)
+
+	// sL, sR and commitment: (S, rho)
+	random, err := zkrp.randomScalars(2 * nm)
+	if err != nil {
+		return proof, err
+	}
+	sL, sR := random[:nm], random[nm:]
+	S, _ := commitVector(zkrp.pool, sL, sR, blinds.rho, zkrp.H, gg, hh, nm)
+
+	// Fiat-Shamir heuristic to compute challenges y, z
+	group.Normalize([]Element{A, S})
+	transcript.AppendPoint("A", A)
+	transcript.AppendPoint("S", S)
+	y := transcript.ChallengeScalar("y", group)
+	z := transcript.ChallengeScalar("z", group)
+
+	//////////////////////////////////////////////////////////////////////////////
+	// Second phase
+	//////////////////////////////////////////////////////////////////////////////
+
+	// l(X) = l0 + l1.X with l0 = aL - z.1^nm and l1 = sL
+	// r(X) = r0 + r1.X with r0 = y^nm . (aR + z.1^nm) + z^(1+j).2^n and r1 = y^nm . sR
+	vy := scalarPowers(group, y, nm)
+	z22n := zkrp.powersOfTwoZ(z, m)
+	l0 := make([]Scalar, nm)
+	r0 := make([]Scalar, nm)
+	r1 := make([]Scalar, nm)
+	i = 0
+	for i < nm {
+		l0[i] = group.NewScalar().Sub(aL[i], z)
+		r0[i] = group.NewScalar().Add(aR[i], z)
+		r0[i].Mul(r0[i], vy[i])
+		r0[i].Add(r0[i], z22n[i])
+		r1[i] = group.NewScalar().Mul(vy[i], sR[i])
+		i = i + 1
+	}
+
+	// t1 = < l0, r1 > + < l1, r0 > and t2 = < l1, r1 >
+	t1 := group.NewScalar().Add(innerProduct(group, l0, r1), innerProduct(group, sL, r0))
+	t2 := innerProduct(group, sL, r1)
+
+	T1, _ := CommitG1(t1, blinds.tau1, zkrp.H)
+	T2, _ := CommitG1(t2, blinds.tau2, zkrp.H)
+
+	// Fiat-Shamir heuristic to compute 'random' challenge x
+	group.Normalize([]Element{T1, T2})
+	transcript.AppendPoint("T1", T1)
+	transcript.AppendPoint("T2", T2)
+	x := transcript.ChallengeScalar("x", group)
+
+	//////////////////////////////////////////////////////////////////////////////
+	// Third phase                                                              //
+	//////////////////////////////////////////////////////////////////////////////
+
+	// bl = l0 + l1.x and br = r0 + r1.x
+	bl := make([]Scalar, nm)
+	br := make([]Scalar, nm)
+	t := group.NewScalar()
+	i = 0
+	for i < nm {
+		bl[i] = group.NewScalar().Add(l0[i], t.Mul(sL[i], x))
+		br[i] = group.NewScalar().Add(r0[i], t.Mul(r1[i], x))
+		i = i + 1
+	}
+	tprime := innerProduct(group, bl, br)
+
+	// taux = tau2 . x^2 + tau1 . x + sum_j z^(1+j) . gamma_j
+	taux := group.NewScalar().Mul(blinds.tau2, x)
+	taux.Add(taux, blinds.tau1)
+	taux.Mul(taux, x)
+	zj := group.NewScalar().Mul(z, z)
+	j = 0
+	for j < m {
+		taux.Add(taux, t.Mul(zj, gammas[j]))
+		zj.Mul(zj, z)
+		j = j + 1
+	}
+
+	// mu = alpha + rho.x
+	mu := group.NewScalar().Mul(blinds.rho, x)
+	mu.Add(mu, blinds.alpha)
+
+	padded := zkrp.paddedSize(m)
+	proofip, err := zkrp.proveInnerProduct(transcript, y, taux, mu, tprime, padVector(group, bl, padded), padVector(group, br, padded))
+	if err != nil {
+		return proof, err
+	}
+
+	proof.A = A
+	proof.S = S
+	proof.T1 = T1
+	proof.T2 = T2
+	proof.Taux = taux
+	proof.Mu = mu
+	proof.Tprime = tprime
+	proof.Proofip = proofip
+	return proof, nil
+}
+
+/*
+proveInnerProduct appends taux, mu and tprime to the transcript and computes the inner
+product proof of l and r over (g, h', P.h^-mu, tprime), with u' = u^w. It only keeps
+what the verifier cannot recompute from the public parameters.
+*/
+func (zkrp *Bp) proveInnerProduct(transcript *Transcript, y, taux, mu, tprime Scalar, l, r []Scalar) (proofBip, error) {
+	var (
+		nm int64
+	)
+	group := zkrp.Group()
+	nm = int64(len(l))
+	gg := zkrp.Gg[:nm]
+	hh := zkrp.Hh[:nm]
+	transcript.AppendScalar("taux", taux)
+	transcript.AppendScalar("mu", mu)
+	transcript.AppendScalar("t", tprime)
+	w := transcript.ChallengeScalar("w", group)
+	ux := group.NewElement().ScalarMult(zkrp.Zkip.Uu, w)
+	hprime := switchGenerators(zkrp.pool, hh, y)
+	commit, _ := vectorExp(zkrp.pool, append(append([]Element{}, gg...), hprime...), append(append([]Scalar{}, l...), r...))
+	commit.Add(commit, group.NewElement().ScalarMult(ux, tprime))
+	proofip, err := proveBIP(zkrp.pool, transcript, l, r, gg, hprime, ux, commit, nm, nil, nil)
+	if err != nil {
+		return proofip, err
+	}
+	return proofBip{
+		Ls: proofip.Ls,
+		Rs: proofip.Rs,
+		A:  proofip.A,
+		B:  proofip.B,
+		N:  proofip.N,
+	}, nil
+}
+
+/*
+verifyRange returns true if and only if the proof shows that every value committed in V
+belongs to [0, 2^n). The generators h', the inner product commitment P and tprime are
+recomputed from the public parameters and the challenges, proof.V is not used.
+*/
+func (zkrp *Bp) verifyRange(transcript *Transcript, V []Element, proof proofAggBP) (bool, error) {
+	var (
+		j, m, padded int64
+	)
+	group := zkrp.Group()
+	m = int64(len(V))
+	if err := zkrp.checkRange(m); err != nil {
+		return false, err
+	}
+	if err := zkrp.checkProof(V, proof); err != nil {
+		return false, err
+	}
+	padded = zkrp.paddedSize(m)
+	if int64(len(proof.Proofip.Ls)) != log2(padded) || int64(len(proof.Proofip.Rs)) != log2(padded) {
+		return false, errors.New("inner product proof has the wrong number of rounds")
+	}
+	gg := zkrp.Gg[:padded]
+	hh := zkrp.Hh[:padded]
+
+	y, z, x, w := zkrp.rangeChallenges(transcript, V, proof)
+
+	//////////////////////////////////////////////////////////////////////////////
+	// Check that tprime  = t(x) = t0 + t1x + t2x^2  ----------  Condition (65) //
+	//////////////////////////////////////////////////////////////////////////////
+
+	lhs, _ := CommitG1(proof.Tprime, proof.Taux, zkrp.H)
+
+	// V^(z^2.z^m) . g^delta . T1^x . T2^(x^2)
+	delta, _ := zkrp.DeltaAggregate(y, z, m)
+	rhs := group.NewElement().ScalarBaseMult(delta)
+	zj := group.NewScalar().Mul(z, z)
+	j = 0
+	for j < m {
+		rhs.Add(rhs, group.NewElement().ScalarMult(V[j], zj))
+		zj.Mul(zj, z)
+		j = j + 1
+	}
+	x2 := group.NewScalar().Mul(x, x)
+	rhs.Add(rhs, group.NewElement().ScalarMult(proof.T1, x))
+	rhs.Add(rhs, group.NewElement().ScalarMult(proof.T2, x2))
+
+	lhs.Neg(lhs)
+	rhs.Add(rhs, lhs)
+	c65 := rhs.IsIdentity()
+
+	// Compute P = A.S^x.g^-z.h'^(z.y^nm + z^(1+j).2^n).h^-mu ##### Condition (66)
+	P := zkrp.rangeCommitment(proof, y, z, x, m)
+
+	// Verify Inner Product Proof over (g, h', P, tprime) ####### Condition (67)
+	// with h'_i = h_i^(y^-i) folded into the exponents of h
+
+	// P' = P.u'^tprime, with u' = u^w
+	ux := group.NewElement().ScalarMult(zkrp.Zkip.Uu, w)
+	P.Add(P, group.NewElement().ScalarMult(ux, proof.Tprime))
+	yinv := scalarPowers(group, group.NewScalar().Inverse(y), padded)
+	ok, _ := verifyInnerProduct(transcript, gg, hh, yinv, ux, P, proof.Proofip)
+
+	return c65 && ok, nil
+}
+
+/*
+checkProof verifies that the commitments V and every point of the proof are elements
+of the group of the parameters, and that its scalars are scalars of that group.
+*/
+func (zkrp *Bp) checkProof(V []Element, proof proofAggBP) error {
+	group := zkrp.Group()
+	if !sameGroup(group, V...) || !sameGroup(group, proof.A, proof.S, proof.T1, proof.T2) ||
+		!sameGroup(group, proof.Proofip.Ls...) || !sameGroup(group, proof.Proofip.Rs...) {
+		return errors.New("proof is not over the group of the parameters")
+	}
+	if !sameScalarGroup(group, proof.Taux, proof.Mu, proof.Tprime, proof.Proofip.A, proof.Proofip.B) {
+		return errors.New("proof is incomplete")
+	}
+	return nil
+}
+
+/*
+rangeChallenges replays the prover's transcript and returns the challenges y, z, x and
+the challenge w of the inner product, such that u' = u^w.
+*/
+func (zkrp *Bp) rangeChallenges(transcript *Transcript, V []Element, proof proofAggBP) (Scalar, Scalar, Scalar, Scalar) {
+	group := zkrp.Group()
+	zkrp.rangeDomainSep(transcript, V)
+	transcript.AppendPoint("A", proof.A)
+	transcript.AppendPoint("S", proof.S)
+	y := transcript.ChallengeScalar("y", group)
+	z := transcript.ChallengeScalar("z", group)
+	transcript.AppendPoint("T1", proof.T1)
+	transcript.AppendPoint("T2", proof.T2)
+	x := transcript.ChallengeScalar("x", group)
+	transcript.AppendScalar("taux", proof.Taux)
+	transcript.AppendScalar("mu", proof.Mu)
+	transcript.AppendScalar("t", proof.Tprime)
+	w := transcript.ChallengeScalar("w", group)
+	return y, z, x, w
+}
+
+/*
+rangeCommitment computes P = A.S^x.g^-z.h'^(z.y^nm + z^(1+j).2^n).h^-mu, the commitment
+to the vectors l and r of the inner product argument. Since h'_i = h_i^(y^-i), it is
+computed as A.S^x.(prod g_i)^-z.h_i^(z + y^-i.z^(1+j).2^n).h^-mu, without h'.
+*/
+func (zkrp *Bp) rangeCommitment(proof proofAggBP, y, z, x Scalar, m int64) Element {
+	var (
+		i, nm int64
+	)
+	group := zkrp.Group()
+	nm = zkrp.N * m
+	P := group.NewElement().ScalarMult(proof.S, x)
+	P.Add(P, proof.A)
+
+	gsum := group.NewElement()
+	i = 0
+	for i < nm {
+		gsum.Add(gsum, zkrp.Gg[i])
+		i = i + 1
+	}
+	P.Add(P, group.NewElement().ScalarMult(gsum, group.NewScalar().Neg(z)))
+
+	z22n := zkrp.powersOfTwoZ(z, m)
+	yinv := group.NewScalar().Inverse(y)
+	expy := group.NewScalar().SetInt64(1)
+	hexp := make([]Scalar, nm)
+	i = 0
+	for i < nm {
+		hexp[i] = group.NewScalar().Mul(expy, z22n[i])
+		hexp[i].Add(z, hexp[i])
+		expy.Mul(expy, yinv)
+		i = i + 1
+	}
+	hh, _ := vectorExp(zkrp.pool, zkrp.Hh[:nm], hexp)
+	P.Add(P, hh)
+
+	hmu := group.NewElement().ScalarMult(zkrp.H, proof.Mu)
+	hmu.Neg(hmu)
+	P.Add(P, hmu)
+	return P
+}
+
+type aggstring struct {
+	Scheme  Scheme    `json:"Scheme"`
+	Group   string    `json:"Group"`
+	V       []pstring `json:"V"`
+	A       pstring   `json:"A"`
+	S       pstring   `json:"S"`
+	T1      pstring   `json:"T1"`
+	T2      pstring   `json:"T2"`
+	Taux    string    `json:"Taux"`
+	Mu      string    `json:"Mu"`
+	Tprime  string    `json:"Tprime"`
+	Proofip ipstring  `json:"Proofip"`
+}
+
+func (p *proofAggBP) MarshalJSON() ([]byte, error) {
+	var (
+		i   int
+		aux aggstring
+	)
+	if len(p.V) == 0 {
+		return nil, errors.New("aggregated proof has no commitment")
+	}
+	group := p.V[0].Group()
+	aux.Scheme = SchemeBulletproofsAggregate
+	aux.Group = group.Name()
+	aux.V = make([]pstring, len(p.V))
+	i = 0
+	for i < len(p.V) {
+		aux.V[i] = newPstring(p.V[i])
+		i = i + 1
+	}
+	aux.A = newPstring(p.A)
+	aux.S = newPstring(p.S)
+	aux.T1 = newPstring(p.T1)
+	aux.T2 = newPstring(p.T2)
+	aux.Taux = scalarString(p.Taux)
+	aux.Mu = scalarString(p.Mu)
+	aux.Tprime = scalarString(p.Tprime)
+	aux.Proofip.A = scalarString(p.Proofip.A)
+	aux.Proofip.B = scalarString(p.Proofip.B)
+	aux.Proofip.Ls = make([]pstring, len(p.Proofip.Ls))
+	aux.Proofip.Rs = make([]pstring, len(p.Proofip.Rs))
+	i = 0
+	for i < len(p.Proofip.Ls) {
+		aux.Proofip.Ls[i] = newPstring(p.Proofip.Ls[i])
+		aux.Proofip.Rs[i] = newPstring(p.Proofip.Rs[i])
+		i = i + 1
+	}
+	return json.Marshal(&aux)
+}
+
+func (p *proofAggBP) UnmarshalJSON(data []byte) error {
+	var (
+		i     int
+		err   error
+		aux   aggstring
+		proof proofAggBP
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Scheme != SchemeBulletproofsAggregate {
+		return errors.New("proof is not an aggregated Bulletproofs proof")
+	}
+	if len(aux.V) == 0 {
+		return errors.New("aggregated proof has no commitment")
+	}
+	if len(aux.Proofip.Ls) != len(aux.Proofip.Rs) {
+		return errors.New("inner product proof must have as many L as R")
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	proof.V = make([]Element, len(aux.V))
+	i = 0
+	for i < len(aux.V) {
+		if proof.V[i], err = aux.V[i].point(group); err != nil {
+			return err
+		}
+		i = i + 1
+	}
+	if proof.A, err = aux.A.point(group); err != nil {
+		return err
+	}
+	if proof.S, err = aux.S.point(group); err != nil {
+		return err
+	}
+	if proof.T1, err = aux.T1.point(group); err != nil {
+		return err
+	}
+	if proof.T2, err = aux.T2.point(group); err != nil {
+		return err
+	}
+	if proof.Taux, err = scalar(group, aux.Taux); err != nil {
+		return err
+	}
+	if proof.Mu, err = scalar(group, aux.Mu); err != nil {
+		return err
+	}
+	if proof.Tprime, err = scalar(group, aux.Tprime); err != nil {
+		return err
+	}
+	if proof.Proofip.A, err = scalar(group, aux.Proofip.A); err != nil {
+		return err
+	}
+	if proof.Proofip.B, err = scalar(group, aux.Proofip.B); err != nil {
+		return err
+	}
+	proof.Proofip.Ls = make([]Element, len(aux.Proofip.Ls))
+	proof.Proofip.Rs = make([]Element, len(aux.Proofip.Rs))
+	i = 0
+	for i < len(aux.Proofip.Ls) {
+		if proof.Proofip.Ls[i], err = aux.Proofip.Ls[i].point(group); err != nil {
+			return err
+		}
+		if proof.Proofip.Rs[i], err = aux.Proofip.Rs[i].point(group); err != nil {
+			return err
+		}
+		i = i + 1
+	}
+	*p = proof
+	return nil
+}
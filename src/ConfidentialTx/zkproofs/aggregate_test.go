@@ -0,0 +1,195 @@
+package zkproofs
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+/*
+Test the TRUE case of the aggregated ZK Range Proof for several numbers of values.
+*/
+func TestTrueAggregateBulletproofs(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 0, 255, 4)
+		for _, m := range []int{1, 2, 3, 4} {
+			secrets := make([]*big.Int, m)
+			for j := range secrets {
+				secrets[j] = new(big.Int).SetInt64(int64(17 * (j + 1)))
+			}
+			gammas, proof, err := zkrp.GenerateAggregateProof(secrets)
+			if err != nil {
+				t.Fatalf("Unexpected error for m = %d: %s", m, err)
+			}
+			if len(gammas) != m || len(proof.V) != m {
+				t.Errorf("Assert failure: expected %d commitments, actual: %d", m, len(proof.V))
+			}
+			rounds := int(log2(nextPowerOfTwo(zkrp.N * int64(m))))
+			if len(proof.Proofip.Ls) != rounds || len(proof.Proofip.Rs) != rounds {
+				t.Errorf("Assert failure: expected %d rounds, actual: %d", rounds, len(proof.Proofip.Ls))
+			}
+			ok, _ := zkrp.VerifyAggregate(proof)
+			if ok != true {
+				t.Errorf("Assert failure for m = %d: expected true, actual: %t", m, ok)
+			}
+		}
+	})
+}
+
+/*
+Test the FALSE case of the aggregated ZK Range Proof, where a single value is out of range.
+*/
+func TestFalseAggregateBulletproofs(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 0, 255, 4)
+		secrets := []*big.Int{
+			new(big.Int).SetInt64(3),
+			new(big.Int).SetInt64(256),
+			new(big.Int).SetInt64(255),
+			new(big.Int).SetInt64(0),
+		}
+		_, proof, _ := zkrp.GenerateAggregateProof(secrets)
+		ok, _ := zkrp.VerifyAggregate(proof)
+		if ok != false {
+			t.Errorf("Assert failure: expected false, actual: %t", ok)
+		}
+	})
+}
+
+/*
+Test that swapping the commitments invalidates the aggregated proof.
+*/
+func TestAggregateBulletproofsSwappedCommitments(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 255, 2)
+	secrets := []*big.Int{new(big.Int).SetInt64(10), new(big.Int).SetInt64(20)}
+	_, proof, _ := zkrp.GenerateAggregateProof(secrets)
+	proof.V[0], proof.V[1] = proof.V[1], proof.V[0]
+	ok, _ := zkrp.VerifyAggregate(proof)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+}
+
+/*
+Test aggregated proofs over an interval that needs both x - a and x - b - 1 + 2^n.
+*/
+func TestIntervalAggregateBulletproofs(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(18, 200, 2)
+	_, proof, _ := zkrp.GenerateAggregateProof([]*big.Int{new(big.Int).SetInt64(18), new(big.Int).SetInt64(200)})
+	ok, _ := zkrp.VerifyAggregate(proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+	_, proof, _ = zkrp.GenerateAggregateProof([]*big.Int{new(big.Int).SetInt64(100), new(big.Int).SetInt64(201)})
+	ok, _ = zkrp.VerifyAggregate(proof)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+}
+
+/*
+Test that the number of values is validated against the parameters.
+*/
+func TestAggregateBulletproofsInput(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 255, 4)
+	one := new(big.Int).SetInt64(1)
+	_, _, err := zkrp.GenerateAggregateProof([]*big.Int{})
+	if err == nil {
+		t.Errorf("Assert failure: expected error for no values")
+	}
+	_, _, err = zkrp.GenerateAggregateProof([]*big.Int{one, one, one, one, one, one, one, one})
+	if err == nil {
+		t.Errorf("Assert failure: expected error for more than M values")
+	}
+}
+
+/*
+Test that serialized aggregated proofs are tagged with their scheme, that untagged ones
+are rejected, and that they are not loaded as the proof of a single commitment.
+*/
+func TestAggregateProofSchemeTag(t *testing.T) {
+	var (
+		zkrp   Bp
+		loaded proofAggBP
+	)
+	zkrp.SetupAggregate(0, 255, 2)
+	_, proof, _ := zkrp.GenerateAggregateProof([]*big.Int{new(big.Int).SetInt64(3), new(big.Int).SetInt64(250)})
+	data, err := json.Marshal(&proof)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(string(data), "\"Scheme\":\"bulletproofs-aggregate\"") {
+		t.Errorf("Assert failure: expected the scheme in %s", data)
+	}
+	if err = json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ok, _ := zkrp.VerifyAggregate(loaded)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+	if _, _, err = LoadRangeProof(data); err == nil {
+		t.Errorf("Assert failure: expected error when loading an aggregated proof as a range proof")
+	}
+	for _, scheme := range []string{"", "bulletproofs", "bulletproofs+"} {
+		other := strings.Replace(string(data), "bulletproofs-aggregate", scheme, 1)
+		if err = json.Unmarshal([]byte(other), &loaded); err == nil {
+			t.Errorf("Assert failure: expected error for the scheme %q", scheme)
+		}
+	}
+	_, single, _ := zkrp.GenerateProof(new(big.Int).SetInt64(3))
+	data, _ = DumpProof(&single)
+	if err = json.Unmarshal(data, &loaded); err == nil {
+		t.Errorf("Assert failure: expected error when loading a single proof as an aggregated proof")
+	}
+}
+
+/*
+Test that single value proofs keep working with parameters set up for aggregation.
+*/
+func TestBulletproofsWithAggregateSetup(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 4294967295, 2)
+	_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(65535))
+	ok, _ := zkrp.Verify(proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
+
+func BenchmarkAggregateBulletproofs(b *testing.B) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 4294967295, 8)
+	secrets := make([]*big.Int, 8)
+	for j := range secrets {
+		secrets[j] = new(big.Int).SetInt64(4294967295)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, proof, _ := zkrp.GenerateAggregateProof(secrets)
+		ok, _ := zkrp.VerifyAggregate(proof)
+		if ok != true {
+			b.Errorf("Assert failure: expected true, actual: %t", ok)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+/*
+This file contains the batch verification of Bulletproofs range proofs. The conditions
+checked by Verify, including the whole inner product argument, are written as a single
+equation per proof, and the equations of all proofs are combined with random weights
+into one multi-exponentiation, as described in section 6.2 of the paper:
+Bulletproofs: Short Proofs for Confidential Transactions and More
+Benedikt Bunz, Jonathan Bootle, Dan Boneh, Andrew Poelstra, Pieter Wuille and Greg Maxwell
+*/
+
+package zkproofs
+
+import (
+	"errors"
+)
+
+/*
+VerifyBatch returns true if and only if all the proofs are valid. When the batch fails,
+every proof is verified on its own and the indices of the invalid ones are returned.
+*/
+func (zkrp *Bp) VerifyBatch(proofs []proofBP) (bool, []int, error) {
+	var (
+		i int
+	)
+	transcripts := make([]*Transcript, len(proofs))
+	i = 0
+	for i < len(proofs) {
+		transcripts[i] = NewTranscript(DOMAIN)
+		i = i + 1
+	}
+	return zkrp.VerifyBatchWithTranscripts(transcripts, proofs)
+}
+
+/*
+VerifyBatchWithTranscripts is VerifyBatch where the Fiat-Shamir challenges of proofs[i]
+are bound to transcripts[i].
+*/
+func (zkrp *Bp) VerifyBatchWithTranscripts(transcripts []*Transcript, proofs []proofBP) (bool, []int, error) {
+	var (
+		i      int
+		failed []int
+	)
+	if len(proofs) == 0 {
+		return false, nil, errors.New("no proofs to verify")
+	}
+	if len(transcripts) != len(proofs) {
+		return false, nil, errors.New("there must be one transcript per proof")
+	}
+	batch := newBatchVerifier(zkrp)
+	i = 0
+	for i < len(proofs) {
+		if !sameGroup(zkrp.Group(), proofs[i].V) {
+			failed = append(failed, i)
+			i = i + 1
+			continue
+		}
+		V := zkrp.shiftCommitments([]Element{proofs[i].V})
+		if err := batch.add(transcripts[i].Clone(), V, proofs[i].aggregate()); err != nil {
+			failed = append(failed, i)
+		}
+		i = i + 1
+	}
+	if len(failed) == 0 && batch.verify() {
+		return true, nil, nil
+	}
+
+	// Find the invalid proofs one by one.
+	failed = nil
+	i = 0
+	for i < len(proofs) {
+		ok, _ := zkrp.VerifyWithTranscript(transcripts[i].Clone(), proofs[i])
+		if !ok {
+			failed = append(failed, i)
+		}
+		i = i + 1
+	}
+	return false, failed, nil
+}
+
+/*
+batchVerifier accumulates the weighted verification equations of range proofs. The
+scalars of the points shared by every proof (g, h, the vectors Gg, Hh and u) are
+summed up, while the points that are specific to a proof are appended to the list.
+*/
+type batchVerifier struct {
+	zkrp    *Bp
+	g       Scalar
+	h       Scalar
+	u       Scalar
+	gg      []Scalar
+	hh      []Scalar
+	points  []Element
+	scalars []Scalar
+}
+
+func newBatchVerifier(zkrp *Bp) *batchVerifier {
+	var (
+		i int
+	)
+	group := zkrp.Group()
+	batch := &batchVerifier{
+		zkrp: zkrp,
+		g:    group.NewScalar(),
+		h:    group.NewScalar(),
+		u:    group.NewScalar(),
+		gg:   make([]Scalar, len(zkrp.Gg)),
+		hh:   make([]Scalar, len(zkrp.Hh)),
+	}
+	i = 0
+	for i < len(zkrp.Gg) {
+		batch.gg[i] = group.NewScalar()
+		batch.hh[i] = group.NewScalar()
+		i = i + 1
+	}
+	return batch
+}
+
+/*
+randomWeight returns a random non-zero scalar.
+*/
+func randomWeight(group Group) Scalar {
+	for {
+		c := group.NewScalar().SetRandom()
+		if !c.IsZero() {
+			return c
+		}
+	}
+}
+
+/*
+innerProductScalars returns the exponents s_i such that the inner product verifier ends
+up with g^(s_i) and h^(1/s_i), given the challenges x_k of the rounds and their inverses.
+In the round k, the generator i gets x_k when its (log2(n)-1-k)-th bit is set and x_k^-1
+otherwise.
+*/
+func innerProductScalars(group Group, x, xinv []Scalar) []Scalar {
+	var (
+		k, i int
+		s    []Scalar
+	)
+	s = []Scalar{group.NewScalar().SetInt64(1)}
+	k = 0
+	for k < len(x) {
+		next := make([]Scalar, 2*len(s))
+		i = 0
+		for i < len(s) {
+			next[2*i] = group.NewScalar().Mul(s[i], xinv[k])
+			next[2*i+1] = group.NewScalar().Mul(s[i], x[k])
+			i = i + 1
+		}
+		s = next
+		k = k + 1
+	}
+	return s
+}
+
+/*
+add appends the verification equations of a proof that the values committed in V
+belong to [0, 2^n). Condition (65) gets the weight d:
+
+	g^(tprime-delta).h^taux.V^(-z^2.z^m).T1^-x.T2^(-x^2) = 1
+
+and the inner product argument over P the weight c:
+
+	A.S^x.g^(-z-a.s).h^(z+y^-i.(z^(1+j).2^n-b/s)).h^-mu.u'^(tprime-a.b).L^(x^2).R^(x^-2) = 1
+
+where u' = u^w and w is the Fiat-Shamir challenge of the inner product.
+*/
+func (batch *batchVerifier) add(transcript *Transcript, V []Element, proof proofAggBP) error {
+	var (
+		i, j, k, m, nm, padded, logn int64
+	)
+	zkrp := batch.zkrp
+	group := zkrp.Group()
+	m = int64(len(V))
+	if err := zkrp.checkRange(m); err != nil {
+		return err
+	}
+	if err := zkrp.checkProof(V, proof); err != nil {
+		return err
+	}
+	nm = zkrp.N * m
+	padded = zkrp.paddedSize(m)
+	logn = log2(padded)
+	if int64(len(proof.Proofip.Ls)) != logn || int64(len(proof.Proofip.Rs)) != logn {
+		return errors.New("inner product proof has the wrong number of rounds")
+	}
+
+	y, z, x, w := zkrp.rangeChallenges(transcript, V, proof)
+	tprime := proof.Tprime
+	t := group.NewScalar()
+
+	c := randomWeight(group)
+	d := randomWeight(group)
+
+	// Condition (65)
+	delta, _ := zkrp.DeltaAggregate(y, z, m)
+	batch.g.Add(batch.g, t.Mul(d, t.Sub(tprime, delta)))
+	batch.h.Add(batch.h, t.Mul(d, proof.Taux))
+	zj := group.NewScalar().Mul(z, z)
+	j = 0
+	for j < m {
+		dzj := group.NewScalar().Mul(d, zj)
+		batch.append(V[j], dzj.Neg(dzj))
+		zj.Mul(zj, z)
+		j = j + 1
+	}
+	dx := group.NewScalar().Mul(d, x)
+	dx2 := group.NewScalar().Mul(dx, x)
+	batch.append(proof.T1, dx.Neg(dx))
+	batch.append(proof.T2, dx2.Neg(dx2))
+
+	// Inner product argument
+	xs := innerProductChallenges(transcript, proof.Proofip, group)
+	xinvs := inverses(group, xs)
+	k = 0
+	for k < logn {
+		cx2 := group.NewScalar().Mul(xs[k], xs[k])
+		cx2inv := group.NewScalar().Mul(xinvs[k], xinvs[k])
+		batch.append(proof.Proofip.Ls[k], cx2.Mul(cx2, c))
+		batch.append(proof.Proofip.Rs[k], cx2inv.Mul(cx2inv, c))
+		k = k + 1
+	}
+	s := innerProductScalars(group, xs, xinvs)
+
+	batch.append(proof.A, c)
+	batch.append(proof.S, group.NewScalar().Mul(c, x))
+	batch.h.Sub(batch.h, t.Mul(c, proof.Mu))
+	a, b := proof.Proofip.A, proof.Proofip.B
+	ab := group.NewScalar().Mul(a, b)
+	cw := group.NewScalar().Mul(c, w)
+	batch.u.Add(batch.u, t.Mul(cw, t.Sub(tprime, ab)))
+
+	z22n := zkrp.powersOfTwoZ(z, m)
+	yinv := group.NewScalar().Inverse(y)
+	expy := group.NewScalar().SetInt64(1)
+	gi := group.NewScalar()
+	hi := group.NewScalar()
+	i = 0
+	for i < padded {
+		// g_i^(-z - a.s_i) and h_i^(z + y^-i.(z^(1+j).2^n - b/s_i)), the entries that
+		// pad the vectors only get the terms of the inner product argument
+		gi.Mul(a, s[i])
+		hi.Mul(b, s[padded-1-i])
+		hi.Neg(hi)
+		if i < nm {
+			gi.Add(gi, z)
+			hi.Add(hi, z22n[i])
+		}
+		hi.Mul(hi, expy)
+		if i < nm {
+			hi.Add(hi, z)
+		}
+		batch.gg[i].Sub(batch.gg[i], t.Mul(c, gi))
+		batch.hh[i].Add(batch.hh[i], t.Mul(c, hi))
+		expy.Mul(expy, yinv)
+		i = i + 1
+	}
+	return nil
+}
+
+func (batch *batchVerifier) append(p Element, scalar Scalar) {
+	batch.points = append(batch.points, p)
+	batch.scalars = append(batch.scalars, scalar)
+}
+
+/*
+verify computes the multi-exponentiation of all the accumulated equations and returns
+true if and only if it is the identity.
+*/
+func (batch *batchVerifier) verify() bool {
+	zkrp := batch.zkrp
+	points := append([]Element{zkrp.G, zkrp.H, zkrp.Zkip.Uu}, batch.points...)
+	scalars := append([]Scalar{batch.g, batch.h, batch.u}, batch.scalars...)
+	points = append(points, zkrp.Gg...)
+	scalars = append(scalars, batch.gg...)
+	points = append(points, zkrp.Hh...)
+	scalars = append(scalars, batch.hh...)
+	result, err := vectorExp(zkrp.pool, points, scalars)
+	if err != nil {
+		return false
+	}
+	return result.IsIdentity()
+}
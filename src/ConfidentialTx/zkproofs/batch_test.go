@@ -0,0 +1,109 @@
+package zkproofs
+
+import (
+	"math/big"
+	"testing"
+)
+
+func generateBatch(zkrp *Bp, secrets []int64) []proofBP {
+	proofs := make([]proofBP, len(secrets))
+	for i, secret := range secrets {
+		_, proofs[i], _ = zkrp.GenerateProof(new(big.Int).SetInt64(secret))
+	}
+	return proofs
+}
+
+/*
+Test the TRUE case of the batch verification.
+*/
+func TestTrueVerifyBatch(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 0, 4294967295, 1)
+		proofs := generateBatch(&zkrp, []int64{0, 18, 65535, 4294967295})
+		ok, failed, err := zkrp.VerifyBatch(proofs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if ok != true || len(failed) != 0 {
+			t.Errorf("Assert failure: expected true, actual: %t, failed: %v", ok, failed)
+		}
+	})
+}
+
+/*
+Test the batch verification of proofs whose vectors are padded to a power of two, with
+one proof of a value out of range.
+*/
+func TestVerifyBatchPadded(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 1048575)
+	proofs := generateBatch(&zkrp, []int64{0, 1000, 1048575, 1048576})
+	ok, failed, err := zkrp.VerifyBatch(proofs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if ok != false || len(failed) != 1 || failed[0] != 3 {
+		t.Errorf("Assert failure: expected proof 3 to fail, actual: %t, failed: %v", ok, failed)
+	}
+}
+
+/*
+Test that the batch verification reports the invalid proofs.
+*/
+func TestFalseVerifyBatch(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 18, 200, 1)
+		proofs := generateBatch(&zkrp, []int64{18, 201, 100, 150, 200})
+		proofs[3].Taux = group.NewScalar().Add(proofs[3].Taux, group.NewScalar().SetInt64(1))
+		ok, failed, _ := zkrp.VerifyBatch(proofs)
+		if ok != false {
+			t.Errorf("Assert failure: expected false, actual: %t", ok)
+		}
+		if len(failed) != 2 || failed[0] != 1 || failed[1] != 3 {
+			t.Errorf("Assert failure: expected failed proofs [1 3], actual: %v", failed)
+		}
+	})
+}
+
+/*
+Test that a proof with a truncated inner product argument is reported.
+*/
+func TestVerifyBatchTruncatedProof(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 255)
+	proofs := generateBatch(&zkrp, []int64{1, 2})
+	proofs[0].Proofip.Ls = proofs[0].Proofip.Ls[1:]
+	ok, failed, _ := zkrp.VerifyBatch(proofs)
+	if ok != false || len(failed) != 1 || failed[0] != 0 {
+		t.Errorf("Assert failure: expected failed proofs [0], actual: %t %v", ok, failed)
+	}
+	_, _, err := zkrp.VerifyBatch(nil)
+	if err == nil {
+		t.Errorf("Assert failure: expected error for an empty batch")
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 4294967295)
+	proofs := generateBatch(&zkrp, []int64{1, 2, 3, 4, 5, 6, 7, 8})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ok, _, _ := zkrp.VerifyBatch(proofs)
+		if ok != true {
+			b.Errorf("Assert failure: expected true, actual: %t", ok)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+/*
+This file contains the group G1 of the Barreto-Naehrig curve BN254, y^2 = x^3 + 3 over
+the prime field of bn256.P, as a backend of Group built on the bn256.G1 of
+go-ethereum. It is the group of the ECADD and ECMUL precompiles of Ethereum (EIP-196),
+so that the range proofs over it can also be checked by contracts: elements are
+encoded as the 64 bytes the precompiles take, X || Y in 32 bytes big endian each, and
+the point at infinity as 64 zero bytes.
+
+Messages are hashed to the group with the Shallue-van de Woestijne map of RFC 9380,
+section 6.6.1, with Z = 1, applied to two field elements of expand_message_xmd with
+SHA-256 and L = 48 bytes, and the two points are added. The cofactor of G1 is 1. The
+suite is named BN254G1_XMD:SHA-256_SVDW_RO_, after those of RFC 9380.
+
+The arithmetic of bn256.G1 is not constant time: its field operations and its group
+law take a time that depends on the values of the points. The ConstantTime methods of
+this group only run the same sequence of operations and table reads for all the
+scalars, so they are best-effort, and the proofs of secrets whose prover can be timed
+should use secp256k1 or ristretto255.
+
+The points of bn256.G1 are never modified once they are made: the operations write a
+new point, since the group law of the library does not support aliasing, and elements
+share their points.
+*/
+
+package zkproofs
+
+import (
+	"errors"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/google"
+)
+
+var (
+	BN254SUITE = "BN254G1_XMD:SHA-256_SVDW_RO_"
+)
+
+var (
+	// the constants of the SvdW map for y^2 = g(x) = x^3 + 3 and Z = 1
+	svdwZ = big.NewInt(1)
+	// c1 = g(Z)
+	svdwC1 = bn254G(svdwZ)
+	// c2 = -Z / 2
+	svdwC2 = Mod(new(big.Int).Neg(new(big.Int).Mul(svdwZ, ModInverse(big.NewInt(2), bn256.P))), bn256.P)
+	// c3 = sqrt(-g(Z).3.Z^2), the root with sgn0(c3) = 0
+	svdwC3 = bn254Sqrt(Mod(new(big.Int).Neg(new(big.Int).Mul(svdwC1, big.NewInt(3))), bn256.P), 0)
+	// c4 = -4.g(Z) / (3.Z^2)
+	svdwC4 = Mod(new(big.Int).Mul(new(big.Int).Neg(new(big.Int).Mul(big.NewInt(4), svdwC1)), ModInverse(big.NewInt(3), bn256.P)), bn256.P)
+)
+
+/*
+bn254G returns g(x) = x^3 + 3 mod p.
+*/
+func bn254G(x *big.Int) *big.Int {
+	gx := new(big.Int).Mul(x, x)
+	gx.Mul(gx, x)
+	gx.Add(gx, big.NewInt(3))
+	return gx.Mod(gx, bn256.P)
+}
+
+/*
+bn254Sqrt returns the square root of x mod p whose sgn0, i.e. its parity, is sign, or
+nil if x is not a square.
+*/
+func bn254Sqrt(x *big.Int, sign uint) *big.Int {
+	y := new(big.Int).ModSqrt(x, bn256.P)
+	if y == nil {
+		return nil
+	}
+	if y.Bit(0) != sign {
+		y.Sub(bn256.P, y)
+	}
+	return Mod(y, bn256.P)
+}
+
+/*
+bn254 is an element of G1, the points of BN254.
+*/
+type bn254 struct {
+	p *bn256.G1
+}
+
+/*
+bn254Infinity returns a new point at infinity, the zero value of bn256.G1 not being
+usable as an input.
+*/
+func bn254Infinity() *bn256.G1 {
+	return new(bn256.G1).ScalarBaseMult(new(big.Int))
+}
+
+/*
+g1FromAffine returns the point with affine coordinates (x, y), which must be on the
+curve, without the check of Unmarshal.
+*/
+func g1FromAffine(x, y *big.Int) *bn256.G1 {
+	e := bn254Infinity()
+	ex, ey, ez, et := e.CurvePoints()
+	ex.Set(x)
+	ey.Set(y)
+	ez.SetInt64(1)
+	et.SetInt64(1)
+	return e
+}
+
+/*
+g1FromBytes returns the point marshaled in m by G1.Marshal, which must not be the
+point at infinity, like g2FromBytes.
+*/
+func g1FromBytes(m []byte) *bn256.G1 {
+	return g1FromAffine(new(big.Int).SetBytes(m[0:32]), new(big.Int).SetBytes(m[32:64]))
+}
+
+/*
+affine returns the affine coordinates of the point in [0, p), or nil coordinates for
+the point at infinity. Unlike G1.Marshal, it does not normalize the point in place.
+*/
+func (e *bn254) affine() (*big.Int, *big.Int) {
+	x, y, z, _ := e.p.CurvePoints()
+	if Mod(z, bn256.P).Sign() == 0 {
+		return nil, nil
+	}
+	if z.Cmp(big.NewInt(1)) == 0 {
+		return Mod(x, bn256.P), Mod(y, bn256.P)
+	}
+	zInv := ModInverse(z, bn256.P)
+	zInv2 := Mod(new(big.Int).Mul(zInv, zInv), bn256.P)
+	ax := Mod(new(big.Int).Mul(x, zInv2), bn256.P)
+	ay := Mod(new(big.Int).Mul(y, Mod(new(big.Int).Mul(zInv2, zInv), bn256.P)), bn256.P)
+	return ax, ay
+}
+
+/*
+Bytes returns the 64-byte encoding of the precompiles, X || Y, or 64 zero bytes for
+the point at infinity.
+*/
+func (e *bn254) Bytes() []byte {
+	result := make([]byte, 64)
+	x, y := e.affine()
+	if x != nil {
+		x.FillBytes(result[:32])
+		y.FillBytes(result[32:])
+	}
+	return result
+}
+
+/*
+Group returns BN254.
+*/
+func (e *bn254) Group() Group {
+	return BN254
+}
+
+func (e *bn254) IsIdentity() bool {
+	_, _, z, _ := e.p.CurvePoints()
+	return Mod(z, bn256.P).Sign() == 0
+}
+
+/*
+Equal returns true if and only if e and b are the same point, comparing the Jacobian
+coordinates without inversion: X1.Z2^2 = X2.Z1^2 and Y1.Z2^3 = Y2.Z1^3. Elements of
+other groups are never equal to e.
+*/
+func (e *bn254) Equal(b Element) bool {
+	q, ok := b.(*bn254)
+	if !ok {
+		return false
+	}
+	if e.IsIdentity() || q.IsIdentity() {
+		return e.IsIdentity() == q.IsIdentity()
+	}
+	x1, y1, z1, _ := e.p.CurvePoints()
+	x2, y2, z2, _ := q.p.CurvePoints()
+	z1z1 := new(big.Int).Mul(z1, z1)
+	z2z2 := new(big.Int).Mul(z2, z2)
+	u1 := Mod(new(big.Int).Mul(x1, z2z2), bn256.P)
+	u2 := Mod(new(big.Int).Mul(x2, z1z1), bn256.P)
+	s1 := Mod(new(big.Int).Mul(y1, z2z2.Mul(z2z2, z2)), bn256.P)
+	s2 := Mod(new(big.Int).Mul(y2, z1z1.Mul(z1z1, z1)), bn256.P)
+	return u1.Cmp(u2) == 0 && s1.Cmp(s2) == 0
+}
+
+func (e *bn254) Set(a Element) Element {
+	e.p = a.(*bn254).p
+	return e
+}
+
+func (e *bn254) SetIdentity() Element {
+	e.p = bn254Infinity()
+	return e
+}
+
+func (e *bn254) Neg(a Element) Element {
+	e.p = new(bn256.G1).Neg(a.(*bn254).p)
+	return e
+}
+
+func (e *bn254) Add(a, b Element) Element {
+	e.p = new(bn256.G1).Add(a.(*bn254).p, b.(*bn254).p)
+	return e
+}
+
+func (e *bn254) Sub(a, b Element) Element {
+	nb := new(bn256.G1).Neg(b.(*bn254).p)
+	e.p = new(bn256.G1).Add(a.(*bn254).p, nb)
+	return e
+}
+
+func (e *bn254) Double(a Element) Element {
+	e.p = new(bn256.G1).Add(a.(*bn254).p, a.(*bn254).p)
+	return e
+}
+
+/*
+ScalarMult sets e = k.a with the double and add of the library, in time that depends
+on k.
+*/
+func (e *bn254) ScalarMult(a Element, k Scalar) Element {
+	e.p = new(bn256.G1).ScalarMult(a.(*bn254).p, k.BigInt())
+	return e
+}
+
+func (e *bn254) ScalarBaseMult(k Scalar) Element {
+	e.p = new(bn256.G1).ScalarBaseMult(k.BigInt())
+	return e
+}
+
+/*
+String returns the readable representation of the point, its affine coordinates.
+*/
+func (e *bn254) String() string {
+	x, y := e.affine()
+	return "bn254(" + x.String() + "," + y.String() + ")"
+}
+
+/*
+mapToCurveSVDW is the Shallue-van de Woestijne map of RFC 9380 to BN254. It returns
+the affine coordinates of the point, which is never the point at infinity.
+*/
+func mapToCurveSVDW(u *big.Int) (*big.Int, *big.Int) {
+	p := bn256.P
+	tv1 := Mod(new(big.Int).Mul(new(big.Int).Mul(u, u), svdwC1), p)
+	tv2 := Mod(new(big.Int).Add(big.NewInt(1), tv1), p)
+	tv1 = Mod(new(big.Int).Sub(big.NewInt(1), tv1), p)
+	// inv0 of RFC 9380, 1/0 = 0
+	tv3 := new(big.Int)
+	if t := Mod(new(big.Int).Mul(tv1, tv2), p); t.Sign() != 0 {
+		tv3 = ModInverse(t, p)
+	}
+	tv4 := Mod(new(big.Int).Mul(new(big.Int).Mul(u, tv1), new(big.Int).Mul(tv3, svdwC3)), p)
+	x1 := Mod(new(big.Int).Sub(svdwC2, tv4), p)
+	x2 := Mod(new(big.Int).Add(svdwC2, tv4), p)
+	x3 := Mod(new(big.Int).Mul(tv2, tv2), p)
+	x3 = Mod(new(big.Int).Mul(x3, tv3), p)
+	x3 = Mod(new(big.Int).Mul(x3, x3), p)
+	x3 = Mod(new(big.Int).Add(new(big.Int).Mul(x3, svdwC4), svdwZ), p)
+	x := x3
+	if big.Jacobi(bn254G(x1), p) >= 0 {
+		x = x1
+	} else if big.Jacobi(bn254G(x2), p) >= 0 {
+		x = x2
+	}
+	y := bn254Sqrt(bn254G(x), u.Bit(0))
+	return x, y
+}
+
+/*
+hashToCurveBN254 is hash_to_curve of RFC 9380 on BN254 with the SvdW map.
+*/
+func hashToCurveBN254(msg, dst []byte) *bn254 {
+	const L = 48
+	uniform, _ := expandMessageXMD(msg, dst, 2*L)
+	u0 := Mod(new(big.Int).SetBytes(uniform[:L]), bn256.P)
+	u1 := Mod(new(big.Int).SetBytes(uniform[L:]), bn256.P)
+	q0 := g1FromAffine(mapToCurveSVDW(u0))
+	q1 := g1FromAffine(mapToCurveSVDW(u1))
+	return &bn254{p: new(bn256.G1).Add(q0, q1)}
+}
+
+/*
+regularMultiScalarMultG1 returns sum_i k_i.a_i in G1 with the same sequence of operations
+for all the scalars, as regularMultiScalarMultG2. Points at infinity are skipped, which
+only depends on the points.
+*/
+func regularMultiScalarMultG1(a []*bn256.G1, k []*big.Int) *bn256.G1 {
+	var (
+		tables [][][]byte
+		digits [][64]uint64
+	)
+	sixteen := big.NewInt(16)
+	for t := range a {
+		if (&bn254{p: a[t]}).IsIdentity() {
+			continue
+		}
+		table := make([][]byte, 16)
+		multiple := a[t]
+		for j := range table {
+			if j > 0 {
+				multiple = new(bn256.G1).Add(multiple, a[t])
+			}
+			table[j] = (&bn254{p: multiple}).Bytes()
+		}
+		tables = append(tables, table)
+		digits = append(digits, ctRecode(k[t]))
+	}
+	if len(tables) == 0 {
+		return bn254Infinity()
+	}
+	acc := g1FromBytes(ctSelect(tables[0], digits[0][63]))
+	for i := 63; i >= 0; i-- {
+		if i < 63 {
+			acc = new(bn256.G1).ScalarMult(acc, sixteen)
+		}
+		for t := range tables {
+			if i == 63 && t == 0 {
+				continue
+			}
+			acc = new(bn256.G1).Add(acc, g1FromBytes(ctSelect(tables[t], digits[t][i])))
+		}
+	}
+	return acc
+}
+
+/*
+bn254Group is the Group G1 of BN254, the type bn254.
+*/
+type bn254Group struct{}
+
+/*
+BN254 is the group G1 of BN254, also known as alt_bn128, the curve of bn256.
+*/
+var BN254 Group = bn254Group{}
+
+/*
+bn254Scalars holds the constants of the scalars of BN254.
+*/
+var bn254Scalars = newScalarField(bn256.Order)
+
+func init() {
+	RegisterGroup(BN254)
+}
+
+func (bn254Group) Name() string {
+	return "bn254"
+}
+
+func (bn254Group) Order() *big.Int {
+	return bn256.Order
+}
+
+func (bn254Group) NewElement() Element {
+	return &bn254{p: bn254Infinity()}
+}
+
+func (bn254Group) Generator() Element {
+	return &bn254{p: g1FromAffine(big.NewInt(1), big.NewInt(2))}
+}
+
+func (bn254Group) HashSuite() string {
+	return BN254SUITE
+}
+
+func (bn254Group) HashToElement(msg, dst []byte) Element {
+	return hashToCurveBN254(msg, dst)
+}
+
+/*
+DecodeElement decodes the 64-byte encoding of the precompiles with G1.Unmarshal, which
+checks that the coordinates are below p and that the point is on the curve, or is 64
+zero bytes for the point at infinity.
+*/
+func (bn254Group) DecodeElement(data []byte) (Element, error) {
+	if len(data) != 64 {
+		return nil, errors.New("invalid bn254 encoding")
+	}
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return &bn254{p: p}, nil
+}
+
+/*
+g1Points returns the points of the elements of BN254.
+*/
+func g1Points(a []Element) []*bn256.G1 {
+	result := make([]*bn256.G1, len(a))
+	for i := range a {
+		result[i] = a[i].(*bn254).p
+	}
+	return result
+}
+
+/*
+MultiScalarMult uses Straus' method, as straus in msm.go, with the group law of the
+library: the doublings are shared by all the terms and every nonzero window of 4 bits
+of a scalar adds a multiple read from the table of its point.
+*/
+func (bn254Group) MultiScalarMult(a []Element, k []Scalar) Element {
+	points := g1Points(a)
+	tables := make([][1 << strausWindow]*bn256.G1, len(a))
+	scalars := make([][4]uint64, len(a))
+	for t := range points {
+		tables[t][1] = points[t]
+		for j := 2; j < len(tables[t]); j++ {
+			tables[t][j] = new(bn256.G1).Add(tables[t][j-1], points[t])
+		}
+		scalars[t] = scalarLimbs(k[t])
+	}
+	acc := bn254Infinity()
+	for i := 256/strausWindow - 1; i >= 0; i-- {
+		for d := 0; d < strausWindow; d++ {
+			acc = new(bn256.G1).Add(acc, acc)
+		}
+		for t := range tables {
+			w := window(&scalars[t], uint(i*strausWindow), strausWindow)
+			if w != 0 {
+				acc = new(bn256.G1).Add(acc, tables[t][w])
+			}
+		}
+	}
+	return &bn254{p: acc}
+}
+
+/*
+ConstantTimeScalarMult and the other ConstantTime methods are best-effort, since the
+group law of the library is not constant time, and neither is the conversion of the
+scalars to the big.Int of the library.
+*/
+func (bn254Group) ConstantTimeScalarMult(a Element, k Scalar) Element {
+	return &bn254{p: regularMultiScalarMultG1([]*bn256.G1{a.(*bn254).p}, []*big.Int{k.BigInt()})}
+}
+
+func (bn254Group) ConstantTimeScalarBaseMult(k Scalar) Element {
+	return &bn254{p: regularMultiScalarMultG1([]*bn256.G1{G1}, []*big.Int{k.BigInt()})}
+}
+
+func (bn254Group) ConstantTimeMultiScalarMult(a []Element, k []Scalar) Element {
+	return &bn254{p: regularMultiScalarMultG1(g1Points(a), bigInts(k))}
+}
+
+/*
+Normalize replaces the points by their affine representatives, so that Bytes needs no
+inversion.
+*/
+func (bn254Group) Normalize(a []Element) {
+	for _, e := range a {
+		b := e.(*bn254)
+		if x, y := b.affine(); x != nil {
+			b.p = g1FromAffine(x, y)
+		}
+	}
+}
+
+/*
+Precompute does nothing, BN254 has no fixed-base tables.
+*/
+func (bn254Group) Precompute(a Element) {
+}
+
+func (bn254Group) NewScalar() Scalar {
+	return newMontScalar(bn254Scalars)
+}
@@ -0,0 +1,83 @@
+package zkproofs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/google"
+)
+
+/*
+Test that the elements are encoded in the layout of the ECADD and ECMUL precompiles,
+the one of G1.Marshal, with 2.G of EIP-196.
+*/
+func TestBN254Encoding(t *testing.T) {
+	g := BN254.Generator().Bytes()
+	if new(big.Int).SetBytes(g[:32]).Int64() != 1 || new(big.Int).SetBytes(g[32:]).Int64() != 2 {
+		t.Errorf("Assert failure: expected (1, 2), actual: %x", g)
+	}
+	expected := "030644e72e131a029b85045b68181585d97816a916871ca8d3c208c16d87cfd3" +
+		"15ed738c0e0a7c92e7845f96b2ae9c0a68a6a449e3538fc7ff3ebf7a5a18a2c4"
+	if actual := hex.EncodeToString(BN254.NewElement().Double(BN254.Generator()).Bytes()); actual != expected {
+		t.Errorf("Assert failure: expected %s, actual: %s", expected, actual)
+	}
+	if !bytes.Equal(BN254.NewElement().Bytes(), make([]byte, 64)) {
+		t.Errorf("Assert failure: expected 64 zero bytes for the point at infinity")
+	}
+	for i := 0; i < 4; i++ {
+		k, _ := rand.Int(rand.Reader, bn256.Order)
+		p := BN254.NewElement().ScalarBaseMult(BN254.NewScalar().SetBigInt(k))
+		if !bytes.Equal(p.Bytes(), new(bn256.G1).ScalarBaseMult(k).Marshal()) {
+			t.Errorf("Assert failure: expected the encoding of G1.Marshal for %s", p)
+		}
+	}
+}
+
+/*
+Test that the coordinates of the encodings must be below p and on the curve.
+*/
+func TestBN254InvalidEncodings(t *testing.T) {
+	one := make([]byte, 32)
+	one[31] = 1
+	three := make([]byte, 32)
+	three[31] = 3
+	encodings := [][]byte{
+		// (1, 3) is not on the curve
+		append(append([]byte{}, one...), three...),
+		// (1, 2 + p) is the generator with y not reduced
+		append(append([]byte{}, one...), new(big.Int).Add(bn256.P, big.NewInt(2)).FillBytes(make([]byte, 32))...),
+		// (p, 0) is the point at infinity with x not reduced
+		append(bn256.P.FillBytes(make([]byte, 32)), make([]byte, 32)...),
+	}
+	for _, data := range encodings {
+		if _, err := BN254.DecodeElement(data); err == nil {
+			t.Errorf("Assert failure: expected an error for %x", data)
+		}
+	}
+}
+
+/*
+Test that the SvdW map returns points of the curve whose y has the sign of u, including
+for u = 0 and for the exceptional values of u where tv1.tv2 = 0.
+*/
+func TestBN254MapToCurve(t *testing.T) {
+	// u^2 = 1/g(Z) makes tv1 = 0, u = 1/2 since g(1) = 4
+	values := []*big.Int{big.NewInt(0), big.NewInt(1), ModInverse(big.NewInt(2), bn256.P)}
+	for i := 0; i < 16; i++ {
+		u, _ := rand.Int(rand.Reader, bn256.P)
+		values = append(values, u)
+	}
+	for _, u := range values {
+		x, y := mapToCurveSVDW(u)
+		if y == nil || new(big.Int).Exp(y, big.NewInt(2), bn256.P).Cmp(bn254G(x)) != 0 {
+			t.Errorf("Assert failure: expected a point of the curve for %s", u)
+			continue
+		}
+		if y.Bit(0) != u.Bit(0) {
+			t.Errorf("Assert failure: expected the sign of %s", u)
+		}
+	}
+}
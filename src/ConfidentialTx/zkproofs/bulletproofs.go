@@ -24,89 +24,194 @@ Asiacrypt 2008
 package zkproofs
 
 import (
-	"bytes"
 	"crypto/rand"
-	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
-	"math"
 	"math/big"
-
-	"../byteconversion"
 )
 
 var (
-	ORDER = CURVE.N
-	SEEDH = "BulletproofsDoesNotNeedTrustedSetupH"
-	SEEDU = "BulletproofsDoesNotNeedTrustedSetupU"
-	SAVE  = true
+	ORDER  = CURVE.N
+	SEEDH  = "BulletproofsDoesNotNeedTrustedSetupH"
+	SEEDU  = "BulletproofsDoesNotNeedTrustedSetupU"
+	SAVE   = true
+	DOMAIN = "ConfidentialTx"
 )
 
 /*
-Bulletproofs parameters.
+Bulletproofs parameters. They are only written by Setup, SetPool and SetRandom:
+proving and verification keep their state in the transcript and in local values, so
+one Bp can serve any number of concurrent calls. All the points belong to the group
+given to SetupWithGroup.
 */
 type Bp struct {
-	N    int64 // n 位
-	G    *p256 // 曲线上的点 G 和 H
-	H    *p256
-	Gg   []*p256
-	Hh   []*p256
-	Zkip bip
+	N     int64 // n 位
+	M     int64 // 可聚合的值的个数
+	A     int64 // 区间 [A, B]
+	B     int64
+	G     Element // 曲线上的点 G 和 H
+	H     Element
+	Gg    []Element
+	Hh    []Element
+	Zkip  bip
+	group Group
+	// seed of the generators, SEEDH unless the parameters were loaded from a file
+	seed string
+	// pool computes the vector operations, sequentially if it is nil
+	pool *Pool
+	// random is the source of the blinding factors, crypto/rand.Reader if it is nil
+	random io.Reader
+}
+
+/*
+Group returns the group of the parameters, secp256k1 unless they were set up with
+SetupWithGroup.
+*/
+func (zkrp *Bp) Group() Group {
+	if zkrp.group == nil {
+		return Secp256k1
+	}
+	return zkrp.group
 }
 
 /*
-Bulletproofs proof.
+SetPool makes the proofs and verifications with the parameters split their vector
+operations among the workers of pool. The default nil pool keeps them sequential.
+*/
+func (zkrp *Bp) SetPool(pool *Pool) {
+	zkrp.pool = pool
+}
+
+/*
+SetRandom makes the provers draw the blinding factors from r instead of
+crypto/rand.Reader, e.g. to reproduce proofs in tests. r must be safe for concurrent
+use if proofs are generated concurrently.
+*/
+func (zkrp *Bp) SetRandom(r io.Reader) {
+	zkrp.random = r
+}
+
+/*
+randomScalars returns n uniformly random scalars of the group of the parameters.
+*/
+func (zkrp *Bp) randomScalars(n int64) ([]Scalar, error) {
+	var (
+		i      int64
+		result []Scalar
+	)
+	r := zkrp.random
+	if r == nil {
+		r = rand.Reader
+	}
+	group := zkrp.Group()
+	result = make([]Scalar, n)
+	i = 0
+	for i < n {
+		k, err := rand.Int(r, group.Order())
+		if err != nil {
+			return nil, err
+		}
+		result[i] = group.NewScalar().SetBigInt(k)
+		i = i + 1
+	}
+	return result, nil
+}
+
+/*
+Bulletproofs proof. The inner product proof only holds a, b and the points L and R,
+since the verifier recomputes the generators h', the commitment P and tprime itself.
 */
 type proofBP struct {
-	V       *p256
-	A       *p256
-	S       *p256
-	T1      *p256
-	T2      *p256
-	Taux    *big.Int
-	Mu      *big.Int
-	Tprime  *big.Int
+	V       Element
+	A       Element
+	S       Element
+	T1      Element
+	T2      Element
+	Taux    Scalar
+	Mu      Scalar
+	Tprime  Scalar
 	Proofip proofBip
-	Commit  *p256
 }
 
-type (
-	pstring struct {
-		X string
-		Y string
-	}
-)
+/*
+pstring is the JSON encoding of a point, the hex string of its canonical encoding,
+e.g. the SEC1 compressed encoding for secp256k1.
+*/
+type pstring string
 
 type (
 	ipstring struct {
-		N  int64
 		A  string
 		B  string
-		U  pstring
-		P  pstring
-		Gg pstring
-		Hh pstring
 		Ls []pstring
 		Rs []pstring
 	}
 )
 
+func newPstring(p Element) pstring {
+	return pstring(hex.EncodeToString(p.Bytes()))
+}
+
+/*
+point decodes a point of the group written by MarshalJSON, e.g. in either SEC1
+encoding for secp256k1. The point must not be the identity.
+*/
+func (s pstring) point(group Group) (Element, error) {
+	data, err := hex.DecodeString(string(s))
+	if err != nil {
+		return nil, errors.New("invalid point encoding")
+	}
+	p, err := group.DecodeElement(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsIdentity() {
+		return nil, errors.New("unexpected point at infinity")
+	}
+	return p, nil
+}
+
+/*
+scalarString writes k as the hex string of its canonical encoding, the fixed-length
+Scalar.Bytes.
+*/
+func scalarString(k Scalar) string {
+	return hex.EncodeToString(k.Bytes())
+}
+
+/*
+scalar decodes a scalar of the group written by scalarString. Only the canonical
+encoding is accepted, in lowercase hex, of the right length and less than the order,
+so that every scalar of a proof has a single encoding.
+*/
+func scalar(group Group, s string) (Scalar, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil || hex.EncodeToString(data) != s {
+		return nil, errors.New("invalid scalar encoding")
+	}
+	return group.NewScalar().SetBytes(data)
+}
+
 func (p *proofBP) MarshalJSON() ([]byte, error) {
-	type Alias proofBP
 	var iLs []pstring
 	var iRs []pstring
 	var i int
+	group := p.V.Group()
 	logn := len(p.Proofip.Ls)
 	iLs = make([]pstring, logn)
 	iRs = make([]pstring, logn)
 	i = 0
 	for i < logn {
-		iLs[i] = pstring{X: p.Proofip.Ls[i].X.String(), Y: p.Proofip.Ls[i].Y.String()}
-		iRs[i] = pstring{X: p.Proofip.Rs[i].X.String(), Y: p.Proofip.Rs[i].Y.String()}
+		iLs[i] = newPstring(p.Proofip.Ls[i])
+		iRs[i] = newPstring(p.Proofip.Rs[i])
 		i = i + 1
 	}
 	return json.Marshal(&struct {
+		Scheme  Scheme   `json:"Scheme"`
+		Group   string   `json:"Group"`
 		V       pstring  `json:"V"`
 		A       pstring  `json:"A"`
 		S       pstring  `json:"S"`
@@ -115,258 +220,223 @@ func (p *proofBP) MarshalJSON() ([]byte, error) {
 		Taux    string   `json:"Taux"`
 		Mu      string   `json:"Mu"`
 		Tprime  string   `json:"Tprime"`
-		Commit  pstring  `json:"Commit"`
 		Proofip ipstring `json:"Proofip"`
-		*Alias
 	}{
-		V:      pstring{X: p.V.X.String(), Y: p.V.Y.String()},
-		A:      pstring{X: p.A.X.String(), Y: p.A.Y.String()},
-		S:      pstring{X: p.S.X.String(), Y: p.S.Y.String()},
-		T1:     pstring{X: p.T1.X.String(), Y: p.T1.Y.String()},
-		T2:     pstring{X: p.T2.X.String(), Y: p.T2.Y.String()},
-		Mu:     p.Mu.String(),
-		Taux:   p.Taux.String(),
-		Tprime: p.Tprime.String(),
-		Commit: pstring{X: p.Commit.X.String(), Y: p.Commit.Y.String()},
+		Scheme: SchemeBulletproofs,
+		Group:  group.Name(),
+		V:      newPstring(p.V),
+		A:      newPstring(p.A),
+		S:      newPstring(p.S),
+		T1:     newPstring(p.T1),
+		T2:     newPstring(p.T2),
+		Mu:     scalarString(p.Mu),
+		Taux:   scalarString(p.Taux),
+		Tprime: scalarString(p.Tprime),
 		Proofip: ipstring{
-			N:  p.Proofip.N,
-			A:  p.Proofip.A.String(),
-			B:  p.Proofip.B.String(),
-			U:  pstring{X: p.Proofip.U.X.String(), Y: p.Proofip.U.Y.String()},
-			P:  pstring{X: p.Proofip.P.X.String(), Y: p.Proofip.P.Y.String()},
-			Gg: pstring{X: p.Proofip.Gg.X.String(), Y: p.Proofip.Gg.Y.String()},
-			Hh: pstring{X: p.Proofip.Hh.X.String(), Y: p.Proofip.Hh.Y.String()},
+			A:  scalarString(p.Proofip.A),
+			B:  scalarString(p.Proofip.B),
 			Ls: iLs,
 			Rs: iRs,
 		},
-		Alias: (*Alias)(p),
 	})
 }
 
 func (p *proofBP) UnmarshalJSON(data []byte) error {
-	type Alias proofBP
-	aux := &struct {
-		V       pstring  `json:"V"`
-		A       pstring  `json:"A"`
-		S       pstring  `json:"S"`
-		T1      pstring  `json:"T1"`
-		T2      pstring  `json:"T2"`
-		Taux    string   `json:"Taux"`
-		Mu      string   `json:"Mu"`
-		Tprime  string   `json:"Tprime"`
-		Commit  pstring  `json:"Commit"`
-		Proofip ipstring `json:"Proofip"`
-		*Alias
-	}{
-		Alias: (*Alias)(p),
+	var (
+		i   int
+		err error
+		aux struct {
+			Scheme  Scheme   `json:"Scheme"`
+			Group   string   `json:"Group"`
+			V       pstring  `json:"V"`
+			A       pstring  `json:"A"`
+			S       pstring  `json:"S"`
+			T1      pstring  `json:"T1"`
+			T2      pstring  `json:"T2"`
+			Taux    string   `json:"Taux"`
+			Mu      string   `json:"Mu"`
+			Tprime  string   `json:"Tprime"`
+			Proofip ipstring `json:"Proofip"`
+		}
+		proof proofBP
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Scheme != SchemeBulletproofs {
+		return errors.New("proof was not made with Bulletproofs")
+	}
+	if len(aux.Proofip.Ls) != len(aux.Proofip.Rs) {
+		return errors.New("inner product proof must have as many L as R")
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if proof.V, err = aux.V.point(group); err != nil {
+		return err
+	}
+	if proof.A, err = aux.A.point(group); err != nil {
+		return err
+	}
+	if proof.S, err = aux.S.point(group); err != nil {
+		return err
+	}
+	if proof.T1, err = aux.T1.point(group); err != nil {
+		return err
+	}
+	if proof.T2, err = aux.T2.point(group); err != nil {
+		return err
 	}
-	if err := json.Unmarshal(data, &aux); err != nil {
+	if proof.Taux, err = scalar(group, aux.Taux); err != nil {
+		return err
+	}
+	if proof.Mu, err = scalar(group, aux.Mu); err != nil {
+		return err
+	}
+	if proof.Tprime, err = scalar(group, aux.Tprime); err != nil {
+		return err
+	}
+	if proof.Proofip.A, err = scalar(group, aux.Proofip.A); err != nil {
+		return err
+	}
+	if proof.Proofip.B, err = scalar(group, aux.Proofip.B); err != nil {
 		return err
 	}
-	valVX, _ := new(big.Int).SetString(aux.V.X, 10)
-	valVY, _ := new(big.Int).SetString(aux.V.Y, 10)
-	valAX, _ := new(big.Int).SetString(aux.A.X, 10)
-	valAY, _ := new(big.Int).SetString(aux.A.Y, 10)
-	valSX, _ := new(big.Int).SetString(aux.S.X, 10)
-	valSY, _ := new(big.Int).SetString(aux.S.Y, 10)
-	valT1X, _ := new(big.Int).SetString(aux.T1.X, 10)
-	valT1Y, _ := new(big.Int).SetString(aux.T1.Y, 10)
-	valT2X, _ := new(big.Int).SetString(aux.T2.X, 10)
-	valT2Y, _ := new(big.Int).SetString(aux.T2.Y, 10)
-	valCommitX, _ := new(big.Int).SetString(aux.Commit.X, 10)
-	valCommitY, _ := new(big.Int).SetString(aux.Commit.Y, 10)
-	valN := aux.Proofip.N
-	valA, _ := new(big.Int).SetString(aux.Proofip.A, 10)
-	valB, _ := new(big.Int).SetString(aux.Proofip.B, 10)
-	valUx, _ := new(big.Int).SetString(aux.Proofip.U.X, 10)
-	valUy, _ := new(big.Int).SetString(aux.Proofip.U.Y, 10)
-	valPx, _ := new(big.Int).SetString(aux.Proofip.P.X, 10)
-	valPy, _ := new(big.Int).SetString(aux.Proofip.P.Y, 10)
-	valGgx, _ := new(big.Int).SetString(aux.Proofip.Gg.X, 10)
-	valGgy, _ := new(big.Int).SetString(aux.Proofip.Gg.Y, 10)
-	valHhx, _ := new(big.Int).SetString(aux.Proofip.Hh.X, 10)
-	valHhy, _ := new(big.Int).SetString(aux.Proofip.Hh.Y, 10)
-	p.V = &p256{
-		X: valVX,
-		Y: valVY,
-	}
-	p.A = &p256{
-		X: valAX,
-		Y: valAY,
-	}
-	p.S = &p256{
-		X: valSX,
-		Y: valSY,
-	}
-	p.T1 = &p256{
-		X: valT1X,
-		Y: valT1Y,
-	}
-	p.T2 = &p256{
-		X: valT2X,
-		Y: valT2Y,
-	}
-	p.Commit = &p256{
-		X: valCommitX,
-		Y: valCommitY,
-	}
-	valU := &p256{
-		X: valUx,
-		Y: valUy,
-	}
-	valP := &p256{
-		X: valPx,
-		Y: valPy,
-	}
-	valGg := &p256{
-		X: valGgx,
-		Y: valGgy,
-	}
-	valHh := &p256{
-		X: valHhx,
-		Y: valHhy,
-	}
-	p.Taux, _ = new(big.Int).SetString(aux.Taux, 10)
-	p.Mu, _ = new(big.Int).SetString(aux.Mu, 10)
-	p.Tprime, _ = new(big.Int).SetString(aux.Tprime, 10)
 	logn := len(aux.Proofip.Ls)
-	valLs := make([]*p256, logn)
-	valRs := make([]*p256, logn)
-	var (
-		i      int
-		valLsx *big.Int
-		valLsy *big.Int
-		valRsx *big.Int
-		valRsy *big.Int
-	)
+	proof.Proofip.Ls = make([]Element, logn)
+	proof.Proofip.Rs = make([]Element, logn)
 	i = 0
 	for i < logn {
-		valLsx, _ = new(big.Int).SetString(aux.Proofip.Ls[i].X, 10)
-		valLsy, _ = new(big.Int).SetString(aux.Proofip.Ls[i].Y, 10)
-		valLs[i] = &p256{X: valLsx, Y: valLsy}
-		valRsx, _ = new(big.Int).SetString(aux.Proofip.Rs[i].X, 10)
-		valRsy, _ = new(big.Int).SetString(aux.Proofip.Rs[i].Y, 10)
-		valRs[i] = &p256{X: valRsx, Y: valRsy}
+		if proof.Proofip.Ls[i], err = aux.Proofip.Ls[i].point(group); err != nil {
+			return err
+		}
+		if proof.Proofip.Rs[i], err = aux.Proofip.Rs[i].point(group); err != nil {
+			return err
+		}
 		i = i + 1
 	}
-	p.Proofip = proofBip{
-		N:  valN,
-		A:  valA,
-		B:  valB,
-		U:  valU,
-		P:  valP,
-		Gg: valGg,
-		Hh: valHh,
-		Ls: valLs,
-		Rs: valRs,
-	}
+	*p = proof
 	return nil
 }
 
 type (
 	ipgenstring struct {
 		N  int64
-		Cc string
 		Uu pstring
 		H  pstring
 		Gg []pstring
 		Hh []pstring
-		P  pstring
+	}
+	bpstring struct {
+		N     int64
+		M     int64
+		A     int64
+		B     int64
+		Group string
+		G     pstring
+		H     pstring
+		Gg    []pstring
+		Hh    []pstring
+		Zkip  ipgenstring
 	}
 )
 
-func (s *Bp) MarshalJSON() ([]byte, error) {
-	type Alias Bp
-	var iHh []pstring
-	var iGg []pstring
+/*
+pstrings encodes every point with newPstring.
+*/
+func pstrings(a []Element) []pstring {
+	result := make([]pstring, len(a))
+	for i := range a {
+		result[i] = newPstring(a[i])
+	}
+	return result
+}
 
-	var i int
-	n := len(s.Gg)
-	iGg = make([]pstring, n)
-	iHh = make([]pstring, n)
-	i = 0
-	for i < n {
-		iGg[i] = pstring{X: s.Zkip.Gg[i].X.String(), Y: s.Zkip.Gg[i].Y.String()}
-		iHh[i] = pstring{X: s.Zkip.Hh[i].X.String(), Y: s.Zkip.Hh[i].Y.String()}
-		i = i + 1
+/*
+pointVector decodes every point with pstring.point.
+*/
+func pointVector(group Group, s []pstring) ([]Element, error) {
+	var (
+		err error
+	)
+	result := make([]Element, len(s))
+	for i := range s {
+		if result[i], err = s[i].point(group); err != nil {
+			return nil, err
+		}
 	}
-	return json.Marshal(&struct {
-		Zkip ipgenstring `json:"Zkip"`
-		*Alias
-	}{
+	return result, nil
+}
+
+func (s *Bp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&bpstring{
+		N:     s.N,
+		M:     s.M,
+		A:     s.A,
+		B:     s.B,
+		Group: s.Group().Name(),
+		G:     newPstring(s.G),
+		H:     newPstring(s.H),
+		Gg:    pstrings(s.Gg),
+		Hh:    pstrings(s.Hh),
 		Zkip: ipgenstring{
-			N:  s.N,
-			Cc: s.Zkip.Cc.String(),
-			Uu: pstring{X: s.Zkip.Uu.X.String(), Y: s.Zkip.Uu.Y.String()},
-			H:  pstring{X: s.Zkip.H.X.String(), Y: s.Zkip.H.Y.String()},
-			Gg: iGg,
-			Hh: iHh,
-			P:  pstring{X: s.Zkip.P.X.String(), Y: s.Zkip.P.Y.String()},
+			N:  s.Zkip.N,
+			Uu: newPstring(s.Zkip.Uu),
+			H:  newPstring(s.Zkip.H),
+			Gg: pstrings(s.Zkip.Gg),
+			Hh: pstrings(s.Zkip.Hh),
 		},
-		Alias: (*Alias)(s),
 	})
 }
 
+/*
+UnmarshalJSON decodes parameters written by MarshalJSON. Those written before groups
+were pluggable have no group and are over secp256k1.
+*/
 func (s *Bp) UnmarshalJSON(data []byte) error {
-	type Alias Bp
-	aux := &struct {
-		Zkip ipgenstring `json:"Zkip"`
-		*Alias
-	}{
-		Alias: (*Alias)(s),
+	var (
+		aux    bpstring
+		params Bp
+		err    error
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
 	}
-	if err := json.Unmarshal(data, &aux); err != nil {
+	n := aux.Zkip.N
+	if n < 0 || int64(len(aux.Zkip.Gg)) < n || int64(len(aux.Zkip.Hh)) < n {
+		return errors.New("invalid number of generators")
+	}
+	if params.group, err = groupOrDefault(aux.Group); err != nil {
 		return err
 	}
-	n := aux.N
-	valGg := make([]*p256, n)
-	valHh := make([]*p256, n)
-	var (
-		i      int64
-		valGgx *big.Int
-		valGgy *big.Int
-		valHhx *big.Int
-		valHhy *big.Int
-	)
-	i = 0
-	for i < n {
-		valGgx, _ = new(big.Int).SetString(aux.Zkip.Gg[i].X, 10)
-		valGgy, _ = new(big.Int).SetString(aux.Zkip.Gg[i].Y, 10)
-		valGg[i] = &p256{X: valGgx, Y: valGgy}
-		valHhx, _ = new(big.Int).SetString(aux.Zkip.Hh[i].X, 10)
-		valHhy, _ = new(big.Int).SetString(aux.Zkip.Hh[i].Y, 10)
-		valHh[i] = &p256{X: valHhx, Y: valHhy}
-		i = i + 1
+	params.N, params.M, params.A, params.B = aux.N, aux.M, aux.A, aux.B
+	if params.G, err = aux.G.point(params.group); err != nil {
+		return err
+	}
+	if params.H, err = aux.H.point(params.group); err != nil {
+		return err
 	}
-	valN := aux.N
-	valCc, _ := new(big.Int).SetString(aux.Zkip.Cc, 10)
-	valUux, _ := new(big.Int).SetString(aux.Zkip.Uu.X, 10)
-	valUuy, _ := new(big.Int).SetString(aux.Zkip.Uu.Y, 10)
-	valHx, _ := new(big.Int).SetString(aux.Zkip.H.X, 10)
-	valHy, _ := new(big.Int).SetString(aux.Zkip.H.Y, 10)
-	valPx, _ := new(big.Int).SetString(aux.Zkip.P.X, 10)
-	valPy, _ := new(big.Int).SetString(aux.Zkip.P.Y, 10)
-	valUu := &p256{
-		X: valUux,
-		Y: valUuy,
-	}
-	valH := &p256{
-		X: valHx,
-		Y: valHy,
-	}
-	valP := &p256{
-		X: valPx,
-		Y: valPy,
-	}
-	s.Zkip = bip{
-		N:  valN,
-		Cc: valCc,
-		Uu: valUu,
-		H:  valH,
-		Gg: valGg,
-		Hh: valHh,
-		P:  valP,
+	if params.Gg, err = pointVector(params.group, aux.Gg); err != nil {
+		return err
+	}
+	if params.Hh, err = pointVector(params.group, aux.Hh); err != nil {
+		return err
+	}
+	params.Zkip.N = n
+	if params.Zkip.Uu, err = aux.Zkip.Uu.point(params.group); err != nil {
+		return err
+	}
+	if params.Zkip.H, err = aux.Zkip.H.point(params.group); err != nil {
+		return err
 	}
+	if params.Zkip.Gg, err = pointVector(params.group, aux.Zkip.Gg[:n]); err != nil {
+		return err
+	}
+	if params.Zkip.Hh, err = pointVector(params.group, aux.Zkip.Hh[:n]); err != nil {
+		return err
+	}
+	*s = params
 	return nil
 }
 
@@ -390,12 +460,12 @@ func VectorCopy(a *big.Int, n int64) ([]*big.Int, error) {
 /*
 VectorCopy returns a vector composed by copies of a.
 */
-func VectorG1Copy(a *p256, n int64) ([]*p256, error) {
+func VectorG1Copy(a Element, n int64) ([]Element, error) {
 	var (
 		i      int64
-		result []*p256
+		result []Element
 	)
-	result = make([]*p256, n)
+	result = make([]Element, n)
 	i = 0
 	for i < n {
 		result[i] = a
@@ -422,11 +492,11 @@ func VectorConvertToBig(a []int64, n int64) ([]*big.Int, error) {
 }
 
 /*
-VectorAdd computes vector addition componentwisely.
+VectorECMul computes vector EC addition componentwisely.
 */
-func VectorAdd(a, b []*big.Int) ([]*big.Int, error) {
+func VectorECAdd(a, b []Element) ([]Element, error) {
 	var (
-		result  []*big.Int
+		result  []Element
 		i, n, m int64
 	)
 	n = int64(len(a))
@@ -434,22 +504,30 @@ func VectorAdd(a, b []*big.Int) ([]*big.Int, error) {
 	if n != m {
 		return nil, errors.New("Size of first argument is different from size of second argument.")
 	}
+	result = make([]Element, n)
 	i = 0
-	result = make([]*big.Int, n)
 	for i < n {
-		result[i] = Add(a[i], b[i])
-		result[i] = Mod(result[i], ORDER)
+		result[i] = a[i].Group().NewElement().Add(a[i], b[i])
 		i = i + 1
 	}
 	return result, nil
 }
 
 /*
-VectorSub computes vector addition componentwisely.
+VectorExp computes Prod_i^n{a[i]^b[i]}, with the multi-scalar multiplication of the
+group of the points. The vectors must not be empty.
+*/
+func VectorExp(a []Element, b []Scalar) (Element, error) {
+	return vectorExp(nil, a, b)
+}
+
+/*
+vectorExp is VectorExp with the multi-scalar multiplication split among the workers
+of the pool.
 */
-func VectorSub(a, b []*big.Int) ([]*big.Int, error) {
+func vectorExp(pool *Pool, a []Element, b []Scalar) (Element, error) {
 	var (
-		result  []*big.Int
+		result  Element
 		i, n, m int64
 	)
 	n = int64(len(a))
@@ -457,644 +535,425 @@ func VectorSub(a, b []*big.Int) ([]*big.Int, error) {
 	if n != m {
 		return nil, errors.New("Size of first argument is different from size of second argument.")
 	}
+	if n == 0 {
+		return nil, errors.New("vectors must not be empty")
+	}
+	group := a[0].Group()
+	// every worker computes the multi-scalar multiplication of its chunk, then the
+	// partial results are added in order
+	w := pool.workers(n)
+	partial := make([]Element, w)
+	pool.parallelFor(n, w, func(chunk, lo, hi int64) {
+		partial[chunk] = group.MultiScalarMult(a[lo:hi], b[lo:hi])
+	})
 	i = 0
-	result = make([]*big.Int, n)
-	for i < n {
-		result[i] = Sub(a[i], b[i])
-		result[i] = Mod(result[i], ORDER)
+	result = group.NewElement()
+	for i < w {
+		result.Add(result, partial[i])
 		i = i + 1
 	}
 	return result, nil
 }
 
 /*
-VectorScalarMul computes vector scalar multiplication componentwisely.
+VectorScalarExp computes a[i]^b for each i.
 */
-func VectorScalarMul(a []*big.Int, b *big.Int) ([]*big.Int, error) {
-	var (
-		result []*big.Int
-		i, n   int64
-	)
-	n = int64(len(a))
-	i = 0
-	result = make([]*big.Int, n)
-	for i < n {
-		result[i] = Multiply(a[i], b)
-		result[i] = Mod(result[i], ORDER)
-		i = i + 1
-	}
-	return result, nil
+func VectorScalarExp(a []Element, b Scalar) ([]Element, error) {
+	return vectorScalarExp(nil, a, b)
 }
 
 /*
-VectorMul computes vector multiplication componentwisely.
+vectorScalarExp is VectorScalarExp with the scalar multiplications split among the
+workers of the pool.
 */
-func VectorMul(a, b []*big.Int) ([]*big.Int, error) {
+func vectorScalarExp(pool *Pool, a []Element, b Scalar) ([]Element, error) {
 	var (
-		result  []*big.Int
-		i, n, m int64
+		result []Element
+		n      int64
 	)
 	n = int64(len(a))
-	m = int64(len(b))
-	if n != m {
-		return nil, errors.New("Size of first argument is different from size of second argument.")
-	}
-	i = 0
-	result = make([]*big.Int, n)
-	for i < n {
-		result[i] = Multiply(a[i], b[i])
-		result[i] = Mod(result[i], ORDER)
-		i = i + 1
-	}
+	result = make([]Element, n)
+	pool.parallelFor(n, pool.workers(n), func(chunk, lo, hi int64) {
+		for i := lo; i < hi; i++ {
+			result[i] = a[i].Group().NewElement().ScalarMult(a[i], b)
+		}
+	})
 	return result, nil
 }
 
 /*
-VectorECMul computes vector EC addition componentwisely.
+aR = aL - 1^n
 */
-func VectorECAdd(a, b []*p256) ([]*p256, error) {
+func ComputeAR(x []int64) ([]int64, error) {
 	var (
-		result  []*p256
-		i, n, m int64
+		i      int64
+		result []int64
 	)
-	n = int64(len(a))
-	m = int64(len(b))
-	if n != m {
-		return nil, errors.New("Size of first argument is different from size of second argument.")
-	}
-	result = make([]*p256, n)
+	result = make([]int64, len(x))
 	i = 0
-	for i < n {
-		result[i] = new(p256).Multiply(a[i], b[i])
+	for i < int64(len(x)) {
+		if x[i] == 0 {
+			result[i] = -1
+		} else if x[i] == 1 {
+			result[i] = 0
+		} else {
+			return nil, errors.New("input contains non-binary element")
+		}
 		i = i + 1
 	}
 	return result, nil
 }
 
 /*
-ScalarProduct return the inner product between a and b.
+Commitvector computes a commitment to the bit of the secret.
 */
-func ScalarProduct(a, b []*big.Int) (*big.Int, error) {
+func CommitVector(aL, aR []int64, alpha Scalar, G, H Element, g, h []Element, n int64) (Element, error) {
 	var (
-		result  *big.Int
-		i, n, m int64
+		i int64
 	)
-	n = int64(len(a))
-	m = int64(len(b))
-	if n != m {
-		return nil, errors.New("Size of first argument is different from size of second argument.")
-	}
+	// Compute h^alpha.vg^aL.vh^aR
+	group := H.Group()
+	bL := make([]Scalar, n)
+	bR := make([]Scalar, n)
 	i = 0
-	result = GetBigInt("0")
 	for i < n {
-		ab := Multiply(a[i], b[i])
-		result.Add(result, ab)
-		result = Mod(result, ORDER)
+		bL[i] = group.NewScalar().SetInt64(aL[i])
+		bR[i] = group.NewScalar().SetInt64(aR[i])
 		i = i + 1
 	}
-	return result, nil
+	return commitVector(nil, bL, bR, alpha, H, g, h, n)
 }
 
 /*
-VectorExp computes Prod_i^n{a[i]^b[i]}.
+commitVector computes h^alpha.vg^aL.vh^aR with the multi-scalar multiplication split
+among the workers of the pool. The vectors and alpha are secret, so the multi-scalar
+multiplication is constant-time.
 */
-func VectorExp(a []*p256, b []*big.Int) (*p256, error) {
-	var (
-		result  *p256
-		i, n, m int64
-	)
-	n = int64(len(a))
-	m = int64(len(b))
-	if n != m {
-		return nil, errors.New("Size of first argument is different from size of second argument.")
+func commitVector(pool *Pool, aL, aR []Scalar, alpha Scalar, H Element, g, h []Element, n int64) (Element, error) {
+	// Compute h^alpha.vg^aL.vh^aR
+	points := append([]Element{H}, g[:n]...)
+	points = append(points, h[:n]...)
+	scalars := append([]Scalar{alpha}, aL[:n]...)
+	scalars = append(scalars, aR[:n]...)
+	return ctVectorExp(pool, points, scalars), nil
+}
+
+/*
+LoadProofFromDisk reads the generator from a file.
+*/
+func LoadProofFromDisk(s string) (*proofBP, error) {
+	var result proofBP
+	c, err := ioutil.ReadFile(s)
+	if err != nil {
+		return nil, err
 	}
-	i = 0
-	result = new(p256).SetInfinity()
-	for i < n {
-		result.Multiply(result, new(p256).ScalarMult(a[i], b[i]))
-		i = i + 1
+	if len(c) > 0 {
+		json.Unmarshal(c, &result)
+		return &result, nil
 	}
-	return result, nil
+	return nil, errors.New("Could not load proof.")
 }
 
 /*
-VectorScalarExp computes a[i]^b for each i.
+delta(y,z) = (z-z^2) . < 1^n, y^n > - z^3 . < 1^n, 2^n >
 */
-func VectorScalarExp(a []*p256, b *big.Int) ([]*p256, error) {
-	var (
-		result []*p256
-		i, n   int64
-	)
-	n = int64(len(a))
-	result = make([]*p256, n)
-	i = 0
-	for i < n {
-		result[i] = new(p256).ScalarMult(a[i], b)
-		i = i + 1
-	}
-	return result, nil
+func (zkrp *Bp) Delta(y, z Scalar) (Scalar, error) {
+	return zkrp.DeltaAggregate(y, z, 1)
 }
 
 /*
-PowerOf returns a vector composed by powers of x.
+delta(y,z) = (z-z^2) . < 1^nm, y^nm > - sum_{j=1}^{m} z^(j+2) . < 1^n, 2^n >
+It is the generalization of Delta to m aggregated values, see section 4.3 of the paper.
 */
-func PowerOf(x *big.Int, n int64) ([]*big.Int, error) {
+func (zkrp *Bp) DeltaAggregate(y, z Scalar, m int64) (Scalar, error) {
 	var (
-		i      int64
-		result []*big.Int
+		i, j int64
 	)
-	result = make([]*big.Int, n)
-	current := GetBigInt("1")
+	group := zkrp.Group()
+	z2 := group.NewScalar().Mul(z, z)
+
+	// < 1^nm, y^nm > and < 1^n, 2^n >
+	sp1y := group.NewScalar()
+	expy := group.NewScalar().SetInt64(1)
 	i = 0
-	for i < n {
-		result[i] = current
-		current = Multiply(current, x)
-		current = Mod(current, ORDER)
+	for i < zkrp.N*m {
+		sp1y.Add(sp1y, expy)
+		expy.Mul(expy, y)
 		i = i + 1
 	}
+	sp12 := group.NewScalar().SetBigInt(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(zkrp.N)), big.NewInt(1)))
+
+	result := group.NewScalar().Sub(z, z2)
+	result.Mul(result, sp1y)
+
+	// z^(j+2) for j = 1..m
+	zj := group.NewScalar().Mul(z2, z)
+	t := group.NewScalar()
+	j = 0
+	for j < m {
+		result.Sub(result, t.Mul(zj, sp12))
+		zj.Mul(zj, z)
+		j = j + 1
+	}
 	return result, nil
 }
 
 /*
-aR = aL - 1^n
+SetupPre is responsible for loading the common parameters from setup.json, which
+must have been saved by SaveToDisk for the interval [a, b] and have the given digest.
 */
-func ComputeAR(x []int64) ([]int64, error) {
-	var (
-		i      int64
-		result []int64
-	)
-	result = make([]int64, len(x))
-	i = 0
-	for i < int64(len(x)) {
-		if x[i] == 0 {
-			result[i] = -1
-		} else if x[i] == 1 {
-			result[i] = 0
-		} else {
-			return nil, errors.New("input contains non-binary element")
-		}
-		i = i + 1
+func (zkrp *Bp) SetupPre(a, b int64, digest []byte) error {
+	res, err := LoadParamFromDisk("setup.json", digest)
+	if err != nil {
+		return err
 	}
-	return result, nil
+	if res.A != a || res.B != b {
+		return errors.New("setup.json was not saved for the interval [a, b]")
+	}
+	*zkrp = *res
+	return nil
 }
 
 /*
-Hash is responsible for the computing a Zp element given elements from GT and G1.
+Setup is responsible for computing the common parameters of range proofs that a
+secret belongs to the interval [a, b].
 */
-func HashBP(A, S *p256) (*big.Int, *big.Int, error) {
-
-	digest1 := sha256.New()
-	var buffer bytes.Buffer
-	buffer.WriteString(A.X.String())
-	buffer.WriteString(A.Y.String())
-	buffer.WriteString(S.X.String())
-	buffer.WriteString(S.Y.String())
-	digest1.Write([]byte(buffer.String()))
-	output1 := digest1.Sum(nil)
-	tmp1 := output1[0:len(output1)]
-	result1 := new(big.Int).SetBytes(tmp1)
-
-	digest2 := sha256.New()
-	var buffer2 bytes.Buffer
-	buffer2.WriteString(A.X.String())
-	buffer2.WriteString(A.Y.String())
-	buffer2.WriteString(S.X.String())
-	buffer2.WriteString(S.Y.String())
-	buffer2.WriteString(result1.String())
-	digest2.Write([]byte(buffer2.String()))
-	output2 := digest2.Sum(nil)
-	tmp2 := output2[0:len(output2)]
-	result2 := new(big.Int).SetBytes(tmp2)
-
-	return result1, result2, nil
+func (zkrp *Bp) Setup(a, b int64) error {
+	return zkrp.SetupAggregate(a, b, 1)
 }
 
 /*
-Commitvector computes a commitment to the bit of the secret.
+SetupAggregate is responsible for computing the common parameters of range proofs
+that up to m secrets belong to the interval [a, b].
+*/
+func (zkrp *Bp) SetupAggregate(a, b, m int64) error {
+	return zkrp.SetupWithGroup(Secp256k1, a, b, m)
+}
+
+/*
+SetupWithGroup is SetupAggregate over the given group instead of secp256k1.
 */
-func CommitVector(aL, aR []int64, alpha *big.Int, G, H *p256, g, h []*p256, n int64) (*p256, error) {
+func (zkrp *Bp) SetupWithGroup(group Group, a, b, m int64) error {
 	var (
-		i int64
-		R *p256
+		n int64
 	)
-	// Compute h^alpha.vg^aL.vh^aR
-	R = new(p256).ScalarMult(H, alpha)
-	i = 0
-	for i < n {
-		gaL := new(p256).ScalarMult(g[i], new(big.Int).SetInt64(aL[i]))
-		haR := new(p256).ScalarMult(h[i], new(big.Int).SetInt64(aR[i]))
-		R.Multiply(R, gaL)
-		R.Multiply(R, haR)
-		i = i + 1
+	if a > b {
+		return errors.New("a must be less than or equal to b")
+	}
+	if m < 1 {
+		return errors.New("m must be at least 1")
 	}
-	return R, nil
+	// 计算 G 和 H
+	zkrp.group = group
+	zkrp.G = group.Generator()
+	// 有 n 位
+	zkrp.N = RangeBits(a, b)
+	zkrp.M = m
+	zkrp.A = a
+	zkrp.B = b
+	// every secret is proven as len(shifts) values in [0, 2^n), and the vectors of
+	// the inner product argument are padded to a power of two
+	n = zkrp.N * zkrp.M * int64(len(zkrp.shifts()))
+	zkrp.seed = SEEDH
+	set := getGenerators(group, nextPowerOfTwo(n), zkrp.seed)
+	zkrp.H, zkrp.Gg, zkrp.Hh = set.H, set.Gg, set.Hh
+
+	// Setup Inner Product, over the padded vectors of the range proofs
+	padded := zkrp.paddedSize(zkrp.M * int64(len(zkrp.shifts())))
+	zkrp.Zkip.Setup(zkrp.H, zkrp.Gg[:padded], zkrp.Hh[:padded])
+	return nil
 }
 
 /*
-
- */
-func CommitVectorBig(aL, aR []*big.Int, alpha *big.Int, G, H *p256, g, h []*p256, n int64) (*p256, error) {
+generators derives the vectors of n generators g and h of the group from the seed,
+which have no known discrete logarithm relation between them, with the versioned
+derivation of hashtocurve.go. Callers use getGenerators, which derives them once per
+process.
+*/
+func generators(group Group, n int64, seed string) ([]Element, []Element) {
 	var (
-		i int64
-		R *p256
+		i    int64
+		g, h []Element
 	)
-	// Compute h^alpha.vg^aL.vh^aR
-	R = new(p256).ScalarMult(H, alpha)
+	g = make([]Element, n)
+	h = make([]Element, n)
 	i = 0
 	for i < n {
-		R.Multiply(R, new(p256).ScalarMult(g[i], aL[i]))
-		R.Multiply(R, new(p256).ScalarMult(h[i], aR[i]))
+		g[i] = deriveGenerator(group, seed, "Gg", i)
+		h[i] = deriveGenerator(group, seed, "Hh", i)
 		i = i + 1
 	}
-	return R, nil
+	return g, h
 }
 
 /*
-SaveToDisk is responsible for saving the generator to disk, such it is possible
-to then later.
+RangeBits returns the number of bits n used to prove that a secret belongs to [a, b],
+that is the smallest n >= 1 such that 2^n > b - a. It is at most 64.
 */
-func (zkrp *Bp) SaveToDisk(s string, p *proofBP) error {
-	data, err := json.Marshal(zkrp)
-	errw := ioutil.WriteFile(s, data, 0644)
-	if p != nil {
-		datap, errp := json.Marshal(p)
-		errpw := ioutil.WriteFile("proof.dat", datap, 0644)
-		if errp != nil || errpw != nil {
-			return errors.New("proof not saved to disk.")
-		}
-	}
-	if err != nil || errw != nil {
-		return errors.New("parameters not saved to disk.")
+func RangeBits(a, b int64) int64 {
+	var (
+		n int64
+	)
+	d := new(big.Int).Sub(new(big.Int).SetInt64(b), new(big.Int).SetInt64(a))
+	n = int64(d.BitLen())
+	if n < 1 {
+		n = 1
 	}
-	return nil
+	return n
 }
 
 /*
-LoadGenFromDisk reads the generator from a file.
+shifts returns the offsets that map a secret x in [A, B] to values in [0, 2^N).
+If B - A + 1 = 2^N it is enough to prove x - A >= 0, otherwise both x - A and
+x - B - 1 + 2^N are proven against the same commitment, as done by ccs08.Prove.
 */
-func LoadParamFromDisk(s string) (*Bp, error) {
-	var result Bp
-	c, err := ioutil.ReadFile(s)
-	if err != nil {
-		return nil, err
-	}
-	if len(c) > 0 {
-		json.Unmarshal(c, &result)
-		return &result, nil
+func (zkrp *Bp) shifts() []*big.Int {
+	a := new(big.Int).SetInt64(zkrp.A)
+	b := new(big.Int).SetInt64(zkrp.B)
+	twon := new(big.Int).Lsh(new(big.Int).SetInt64(1), uint(zkrp.N))
+	lower := new(big.Int).Neg(a)
+	width := Add(Sub(b, a), new(big.Int).SetInt64(1))
+	if width.Cmp(twon) == 0 {
+		return []*big.Int{lower}
 	}
-	return nil, errors.New("Could not load generators.")
+	upper := Sub(Sub(twon, b), new(big.Int).SetInt64(1))
+	return []*big.Int{lower, upper}
 }
 
 /*
-LoadProofFromDisk reads the generator from a file.
+shiftValues returns the values proven in [0, 2^N) for the given secrets, as integers
+to be decomposed in bits, together with their blinding factors.
 */
-func LoadProofFromDisk(s string) (*proofBP, error) {
-	var result proofBP
-	c, err := ioutil.ReadFile(s)
-	if err != nil {
-		return nil, err
-	}
-	if len(c) > 0 {
-		json.Unmarshal(c, &result)
-		return &result, nil
+func (zkrp *Bp) shiftValues(secrets []*big.Int, gammas []Scalar) ([]*big.Int, []Scalar) {
+	var (
+		values []*big.Int
+		blinds []Scalar
+	)
+	shifts := zkrp.shifts()
+	for j := range secrets {
+		for _, shift := range shifts {
+			values = append(values, Add(secrets[j], shift))
+			blinds = append(blinds, gammas[j])
+		}
 	}
-	return nil, errors.New("Could not load proof.")
+	return values, blinds
 }
 
 /*
-delta(y,z) = (z-z^2) . < 1^n, y^n > - z^3 . < 1^n, 2^n >
+shiftCommitments computes V.g^shift, i.e. the commitments to the values returned by
+shiftValues, from the commitments to the secrets alone.
 */
-func (zkrp *Bp) Delta(y, z *big.Int) (*big.Int, error) {
+func (zkrp *Bp) shiftCommitments(V []Element) []Element {
 	var (
-		result *big.Int
+		result []Element
 	)
-	// delta(y,z) = (z-z^2) . < 1^n, y^n > - z^3 . < 1^n, 2^n >
-	z2 := Multiply(z, z)
-	z2 = Mod(z2, ORDER)
-	z3 := Multiply(z2, z)
-	z3 = Mod(z3, ORDER)
-
-	// < 1^n, y^n >
-	v1, _ := VectorCopy(new(big.Int).SetInt64(1), zkrp.N)
-	vy, _ := PowerOf(y, zkrp.N)
-	sp1y, _ := ScalarProduct(v1, vy)
-
-	// < 1^n, 2^n >
-	p2n, _ := PowerOf(new(big.Int).SetInt64(2), zkrp.N)
-	sp12, _ := ScalarProduct(v1, p2n)
-
-	result = Sub(z, z2)
-	result = Mod(result, ORDER)
-	result = Multiply(result, sp1y)
-	result = Mod(result, ORDER)
-	result = Sub(result, Multiply(z3, sp12))
-	result = Mod(result, ORDER)
-
-	return result, nil
+	group := zkrp.Group()
+	shifts := zkrp.shifts()
+	for j := range V {
+		for _, shift := range shifts {
+			Vs := group.NewElement().ScalarBaseMult(group.NewScalar().SetBigInt(shift))
+			Vs.Add(Vs, V[j])
+			result = append(result, Vs)
+		}
+	}
+	return result
 }
 
 /*
-SetupPre is responsible for computing the common parameters.
+Prove computes the ZK proof that the secret belongs to [A, B]. It returns the blinding
+factor gamma of proof.V and the proof, which holds everything the verifier needs
+besides the public parameters.
 */
-func (zkrp *Bp) SetupPre(a, b int64) {
-	res, _ := LoadParamFromDisk("setup.json")
-	zkrp = res
-	// Setup Inner Product
-	zkrp.Zkip.Setup(zkrp.H, zkrp.Gg, zkrp.Hh, new(big.Int).SetInt64(0))
+func (zkrp *Bp) GenerateProof(secret *big.Int) (*big.Int, proofBP, error) {
+	return zkrp.GenerateProofWithTranscript(NewTranscript(DOMAIN), secret)
 }
 
 /*
-Setup is responsible for computing the common parameters.
+GenerateProofWithTranscript is GenerateProof with the Fiat-Shamir challenges bound to
+the given transcript.
 */
-func (zkrp *Bp) Setup(a, b int64) {
-	var (
-		i int64
-	)
-	// 计算 G 和 H
-	zkrp.G = new(p256).ScalarBaseMult(new(big.Int).SetInt64(1))
-	zkrp.H, _ = MapToGroup(SEEDH)
-	// 有 n 位
-	zkrp.N = int64(math.Log2(float64(b)))
-	zkrp.Gg = make([]*p256, zkrp.N)
-	zkrp.Hh = make([]*p256, zkrp.N)
-	i = 0
-	for i < zkrp.N {
-		zkrp.Gg[i], _ = MapToGroup(SEEDH + "g" + string(i))
-		zkrp.Hh[i], _ = MapToGroup(SEEDH + "h" + string(i))
-		i = i + 1
+func (zkrp *Bp) GenerateProofWithTranscript(transcript *Transcript, secret *big.Int) (*big.Int, proofBP, error) {
+	// commitment to v and gamma
+	gammas, err := zkrp.randomScalars(1)
+	if err != nil {
+		return nil, proofBP{}, err
 	}
+	gamma := gammas[0]
+	V, _ := CommitG1(zkrp.Group().NewScalar().SetBigInt(secret), gamma, zkrp.H)
 
-	// Setup Inner Product
-	zkrp.Zkip.Setup(zkrp.H, zkrp.Gg, zkrp.Hh, new(big.Int).SetInt64(0))
-	// zkrp.SaveToDisk("setup.json", nil)
+	blinds, err := zkrp.randomBlinds()
+	if err != nil {
+		return nil, proofBP{}, err
+	}
+	proof, err := zkrp.proveSecret(transcript, V, secret, gamma, blinds)
+	if err != nil {
+		return nil, proof, err
+	}
+	return gamma.BigInt(), proof, nil
 }
 
 /*
-Prove computes the ZK proof.
+proveSecret computes the proof that the secret committed in V with the blinding factor
+gamma belongs to [A, B], with the given blinding factors of A, S, T1 and T2.
 */
-func (zkrp *Bp) GenerateProof(secret *big.Int) (*big.Int, *big.Int, []*p256, *p256, proofBP, error) {
+func (zkrp *Bp) proveSecret(transcript *Transcript, V Element, secret *big.Int, gamma Scalar, blinds rangeBlinds) (proofBP, error) {
 	var (
-		i     int64
-		sL    []*big.Int
-		sR    []*big.Int
 		proof proofBP
 	)
-	//////////////////////////////////////////////////////////////////////////////
-	// First phase
-	//////////////////////////////////////////////////////////////////////////////
-
-	// commitment to v and gamma
-	gamma, _ := rand.Int(rand.Reader, ORDER)
-	V, _ := CommitG1(secret, gamma, zkrp.H)
-
-	// aL, aR and commitment: (A, alpha)
-	// 因式分解得到 aL
-	aL, _ := Decompose(secret, 2, zkrp.N)
-	// aR = aL - 1
-	aR, _ := ComputeAR(aL)
-	// 盲因子 alpha
-	alpha, _ := rand.Int(rand.Reader, ORDER)
-	// A 为 aL 的佩德森承诺
-	A, _ := CommitVector(aL, aR, alpha, zkrp.G, zkrp.H, zkrp.Gg, zkrp.Hh, zkrp.N)
-
-	// sL, sR and commitment: (S, rho)
-	rho, _ := rand.Int(rand.Reader, ORDER)
-	sL = make([]*big.Int, zkrp.N)
-	sR = make([]*big.Int, zkrp.N)
-	i = 0
-	for i < zkrp.N {
-		sL[i], _ = rand.Int(rand.Reader, ORDER)
-		sR[i], _ = rand.Int(rand.Reader, ORDER)
-		i = i + 1
-	}
-	// S 为 aR 的佩德森承诺
-	S, _ := CommitVectorBig(sL, sR, rho, zkrp.G, zkrp.H, zkrp.Gg, zkrp.Hh, zkrp.N)
-
-	// Fiat-Shamir heuristic to compute challenges y, z
-	y, z, _ := HashBP(A, S)
-
-	//////////////////////////////////////////////////////////////////////////////
-	// Second phase
-	//////////////////////////////////////////////////////////////////////////////
-	tau1, _ := rand.Int(rand.Reader, ORDER) // page 20 from eprint version
-	tau2, _ := rand.Int(rand.Reader, ORDER)
-
-	// compute t1: < aL - z.1^n, y^n . sR > + < sL, y^n . (aR + z . 1^n) >
-	vz, _ := VectorCopy(z, zkrp.N)
-	vy, _ := PowerOf(y, zkrp.N)
-
-	// aL - z.1^n
-	naL, _ := VectorConvertToBig(aL, zkrp.N)
-	aLmvz, _ := VectorSub(naL, vz)
-
-	// y^n .sR
-	ynsR, _ := VectorMul(vy, sR)
-
-	// scalar prod: < aL - z.1^n, y^n . sR >
-	sp1, _ := ScalarProduct(aLmvz, ynsR)
-
-	// scalar prod: < sL, y^n . (aR + z . 1^n) >
-	naR, _ := VectorConvertToBig(aR, zkrp.N)
-	aRzn, _ := VectorAdd(naR, vz)
-	ynaRzn, _ := VectorMul(vy, aRzn)
-
-	// Add z^2.2^n to the result
-	// z^2 . 2^n
-	p2n, _ := PowerOf(new(big.Int).SetInt64(2), zkrp.N)
-	zsquared := Multiply(z, z)
-	z22n, _ := VectorScalarMul(p2n, zsquared)
-	ynaRzn, _ = VectorAdd(ynaRzn, z22n)
-	sp2, _ := ScalarProduct(sL, ynaRzn)
-
-	// sp1 + sp2
-	t1 := Add(sp1, sp2)
-	t1 = Mod(t1, ORDER)
-
-	// compute t2: < sL, y^n . sR >
-	t2, _ := ScalarProduct(sL, ynsR)
-	t2 = Mod(t2, ORDER)
-
-	// compute T1
-	T1, _ := CommitG1(t1, tau1, zkrp.H)
-
-	// compute T2
-	T2, _ := CommitG1(t2, tau2, zkrp.H)
-
-	// Fiat-Shamir heuristic to compute 'random' challenge x
-	x, _, _ := HashBP(T1, T2)
-
-	//////////////////////////////////////////////////////////////////////////////
-	// Third phase                                                              //
-	//////////////////////////////////////////////////////////////////////////////
-
-	// compute bl
-	sLx, _ := VectorScalarMul(sL, x)
-	bl, _ := VectorAdd(aLmvz, sLx)
-
-	// compute br
-	// y^n . ( aR + z.1^n + sR.x )
-	sRx, _ := VectorScalarMul(sR, x)
-	aRzn, _ = VectorAdd(aRzn, sRx)
-	ynaRzn, _ = VectorMul(vy, aRzn)
-	// y^n . ( aR + z.1^n sR.x ) + z^2 . 2^n
-	br, _ := VectorAdd(ynaRzn, z22n)
-
-	// Compute t` = < bl, br >
-	tprime, _ := ScalarProduct(bl, br)
-
-	// Compute taux = tau2 . x^2 + tau1 . x + z^2 . gamma
-	taux := Multiply(tau2, Multiply(x, x))
-	taux = Add(taux, Multiply(tau1, x))
-	taux = Add(taux, Multiply(Multiply(z, z), gamma))
-	taux = Mod(taux, ORDER)
-
-	// Compute mu = alpha + rho.x
-	mu := Multiply(rho, x)
-	mu = Add(mu, alpha)
-	mu = Mod(mu, ORDER)
-
-	// Inner Product over (g, h', P.h^-mu, tprime)
-	// Compute h'
-	hprime := make([]*p256, zkrp.N)
-	// Switch generators
-	yinv := ModInverse(y, ORDER)
-	expy := yinv
-	hprime[0] = zkrp.Hh[0]
-	i = 1
-	for i < zkrp.N {
-		hprime[i] = new(p256).ScalarMult(zkrp.Hh[i], expy)
-		expy = Multiply(expy, yinv)
-		i = i + 1
+	values, gammas := zkrp.shiftValues([]*big.Int{secret}, []Scalar{gamma})
+	agg, err := zkrp.proveRange(transcript, zkrp.shiftCommitments([]Element{V}), values, gammas, blinds)
+	if err != nil {
+		return proof, err
 	}
 
-	// Update Inner Product Proof Setup
-	zkrp.Zkip.Hh = hprime
-	zkrp.Zkip.Cc = tprime
-
-	commit, _ := CommitInnerProduct(zkrp.Gg, hprime, bl, br)
-	proofip, _ := zkrp.Zkip.GenerateProof(bl, br, commit)
-
 	proof.V = V
-	proof.A = A
-	proof.S = S
-	proof.T1 = T1
-	proof.T2 = T2
-	proof.Taux = taux
-	proof.Mu = mu
-	proof.Tprime = tprime
-	proof.Proofip = proofip
-	proof.Commit = commit
+	proof.A = agg.A
+	proof.S = agg.S
+	proof.T1 = agg.T1
+	proof.T2 = agg.T2
+	proof.Taux = agg.Taux
+	proof.Mu = agg.Mu
+	proof.Tprime = agg.Tprime
+	proof.Proofip = agg.Proofip
 
 	// zkrp.SaveToDisk("setup.json", &proof)
-	return gamma, tprime, hprime, zkrp.Zkip.P, proof, nil
+	return proof, nil
 }
 
 /*
-Verify returns true if and only if the proof is valid.
+Verify returns true if and only if the proof is valid, i.e. the secret committed in
+proof.V belongs to [A, B].
 */
 func (zkrp *Bp) Verify(proof proofBP) (bool, error) {
-	var (
-		i      int64
-		hprime []*p256
-	)
-	hprime = make([]*p256, zkrp.N)
-	y, z, _ := HashBP(proof.A, proof.S)
-	x, _, _ := HashBP(proof.T1, proof.T2)
-
-	// Switch generators
-	yinv := ModInverse(y, ORDER)
-	expy := yinv
-	hprime[0] = zkrp.Hh[0]
-	i = 1
-	for i < zkrp.N {
-		hprime[i] = new(p256).ScalarMult(zkrp.Hh[i], expy)
-		expy = Multiply(expy, yinv)
-		i = i + 1
-	}
-
-	//////////////////////////////////////////////////////////////////////////////
-	// Check that tprime  = t(x) = t0 + t1x + t2x^2  ----------  Condition (65) //
-	//////////////////////////////////////////////////////////////////////////////
-
-	// Compute left hand side
-	lhs, _ := CommitG1(proof.Tprime, proof.Taux, zkrp.H)
-
-	// Compute right hand side
-	z2 := Multiply(z, z)
-	z2 = Mod(z2, ORDER)
-	x2 := Multiply(x, x)
-	x2 = Mod(x2, ORDER)
-
-	rhs := new(p256).ScalarMult(proof.V, z2)
-
-	delta, _ := zkrp.Delta(y, z)
-
-	gdelta := new(p256).ScalarBaseMult(delta)
-
-	rhs.Multiply(rhs, gdelta)
-
-	T1x := new(p256).ScalarMult(proof.T1, x)
-	T2x2 := new(p256).ScalarMult(proof.T2, x2)
-
-	rhs.Multiply(rhs, T1x)
-	rhs.Multiply(rhs, T2x2)
-
-	// Subtract lhs and rhs and compare with poitn at infinity
-	lhs.Neg(lhs)
-	rhs.Multiply(rhs, lhs)
-	c65 := rhs.IsZero() // Condition (65), page 20, from eprint version
-
-	// Compute P - lhs  #################### Condition (66) ######################
-
-	// S^x
-	Sx := new(p256).ScalarMult(proof.S, x)
-	// A.S^x
-	ASx := new(p256).Add(proof.A, Sx)
-
-	// g^-z
-	mz := Sub(ORDER, z)
-	vmz, _ := VectorCopy(mz, zkrp.N)
-	gpmz, _ := VectorExp(zkrp.Gg, vmz)
-	//fmt.Println("############## gpmz ###############")
-	//fmt.Println(gpmz);
-
-	// z.y^n
-	vz, _ := VectorCopy(z, zkrp.N)
-	vy, _ := PowerOf(y, zkrp.N)
-	zyn, _ := VectorMul(vy, vz)
-
-	p2n, _ := PowerOf(new(big.Int).SetInt64(2), zkrp.N)
-	zsquared := Multiply(z, z)
-	z22n, _ := VectorScalarMul(p2n, zsquared)
-
-	// z.y^n + z^2.2^n
-	zynz22n, _ := VectorAdd(zyn, z22n)
-
-	lP := new(p256)
-	lP.Add(ASx, gpmz)
-
-	// h'^(z.y^n + z^2.2^n)
-	hprimeexp, _ := VectorExp(hprime, zynz22n)
-
-	lP.Add(lP, hprimeexp)
-
-	// Compute P - rhs  #################### Condition (67) ######################
-
-	// h^mu
-	rP := new(p256).ScalarMult(zkrp.H, proof.Mu)
-	rP.Multiply(rP, proof.Commit)
-
-	// Subtract lhs and rhs and compare with poitn at infinity
-	lP = lP.Neg(lP)
-	rP.Add(rP, lP)
-	c67 := rP.IsZero()
-
-	// Verify Inner Product Proof ################################################
-	ok, _ := zkrp.Zkip.Verify(proof.Proofip)
+	return zkrp.VerifyWithTranscript(NewTranscript(DOMAIN), proof)
+}
 
-	result := c65 && c67 && ok
+/*
+VerifyWithTranscript is Verify with the Fiat-Shamir challenges bound to the given
+transcript, which must be in the same state as the prover's one.
+*/
+func (zkrp *Bp) VerifyWithTranscript(transcript *Transcript, proof proofBP) (bool, error) {
+	if !sameGroup(zkrp.Group(), proof.V) {
+		return false, errors.New("proof is not over the group of the parameters")
+	}
+	return zkrp.verifyRange(transcript, zkrp.shiftCommitments([]Element{proof.V}), proof.aggregate())
+}
 
-	return result, nil
+/*
+aggregate returns the proof as an aggregated proof of the single commitment proof.V.
+*/
+func (proof proofBP) aggregate() proofAggBP {
+	return proofAggBP{
+		V:       []Element{proof.V},
+		A:       proof.A,
+		S:       proof.S,
+		T1:      proof.T1,
+		T2:      proof.T2,
+		Taux:    proof.Taux,
+		Mu:      proof.Mu,
+		Tprime:  proof.Tprime,
+		Proofip: proof.Proofip,
+	}
 }
 
 //////////////////////////////////// Inner Product ////////////////////////////////////
@@ -1104,135 +963,122 @@ Base struct for the Inner Product Argument.
 */
 type bip struct {
 	N  int64
-	Cc *big.Int
-	Uu *p256
-	H  *p256
-	Gg []*p256
-	Hh []*p256
-	P  *p256
+	Uu Element
+	H  Element
+	Gg []Element
+	Hh []Element
 }
 
 /*
 Struct that contains the Inner Product Proof.
 */
 type proofBip struct {
-	Ls []*p256
-	Rs []*p256
-	U  *p256
-	P  *p256
-	Gg *p256
-	Hh *p256
-	A  *big.Int
-	B  *big.Int
+	Ls []Element
+	Rs []Element
+	U  Element
+	P  Element
+	Gg Element
+	Hh Element
+	A  Scalar
+	B  Scalar
 	N  int64
 }
 
-/*
-HashIP is responsible for the computing a Zp element given elements from GT and G1.
-*/
-func HashIP(g, h []*p256, P *p256, c *big.Int, n int64) (*big.Int, error) {
-	var (
-		i int64
-	)
-
-	digest := sha256.New()
-	digest.Write([]byte(P.String()))
-
-	i = 0
-	for i < n {
-		digest.Write([]byte(g[i].String()))
-		digest.Write([]byte(h[i].String()))
-		i = i + 1
-	}
-
-	digest.Write([]byte(c.String()))
-	output := digest.Sum(nil)
-	tmp := output[0:len(output)]
-	result, err := byteconversion.FromByteArray(tmp)
-
-	return result, err
-}
-
 /*
 CommitInnerProduct is responsible for calculating g^a.h^b.
 */
-func CommitInnerProduct(g, h []*p256, a, b []*big.Int) (*p256, error) {
-	var (
-		result *p256
-	)
-
-	ga, _ := VectorExp(g, a)
-	hb, _ := VectorExp(h, b)
-	result = new(p256).Multiply(ga, hb)
-	return result, nil
+func CommitInnerProduct(g, h []Element, a, b []Scalar) (Element, error) {
+	if len(g) != len(a) || len(h) != len(b) {
+		return nil, errors.New("Size of first argument is different from size of second argument.")
+	}
+	points := append(append([]Element{}, g...), h...)
+	scalars := append(append([]Scalar{}, a...), b...)
+	return VectorExp(points, scalars)
 }
 
 /*
 Setup is responsible for computing the inner product basic parameters that are common to both
-Prove and Verify algorithms.
+Prove and Verify algorithms. They are not modified by Prove and Verify, which receive the
+statement (P, c) of each proof, so that the parameters can be shared by concurrent calls.
 */
-func (zkip *bip) Setup(H *p256, g, h []*p256, c *big.Int) (bip, error) {
-	var (
-		params bip
-	)
-
-	zkip.Gg = make([]*p256, zkip.N)
-	zkip.Hh = make([]*p256, zkip.N)
-	zkip.Uu, _ = MapToGroup(SEEDU)
+func (zkip *bip) Setup(H Element, g, h []Element) (bip, error) {
+	zkip.N = int64(len(g))
+	zkip.Uu = seedPoint(H.Group(), SEEDU)
 	zkip.H = H
 	zkip.Gg = g
 	zkip.Hh = h
-	zkip.Cc = c
-	zkip.P = new(p256).SetInfinity()
+	return *zkip, nil
+}
 
-	return params, nil
+/*
+innerProductDomainSep appends the domain separator of the inner product argument
+and the statement (P, c) to the transcript.
+*/
+func innerProductDomainSep(transcript *Transcript, n int64, P Element, c Scalar) {
+	transcript.AppendMessage("dom-sep", []byte("ipp v1"))
+	transcript.AppendUint64("n", uint64(n))
+	transcript.AppendPoint("P", P)
+	transcript.AppendScalar("c", c)
 }
 
 /*
-Prove is responsible for the generation of the Inner Product Proof.
+Prove is responsible for the generation of the Inner Product Proof that P = g^a.h^b
+and c = <a, b>.
 */
-func (zkip *bip) GenerateProof(a, b []*big.Int, P *p256) (proofBip, error) {
+func (zkip *bip) GenerateProof(transcript *Transcript, a, b []Scalar, c Scalar, P Element) (proofBip, error) {
 	var (
 		proof proofBip
 		n, m  int64
-		Ls    []*p256
-		Rs    []*p256
+		Ls    []Element
+		Rs    []Element
 	)
+	group := zkip.Uu.Group()
 
 	n = int64(len(a))
 	m = int64(len(b))
 	if n != m {
 		return proof, errors.New("Size of first array argument must be equal to the second")
-	} else {
-		// Fiat-Shamir:
-		// x = Hash(g,h,P,c)
-		x, _ := HashIP(zkip.Gg, zkip.Hh, P, zkip.Cc, zkip.N)
-		// Pprime = P.u^(x.c)
-		ux := new(p256).ScalarMult(zkip.Uu, x)
-		uxc := new(p256).ScalarMult(ux, zkip.Cc)
-		PP := new(p256).Multiply(P, uxc)
-		// Execute Protocol 2 recursively
-		zkip.P = PP
-		proof, err := BIP(a, b, zkip.Gg, zkip.Hh, ux, zkip.P, n, Ls, Rs)
-		proof.P = PP
-		return proof, err
 	}
-
-	return proof, nil
+	// Fiat-Shamir:
+	// x = Hash(transcript,n,P,c)
+	innerProductDomainSep(transcript, n, P, c)
+	x := transcript.ChallengeScalar("x", group)
+	// Pprime = P.u^(x.c)
+	ux := group.NewElement().ScalarMult(zkip.Uu, x)
+	uxc := group.NewElement().ScalarMult(ux, c)
+	PP := group.NewElement().Add(P, uxc)
+	// Execute Protocol 2 recursively
+	proof, err := BIP(transcript, a, b, zkip.Gg, zkip.Hh, ux, PP, n, Ls, Rs)
+	proof.P = PP
+	return proof, err
 }
 
 /*
 BIP is the main recursive function that will be used to compute the inner product argument.
+The vectors are halved in each round, so their size n must be a power of two: callers pad
+them with zeros.
 */
-func BIP(a, b []*big.Int, g, h []*p256, u, P *p256, n int64, Ls, Rs []*p256) (proofBip, error) {
+func BIP(transcript *Transcript, a, b []Scalar, g, h []Element, u, P Element, n int64, Ls, Rs []Element) (proofBip, error) {
+	return proveBIP(nil, transcript, a, b, g, h, u, P, n, Ls, Rs)
+}
+
+/*
+proveBIP is BIP on scalars, with the vector operations split among the workers of the
+pool.
+*/
+func proveBIP(pool *Pool, transcript *Transcript, a, b []Scalar, g, h []Element, u, P Element, n int64, Ls, Rs []Element) (proofBip, error) {
 	var (
 		proof                            proofBip
-		cL, cR, x, xinv, x2, x2inv       *big.Int
-		L, R, Lh, Rh, Pprime             *p256
-		gprime, hprime, gprime2, hprime2 []*p256
-		aprime, bprime, aprime2, bprime2 []*big.Int
+		i                                int64
+		L, R, Lh, Rh, Pprime             Element
+		gprime, hprime, gprime2, hprime2 []Element
+		aprime, bprime                   []Scalar
 	)
+	group := u.Group()
 
+	if !isPowerOfTwo(n) || int64(len(a)) != n || int64(len(b)) != n {
+		return proof, errors.New("size of the vectors must be a power of two")
+	}
 	if n == 1 {
 		// recursion end
 		proof.A = a[0]
@@ -1251,108 +1097,167 @@ func BIP(a, b []*big.Int, g, h []*p256, u, P *p256, n int64, Ls, Rs []*p256) (pr
 		nprime := n / 2
 
 		// Compute cL = < a[:n'], b[n':] >
-		cL, _ = ScalarProduct(a[:nprime], b[nprime:])
+		cL := innerProduct(group, a[:nprime], b[nprime:])
 		// Compute cR = < a[n':], b[:n'] >
-		cR, _ = ScalarProduct(a[nprime:], b[:nprime])
+		cR := innerProduct(group, a[nprime:], b[:nprime])
 		// Compute L = g[n':]^(a[:n']).h[:n']^(b[n':]).u^cL
-		L, _ = VectorExp(g[nprime:], a[:nprime])
-		Lh, _ = VectorExp(h[:nprime], b[nprime:])
-		L.Multiply(L, Lh)
-		L.Multiply(L, new(p256).ScalarMult(u, cL))
+		L, _ = vectorExp(pool, g[nprime:], a[:nprime])
+		Lh, _ = vectorExp(pool, h[:nprime], b[nprime:])
+		L.Add(L, Lh)
+		L.Add(L, group.NewElement().ScalarMult(u, cL))
 
 		// Compute R = g[:n']^(a[n':]).h[n':]^(b[:n']).u^cR
-		R, _ = VectorExp(g[:nprime], a[nprime:])
-		Rh, _ = VectorExp(h[nprime:], b[:nprime])
-		R.Multiply(R, Rh)
-		R.Multiply(R, new(p256).ScalarMult(u, cR))
+		R, _ = vectorExp(pool, g[:nprime], a[nprime:])
+		Rh, _ = vectorExp(pool, h[nprime:], b[:nprime])
+		R.Add(R, Rh)
+		R.Add(R, group.NewElement().ScalarMult(u, cR))
 
 		// Fiat-Shamir:
-		x, _, _ = HashBP(L, R)
-		xinv = ModInverse(x, ORDER)
+		group.Normalize([]Element{L, R})
+		transcript.AppendPoint("L", L)
+		transcript.AppendPoint("R", R)
+		x := transcript.ChallengeScalar("x", group)
+		xinv := group.NewScalar().Inverse(x)
 
 		// Compute g' = g[:n']^(x^-1) * g[n':]^(x)
-		gprime, _ = VectorScalarExp(g[:nprime], xinv)
-		gprime2, _ = VectorScalarExp(g[nprime:], x)
+		gprime, _ = vectorScalarExp(pool, g[:nprime], xinv)
+		gprime2, _ = vectorScalarExp(pool, g[nprime:], x)
 		gprime, _ = VectorECAdd(gprime, gprime2)
 		// Compute h' = h[:n']^(x)    * h[n':]^(x^-1)
-		hprime, _ = VectorScalarExp(h[:nprime], x)
-		hprime2, _ = VectorScalarExp(h[nprime:], xinv)
+		hprime, _ = vectorScalarExp(pool, h[:nprime], x)
+		hprime2, _ = vectorScalarExp(pool, h[nprime:], xinv)
 		hprime, _ = VectorECAdd(hprime, hprime2)
 
 		// Compute P' = L^(x^2).P.R^(x^-2)
-		x2 = Mod(Multiply(x, x), ORDER)
-		x2inv = ModInverse(x2, ORDER)
-		Pprime = new(p256).ScalarMult(L, x2)
-		Pprime.Multiply(Pprime, P)
-		Pprime.Multiply(Pprime, new(p256).ScalarMult(R, x2inv))
+		x2 := group.NewScalar().Mul(x, x)
+		x2inv := group.NewScalar().Mul(xinv, xinv)
+		Pprime = group.NewElement().ScalarMult(L, x2)
+		Pprime.Add(Pprime, P)
+		Pprime.Add(Pprime, group.NewElement().ScalarMult(R, x2inv))
 
 		// Compute a' = a[:n'].x      + a[n':].x^(-1)
-		aprime, _ = VectorScalarMul(a[:nprime], x)
-		aprime2, _ = VectorScalarMul(a[nprime:], xinv)
-		aprime, _ = VectorAdd(aprime, aprime2)
 		// Compute b' = b[:n'].x^(-1) + b[n':].x
-		bprime, _ = VectorScalarMul(b[:nprime], xinv)
-		bprime2, _ = VectorScalarMul(b[nprime:], x)
-		bprime, _ = VectorAdd(bprime, bprime2)
+		aprime = make([]Scalar, nprime)
+		bprime = make([]Scalar, nprime)
+		t := group.NewScalar()
+		i = 0
+		for i < nprime {
+			aprime[i] = group.NewScalar().Mul(a[i], x)
+			aprime[i].Add(aprime[i], t.Mul(a[nprime+i], xinv))
+			bprime[i] = group.NewScalar().Mul(b[i], xinv)
+			bprime[i].Add(bprime[i], t.Mul(b[nprime+i], x))
+			i = i + 1
+		}
 
 		Ls = append(Ls, L)
 		Rs = append(Rs, R)
 		// recursion BIP(g',h',u,P'; a', b')
-		proof, _ = BIP(aprime, bprime, gprime, hprime, u, Pprime, nprime, Ls, Rs)
+		proof, _ = proveBIP(pool, transcript, aprime, bprime, gprime, hprime, u, Pprime, nprime, Ls, Rs)
 	}
 	proof.N = n
 	return proof, nil
 }
 
 /*
-Verify is responsible for the verification of the Inner Product Proof.
+Verify is responsible for the verification of the Inner Product Proof that
+P = g^a.h^b and c = <a, b>.
 */
-func (zkip *bip) Verify(proof proofBip) (bool, error) {
+func (zkip *bip) Verify(transcript *Transcript, P Element, c Scalar, proof proofBip) (bool, error) {
+	group := zkip.Uu.Group()
+	n := int64(len(zkip.Gg))
+	innerProductDomainSep(transcript, n, P, c)
+	x := transcript.ChallengeScalar("x", group)
+	// P' = P.u^(x.c)
+	ux := group.NewElement().ScalarMult(zkip.Uu, x)
+	Pprime := group.NewElement().Add(P, group.NewElement().ScalarMult(ux, c))
+	return VerifyBIP(transcript, zkip.Gg, zkip.Hh, ux, Pprime, proof)
+}
 
-	logn := len(proof.Ls)
+/*
+innerProductChallenges appends the points L and R of every round to the transcript
+and returns the challenges x of the rounds.
+*/
+func innerProductChallenges(transcript *Transcript, proof proofBip, group Group) []Scalar {
 	var (
-		i                                    int64
-		x, xinv, x2, x2inv                   *big.Int
-		ngprime, nhprime, ngprime2, nhprime2 []*p256
+		i int
+		x []Scalar
 	)
-
+	x = make([]Scalar, len(proof.Ls))
 	i = 0
-	gprime := zkip.Gg
-	hprime := zkip.Hh
-	Pprime := zkip.P
-	nprime := proof.N
-	for i < int64(logn) {
-		nprime = nprime / 2
-		x, _, _ = HashBP(proof.Ls[i], proof.Rs[i])
-		xinv = ModInverse(x, ORDER)
-		// Compute g' = g[:n']^(x^-1) * g[n':]^(x)
-		ngprime, _ = VectorScalarExp(gprime[:nprime], xinv)
-		ngprime2, _ = VectorScalarExp(gprime[nprime:], x)
-		gprime, _ = VectorECAdd(ngprime, ngprime2)
-		// Compute h' = h[:n']^(x)    * h[n':]^(x^-1)
-		nhprime, _ = VectorScalarExp(hprime[:nprime], x)
-		nhprime2, _ = VectorScalarExp(hprime[nprime:], xinv)
-		hprime, _ = VectorECAdd(nhprime, nhprime2)
-		// Compute P' = L^(x^2).P.R^(x^-2)
-		x2 = Mod(Multiply(x, x), ORDER)
-		x2inv = ModInverse(x2, ORDER)
-		Pprime.Multiply(Pprime, new(p256).ScalarMult(proof.Ls[i], x2))
-		Pprime.Multiply(Pprime, new(p256).ScalarMult(proof.Rs[i], x2inv))
+	for i < len(proof.Ls) {
+		transcript.AppendPoint("L", proof.Ls[i])
+		transcript.AppendPoint("R", proof.Rs[i])
+		x[i] = transcript.ChallengeScalar("x", group)
 		i = i + 1
 	}
+	return x
+}
 
-	// c == a*b
-	ab := Multiply(proof.A, proof.B)
-	ab = Mod(ab, ORDER)
-
-	rhs := new(p256).ScalarMult(gprime[0], proof.A)
-	hb := new(p256).ScalarMult(hprime[0], proof.B)
-	rhs.Multiply(rhs, hb)
-	rhs.Multiply(rhs, new(p256).ScalarMult(proof.U, ab))
+/*
+VerifyBIP verifies the proof computed by BIP over the generators g and h, the point u
+and the commitment P = g^a.h^b.u^<a,b>.
+*/
+func VerifyBIP(transcript *Transcript, g, h []Element, u, P Element, proof proofBip) (bool, error) {
+	return verifyInnerProduct(transcript, g, h, nil, u, P, proof)
+}
 
-	nP := Pprime.Neg(Pprime)
-	nP.Multiply(nP, rhs)
-	c := nP.IsZero()
+/*
+verifyInnerProduct verifies the proof computed by BIP over the generators g and
+h'_i = h_i^(hexp_i), or h' = h if hexp is nil. Instead of folding the generators in
+every round, it computes the exponents s_i of the final generators from the challenges
+x_k, as in section 3.1 of the paper, and checks with a single multi-exponentiation that
 
-	return c, nil
+	g^(a.s).h^(b.hexp_i/s_i).u^(a.b) = P.prod_k L_k^(x_k^2).R_k^(x_k^-2)
+*/
+func verifyInnerProduct(transcript *Transcript, g, h []Element, hexp []Scalar, u, P Element, proof proofBip) (bool, error) {
+	var (
+		i, n int
+	)
+	group := u.Group()
+	n = len(g)
+	if len(proof.Ls) != len(proof.Rs) || int64(n) != int64(1)<<uint(len(proof.Ls)) || len(h) != n {
+		return false, errors.New("inner product proof has the wrong number of rounds")
+	}
+	if !sameScalarGroup(group, proof.A, proof.B) {
+		return false, errors.New("inner product proof is incomplete")
+	}
+	if !sameGroup(group, proof.Ls...) || !sameGroup(group, proof.Rs...) {
+		return false, errors.New("inner product proof is not over the group of u")
+	}
+	xs := innerProductChallenges(transcript, proof, group)
+	xinvs := inverses(group, xs)
+	// the generator h_i ends up with the exponent 1/s_i = s_(n-1-i)
+	s := innerProductScalars(group, xs, xinvs)
+	a := proof.A
+	b := proof.B
+
+	points := make([]Element, 0, 2*n+2*len(xs)+2)
+	scalars := make([]Scalar, 0, 2*n+2*len(xs)+2)
+	i = 0
+	for i < n {
+		hs := group.NewScalar().Mul(b, s[n-1-i])
+		if hexp != nil {
+			hs.Mul(hs, hexp[i])
+		}
+		points = append(points, g[i], h[i])
+		scalars = append(scalars, group.NewScalar().Mul(a, s[i]), hs)
+		i = i + 1
+	}
+	points = append(points, u, P)
+	scalars = append(scalars, group.NewScalar().Mul(a, b), group.NewScalar().SetInt64(-1))
+	i = 0
+	for i < len(xs) {
+		x2 := group.NewScalar().Mul(xs[i], xs[i])
+		points = append(points, proof.Ls[i])
+		scalars = append(scalars, x2.Neg(x2))
+		x2inv := group.NewScalar().Mul(xinvs[i], xinvs[i])
+		points = append(points, proof.Rs[i])
+		scalars = append(scalars, x2inv.Neg(x2inv))
+		i = i + 1
+	}
+	result, err := VectorExp(points, scalars)
+	if err != nil {
+		return false, err
+	}
+	return result.IsIdentity(), nil
 }
@@ -18,8 +18,10 @@ package zkproofs
 
 import (
 	"crypto/rand"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"sync"
 	"testing"
 	"time"
 
@@ -64,145 +66,115 @@ func TestVectorConvertToBig(t *testing.T) {
 }
 
 /*
-Scalar Product returns the inner product between 2 vectors.
+Test Inner Product argument.
 */
-func TestScalarProduct(t *testing.T) {
-	var (
-		a, b []*big.Int
-	)
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(7)
-	a[2] = new(big.Int).SetInt64(7)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(3)
-	b[2] = new(big.Int).SetInt64(3)
-	result, _ := ScalarProduct(a, b)
-	ok := (result.Cmp(new(big.Int).SetInt64(63)) == 0)
-	if ok != true {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
-	}
+func TestInnerProduct(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+			zkip bip
+			a    []Scalar
+			b    []Scalar
+		)
+		// TODO:
+		// Review if it is the best way, since we maybe could use the
+		// inner product independently of the range proof.
+		zkrp.SetupWithGroup(group, 0, 15, 1)
+		a = make([]Scalar, zkrp.N)
+		a[0] = group.NewScalar().SetInt64(2)
+		a[1] = group.NewScalar().SetInt64(-1)
+		a[2] = group.NewScalar().SetInt64(10)
+		a[3] = group.NewScalar().SetInt64(6)
+		b = make([]Scalar, zkrp.N)
+		b[0] = group.NewScalar().SetInt64(1)
+		b[1] = group.NewScalar().SetInt64(2)
+		b[2] = group.NewScalar().SetInt64(10)
+		b[3] = group.NewScalar().SetInt64(7)
+		c := group.NewScalar().SetInt64(142)
+		commit, _ := CommitInnerProduct(zkrp.Gg, zkrp.Hh, a, b)
+		zkip.Setup(zkrp.H, zkrp.Gg, zkrp.Hh)
+		proof, _ := zkip.GenerateProof(NewTranscript("test"), a, b, c, commit)
+		ok, _ := zkip.Verify(NewTranscript("test"), commit, c, proof)
+		if ok != true {
+			t.Errorf("Assert failure: expected true, actual: %t", ok)
+		}
+	})
 }
 
 /*
-Tests Vector addition.
+Test that the single multi-exponentiation verifier of the inner product argument
+rejects tampered proofs and statements.
 */
-func TestVectorAdd(t *testing.T) {
+func TestInnerProductTampered(t *testing.T) {
 	var (
-		a, b []*big.Int
+		zkrp Bp
+		zkip bip
 	)
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(8)
-	a[2] = new(big.Int).SetInt64(9)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(30)
-	b[2] = new(big.Int).SetInt64(40)
-	result, _ := VectorAdd(a, b)
-	ok := (result[0].Cmp(new(big.Int).SetInt64(10)) == 0)
-	ok = ok && (result[1].Cmp(GetBigInt("38")) == 0)
-	ok = ok && (result[2].Cmp(GetBigInt("49")) == 0)
-	if ok != true {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	zkrp.Setup(0, 255)
+	a := make([]Scalar, zkrp.N)
+	b := make([]Scalar, zkrp.N)
+	for i := range a {
+		a[i] = Secp256k1.NewScalar().SetRandom()
+		b[i] = Secp256k1.NewScalar().SetRandom()
 	}
-}
-
-/*
-Tests Vector subtraction.
-*/
-func TestVectorSub(t *testing.T) {
-	var (
-		a, b []*big.Int
-	)
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(8)
-	a[2] = new(big.Int).SetInt64(9)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(30)
-	b[2] = new(big.Int).SetInt64(40)
-	result, _ := VectorSub(a, b)
-	ok := (result[0].Cmp(new(big.Int).SetInt64(4)) == 0)
-	ok = ok && (result[1].Cmp(GetBigInt("115792089237316195423570985008687907852837564279074904382605163141518161494315")) == 0)
-	ok = ok && (result[2].Cmp(GetBigInt("115792089237316195423570985008687907852837564279074904382605163141518161494306")) == 0)
+	c := innerProduct(Secp256k1, a, b)
+	commit, _ := CommitInnerProduct(zkrp.Gg[:zkrp.N], zkrp.Hh[:zkrp.N], a, b)
+	zkip.Setup(zkrp.H, zkrp.Gg[:zkrp.N], zkrp.Hh[:zkrp.N])
+	proof, _ := zkip.GenerateProof(NewTranscript("test"), a, b, c, commit)
+	ok, _ := zkip.Verify(NewTranscript("test"), commit, c, proof)
 	if ok != true {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
 	}
-}
 
-/*
-Tests Vector componentwise multiplication.
-*/
-func TestVectorMul(t *testing.T) {
-	var (
-		a, b []*big.Int
-	)
-	a = make([]*big.Int, 3)
-	b = make([]*big.Int, 3)
-	a[0] = new(big.Int).SetInt64(7)
-	a[1] = new(big.Int).SetInt64(8)
-	a[2] = new(big.Int).SetInt64(9)
-	b[0] = new(big.Int).SetInt64(3)
-	b[1] = new(big.Int).SetInt64(30)
-	b[2] = new(big.Int).SetInt64(40)
-	result, _ := VectorMul(a, b)
-	ok := (result[0].Cmp(new(big.Int).SetInt64(21)) == 0)
-	ok = ok && (result[1].Cmp(new(big.Int).SetInt64(240)) == 0)
-	ok = ok && (result[2].Cmp(new(big.Int).SetInt64(360)) == 0)
-
-	if ok != true {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	one := Secp256k1.NewScalar().SetInt64(1)
+	tampered := []proofBip{proof, proof, proof}
+	tampered[0].A = Secp256k1.NewScalar().Add(proof.A, one)
+	tampered[1].Ls = append([]Element{proof.Rs[0]}, proof.Ls[1:]...)
+	tampered[2].Ls, tampered[2].Rs = proof.Rs, proof.Ls
+	for i, p := range tampered {
+		ok, _ = zkip.Verify(NewTranscript("test"), commit, c, p)
+		if ok != false {
+			t.Errorf("Assert failure for case %d: expected false, actual: %t", i, ok)
+		}
 	}
-}
-
-/*
-Test method PowerOf, which must return a vector containing a growing sequence of
-powers of 2.
-*/
-func TestPowerOf(t *testing.T) {
-	result, _ := PowerOf(new(big.Int).SetInt64(3), 3)
-	ok := (result[0].Cmp(new(big.Int).SetInt64(1)) == 0)
-	ok = ok && (result[1].Cmp(new(big.Int).SetInt64(3)) == 0)
-	ok = ok && (result[2].Cmp(new(big.Int).SetInt64(9)) == 0)
-	if ok != true {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	ok, _ = zkip.Verify(NewTranscript("test"), commit, Secp256k1.NewScalar().Add(c, one), proof)
+	if ok != false {
+		t.Errorf("Assert failure for a wrong inner product: expected false, actual: %t", ok)
+	}
+	ok, _ = zkip.Verify(NewTranscript("test"), zkrp.H, c, proof)
+	if ok != false {
+		t.Errorf("Assert failure for a wrong commitment: expected false, actual: %t", ok)
 	}
 }
 
 /*
-Test Inner Product argument.
+Test that the inner product parameters of Setup have the padded size of the vectors of
+the range proofs, also for bit lengths that are not powers of two.
 */
-func TestInnerProduct(t *testing.T) {
-	var (
-		zkrp Bp
-		zkip bip
-		a    []*big.Int
-		b    []*big.Int
-	)
-	// TODO:
-	// Review if it is the best way, since we maybe could use the
-	// inner product independently of the range proof.
-	zkrp.Setup(0, 16)
-	a = make([]*big.Int, zkrp.N)
-	a[0] = new(big.Int).SetInt64(2)
-	a[1] = new(big.Int).SetInt64(-1)
-	a[2] = new(big.Int).SetInt64(10)
-	a[3] = new(big.Int).SetInt64(6)
-	b = make([]*big.Int, zkrp.N)
-	b[0] = new(big.Int).SetInt64(1)
-	b[1] = new(big.Int).SetInt64(2)
-	b[2] = new(big.Int).SetInt64(10)
-	b[3] = new(big.Int).SetInt64(7)
-	c := new(big.Int).SetInt64(142)
-	commit, _ := CommitInnerProduct(zkrp.Gg, zkrp.Hh, a, b)
-	zkip.Setup(zkrp.H, zkrp.Gg, zkrp.Hh, c)
-	proof, _ := zkip.Prove(a, b, commit)
-	ok, _ := zkip.Verify(proof)
-	if ok != true {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
+func TestInnerProductParams(t *testing.T) {
+	for _, n := range []uint{8, 20} {
+		var zkrp Bp
+		zkrp.SetupAggregate(0, int64(1)<<n-1, 3)
+		padded := zkrp.paddedSize(zkrp.M * int64(len(zkrp.shifts())))
+		if zkrp.Zkip.N != padded || int64(len(zkrp.Zkip.Gg)) != padded || int64(len(zkrp.Zkip.Hh)) != padded {
+			t.Fatalf("Assert failure: expected %d generators, actual: %d", padded, zkrp.Zkip.N)
+		}
+		a := make([]Scalar, padded)
+		b := make([]Scalar, padded)
+		for i := range a {
+			a[i] = Secp256k1.NewScalar().SetRandom()
+			b[i] = Secp256k1.NewScalar().SetRandom()
+		}
+		c := innerProduct(Secp256k1, a, b)
+		commit, _ := CommitInnerProduct(zkrp.Zkip.Gg, zkrp.Zkip.Hh, a, b)
+		proof, err := zkrp.Zkip.GenerateProof(NewTranscript("test"), a, b, c, commit)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		ok, _ := zkrp.Zkip.Verify(NewTranscript("test"), commit, c, proof)
+		if ok != true {
+			t.Errorf("Assert failure: %d bits, expected true, actual: %t", n, ok)
+		}
 	}
 }
 
@@ -214,13 +186,13 @@ func TestFalseBulletproofsZKRP(t *testing.T) {
 		zkrp Bp
 	)
 	startTime := time.Now()
-	zkrp.Setup(0, 4294967296) // ITS BEING USED TO COMPUTE N
+	zkrp.Setup(0, 4294967295) // ITS BEING USED TO COMPUTE N
 	setupTime := time.Now()
 	fmt.Println("Setup time:")
 	fmt.Println(setupTime.Sub(startTime))
 
 	x := new(big.Int).SetInt64(4294967296)
-	proof, _ := zkrp.Prove(x)
+	_, proof, _ := zkrp.GenerateProof(x)
 	proofTime := time.Now()
 	fmt.Println("Proof time:")
 	fmt.Println(proofTime.Sub(setupTime))
@@ -245,13 +217,13 @@ func TestTrueBulletproofsZKRP(t *testing.T) {
 		zkrp Bp
 	)
 	startTime := time.Now()
-	zkrp.Setup(0, 4294967296) // ITS BEING USED TO COMPUTE N
+	zkrp.Setup(0, 4294967295) // ITS BEING USED TO COMPUTE N
 	setupTime := time.Now()
 	fmt.Println("Setup time:")
 	fmt.Println(setupTime.Sub(startTime))
 
 	x := new(big.Int).SetInt64(65535)
-	proof, _ := zkrp.Prove(x)
+	_, proof, _ := zkrp.GenerateProof(x)
 	proofTime := time.Now()
 	fmt.Println("Proof time:")
 	fmt.Println(proofTime.Sub(setupTime))
@@ -268,6 +240,113 @@ func TestTrueBulletproofsZKRP(t *testing.T) {
 	}
 }
 
+/*
+Test ZK Range Proofs using Bulletproofs over intervals whose bounds are not powers of two.
+*/
+func TestIntervalBulletproofsZKRP(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		intervals := []struct {
+			a, b   int64
+			inside []int64
+			out    []int64
+		}{
+			{100, 5000, []int64{100, 2500, 5000}, []int64{99, 5001, 0}},
+			{-50, 50, []int64{-50, 0, 50}, []int64{-51, 51}},
+			{18, 18, []int64{18}, []int64{17, 19}},
+			{0, 4294967295, []int64{0, 4294967295}, []int64{-1, 4294967296}},
+		}
+		for _, interval := range intervals {
+			var zkrp Bp
+			zkrp.SetupWithGroup(group, interval.a, interval.b, 1)
+			for _, x := range interval.inside {
+				_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(x))
+				ok, _ := zkrp.Verify(proof)
+				if ok != true {
+					t.Errorf("Assert failure: %d in [%d, %d], expected true, actual: %t", x, interval.a, interval.b, ok)
+				}
+			}
+			for _, x := range interval.out {
+				_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(x))
+				ok, _ := zkrp.Verify(proof)
+				if ok != false {
+					t.Errorf("Assert failure: %d not in [%d, %d], expected false, actual: %t", x, interval.a, interval.b, ok)
+				}
+			}
+		}
+	})
+}
+
+/*
+Test ZK Range Proofs over [0, 2^n - 1] for bit lengths that are not powers of two, where
+the vectors of the inner product argument are padded.
+*/
+func TestBitLengthsBulletproofsZKRP(t *testing.T) {
+	for _, n := range []uint{8, 20, 48, 64} {
+		var zkrp Bp
+		a, b := int64(0), int64(1)<<n-1
+		if n == 64 {
+			a, b = -9223372036854775808, 9223372036854775807
+		}
+		zkrp.Setup(a, b)
+		if zkrp.N != int64(n) {
+			t.Errorf("Assert failure: expected %d bits, actual: %d", n, zkrp.N)
+		}
+		for _, x := range []int64{a, a + 1, b} {
+			_, proof, err := zkrp.GenerateProof(new(big.Int).SetInt64(x))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			rounds := int(log2(nextPowerOfTwo(zkrp.N)))
+			if len(proof.Proofip.Ls) != rounds {
+				t.Errorf("Assert failure: expected %d rounds, actual: %d", rounds, len(proof.Proofip.Ls))
+			}
+			ok, _ := zkrp.Verify(proof)
+			if ok != true {
+				t.Errorf("Assert failure: %d in [%d, %d], expected true, actual: %t", x, a, b, ok)
+			}
+			_, plus, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(x))
+			ok, _ = zkrp.VerifyPlus(plus)
+			if ok != true {
+				t.Errorf("Assert failure: %d in [%d, %d] with Bulletproofs+, expected true, actual: %t", x, a, b, ok)
+			}
+		}
+		if n < 64 {
+			_, proof, _ := zkrp.GenerateProof(Add(new(big.Int).SetInt64(b), new(big.Int).SetInt64(1)))
+			ok, _ := zkrp.Verify(proof)
+			if ok != false {
+				t.Errorf("Assert failure: %d not in [%d, %d], expected false, actual: %t", b+1, a, b, ok)
+			}
+		}
+	}
+}
+
+/*
+Test the number of bits used for a given interval.
+*/
+func TestRangeBits(t *testing.T) {
+	ok := RangeBits(0, 4294967295) == 32
+	ok = ok && RangeBits(0, 4294967296) == 33
+	ok = ok && RangeBits(100, 5000) == 13
+	ok = ok && RangeBits(-9223372036854775808, 9223372036854775807) == 64
+	ok = ok && RangeBits(7, 7) == 1
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
+
+/*
+Tests if the Setup algorithm is rejecting wrong input as expected.
+*/
+func TestBulletproofsSetupInput(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	e := zkrp.Setup(1900, 1899)
+	if e == nil || e.Error() != "a must be less than or equal to b" {
+		t.Errorf("Assert failure: expected error, actual: %v", e)
+	}
+}
+
 func BenchmarkBulletproofs(b *testing.B) {
 	var (
 		zkrp  Bp
@@ -276,9 +355,9 @@ func BenchmarkBulletproofs(b *testing.B) {
 	)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		zkrp.Setup(0, 4294967296) // ITS BEING USED TO COMPUTE N
+		zkrp.Setup(0, 4294967295) // ITS BEING USED TO COMPUTE N
 		x := new(big.Int).SetInt64(4294967295)
-		proof, _ = zkrp.Prove(x)
+		_, proof, _ = zkrp.GenerateProof(x)
 		ok, _ = zkrp.Verify(proof)
 		if ok != true {
 			b.Errorf("Assert failure: expected true, actual: %t", ok)
@@ -300,39 +379,6 @@ func BenchmarkScalarMult(b *testing.B) {
 	fmt.Println(A)
 }
 
-func TestHashBP(t *testing.T) {
-	agx, _ := new(big.Int).SetString("110720467414728166769654679803728202169916280248550137472490865118702779748947", 10)
-	agy, _ := new(big.Int).SetString("103949684536896233354287911519259186718323435572971865592336813380571928560949", 10)
-	sgx, _ := new(big.Int).SetString("78662919066140655151560869958157053125629409725243565127658074141532489435921", 10)
-	sgy, _ := new(big.Int).SetString("114946280626097680211499478702679495377587739951564115086530426937068100343655", 10)
-	pointa := &p256{X: agx, Y: agy}
-	points := &p256{X: sgx, Y: sgy}
-	result1, result2, _ := HashBP(pointa, points)
-	res1, _ := new(big.Int).SetString("103823382860325249552741530200099120077084118788867728791742258217664299339569", 10)
-	res2, _ := new(big.Int).SetString("8192372577089859289404358830067912230280991346287696886048261417244724213964", 10)
-	ok1 := (result1.Cmp(res1) != 0)
-	ok2 := (result2.Cmp(res2) != 0)
-	ok := ok1 && ok2
-	if ok {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
-	}
-}
-
-func TestHashBPGx(t *testing.T) {
-	gx, _ := new(big.Int).SetString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798", 16)
-	gy, _ := new(big.Int).SetString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8", 16)
-	point := &p256{X: gx, Y: gy}
-	result1, result2, _ := HashBP(point, point)
-	res1, _ := new(big.Int).SetString("11897424191990306464486192136408618361228444529783223689021929580052970909263", 10)
-	res2, _ := new(big.Int).SetString("22166487799255634251145870394406518059682307840904574298117500050508046799269", 10)
-	ok1 := (result1.Cmp(res1) != 0)
-	ok2 := (result2.Cmp(res2) != 0)
-	ok := ok1 && ok2
-	if ok {
-		t.Errorf("Assert failure: expected true, actual: %t", ok)
-	}
-}
-
 func TestInv(t *testing.T) {
 	y, _ := new(big.Int).SetString("103823382860325249552741530200099120077084118788867728791742258217664299339569", 10)
 	yinv := ModInverse(y, ORDER)
@@ -346,10 +392,68 @@ func TestInv(t *testing.T) {
 func TestHPrime(t *testing.T) {
 	var zkrp *Bp
 	var proof *proofBP
-	zkrp, _ = LoadParamFromDisk("setup.dat")
+	zkrp, _ = LoadParamFromDisk("setup.dat", nil)
 	proof, _ = LoadProofFromDisk("proof.dat")
 	ok, _ := zkrp.Verify(*proof)
 	if !ok {
 		t.Errorf("Assert failure: expected true, actual: %t", ok)
 	}
 }
+
+/*
+Test that a single Bp serves concurrent proofs and verifications of every kind, with
+deterministic results, and is not modified by them, with a pool of fewer workers than
+the concurrent calls. Run with -race.
+*/
+func TestConcurrentBulletproofs(t *testing.T) {
+	var (
+		zkrp Bp
+		wg   sync.WaitGroup
+	)
+	zkrp.SetupAggregate(18, 200, 2)
+	pool := NewPool(3)
+	defer pool.Close()
+	zkrp.SetPool(pool)
+	before, _ := json.Marshal(&zkrp)
+	_, shared, _ := zkrp.GenerateProof(new(big.Int).SetInt64(42))
+	nonce := []byte("nonce")
+
+	errs := make(chan string, 64)
+	check := func(name string, ok bool, err error) {
+		if ok != true || err != nil {
+			errs <- fmt.Sprintf("%s: expected true, actual: %t %v", name, ok, err)
+		}
+	}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			secret := new(big.Int).SetInt64(int64(18 + g))
+			_, proof, _ := zkrp.GenerateProof(secret)
+			ok, err := zkrp.Verify(proof)
+			check("Verify", ok, err)
+			ok, err = zkrp.Verify(shared)
+			check("Verify shared", ok, err)
+			_, plus, _ := zkrp.GenerateProofPlus(secret)
+			ok, err = zkrp.VerifyPlus(plus)
+			check("VerifyPlus", ok, err)
+			_, agg, _ := zkrp.GenerateAggregateProof([]*big.Int{secret, new(big.Int).SetInt64(200)})
+			ok, err = zkrp.VerifyAggregate(agg)
+			check("VerifyAggregate", ok, err)
+			ok, _, err = zkrp.VerifyBatch([]proofBP{proof, shared})
+			check("VerifyBatch", ok, err)
+			_, rewindable, _ := zkrp.GenerateRewindableProof(secret, nonce, nil)
+			amount, _, _, err := zkrp.Rewind(rewindable, nonce)
+			check("Rewind", err == nil && amount.Cmp(secret) == 0, err)
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Errorf("Assert failure: %s", e)
+	}
+	after, _ := json.Marshal(&zkrp)
+	if string(before) != string(after) {
+		t.Errorf("Assert failure: the parameters were modified")
+	}
+}
@@ -0,0 +1,576 @@
+/*
+This file contains the Bulletproofs+ range proof, which replaces the inner product
+argument of Bulletproofs by a zero-knowledge weighted inner product argument. The proof
+is made of 2.log2(n.m)+6 elements instead of 2.log2(n.m)+9, and the prover does not
+have to commit to the polynomial t(X). It follows the paper:
+Bulletproofs+: Shorter Proofs for Privacy-Enhanced Distributed Ledger
+Heewon Chung, Kyoohyung Han, Chanyang Ju, Myungsun Kim and Jae Hong Seo
+*/
+
+package zkproofs
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+/*
+Bulletproofs+ proof. Besides the commitment V, it is composed by A, the points A1 and
+B and the scalars R1, S1 and D1 of the last round of the weighted inner product
+argument, and the points L and R of the other rounds.
+*/
+type proofBPPlus struct {
+	V  Element
+	A  Element
+	A1 Element
+	B  Element
+	R1 Scalar
+	S1 Scalar
+	D1 Scalar
+	Ls []Element
+	Rs []Element
+}
+
+/*
+weightedInnerProduct returns <a, b>_y = sum_i a_i.b_i.y^(i+1).
+*/
+func weightedInnerProduct(group Group, a, b []Scalar, y Scalar) Scalar {
+	var (
+		i int
+	)
+	result := group.NewScalar()
+	expy := group.NewScalar().Set(y)
+	t := group.NewScalar()
+	i = 0
+	for i < len(a) {
+		t.Mul(a[i], b[i])
+		result.Add(result, t.Mul(t, expy))
+		expy.Mul(expy, y)
+		i = i + 1
+	}
+	return result
+}
+
+/*
+powersOfTwoZ2 returns the vector d = z^2.2^n || z^4.2^n || ... || z^(2m).2^n.
+*/
+func (zkrp *Bp) powersOfTwoZ2(z Scalar, m int64) []Scalar {
+	var (
+		i, j   int64
+		result []Scalar
+	)
+	group := zkrp.Group()
+	two := group.NewScalar().SetInt64(2)
+	z2 := group.NewScalar().Mul(z, z)
+	zj := group.NewScalar().Set(z2)
+	result = make([]Scalar, 0, zkrp.N*m)
+	j = 0
+	for j < m {
+		current := group.NewScalar().Set(zj)
+		i = 0
+		for i < zkrp.N {
+			result = append(result, group.NewScalar().Set(current))
+			current.Mul(current, two)
+			i = i + 1
+		}
+		zj.Mul(zj, z2)
+		j = j + 1
+	}
+	return result
+}
+
+/*
+GenerateProofPlus computes the Bulletproofs+ ZK proof that the secret belongs to [A, B].
+It returns the blinding factor gamma of proof.V and the proof.
+*/
+func (zkrp *Bp) GenerateProofPlus(secret *big.Int) (*big.Int, proofBPPlus, error) {
+	return zkrp.GenerateProofPlusWithTranscript(NewTranscript(DOMAIN), secret)
+}
+
+/*
+GenerateProofPlusWithTranscript is GenerateProofPlus with the Fiat-Shamir challenges
+bound to the given transcript.
+*/
+func (zkrp *Bp) GenerateProofPlusWithTranscript(transcript *Transcript, secret *big.Int) (*big.Int, proofBPPlus, error) {
+	gammas, err := zkrp.randomScalars(1)
+	if err != nil {
+		return nil, proofBPPlus{}, err
+	}
+	gamma := gammas[0]
+	V, _ := CommitG1(zkrp.Group().NewScalar().SetBigInt(secret), gamma, zkrp.H)
+
+	values, blinds := zkrp.shiftValues([]*big.Int{secret}, []Scalar{gamma})
+	proof, err := zkrp.proveRangePlus(transcript, zkrp.shiftCommitments([]Element{V}), values, blinds)
+	if err != nil {
+		return nil, proof, err
+	}
+	proof.V = V
+	return gamma.BigInt(), proof, nil
+}
+
+/*
+VerifyPlus returns true if and only if the Bulletproofs+ proof is valid, i.e. the
+secret committed in proof.V belongs to [A, B].
+*/
+func (zkrp *Bp) VerifyPlus(proof proofBPPlus) (bool, error) {
+	return zkrp.VerifyPlusWithTranscript(NewTranscript(DOMAIN), proof)
+}
+
+/*
+VerifyPlusWithTranscript is VerifyPlus with the Fiat-Shamir challenges bound to the
+given transcript, which must be in the same state as the prover's one.
+*/
+func (zkrp *Bp) VerifyPlusWithTranscript(transcript *Transcript, proof proofBPPlus) (bool, error) {
+	if !sameGroup(zkrp.Group(), proof.V) {
+		return false, errors.New("proof is not over the group of the parameters")
+	}
+	return zkrp.verifyRangePlus(transcript, zkrp.shiftCommitments([]Element{proof.V}), proof)
+}
+
+/*
+proveRangePlus computes the Bulletproofs+ proof that every value belongs to [0, 2^n),
+where values[j] is committed in V[j] with the blinding factor gammas[j].
+*/
+func (zkrp *Bp) proveRangePlus(transcript *Transcript, V []Element, values []*big.Int, gammas []Scalar) (proofBPPlus, error) {
+	var (
+		i, j, m, nm int64
+		proof       proofBPPlus
+	)
+	group := zkrp.Group()
+	m = int64(len(values))
+	if err := zkrp.checkRange(m); err != nil {
+		return proof, err
+	}
+	nm = zkrp.N * m
+	transcript.AppendMessage("dom-sep", []byte("bulletproofs+ v1"))
+	zkrp.rangeDomainSep(transcript, V)
+
+	// A = g^aL.h^aR.H^alpha, where aL is the concatenation of the bits of the values
+	one := group.NewScalar().SetInt64(1)
+	aL := make([]Scalar, nm)
+	aR := make([]Scalar, nm)
+	j = 0
+	for j < m {
+		bits, _ := Decompose(values[j], 2, zkrp.N)
+		i = 0
+		for i < zkrp.N {
+			aL[j*zkrp.N+i] = group.NewScalar().SetInt64(bits[i])
+			aR[j*zkrp.N+i] = group.NewScalar().Sub(aL[j*zkrp.N+i], one)
+			i = i + 1
+		}
+		j = j + 1
+	}
+	random, err := zkrp.randomScalars(1)
+	if err != nil {
+		return proof, err
+	}
+	alpha := random[0]
+	A, _ := commitVector(zkrp.pool, aL, aR, alpha, zkrp.H, zkrp.Gg[:nm], zkrp.Hh[:nm], nm)
+
+	transcript.AppendPoint("A", A)
+	y := transcript.ChallengeScalar("y", group)
+	z := transcript.ChallengeScalar("z", group)
+
+	// aL^ = aL - z.1^nm and aR^ = aR + z.1^nm + d o y^(nm..1)
+	d := zkrp.powersOfTwoZ2(z, m)
+	vy := scalarPowers(group, y, nm+1)
+	aLhat := make([]Scalar, nm)
+	aRhat := make([]Scalar, nm)
+	t := group.NewScalar()
+	i = 0
+	for i < nm {
+		aLhat[i] = group.NewScalar().Sub(aL[i], z)
+		aRhat[i] = group.NewScalar().Add(aR[i], z)
+		aRhat[i].Add(aRhat[i], t.Mul(d[i], vy[nm-i]))
+		i = i + 1
+	}
+
+	// alpha^ = alpha + sum_j z^(2j).y^(nm+1).gamma_j
+	alphahat := alpha
+	z2 := group.NewScalar().Mul(z, z)
+	zj := group.NewScalar().Mul(z2, vy[nm])
+	zj.Mul(zj, y)
+	j = 0
+	for j < m {
+		alphahat.Add(alphahat, t.Mul(zj, gammas[j]))
+		zj.Mul(zj, z2)
+		j = j + 1
+	}
+
+	padded := zkrp.paddedSize(m)
+	proof, err = zkrp.proveWIP(transcript, padVector(group, aLhat, padded), padVector(group, aRhat, padded), alphahat, y)
+	if err != nil {
+		return proof, err
+	}
+	proof.A = A
+	return proof, nil
+}
+
+/*
+commitWIP returns g^a.h^b.G^c.H^d in constant time with respect to the scalars, which
+are derived from the secret in every round of proveWIP.
+*/
+func (zkrp *Bp) commitWIP(g, h []Element, a, b []Scalar, c, d Scalar) Element {
+	points := append(append([]Element{}, g...), h...)
+	points = append(points, zkrp.G, zkrp.H)
+	scalars := append(append([]Scalar{}, a...), b...)
+	scalars = append(scalars, c, d)
+	return ctVectorExp(zkrp.pool, points, scalars)
+}
+
+/*
+proveWIP computes the weighted inner product argument for the vectors a and b, the
+blinding factor alpha and the weight y, over the commitment
+P = g^a.h^b.G^(<a, b>_y).H^alpha. In each round the vectors are folded in half with
+the challenge e, as in the inner product argument of Bulletproofs, and the last round
+is a Schnorr-like proof of the remaining scalars.
+*/
+func (zkrp *Bp) proveWIP(transcript *Transcript, a, b []Scalar, alpha, y Scalar) (proofBPPlus, error) {
+	var (
+		i, n, nh int64
+		proof    proofBPPlus
+	)
+	group := zkrp.Group()
+	n = int64(len(a))
+	if !isPowerOfTwo(n) {
+		return proof, errors.New("size of the vectors must be a power of two")
+	}
+	g := zkrp.Gg[:n]
+	h := zkrp.Hh[:n]
+	t := group.NewScalar()
+	for n > 1 {
+		nh = n / 2
+		yn := scalarPowers(group, y, nh+1)[nh]
+		yninv := group.NewScalar().Inverse(yn)
+		a1, a2 := a[:nh], a[nh:]
+		b1, b2 := b[:nh], b[nh:]
+		a1y := make([]Scalar, nh)
+		a2y := make([]Scalar, nh)
+		i = 0
+		for i < nh {
+			a1y[i] = group.NewScalar().Mul(a1[i], yninv)
+			a2y[i] = group.NewScalar().Mul(a2[i], yn)
+			i = i + 1
+		}
+
+		// L = g2^(a1.y^-nh).h1^b2.G^cL.H^dL and R = g1^(a2.y^nh).h2^b1.G^cR.H^dR
+		cL := weightedInnerProduct(group, a1, b2, y)
+		cR := weightedInnerProduct(group, a2y, b1, y)
+		random, err := zkrp.randomScalars(2)
+		if err != nil {
+			return proof, err
+		}
+		dL, dR := random[0], random[1]
+		L := zkrp.commitWIP(g[nh:], h[:nh], a1y, b2, cL, dL)
+		R := zkrp.commitWIP(g[:nh], h[nh:], a2y, b1, cR, dR)
+		proof.Ls = append(proof.Ls, L)
+		proof.Rs = append(proof.Rs, R)
+
+		group.Normalize([]Element{L, R})
+		transcript.AppendPoint("L", L)
+		transcript.AppendPoint("R", R)
+		e := transcript.ChallengeScalar("e", group)
+		einv := group.NewScalar().Inverse(e)
+		e2 := group.NewScalar().Mul(e, e)
+		e2inv := group.NewScalar().Mul(einv, einv)
+
+		// g' = g1^(e^-1) o g2^(e.y^-nh), h' = h1^e o h2^(e^-1)
+		// a' = a1.e + a2.y^nh.e^-1, b' = b1.e^-1 + b2.e, alpha' = dL.e^2 + alpha + dR.e^-2
+		eyninv := group.NewScalar().Mul(e, yninv)
+		gprime := make([]Element, nh)
+		hprime := make([]Element, nh)
+		aprime := make([]Scalar, nh)
+		bprime := make([]Scalar, nh)
+		zkrp.pool.parallelFor(nh, zkrp.pool.workers(nh), func(chunk, lo, hi int64) {
+			for i := lo; i < hi; i++ {
+				gprime[i] = group.NewElement().ScalarMult(g[i], einv)
+				gprime[i].Add(gprime[i], group.NewElement().ScalarMult(g[nh+i], eyninv))
+				hprime[i] = group.NewElement().ScalarMult(h[i], e)
+				hprime[i].Add(hprime[i], group.NewElement().ScalarMult(h[nh+i], einv))
+			}
+		})
+		i = 0
+		for i < nh {
+			aprime[i] = group.NewScalar().Mul(a1[i], e)
+			aprime[i].Add(aprime[i], t.Mul(a2y[i], einv))
+			bprime[i] = group.NewScalar().Mul(b1[i], einv)
+			bprime[i].Add(bprime[i], t.Mul(b2[i], e))
+			i = i + 1
+		}
+		alpha = group.NewScalar().Add(alpha, t.Mul(dL, e2))
+		alpha.Add(alpha, t.Mul(dR, e2inv))
+		g, h, a, b = gprime, hprime, aprime, bprime
+		n = nh
+	}
+
+	// A1 = g^r.h^s.G^(r.y.b + s.y.a).H^delta and B = G^(r.y.s).H^eta
+	random, err := zkrp.randomScalars(4)
+	if err != nil {
+		return proof, err
+	}
+	r, s, delta, eta := random[0], random[1], random[2], random[3]
+	ry := group.NewScalar().Mul(r, y)
+	sy := group.NewScalar().Mul(s, y)
+	c := group.NewScalar().Mul(ry, b[0])
+	c.Add(c, t.Mul(sy, a[0]))
+	A1 := zkrp.commitWIP(g, h, []Scalar{r}, []Scalar{s}, c, delta)
+	B, _ := CommitG1(group.NewScalar().Mul(ry, s), eta, zkrp.H)
+
+	group.Normalize([]Element{A1, B})
+	transcript.AppendPoint("A1", A1)
+	transcript.AppendPoint("B", B)
+	e := transcript.ChallengeScalar("e", group)
+
+	// r' = r + a.e, s' = s + b.e, delta' = eta + delta.e + alpha.e^2
+	proof.A1 = A1
+	proof.B = B
+	proof.R1 = r.Add(r, t.Mul(a[0], e))
+	proof.S1 = s.Add(s, t.Mul(b[0], e))
+	d1 := group.NewScalar().Mul(alpha, e)
+	d1.Add(d1, delta)
+	d1.Mul(d1, e)
+	proof.D1 = d1.Add(d1, eta)
+	return proof, nil
+}
+
+/*
+verifyRangePlus returns true if and only if the proof shows that every value committed
+in V belongs to [0, 2^n). The commitment P of the weighted inner product argument and
+every folding round are checked with a single multi-exponentiation:
+P^(e^2).prod(L_k^(e^2.e_k^2).R_k^(e^2.e_k^-2)).A1^e.B = g^(r'.e.s).h^(s'.e.s^-1).G^(r'.y.s').H^delta'
+where P = A.g^-z.h^(z + d o y^(nm..1)).G^zeta.prod_j V_j^(z^(2j).y^(nm+1)) and s is
+the vector of the products of the challenges e_k, with g_i also weighted by y^-i.
+*/
+func (zkrp *Bp) verifyRangePlus(transcript *Transcript, V []Element, proof proofBPPlus) (bool, error) {
+	var (
+		i, j, m, nm, padded int64
+		k                   int
+	)
+	m = int64(len(V))
+	if err := zkrp.checkRange(m); err != nil {
+		return false, err
+	}
+	nm = zkrp.N * m
+	padded = zkrp.paddedSize(m)
+	if int64(len(proof.Ls)) != log2(padded) || int64(len(proof.Rs)) != log2(padded) {
+		return false, errors.New("weighted inner product proof has the wrong number of rounds")
+	}
+	group := zkrp.Group()
+	if !sameGroup(group, V...) || !sameGroup(group, proof.A, proof.A1, proof.B) ||
+		!sameGroup(group, proof.Ls...) || !sameGroup(group, proof.Rs...) {
+		return false, errors.New("proof is not over the group of the parameters")
+	}
+	if !sameScalarGroup(group, proof.R1, proof.S1, proof.D1) {
+		return false, errors.New("proof is incomplete")
+	}
+	transcript.AppendMessage("dom-sep", []byte("bulletproofs+ v1"))
+	zkrp.rangeDomainSep(transcript, V)
+	transcript.AppendPoint("A", proof.A)
+	y := transcript.ChallengeScalar("y", group)
+	z := transcript.ChallengeScalar("z", group)
+	es := make([]Scalar, len(proof.Ls))
+	for k = range es {
+		transcript.AppendPoint("L", proof.Ls[k])
+		transcript.AppendPoint("R", proof.Rs[k])
+		es[k] = transcript.ChallengeScalar("e", group)
+	}
+	esinv := inverses(group, es)
+	transcript.AppendPoint("A1", proof.A1)
+	transcript.AppendPoint("B", proof.B)
+	e := transcript.ChallengeScalar("e", group)
+	e2 := group.NewScalar().Mul(e, e)
+	s := innerProductScalars(group, es, esinv)
+	r1, s1, d1 := proof.R1, proof.S1, proof.D1
+
+	d := zkrp.powersOfTwoZ2(z, m)
+	vy := scalarPowers(group, y, nm+2)
+	yinv := group.NewScalar().Inverse(y)
+
+	points := []Element{proof.A, proof.A1, proof.B, zkrp.G, zkrp.H}
+	scalars := []Scalar{e2, e, group.NewScalar().SetInt64(1)}
+
+	// zeta = (z - z^2).sum_i y^i - z.y^(nm+1).sum_i d_i
+	sumy := group.NewScalar()
+	sumd := group.NewScalar()
+	i = 0
+	for i < nm {
+		sumy.Add(sumy, vy[i+1])
+		sumd.Add(sumd, d[i])
+		i = i + 1
+	}
+	t := group.NewScalar()
+	z2 := group.NewScalar().Mul(z, z)
+	zeta := group.NewScalar().Sub(z, z2)
+	zeta.Mul(zeta, sumy)
+	zeta.Sub(zeta, t.Mul(t.Mul(z, vy[nm+1]), sumd))
+
+	// G^(e^2.zeta - r'.y.s') and H^-delta'
+	gexp := group.NewScalar().Mul(e2, zeta)
+	gexp.Sub(gexp, t.Mul(t.Mul(r1, y), s1))
+	scalars = append(scalars, gexp, group.NewScalar().Neg(d1))
+
+	// V_j^(e^2.z^(2j).y^(nm+1))
+	zj := group.NewScalar().Mul(e2, z2)
+	zj.Mul(zj, vy[nm+1])
+	j = 0
+	for j < m {
+		points = append(points, V[j])
+		scalars = append(scalars, group.NewScalar().Set(zj))
+		zj.Mul(zj, z2)
+		j = j + 1
+	}
+
+	// L_k^(e^2.e_k^2) and R_k^(e^2.e_k^-2)
+	for k = range es {
+		points = append(points, proof.Ls[k], proof.Rs[k])
+		el := group.NewScalar().Mul(es[k], es[k])
+		er := group.NewScalar().Mul(esinv[k], esinv[k])
+		scalars = append(scalars, el.Mul(el, e2), er.Mul(er, e2))
+	}
+
+	// g_i^(-z.e^2 - r'.e.s_i.y^-i) and h_i^(e^2.(z + d_i.y^(nm-i)) - s'.e.s_(nm-1-i)), the
+	// entries that pad the vectors only get the terms of the last round
+	re := group.NewScalar().Mul(r1, e)
+	se := group.NewScalar().Mul(s1, e)
+	ze2 := group.NewScalar().Mul(z, e2)
+	expyinv := group.NewScalar().SetInt64(1)
+	gexps := make([]Scalar, padded)
+	hexps := make([]Scalar, padded)
+	i = 0
+	for i < padded {
+		gexps[i] = group.NewScalar().Mul(s[i], expyinv)
+		gexps[i].Mul(gexps[i], re)
+		gexps[i].Neg(gexps[i])
+		hexps[i] = group.NewScalar().Mul(se, s[padded-1-i])
+		hexps[i].Neg(hexps[i])
+		if i < nm {
+			gexps[i].Sub(gexps[i], ze2)
+			hexps[i].Add(hexps[i], ze2)
+			hexps[i].Add(hexps[i], t.Mul(t.Mul(d[i], vy[nm-i]), e2))
+		}
+		expyinv.Mul(expyinv, yinv)
+		i = i + 1
+	}
+	points = append(points, zkrp.Gg[:padded]...)
+	scalars = append(scalars, gexps...)
+	points = append(points, zkrp.Hh[:padded]...)
+	scalars = append(scalars, hexps...)
+
+	result, err := vectorExp(zkrp.pool, points, scalars)
+	if err != nil {
+		return false, err
+	}
+	return result.IsIdentity(), nil
+}
+
+func (p *proofBPPlus) MarshalJSON() ([]byte, error) {
+	var iLs []pstring
+	var iRs []pstring
+	var i int
+	group := p.V.Group()
+	logn := len(p.Ls)
+	iLs = make([]pstring, logn)
+	iRs = make([]pstring, logn)
+	i = 0
+	for i < logn {
+		iLs[i] = newPstring(p.Ls[i])
+		iRs[i] = newPstring(p.Rs[i])
+		i = i + 1
+	}
+	return json.Marshal(&struct {
+		Scheme Scheme    `json:"Scheme"`
+		Group  string    `json:"Group"`
+		V      pstring   `json:"V"`
+		A      pstring   `json:"A"`
+		A1     pstring   `json:"A1"`
+		B      pstring   `json:"B"`
+		R1     string    `json:"R1"`
+		S1     string    `json:"S1"`
+		D1     string    `json:"D1"`
+		Ls     []pstring `json:"Ls"`
+		Rs     []pstring `json:"Rs"`
+	}{
+		Scheme: SchemeBulletproofsPlus,
+		Group:  group.Name(),
+		V:      newPstring(p.V),
+		A:      newPstring(p.A),
+		A1:     newPstring(p.A1),
+		B:      newPstring(p.B),
+		R1:     scalarString(p.R1),
+		S1:     scalarString(p.S1),
+		D1:     scalarString(p.D1),
+		Ls:     iLs,
+		Rs:     iRs,
+	})
+}
+
+func (p *proofBPPlus) UnmarshalJSON(data []byte) error {
+	var (
+		i   int
+		err error
+		aux struct {
+			Scheme Scheme    `json:"Scheme"`
+			Group  string    `json:"Group"`
+			V      pstring   `json:"V"`
+			A      pstring   `json:"A"`
+			A1     pstring   `json:"A1"`
+			B      pstring   `json:"B"`
+			R1     string    `json:"R1"`
+			S1     string    `json:"S1"`
+			D1     string    `json:"D1"`
+			Ls     []pstring `json:"Ls"`
+			Rs     []pstring `json:"Rs"`
+		}
+		proof proofBPPlus
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Scheme != SchemeBulletproofsPlus {
+		return errors.New("proof was not made with Bulletproofs+")
+	}
+	if len(aux.Ls) != len(aux.Rs) {
+		return errors.New("weighted inner product proof must have as many L as R")
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if proof.V, err = aux.V.point(group); err != nil {
+		return err
+	}
+	if proof.A, err = aux.A.point(group); err != nil {
+		return err
+	}
+	if proof.A1, err = aux.A1.point(group); err != nil {
+		return err
+	}
+	if proof.B, err = aux.B.point(group); err != nil {
+		return err
+	}
+	if proof.R1, err = scalar(group, aux.R1); err != nil {
+		return err
+	}
+	if proof.S1, err = scalar(group, aux.S1); err != nil {
+		return err
+	}
+	if proof.D1, err = scalar(group, aux.D1); err != nil {
+		return err
+	}
+	logn := len(aux.Ls)
+	proof.Ls = make([]Element, logn)
+	proof.Rs = make([]Element, logn)
+	i = 0
+	for i < logn {
+		if proof.Ls[i], err = aux.Ls[i].point(group); err != nil {
+			return err
+		}
+		if proof.Rs[i], err = aux.Rs[i].point(group); err != nil {
+			return err
+		}
+		i = i + 1
+	}
+	*p = proof
+	return nil
+}
@@ -0,0 +1,209 @@
+package zkproofs
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+/*
+Test the TRUE case of Bulletproofs+ over intervals with one and two shifts.
+*/
+func TestTrueBulletproofsPlus(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		intervals := [][3]int64{{0, 255, 0}, {0, 255, 255}, {18, 200, 18}, {18, 200, 40}, {18, 200, 200}}
+		for _, c := range intervals {
+			zkrp.SetupWithGroup(group, c[0], c[1], 1)
+			_, proof, err := zkrp.GenerateProofPlus(new(big.Int).SetInt64(c[2]))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			ok, err := zkrp.VerifyPlus(proof)
+			if ok != true || err != nil {
+				t.Errorf("Assert failure for %d in [%d, %d]: expected true, actual: %t %v", c[2], c[0], c[1], ok, err)
+			}
+		}
+	})
+}
+
+/*
+Test Bulletproofs+ over [0, 2^n - 1] for bit lengths that are not powers of two, where
+the vectors of the weighted inner product argument are padded.
+*/
+func TestBitLengthsBulletproofsPlus(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		for _, n := range []uint{13, 20, 41, 48} {
+			var zkrp Bp
+			a, b := int64(0), int64(1)<<n-1
+			if err := zkrp.SetupWithGroup(group, a, b, 1); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if zkrp.N != int64(n) {
+				t.Errorf("Assert failure: expected %d bits, actual: %d", n, zkrp.N)
+			}
+			for _, x := range []int64{a, a + 1, b / 3, b} {
+				_, proof, err := zkrp.GenerateProofPlus(new(big.Int).SetInt64(x))
+				if err != nil {
+					t.Fatalf("Unexpected error: %s", err)
+				}
+				rounds := int(log2(nextPowerOfTwo(zkrp.N)))
+				if len(proof.Ls) != rounds {
+					t.Errorf("Assert failure: expected %d rounds, actual: %d", rounds, len(proof.Ls))
+				}
+				ok, err := zkrp.VerifyPlus(proof)
+				if ok != true || err != nil {
+					t.Errorf("Assert failure for %d in [%d, %d]: expected true, actual: %t %v", x, a, b, ok, err)
+				}
+			}
+			_, proof, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(b + 1))
+			ok, _ := zkrp.VerifyPlus(proof)
+			if ok != false {
+				t.Errorf("Assert failure for %d not in [%d, %d]: expected false, actual: %t", b+1, a, b, ok)
+			}
+		}
+	})
+}
+
+/*
+Test the FALSE case of Bulletproofs+, where the secret is out of the interval.
+*/
+func TestFalseBulletproofsPlus(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		intervals := [][3]int64{{0, 255, 256}, {0, 255, -1}, {18, 200, 17}, {18, 200, 201}}
+		for _, c := range intervals {
+			zkrp.SetupWithGroup(group, c[0], c[1], 1)
+			_, proof, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(c[2]))
+			ok, _ := zkrp.VerifyPlus(proof)
+			if ok != false {
+				t.Errorf("Assert failure for %d in [%d, %d]: expected false, actual: %t", c[2], c[0], c[1], ok)
+			}
+		}
+	})
+}
+
+/*
+Test that tampered Bulletproofs+ proofs are rejected.
+*/
+func TestBulletproofsPlusTampered(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 255)
+	_, proof, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(42))
+	_, other, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(43))
+	tampered := []proofBPPlus{proof, proof, proof, proof}
+	tampered[0].V = other.V
+	tampered[1].R1 = Secp256k1.NewScalar().Add(proof.R1, Secp256k1.NewScalar().SetInt64(1))
+	tampered[2].Ls = append([]Element{other.Ls[0]}, proof.Ls[1:]...)
+	tampered[3].Ls = proof.Ls[1:]
+	tampered[3].Rs = proof.Rs[1:]
+	for i, p := range tampered {
+		ok, _ := zkrp.VerifyPlus(p)
+		if ok != false {
+			t.Errorf("Assert failure for case %d: expected false, actual: %t", i, ok)
+		}
+	}
+}
+
+/*
+Test that a Bulletproofs+ proof is bound to its transcript.
+*/
+func TestBulletproofsPlusDomainSeparation(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 255)
+	_, proof, _ := zkrp.GenerateProofPlusWithTranscript(NewTranscript("chain 1"), new(big.Int).SetInt64(42))
+	ok, _ := zkrp.VerifyPlusWithTranscript(NewTranscript("chain 1"), proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+	ok, _ = zkrp.VerifyPlusWithTranscript(NewTranscript("chain 2"), proof)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+}
+
+/*
+Test that the caller chooses the scheme, and that serialized proofs are tagged with
+it and loaded back as proofs of the same scheme.
+*/
+func TestRangeProofSchemes(t *testing.T) {
+	zkrp := GetZkrp()
+	sizes := make(map[Scheme]int)
+	for _, scheme := range []Scheme{SchemeBulletproofs, SchemeBulletproofsPlus} {
+		_, proof, err := zkrp.GenerateRangeProof(scheme, new(big.Int).SetInt64(30))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if proof.Scheme() != scheme {
+			t.Errorf("Assert failure: expected %s, actual: %s", scheme, proof.Scheme())
+		}
+		data, _ := DumpProof(proof)
+		if !strings.Contains(string(data), "\"Scheme\":\""+string(scheme)+"\"") {
+			t.Errorf("Assert failure: serialized proof is not tagged with %s", scheme)
+		}
+		sizes[scheme] = len(data)
+		verifier, loaded, err := LoadRangeProof(data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if loaded.Scheme() != scheme || !loaded.Commitment().Equal(proof.Commitment()) {
+			t.Errorf("Assert failure: expected the %s proof to be loaded back", scheme)
+		}
+		ok, _ := verifier.VerifyRangeProof(loaded)
+		if ok != true {
+			t.Errorf("Assert failure for %s: expected true, actual: %t", scheme, ok)
+		}
+	}
+	if sizes[SchemeBulletproofsPlus] >= sizes[SchemeBulletproofs] {
+		t.Errorf("Assert failure: expected a shorter Bulletproofs+ proof, actual: %d >= %d", sizes[SchemeBulletproofsPlus], sizes[SchemeBulletproofs])
+	}
+	_, _, err := zkrp.GenerateRangeProof(Scheme("bulletproofs++"), new(big.Int).SetInt64(30))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for an unknown scheme")
+	}
+}
+
+/*
+Test that a proof tagged with one scheme is not loaded as a proof of another one.
+*/
+func TestRangeProofSchemeTag(t *testing.T) {
+	zkrp := GetZkrp()
+	_, proof, _ := zkrp.GenerateRangeProof(SchemeBulletproofsPlus, new(big.Int).SetInt64(30))
+	data, _ := DumpProof(proof)
+	_, _, err := LoadProof(data)
+	if err == nil {
+		t.Errorf("Assert failure: expected error when loading a Bulletproofs+ proof as Bulletproofs")
+	}
+	_, _, err = LoadRangeProof([]byte(strings.Replace(string(data), "bulletproofs+", "other", 1)))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for an unknown scheme")
+	}
+	_, bp, _ := zkrp.GenerateProof(new(big.Int).SetInt64(30))
+	data, _ = DumpProof(&bp)
+	untagged := []byte(strings.Replace(string(data), "\"Scheme\":\"bulletproofs\",", "", 1))
+	_, _, err = LoadRangeProof(untagged)
+	if err == nil {
+		t.Errorf("Assert failure: expected error for an untagged proof")
+	}
+	_, _, err = LoadProof(untagged)
+	if err == nil {
+		t.Errorf("Assert failure: expected error when loading an untagged proof as Bulletproofs")
+	}
+}
+
+func BenchmarkBulletproofsPlus(b *testing.B) {
+	zkrp := GetZkrp()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, proof, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(1000))
+		zkrp.VerifyPlus(proof)
+	}
+}
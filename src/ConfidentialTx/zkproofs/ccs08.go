@@ -19,13 +19,16 @@ This file contains the implementation of the ZKRP scheme proposed in the paper:
 Efficient Protocols for Set Membership and Range Proofs
 Jan Camenisch, Rafik Chaabouni, abhi shelat
 Asiacrypt 2008
+
+It runs on the bn256 of go-ethereum, whose arithmetic is not constant time, so the
+prover is outside the constant-time guarantees of this package: its scalar
+multiplications only follow the same sequence of operations for all the secrets.
 */
 
 package zkproofs
 
 import (
 	"bytes"
-	"crypto/rand"
 	"errors"
 	"math"
 	"math/big"
@@ -77,8 +80,8 @@ type proofSet struct {
 	V              *bn256.G2
 	D, C           *bn256.G2
 	a              *bn256.GT
-	s, t, zsig, zv *big.Int
-	c, m, zr       *big.Int
+	s, t, zsig, zv Scalar
+	c, m, zr       Scalar
 }
 
 /*
@@ -88,8 +91,8 @@ type proofUL struct {
 	V              []*bn256.G2
 	D, C           *bn256.G2
 	a              []*bn256.GT
-	s, t, zsig, zv []*big.Int
-	c, m, zr       *big.Int
+	s, t, zsig, zv []Scalar
+	c, m, zr       Scalar
 }
 
 /*
@@ -138,12 +141,47 @@ func SetupUL(u, l int64) (paramsUL, error) {
 	return p, nil
 }
 
+/*
+challengeSet computes the Fiat-Shamir challenge of the ZK Set Membership proof.
+*/
+func challengeSet(transcript *Transcript, proof_out *proofSet, p *paramsSet) Scalar {
+	transcript.AppendMessage("dom-sep", []byte("ccs08 set v1"))
+	transcript.AppendMessage("H", p.H.Marshal())
+	transcript.AppendMessage("y", p.kp.pubk.Marshal())
+	transcript.AppendMessage("C", proof_out.C.Marshal())
+	transcript.AppendMessage("V", proof_out.V.Marshal())
+	transcript.AppendMessage("a", proof_out.a.Marshal())
+	transcript.AppendMessage("D", proof_out.D.Marshal())
+	return transcript.ChallengeScalar("c", BN254)
+}
+
+/*
+challengeUL computes the Fiat-Shamir challenge of the ZKRP proof for [0,U^L).
+*/
+func challengeUL(transcript *Transcript, proof_out *proofUL, p *paramsUL) Scalar {
+	var (
+		i int64
+	)
+	transcript.AppendMessage("dom-sep", []byte("ccs08 range v1"))
+	transcript.AppendUint64("u", uint64(p.u))
+	transcript.AppendUint64("l", uint64(p.l))
+	transcript.AppendMessage("H", p.H.Marshal())
+	transcript.AppendMessage("y", p.kp.pubk.Marshal())
+	transcript.AppendMessage("C", proof_out.C.Marshal())
+	for i = 0; i < p.l; i++ {
+		transcript.AppendMessage("V", proof_out.V[i].Marshal())
+		transcript.AppendMessage("a", proof_out.a[i].Marshal())
+	}
+	transcript.AppendMessage("D", proof_out.D.Marshal())
+	return transcript.ChallengeScalar("c", BN254)
+}
+
 /*
 ProveSet method is used to produce the ZK Set Membership proof.
 */
-func ProveSet(x int64, r *big.Int, p paramsSet) (proofSet, error) {
+func ProveSet(transcript *Transcript, x int64, r *big.Int, p paramsSet) (proofSet, error) {
 	var (
-		v         *big.Int
+		v         Scalar
 		proof_out proofSet
 	)
 
@@ -152,24 +190,24 @@ func ProveSet(x int64, r *big.Int, p paramsSet) (proofSet, error) {
 	// proof_out.D.SetInfinity()
 	_, _, epz, _ := proof_out.D.CurvePoints()
 	epz.SetZero()
-	proof_out.m, _ = rand.Int(rand.Reader, bn256.Order)
+	proof_out.m = BN254.NewScalar().SetRandom()
 
 	D := new(bn256.G2)
-	v, _ = rand.Int(rand.Reader, bn256.Order)
+	v = BN254.NewScalar().SetRandom()
 	A, ok := p.signatures[x]
 	if ok {
 		// D = g^s.H^m
-		D = new(bn256.G2).ScalarMult(p.H, proof_out.m)
-		proof_out.s, _ = rand.Int(rand.Reader, bn256.Order)
-		aux := new(bn256.G2).ScalarBaseMult(proof_out.s)
+		D = new(bn256.G2).ScalarMult(p.H, proof_out.m.BigInt())
+		proof_out.s = BN254.NewScalar().SetRandom()
+		aux := new(bn256.G2).ScalarBaseMult(proof_out.s.BigInt())
 		D.Add(D, aux)
 
-		proof_out.V = new(bn256.G2).ScalarMult(A, v)
-		proof_out.t, _ = rand.Int(rand.Reader, bn256.Order)
+		proof_out.V = new(bn256.G2).ScalarMult(A, v.BigInt())
+		proof_out.t = BN254.NewScalar().SetRandom()
 		proof_out.a = bn256.Pair(G1, proof_out.V)
-		proof_out.a.ScalarMult(proof_out.a, proof_out.s)
+		proof_out.a.ScalarMult(proof_out.a, proof_out.s.BigInt())
 		proof_out.a.Neg(proof_out.a)
-		proof_out.a.Add(proof_out.a, new(bn256.GT).ScalarMult(E, proof_out.t))
+		proof_out.a.Add(proof_out.a, new(bn256.GT).ScalarMult(E, proof_out.t.BigInt()))
 	} else {
 		return proof_out, errors.New("Could not generate proof. Element does not belong to the interval.")
 	}
@@ -179,82 +217,81 @@ func ProveSet(x int64, r *big.Int, p paramsSet) (proofSet, error) {
 	// so that it is possible to delegate the commitment computation to an external party.
 	proof_out.C, _ = Commit(new(big.Int).SetInt64(x), r, p.H)
 	// Fiat-Shamir heuristic
-	proof_out.c, _ = HashSet(proof_out.a, proof_out.D)
-	proof_out.c = Mod(proof_out.c, bn256.Order)
-
-	proof_out.zr = Sub(proof_out.m, Multiply(r, proof_out.c))
-	proof_out.zr = Mod(proof_out.zr, bn256.Order)
-	proof_out.zsig = Sub(proof_out.s, Multiply(new(big.Int).SetInt64(x), proof_out.c))
-	proof_out.zsig = Mod(proof_out.zsig, bn256.Order)
-	proof_out.zv = Sub(proof_out.t, Multiply(v, proof_out.c))
-	proof_out.zv = Mod(proof_out.zv, bn256.Order)
+	proof_out.c = challengeSet(transcript, &proof_out, &p)
+
+	proof_out.zr = response(proof_out.m, BN254.NewScalar().SetBigInt(r), proof_out.c)
+	proof_out.zsig = response(proof_out.s, BN254.NewScalar().SetInt64(x), proof_out.c)
+	proof_out.zv = response(proof_out.t, v, proof_out.c)
 	return proof_out, nil
 }
 
+/*
+response returns k - x.c, a scalar of BN254.
+*/
+func response(k, x, c Scalar) Scalar {
+	t := BN254.NewScalar().Mul(x, c)
+	return t.Sub(k, t)
+}
+
 /*
 ProveUL method is used to produce the ZKRP proof that secret x belongs to the interval [0,U^L].
+The signatures of the digits of x are selected by reading all of them, and the secrets are
+exponentiated with the same sequence of operations whatever their value, but the arithmetic
+of bn256 is not constant time, so neither is ProveUL.
 */
-func ProveUL(x, r *big.Int, p paramsUL) (proofUL, error) {
+func ProveUL(transcript *Transcript, x, r *big.Int, p paramsUL) (proofUL, error) {
 	var (
 		i         int64
-		v         []*big.Int
+		v         []Scalar
 		proof_out proofUL
 	)
 	decx, _ := Decompose(x, p.u, p.l)
 
 	// Initialize variables
-	v = make([]*big.Int, p.l, p.l)
+	v = make([]Scalar, p.l, p.l)
 	proof_out.V = make([]*bn256.G2, p.l, p.l)
 	proof_out.a = make([]*bn256.GT, p.l, p.l)
-	proof_out.s = make([]*big.Int, p.l, p.l)
-	proof_out.t = make([]*big.Int, p.l, p.l)
-	proof_out.zsig = make([]*big.Int, p.l, p.l)
-	proof_out.zv = make([]*big.Int, p.l, p.l)
-	proof_out.D = new(bn256.G2)
-	// proof_out.D.SetInfinity()
-	_, _, epz, _ := proof_out.D.CurvePoints()
-	epz.SetZero()
-	proof_out.m, _ = rand.Int(rand.Reader, bn256.Order)
-
-	// D = H^m
-	D := new(bn256.G2).ScalarMult(p.H, proof_out.m)
-	for i = 0; i < p.l; i++ {
-		v[i], _ = rand.Int(rand.Reader, bn256.Order)
-		A, ok := p.signatures[strconv.FormatInt(decx[i], 10)]
-		if ok {
-			proof_out.V[i] = new(bn256.G2).ScalarMult(A, v[i])
-			proof_out.s[i], _ = rand.Int(rand.Reader, bn256.Order)
-			proof_out.t[i], _ = rand.Int(rand.Reader, bn256.Order)
-			proof_out.a[i] = bn256.Pair(G1, proof_out.V[i])
-			proof_out.a[i].ScalarMult(proof_out.a[i], proof_out.s[i])
-			proof_out.a[i].Neg(proof_out.a[i])
-			proof_out.a[i].Add(proof_out.a[i], new(bn256.GT).ScalarMult(E, proof_out.t[i]))
-
-			ui := new(big.Int).Exp(new(big.Int).SetInt64(p.u), new(big.Int).SetInt64(i), nil)
-			muisi := new(big.Int).Mul(proof_out.s[i], ui)
-			muisi = Mod(muisi, bn256.Order)
-			aux := new(bn256.G2).ScalarBaseMult(muisi)
-			D.Add(D, aux)
-		} else {
+	proof_out.s = make([]Scalar, p.l, p.l)
+	proof_out.t = make([]Scalar, p.l, p.l)
+	proof_out.zsig = make([]Scalar, p.l, p.l)
+	proof_out.zv = make([]Scalar, p.l, p.l)
+	proof_out.m = BN254.NewScalar().SetRandom()
+
+	// the signatures of all the digits, to select the ones of x in constant time
+	signatures := make([][]byte, p.u)
+	for i = 0; i < p.u; i++ {
+		A, ok := p.signatures[strconv.FormatInt(i, 10)]
+		if !ok {
 			return proof_out, errors.New("Could not generate proof. Element does not belong to the interval.")
 		}
+		// Marshal normalizes its receiver, which is shared
+		signatures[i] = new(bn256.G2).ScalarMult(A, big.NewInt(1)).Marshal()
 	}
-	proof_out.D.Add(proof_out.D, D)
+	for i = 0; i < p.l; i++ {
+		v[i] = BN254.NewScalar().SetRandom()
+		A := g2FromBytes(ctSelect(signatures, uint64(decx[i])+1))
+		proof_out.V[i] = regularMultiScalarMultG2([]*bn256.G2{A}, []*big.Int{v[i].BigInt()})
+		proof_out.s[i] = BN254.NewScalar().SetRandom()
+		proof_out.t[i] = BN254.NewScalar().SetRandom()
+		proof_out.a[i] = regularExpGT(bn256.Pair(G1, proof_out.V[i]), proof_out.s[i].BigInt())
+		proof_out.a[i].Neg(proof_out.a[i])
+		proof_out.a[i].Add(proof_out.a[i], regularExpGT(E, proof_out.t[i].BigInt()))
+	}
+	// D = H^m.g^(sum(s_i.u^i))
+	ui := scalarPowers(BN254, BN254.NewScalar().SetInt64(p.u), p.l)
+	sum := innerProduct(BN254, proof_out.s, ui)
+	proof_out.D = regularMultiScalarMultG2([]*bn256.G2{p.H, G2}, []*big.Int{proof_out.m.BigInt(), sum.BigInt()})
 
 	// Consider passing C as input,
 	// so that it is possible to delegate the commitment computation to an external party.
 	proof_out.C, _ = Commit(x, r, p.H)
 	// Fiat-Shamir heuristic
-	proof_out.c, _ = Hash(proof_out.a, proof_out.D)
-	proof_out.c = Mod(proof_out.c, bn256.Order)
+	proof_out.c = challengeUL(transcript, &proof_out, &p)
 
-	proof_out.zr = Sub(proof_out.m, Multiply(r, proof_out.c))
-	proof_out.zr = Mod(proof_out.zr, bn256.Order)
+	proof_out.zr = response(proof_out.m, BN254.NewScalar().SetBigInt(r), proof_out.c)
 	for i = 0; i < p.l; i++ {
-		proof_out.zsig[i] = Sub(proof_out.s[i], Multiply(new(big.Int).SetInt64(decx[i]), proof_out.c))
-		proof_out.zsig[i] = Mod(proof_out.zsig[i], bn256.Order)
-		proof_out.zv[i] = Sub(proof_out.t[i], Multiply(v[i], proof_out.c))
-		proof_out.zv[i] = Mod(proof_out.zv[i], bn256.Order)
+		proof_out.zsig[i] = response(proof_out.s[i], BN254.NewScalar().SetInt64(decx[i]), proof_out.c)
+		proof_out.zv[i] = response(proof_out.t[i], v[i], proof_out.c)
 	}
 	return proof_out, nil
 }
@@ -262,16 +299,20 @@ func ProveUL(x, r *big.Int, p paramsUL) (proofUL, error) {
 /*
 VerifySet is used to validate the ZK Set Membership proof. It returns true iff the proof is valid.
 */
-func VerifySet(proof_out *proofSet, p *paramsSet) (bool, error) {
+func VerifySet(transcript *Transcript, proof_out *proofSet, p *paramsSet) (bool, error) {
 	var (
 		D      *bn256.G2
 		r1, r2 bool
 		p1, p2 *bn256.GT
 	)
+	// c == Hash(transcript, C, V, a, D) ?
+	if !challengeSet(transcript, proof_out, p).Equal(proof_out.c) {
+		return false, nil
+	}
 	// D == C^c.h^ zr.g^zsig ?
-	D = new(bn256.G2).ScalarMult(proof_out.C, proof_out.c)
-	D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.zr))
-	aux := new(bn256.G2).ScalarBaseMult(proof_out.zsig)
+	D = new(bn256.G2).ScalarMult(proof_out.C, proof_out.c.BigInt())
+	D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.zr.BigInt()))
+	aux := new(bn256.G2).ScalarBaseMult(proof_out.zsig.BigInt())
 	D.Add(D, aux)
 
 	DBytes := D.Marshal()
@@ -281,12 +322,12 @@ func VerifySet(proof_out *proofSet, p *paramsSet) (bool, error) {
 	r2 = true
 	// a == [e(V,y)^c].[e(V,g)^-zsig].[e(g,g)^zv]
 	p1 = bn256.Pair(p.kp.pubk, proof_out.V)
-	p1.ScalarMult(p1, proof_out.c)
+	p1.ScalarMult(p1, proof_out.c.BigInt())
 	p2 = bn256.Pair(G1, proof_out.V)
-	p2.ScalarMult(p2, proof_out.zsig)
+	p2.ScalarMult(p2, proof_out.zsig.BigInt())
 	p2.Neg(p2)
 	p1.Add(p1, p2)
-	p1.Add(p1, new(bn256.GT).ScalarMult(E, proof_out.zv))
+	p1.Add(p1, new(bn256.GT).ScalarMult(E, proof_out.zv.BigInt()))
 
 	pBytes := p1.Marshal()
 	aBytes := proof_out.a.Marshal()
@@ -297,23 +338,26 @@ func VerifySet(proof_out *proofSet, p *paramsSet) (bool, error) {
 /*
 VerifyUL is used to validate the ZKRP proof. It returns true iff the proof is valid.
 */
-func VerifyUL(proof_out *proofUL, p *paramsUL) (bool, error) {
+func VerifyUL(transcript *Transcript, proof_out *proofUL, p *paramsUL) (bool, error) {
 	var (
 		i      int64
 		D      *bn256.G2
 		r1, r2 bool
 		p1, p2 *bn256.GT
 	)
-	// D == C^c.h^ zr.g^zsig ?
-	D = new(bn256.G2).ScalarMult(proof_out.C, proof_out.c)
-	D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.zr))
-	for i = 0; i < p.l; i++ {
-		ui := new(big.Int).Exp(new(big.Int).SetInt64(p.u), new(big.Int).SetInt64(i), nil)
-		muizsigi := new(big.Int).Mul(proof_out.zsig[i], ui)
-		muizsigi = Mod(muizsigi, bn256.Order)
-		aux := new(bn256.G2).ScalarBaseMult(muizsigi)
-		D.Add(D, aux)
+	if int64(len(proof_out.V)) != p.l || int64(len(proof_out.a)) != p.l ||
+		int64(len(proof_out.zsig)) != p.l || int64(len(proof_out.zv)) != p.l {
+		return false, errors.New("proof must have l signatures")
+	}
+	// c == Hash(transcript, C, V, a, D) ?
+	if !challengeUL(transcript, proof_out, p).Equal(proof_out.c) {
+		return false, nil
 	}
+	// D == C^c.h^ zr.g^(sum(zsig_i.u^i)) ?
+	D = new(bn256.G2).ScalarMult(proof_out.C, proof_out.c.BigInt())
+	D.Add(D, new(bn256.G2).ScalarMult(p.H, proof_out.zr.BigInt()))
+	ui := scalarPowers(BN254, BN254.NewScalar().SetInt64(p.u), p.l)
+	D.Add(D, new(bn256.G2).ScalarBaseMult(innerProduct(BN254, proof_out.zsig, ui).BigInt()))
 
 	DBytes := D.Marshal()
 	pDBytes := proof_out.D.Marshal()
@@ -323,12 +367,12 @@ func VerifyUL(proof_out *proofUL, p *paramsUL) (bool, error) {
 	for i = 0; i < p.l; i++ {
 		// a == [e(V,y)^c].[e(V,g)^-zsig].[e(g,g)^zv]
 		p1 = bn256.Pair(p.kp.pubk, proof_out.V[i])
-		p1.ScalarMult(p1, proof_out.c)
+		p1.ScalarMult(p1, proof_out.c.BigInt())
 		p2 = bn256.Pair(G1, proof_out.V[i])
-		p2.ScalarMult(p2, proof_out.zsig[i])
+		p2.ScalarMult(p2, proof_out.zsig[i].BigInt())
 		p2.Neg(p2)
 		p1.Add(p1, p2)
-		p1.Add(p1, new(bn256.GT).ScalarMult(E, proof_out.zv[i]))
+		p1.Add(p1, new(bn256.GT).ScalarMult(E, proof_out.zv[i].BigInt()))
 
 		pBytes := p1.Marshal()
 		aBytes := proof_out.a[i].Marshal()
@@ -410,11 +454,12 @@ func (zkrp *ccs08) Prove() error {
 	// x - b + ul
 	xb := new(big.Int).Sub(zkrp.x, new(big.Int).SetInt64(zkrp.p.b))
 	xb.Add(xb, ul)
-	first, _ := ProveUL(xb, zkrp.r, *zkrp.p.p)
+	transcript := NewTranscript(DOMAIN)
+	first, _ := ProveUL(transcript, xb, zkrp.r, *zkrp.p.p)
 
 	// x - a
 	xa := new(big.Int).Sub(zkrp.x, new(big.Int).SetInt64(zkrp.p.a))
-	second, _ := ProveUL(xa, zkrp.r, *zkrp.p.p)
+	second, _ := ProveUL(transcript, xa, zkrp.r, *zkrp.p.p)
 
 	zkrp.proof_out.p1 = first
 	zkrp.proof_out.p2 = second
@@ -425,7 +470,8 @@ func (zkrp *ccs08) Prove() error {
 Verify is responsible for validating the proof.
 */
 func (zkrp *ccs08) Verify() (bool, error) {
-	first, _ := VerifyUL(&zkrp.proof_out.p1, zkrp.p.p)
-	second, _ := VerifyUL(&zkrp.proof_out.p2, zkrp.p.p)
+	transcript := NewTranscript(DOMAIN)
+	first, _ := VerifyUL(transcript, &zkrp.proof_out.p1, zkrp.p.p)
+	second, _ := VerifyUL(transcript, &zkrp.proof_out.p2, zkrp.p.p)
 	return first && second, nil
 }
@@ -99,8 +99,8 @@ func TestZKRP_UL(t *testing.T) {
 	)
 	p, _ := SetupUL(10, 5)
 	r, _ = rand.Int(rand.Reader, bn256.Order)
-	proof_out, _ := ProveUL(new(big.Int).SetInt64(42176), r, p)
-	result, _ := VerifyUL(&proof_out, &p)
+	proof_out, _ := ProveUL(NewTranscript("test"), new(big.Int).SetInt64(42176), r, p)
+	result, _ := VerifyUL(NewTranscript("test"), &proof_out, &p)
 	fmt.Println("ZKRP UL result: ")
 	fmt.Println(result)
 	if result != true {
@@ -141,11 +141,11 @@ func TestZKSet(t *testing.T) {
 	fmt.Println(" ############### Setup time:")
 	fmt.Println(setupTime.Sub(startTime))
 	r, _ = rand.Int(rand.Reader, bn256.Order)
-	proof_out, _ := ProveSet(12, r, p)
+	proof_out, _ := ProveSet(NewTranscript("test"), 12, r, p)
 	proofTime := time.Now()
 	fmt.Println("Proof time:")
 	fmt.Println(proofTime.Sub(setupTime))
-	result, _ := VerifySet(&proof_out, &p)
+	result, _ := VerifySet(NewTranscript("test"), &proof_out, &p)
 	verifyTime := time.Now()
 	fmt.Println("Verify time:")
 	fmt.Println(verifyTime.Sub(proofTime))
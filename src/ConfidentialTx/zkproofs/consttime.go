@@ -0,0 +1,567 @@
+/*
+This file contains the constant-time scalar multiplications used on secret scalars,
+such as the blinding factors and the bits of the committed values. The scalars are
+read as fixed-size limbs, every window of 4 or 6 bits costs the same additions
+whatever its value, and the multiple of the point is selected by scanning the whole
+table with masks instead of indexing it. The additions use the complete formulas of
+Renes, Costello and Batina (Algorithms 7 and 9 of "Complete addition formulas for
+prime order elliptic curves", 2016) in projective coordinates (X:Y:Z), which stand
+for the affine point (X/Z, Y/Z), so that the point at infinity (0:1:0) and the
+doublings need no branch either.
+
+The scalars given to these functions are converted to limbs with scalarLimbs, a
+Montgomery multiplication whose time does not depend on them. The big.Int scalars of
+bn256 are converted with ctLimbs, which depends on their number of words but not on
+their bits.
+
+The steps of the scalar multiplications, that is the group operations and the reads of
+the tables, are reported to ctTrace when it is set, which the tests use to check that
+they are the same for all the scalars.
+
+The regular scalar multiplications of bn256 follow the same sequence of group
+operations for every scalar, with tables of marshaled points read by
+subtle.ConstantTimeCopy, but run on the group law and field arithmetic of the library,
+which are not constant time. They are not constant time either, and neither is ccs08,
+which is built on them: it is outside the constant-time guarantees of this package.
+*/
+
+package zkproofs
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/google"
+)
+
+var (
+	// fieldB3 = 3.b, with b = 7
+	fieldB3 = fieldElement{21, 0, 0, 0}
+	// ctTrace gets every step of the constant-time scalar multiplications if it is not
+	// nil: "double" and "add" for the group operations and "read" with the position of
+	// every entry of a table that is read. Only the tests set it.
+	ctTrace func(step string, j int)
+)
+
+/*
+ctStep reports a step to ctTrace.
+*/
+func ctStep(step string, j int) {
+	if ctTrace != nil {
+		ctTrace(step, j)
+	}
+}
+
+/*
+ctMask returns 2^64 - 1 if bit is 1 and 0 if bit is 0.
+*/
+func ctMask(bit uint64) uint64 {
+	return -bit
+}
+
+/*
+ctEqual returns 2^64 - 1 if a = b and 0 otherwise.
+*/
+func ctEqual(a, b uint64) uint64 {
+	x := a ^ b
+	return ctMask(((x | -x) >> 63) ^ 1)
+}
+
+/*
+cmov sets z = a if mask is 2^64 - 1 and leaves z unchanged if mask is 0.
+*/
+func (z *fieldElement) cmov(a *fieldElement, mask uint64) {
+	z[0] = z[0]&^mask | a[0]&mask
+	z[1] = z[1]&^mask | a[1]&mask
+	z[2] = z[2]&^mask | a[2]&mask
+	z[3] = z[3]&^mask | a[3]&mask
+}
+
+/*
+projectivePoint is a point of secp256k1 in projective coordinates. The point at
+infinity is (0:1:0).
+*/
+type projectivePoint struct {
+	X, Y, Z fieldElement
+}
+
+var (
+	projectiveInfinity = projectivePoint{Y: fieldOne}
+)
+
+/*
+setJacobian sets p to the Jacobian point a, as (X.Z : Y : Z^3).
+*/
+func (p *projectivePoint) setJacobian(a *jacobianPoint) *projectivePoint {
+	var (
+		z2 fieldElement
+	)
+	z2.square(&a.Z)
+	p.X.mul(&a.X, &a.Z)
+	p.Y = a.Y
+	p.Z.mul(&z2, &a.Z)
+	// Z = 0 is the point at infinity, whose Y must not be 0
+	p.Y.cmov(&fieldOne, ctEqual(a.Z[0]|a.Z[1]|a.Z[2]|a.Z[3], 0))
+	return p
+}
+
+/*
+jacobian returns p in Jacobian coordinates, as (X.Z, Y.Z^2, Z).
+*/
+func (p *projectivePoint) jacobian() jacobianPoint {
+	var (
+		r  jacobianPoint
+		z2 fieldElement
+	)
+	z2.square(&p.Z)
+	r.X.mul(&p.X, &p.Z)
+	r.Y.mul(&p.Y, &z2)
+	r.Z = p.Z
+	return r
+}
+
+func (p *projectivePoint) cmov(a *projectivePoint, mask uint64) {
+	p.X.cmov(&a.X, mask)
+	p.Y.cmov(&a.Y, mask)
+	p.Z.cmov(&a.Z, mask)
+}
+
+/*
+add sets p = a + b with the complete formulas of Algorithm 7, valid for all inputs.
+*/
+func (p *projectivePoint) add(a, b *projectivePoint) *projectivePoint {
+	var (
+		t0, t1, t2, t3, t4, x3, y3, z3 fieldElement
+	)
+	t0.mul(&a.X, &b.X)
+	t1.mul(&a.Y, &b.Y)
+	t2.mul(&a.Z, &b.Z)
+	t3.add(&a.X, &a.Y)
+	t4.add(&b.X, &b.Y)
+	t3.mul(&t3, &t4)
+	t4.add(&t0, &t1)
+	t3.sub(&t3, &t4)
+	t4.add(&a.Y, &a.Z)
+	x3.add(&b.Y, &b.Z)
+	t4.mul(&t4, &x3)
+	x3.add(&t1, &t2)
+	t4.sub(&t4, &x3)
+	x3.add(&a.X, &a.Z)
+	y3.add(&b.X, &b.Z)
+	x3.mul(&x3, &y3)
+	y3.add(&t0, &t2)
+	y3.sub(&x3, &y3)
+	x3.add(&t0, &t0)
+	t0.add(&x3, &t0)
+	t2.mul(&fieldB3, &t2)
+	z3.add(&t1, &t2)
+	t1.sub(&t1, &t2)
+	y3.mul(&fieldB3, &y3)
+	x3.mul(&t4, &y3)
+	t2.mul(&t3, &t1)
+	x3.sub(&t2, &x3)
+	y3.mul(&y3, &t0)
+	t1.mul(&t1, &z3)
+	y3.add(&t1, &y3)
+	t0.mul(&t0, &t3)
+	z3.mul(&z3, &t4)
+	z3.add(&z3, &t0)
+	p.X, p.Y, p.Z = x3, y3, z3
+	return p
+}
+
+/*
+double sets p = 2a with the formulas of Algorithm 9.
+*/
+func (p *projectivePoint) double(a *projectivePoint) *projectivePoint {
+	var (
+		t0, t1, t2, x3, y3, z3 fieldElement
+	)
+	t0.square(&a.Y)
+	z3.add(&t0, &t0)
+	z3.add(&z3, &z3)
+	z3.add(&z3, &z3)
+	t1.mul(&a.Y, &a.Z)
+	t2.square(&a.Z)
+	t2.mul(&fieldB3, &t2)
+	x3.mul(&t2, &z3)
+	y3.add(&t0, &t2)
+	z3.mul(&t1, &z3)
+	t1.add(&t2, &t2)
+	t2.add(&t1, &t2)
+	t0.sub(&t0, &t2)
+	y3.mul(&t0, &y3)
+	y3.add(&x3, &y3)
+	t1.mul(&a.X, &a.Y)
+	x3.mul(&t0, &t1)
+	x3.add(&x3, &x3)
+	p.X, p.Y, p.Z = x3, y3, z3
+	return p
+}
+
+/*
+ctLimbs returns k mod order as little endian limbs, for an order of at most 256 bits.
+The reduction of k in [0, 2^256) and of negative values is done with masks, only
+values of more than 256 bits are reduced with big.Int first.
+*/
+func ctLimbs(k, order *big.Int) [4]uint64 {
+	var (
+		buf     [32]byte
+		x, o, y [4]uint64
+	)
+	if k.BitLen() > 256 {
+		k = new(big.Int).Mod(k, order)
+	}
+	new(big.Int).Abs(k).FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		x[i] = binary.BigEndian.Uint64(buf[24-8*i:])
+	}
+	order.FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		o[i] = binary.BigEndian.Uint64(buf[24-8*i:])
+	}
+	// 2^256 / order subtractions reduce x below order
+	reps := new(big.Int).Div(new(big.Int).Lsh(big.NewInt(1), 256), order).Int64()
+	for i := int64(0); i < reps; i++ {
+		x = ctSubIfGreater(x, o)
+	}
+	// -x = order - x, which is order if x = 0
+	y = ctSub(o, x)
+	y = ctSubIfGreater(y, o)
+	mask := ctMask(uint64(k.Sign()>>1) & 1)
+	for i := range x {
+		x[i] = x[i]&^mask | y[i]&mask
+	}
+	return x
+}
+
+/*
+ctSub returns a - b mod 2^256.
+*/
+func ctSub(a, b [4]uint64) [4]uint64 {
+	var (
+		r [4]uint64
+		c uint64
+	)
+	r[0], c = bits.Sub64(a[0], b[0], 0)
+	r[1], c = bits.Sub64(a[1], b[1], c)
+	r[2], c = bits.Sub64(a[2], b[2], c)
+	r[3], _ = bits.Sub64(a[3], b[3], c)
+	return r
+}
+
+/*
+ctSubIfGreater returns x - o if x >= o and x otherwise.
+*/
+func ctSubIfGreater(x, o [4]uint64) [4]uint64 {
+	var (
+		r [4]uint64
+		c uint64
+	)
+	r[0], c = bits.Sub64(x[0], o[0], 0)
+	r[1], c = bits.Sub64(x[1], o[1], c)
+	r[2], c = bits.Sub64(x[2], o[2], c)
+	r[3], c = bits.Sub64(x[3], o[3], c)
+	// keep x on borrow
+	mask := ctMask(c)
+	for i := range r {
+		r[i] = r[i]&^mask | x[i]&mask
+	}
+	return r
+}
+
+/*
+ctLookup returns the entry w of the table, reading all of them.
+*/
+func ctLookup(table []projectivePoint, w uint64) projectivePoint {
+	var (
+		r projectivePoint
+	)
+	for j := range table {
+		ctStep("read", j)
+		r.cmov(&table[j], ctEqual(w, uint64(j)))
+	}
+	return r
+}
+
+/*
+ctTable returns the multiples 0.P, ..., 15.P of the Jacobian point p.
+*/
+func ctTable(p *jacobianPoint) [1 << strausWindow]projectivePoint {
+	var (
+		table [1 << strausWindow]projectivePoint
+	)
+	table[0] = projectiveInfinity
+	table[1].setJacobian(p)
+	for j := 2; j < len(table); j++ {
+		table[j].add(&table[j-1], &table[1])
+	}
+	return table
+}
+
+/*
+ctScalarMult returns k.a in constant time, with a fixed window of 4 bits: 256
+doublings and 64 additions of a multiple read from a table of 16.
+*/
+func ctScalarMult(a *p256, k Scalar) *p256 {
+	return ctMultiScalarMult([]*p256{a}, []Scalar{k})
+}
+
+/*
+ctMultiScalarMult returns prod_i a_i^(b_i) in constant time with respect to the
+scalars, with Straus' method: the doublings are shared and every window of every
+scalar costs one addition. Terms with a zero scalar are not skipped.
+*/
+func ctMultiScalarMult(a []*p256, b []Scalar) *p256 {
+	var (
+		acc projectivePoint
+		sel projectivePoint
+	)
+	tables := make([][1 << strausWindow]projectivePoint, len(a))
+	scalars := make([][4]uint64, len(a))
+	for t := range a {
+		tables[t] = ctTable(&a[t].jac)
+		scalars[t] = scalarLimbs(b[t])
+	}
+	acc = projectiveInfinity
+	for i := 256/strausWindow - 1; i >= 0; i-- {
+		for d := 0; d < strausWindow; d++ {
+			ctStep("double", d)
+			acc.double(&acc)
+		}
+		for t := range tables {
+			w := window(&scalars[t], uint(i*strausWindow), strausWindow)
+			sel = ctLookup(tables[t][:], w)
+			ctStep("add", t)
+			acc.add(&acc, &sel)
+		}
+	}
+	return &p256{jac: acc.jacobian()}
+}
+
+/*
+ctMult returns k.P in constant time, where P is the base of the table. Every window
+selects its entry, or the point at infinity for a zero window, by reading the whole
+row of the table.
+*/
+func (table *fixedBaseTable) ctMult(k Scalar) *p256 {
+	var (
+		acc, sel, entry projectivePoint
+	)
+	scalar := scalarLimbs(k)
+	acc = projectiveInfinity
+	for i := range table {
+		w := window(&scalar, uint(i*fixedWindow), fixedWindow)
+		sel = projectiveInfinity
+		for j := range table[i] {
+			// the entries are normalized, so their projective coordinates are (x : y : 1)
+			ctStep("read", j)
+			entry = projectivePoint{X: table[i][j].X, Y: table[i][j].Y, Z: fieldOne}
+			sel.cmov(&entry, ctEqual(w, uint64(j+1)))
+		}
+		ctStep("add", i)
+		acc.add(&acc, &sel)
+	}
+	return &p256{jac: acc.jacobian()}
+}
+
+/*
+ctScalarBaseMult returns k.G in constant time.
+*/
+func ctScalarBaseMult(k Scalar) *p256 {
+	return baseTable().ctMult(k)
+}
+
+/*
+ctScalarMultBase returns k.h in constant time, with the table of h if it is a fixed
+base.
+*/
+func ctScalarMultBase(h *p256, k Scalar) *p256 {
+	if table := fixedBaseTableOf(h); table != nil {
+		return table.ctMult(k)
+	}
+	return ctScalarMult(h, k)
+}
+
+/*
+ctVectorExp computes Prod_i^n{a[i]^b[i]} with the constant-time multi-scalar
+multiplication of the group of the points, split among the workers of the pool like
+vectorExp. The chunks only depend on n. The vectors must not be empty.
+*/
+func ctVectorExp(pool *Pool, a []Element, b []Scalar) Element {
+	var (
+		i int64
+	)
+	group := a[0].Group()
+	n := int64(len(a))
+	w := pool.workers(n)
+	partial := make([]Element, w)
+	pool.parallelFor(n, w, func(chunk, lo, hi int64) {
+		partial[chunk] = group.ConstantTimeMultiScalarMult(a[lo:hi], b[lo:hi])
+	})
+	result := group.NewElement()
+	i = 0
+	for i < w {
+		result.Add(result, partial[i])
+		i = i + 1
+	}
+	return result
+}
+
+/*
+ctDivSmall divides the little endian limbs x by u in place and returns the remainder,
+with a binary long division whose steps do not depend on x.
+*/
+func ctDivSmall(x []uint64, u uint64) uint64 {
+	var (
+		r uint64
+	)
+	for i := len(x) - 1; i >= 0; i-- {
+		var q uint64
+		for b := 63; b >= 0; b-- {
+			r = r<<1 | (x[i]>>uint(b))&1
+			// r < 2u, subtract u if r >= u
+			d, borrow := bits.Sub64(r, u, 0)
+			mask := ctMask(borrow)
+			r = d&^mask | r&mask
+			q = q<<1 | (borrow ^ 1)
+		}
+		x[i] = q
+	}
+	return r
+}
+
+var (
+	// ctDigitOffset = sum(16^i) for i < 64
+	ctDigitOffset = [4]uint64{0x1111111111111111, 0x1111111111111111, 0x1111111111111111, 0x1111111111111111}
+)
+
+/*
+ctRecode returns the 64 digits in [1, 16] of a representative of k mod bn256.Order,
+the digit of 16^i at index i. It writes k = u + sum(16^i) with u = k - sum(16^i) +
+order mod 2.order, so that no digit selects the identity and the top digit, of u in
+[order, 2.order), is at least 4: the partial sums of the windowed multiplication are
+then distinct from the entries of the table, but at the last window.
+*/
+func ctRecode(k *big.Int) [64]uint64 {
+	var (
+		buf     [32]byte
+		o, u    [4]uint64
+		d       [64]uint64
+		c, mask uint64
+	)
+	bn256.Order.FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		o[i] = binary.BigEndian.Uint64(buf[24-8*i:])
+	}
+	x := ctLimbs(k, bn256.Order)
+	u[0], c = bits.Sub64(x[0], ctDigitOffset[0], 0)
+	u[1], c = bits.Sub64(x[1], ctDigitOffset[1], c)
+	u[2], c = bits.Sub64(x[2], ctDigitOffset[2], c)
+	u[3], c = bits.Sub64(x[3], ctDigitOffset[3], c)
+	// add the order twice on borrow, the sum is below 2^256
+	mask = ctMask(c)
+	u[0], c = bits.Add64(u[0], o[0]&mask, 0)
+	u[1], c = bits.Add64(u[1], o[1]&mask, c)
+	u[2], c = bits.Add64(u[2], o[2]&mask, c)
+	u[3], _ = bits.Add64(u[3], o[3]&mask, c)
+	u[0], c = bits.Add64(u[0], o[0], 0)
+	u[1], c = bits.Add64(u[1], o[1], c)
+	u[2], c = bits.Add64(u[2], o[2], c)
+	u[3], _ = bits.Add64(u[3], o[3], c)
+	for i := range d {
+		d[i] = (u[i/16]>>(4*uint(i%16)))&15 + 1
+	}
+	return d
+}
+
+/*
+ctSelect returns a copy of entries[d - 1], reading all the entries.
+*/
+func ctSelect(entries [][]byte, d uint64) []byte {
+	out := make([]byte, len(entries[0]))
+	for j := range entries {
+		subtle.ConstantTimeCopy(int(ctEqual(d, uint64(j+1))&1), out, entries[j])
+	}
+	return out
+}
+
+/*
+g2FromBytes returns the point marshaled in m by G2.Marshal, which must not be the
+point at infinity. Unlike Unmarshal, it checks neither that the point is on the curve
+nor that it is in G2, which costs a scalar multiplication by the order, so m must come
+from a point of G2.
+*/
+func g2FromBytes(m []byte) *bn256.G2 {
+	e := new(bn256.G2).ScalarBaseMult(new(big.Int))
+	x, y, z, t := e.CurvePoints()
+	x.Real().SetBytes(m[0:32])
+	x.Imag().SetBytes(m[32:64])
+	y.Real().SetBytes(m[64:96])
+	y.Imag().SetBytes(m[96:128])
+	z.SetOne()
+	t.SetOne()
+	return e
+}
+
+/*
+regularMultiScalarMultG2 returns sum_i k_i.a_i on the twist of bn256, with the same
+sequence of operations for all the scalars: 4 doublings per window and one addition
+per window and point, of an entry of a table selected by ctSelect. The group law of
+the library branches on the point at infinity and on doublings, which do not happen
+for the recoded digits of ctRecode unless the result is a small multiple of one of
+the points, i.e. with negligible probability if one of the scalars is uniformly
+random. The points must not be small multiples of each other. The field arithmetic
+is the library's, so the multiplication is not constant time.
+*/
+func regularMultiScalarMultG2(a []*bn256.G2, k []*big.Int) *bn256.G2 {
+	sixteen := big.NewInt(16)
+	tables := make([][][]byte, len(a))
+	digits := make([][64]uint64, len(a))
+	for t := range a {
+		tables[t] = make([][]byte, 16)
+		for j := range tables[t] {
+			tables[t][j] = new(bn256.G2).ScalarMult(a[t], big.NewInt(int64(j+1))).Marshal()
+		}
+		digits[t] = ctRecode(k[t])
+	}
+	acc := g2FromBytes(ctSelect(tables[0], digits[0][63]))
+	for i := 63; i >= 0; i-- {
+		if i < 63 {
+			acc.ScalarMult(acc, sixteen)
+		}
+		for t := range tables {
+			if i == 63 && t == 0 {
+				continue
+			}
+			acc.Add(acc, g2FromBytes(ctSelect(tables[t], digits[t][i])))
+		}
+	}
+	return acc
+}
+
+/*
+regularExpGT returns a^k in GT with the same sequence of squarings and multiplications
+for all the scalars, like regularMultiScalarMultG2. The field arithmetic is the
+library's, so the exponentiation is not constant time.
+*/
+func regularExpGT(a *bn256.GT, k *big.Int) *bn256.GT {
+	var (
+		acc, e bn256.GT
+	)
+	sixteen := big.NewInt(16)
+	table := make([][]byte, 16)
+	for j := range table {
+		table[j] = new(bn256.GT).ScalarMult(a, big.NewInt(int64(j+1))).Marshal()
+	}
+	digits := ctRecode(k)
+	acc.Unmarshal(ctSelect(table, digits[63]))
+	for i := 62; i >= 0; i-- {
+		acc.ScalarMult(&acc, sixteen)
+		e.Unmarshal(ctSelect(table, digits[i]))
+		acc.Add(&acc, &e)
+	}
+	return &acc
+}
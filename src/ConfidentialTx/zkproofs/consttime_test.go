@@ -0,0 +1,317 @@
+package zkproofs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/google"
+)
+
+/*
+Test the complete projective formulas against the affine ones of secp256k1.
+*/
+func TestProjectivePoint(t *testing.T) {
+	var (
+		jp, jq, jn jacobianPoint
+		p, q, n    projectivePoint
+	)
+	k, _ := rand.Int(rand.Reader, ORDER)
+	px, py := CURVE.ScalarBaseMult(k.Bytes())
+	qx, qy := CURVE.ScalarBaseMult(big.NewInt(7).Bytes())
+	nx, ny := CURVE.ScalarBaseMult(Sub(ORDER, k).Bytes())
+	p.setJacobian(jp.setAffine(px, py))
+	q.setJacobian(jq.setAffine(qx, qy))
+	n.setJacobian(jn.setAffine(nx, ny))
+	// a point with Z != 1
+	p.double(&p)
+	px, py = CURVE.Double(px, py)
+	sumx, sumy := CURVE.Add(px, py, qx, qy)
+	dblx, dbly := CURVE.Double(px, py)
+	inf := projectiveInfinity
+	cases := []struct {
+		name   string
+		actual *projectivePoint
+		x, y   *big.Int
+	}{
+		{"P + Q", new(projectivePoint).add(&p, &q), sumx, sumy},
+		{"P + P", new(projectivePoint).add(&p, &p), dblx, dbly},
+		{"2P", new(projectivePoint).double(&p), dblx, dbly},
+		{"P + O", new(projectivePoint).add(&p, &inf), px, py},
+		{"O + O", new(projectivePoint).add(&inf, &inf), nil, nil},
+		{"2O", new(projectivePoint).double(&inf), nil, nil},
+		{"2N + 4N", new(projectivePoint).add(new(projectivePoint).double(&n), new(projectivePoint).double(new(projectivePoint).double(&n))), nil, nil},
+	}
+	cases[len(cases)-1].x, cases[len(cases)-1].y = CURVE.ScalarMult(nx, ny, big.NewInt(6).Bytes())
+	for _, c := range cases {
+		j := c.actual.jacobian()
+		x, y := j.affine()
+		if (x == nil) != (c.x == nil) || (x != nil && (x.Cmp(c.x) != 0 || y.Cmp(c.y) != 0)) {
+			t.Errorf("Assert failure for %s: expected (%v, %v), actual: (%v, %v)", c.name, c.x, c.y, x, y)
+		}
+	}
+	// P + -P = O
+	var r projectivePoint
+	var jm jacobianPoint
+	m := new(projectivePoint).setJacobian(jm.setAffine(CURVE.ScalarBaseMult(k.Bytes())))
+	j := r.add(m, &n).jacobian()
+	if !j.isInfinity() {
+		t.Errorf("Assert failure: expected the point at infinity")
+	}
+}
+
+/*
+Test the constant-time scalar multiplications against the variable-time ones.
+*/
+func TestConstantTimeScalarMult(t *testing.T) {
+	H := seedPoint(Secp256k1, SEEDH).(*p256)
+	P := new(p256).Multiply(H, H)
+	scalars := []*big.Int{new(big.Int), big.NewInt(1), big.NewInt(2), big.NewInt(-1), Sub(ORDER, big.NewInt(1)),
+		new(big.Int).Set(ORDER), Add(ORDER, big.NewInt(3)), new(big.Int).Lsh(big.NewInt(3), 300),
+		new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))}
+	for i := 0; i < 10; i++ {
+		k, _ := rand.Int(rand.Reader, ORDER)
+		scalars = append(scalars, k)
+	}
+	for _, k := range scalars {
+		s := Secp256k1.NewScalar().SetBigInt(k)
+		cases := []struct {
+			name             string
+			actual, expected Element
+		}{
+			{"ctScalarBaseMult", ctScalarBaseMult(s), new(p256).ScalarBaseMult(s)},
+			{"ctScalarMultBase", ctScalarMultBase(H, s), new(p256).ScalarMult(H, s)},
+			{"ctScalarMult", ctScalarMult(P, s), new(p256).ScalarMult(P, s)},
+			{"ctScalarMult(O)", ctScalarMult(new(p256).SetInfinity(), s), new(p256).SetInfinity()},
+		}
+		for _, c := range cases {
+			if !c.actual.Equal(c.expected) {
+				t.Errorf("Assert failure for %s and %s: expected %v, actual: %v", c.name, k, c.expected, c.actual)
+			}
+		}
+		limbs := fieldElement(ctLimbs(k, bn256.Order))
+		if limbs.big().Cmp(Mod(k, bn256.Order)) != 0 {
+			t.Errorf("Assert failure: wrong reduction of %s modulo the order of bn256", k)
+		}
+	}
+	a, b := randomTerms(20)
+	b[3] = Secp256k1.NewScalar()
+	b[4] = Secp256k1.NewScalar().SetInt64(-1)
+	if !ctMultiScalarMult(a, b).Equal(naiveMultiExp(a, b)) {
+		t.Errorf("Assert failure: wrong constant-time multi-scalar multiplication")
+	}
+}
+
+/*
+Test Decompose against the division of big.Int, for the bases of the range proofs.
+*/
+func TestDecomposeLimbs(t *testing.T) {
+	values := []*big.Int{new(big.Int), big.NewInt(1), big.NewInt(-1), big.NewInt(4294967295),
+		new(big.Int).Lsh(big.NewInt(1), 64), new(big.Int).Lsh(big.NewInt(7), 200)}
+	for i := 0; i < 10; i++ {
+		v, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 130))
+		values = append(values, v)
+	}
+	for _, x := range values {
+		for _, base := range [][2]int64{{2, 32}, {2, 64}, {10, 3}, {57, 5}, {1 << 40, 4}} {
+			u, l := base[0], base[1]
+			digits, err := Decompose(x, u, l)
+			y := new(big.Int).Set(x)
+			for i := int64(0); i < l; i++ {
+				expected := Mod(y, big.NewInt(u)).Int64()
+				y = new(big.Int).Div(y, big.NewInt(u))
+				if err != nil || digits[i] != expected {
+					t.Fatalf("Assert failure for %s in base %d: expected digit %d = %d, actual: %d", x, u, i, expected, digits[i])
+				}
+			}
+		}
+	}
+	if _, err := Decompose(big.NewInt(3), 1, 4); err == nil {
+		t.Errorf("Assert failure: expected an error for base 1")
+	}
+}
+
+/*
+Test the constant-time multiplications of bn256 against the ones of the library.
+*/
+func TestConstantTimeBN256(t *testing.T) {
+	h := new(bn256.G2).ScalarBaseMult(big.NewInt(12345))
+	scalars := []*big.Int{new(big.Int), big.NewInt(1), big.NewInt(2), big.NewInt(32), big.NewInt(-1),
+		Sub(bn256.Order, big.NewInt(1)), bn256.Order, new(big.Int).Lsh(big.NewInt(1), 300)}
+	for i := 0; i < 4; i++ {
+		k, _ := rand.Int(rand.Reader, bn256.Order)
+		scalars = append(scalars, k)
+	}
+	r, _ := rand.Int(rand.Reader, bn256.Order)
+	for _, k := range scalars {
+		digits := ctRecode(k)
+		sum := new(big.Int)
+		for i := 63; i >= 0; i-- {
+			if digits[i] < 1 || digits[i] > 16 {
+				t.Fatalf("Assert failure for %s: digit %d out of range: %d", k, i, digits[i])
+			}
+			sum.Lsh(sum, 4).Add(sum, new(big.Int).SetUint64(digits[i]))
+		}
+		if Mod(sum, bn256.Order).Cmp(Mod(k, bn256.Order)) != 0 {
+			t.Errorf("Assert failure for %s: the digits do not add up to the scalar", k)
+		}
+		kmod := Mod(k, bn256.Order)
+		C, _ := Commit(k, r, h)
+		expected := new(bn256.G2).ScalarBaseMult(kmod)
+		expected.Add(expected, new(bn256.G2).ScalarMult(h, r))
+		if !bytes.Equal(C.Marshal(), expected.Marshal()) {
+			t.Errorf("Assert failure for Commit(%s): expected %s, actual: %s", k, expected, C)
+		}
+		actual := regularMultiScalarMultG2([]*bn256.G2{h}, []*big.Int{k})
+		if !bytes.Equal(actual.Marshal(), new(bn256.G2).ScalarMult(h, kmod).Marshal()) {
+			t.Errorf("Assert failure for the G2 multiplication by %s", k)
+		}
+		gt := regularExpGT(E, k)
+		if !bytes.Equal(gt.Marshal(), new(bn256.GT).ScalarMult(E, kmod).Marshal()) {
+			t.Errorf("Assert failure for the GT exponentiation by %s", k)
+		}
+	}
+	entries := [][]byte{{1, 2}, {3, 4}, {5, 6}}
+	if !bytes.Equal(ctSelect(entries, 2), entries[1]) {
+		t.Errorf("Assert failure: expected the second entry")
+	}
+}
+
+/*
+timingRatio returns the ratio of the fastest runs of f on the secrets a and b. The
+runs are interleaved, and the fastest of many runs filters the noise of the host.
+*/
+func timingRatio(runs int, f func(secret *big.Int), a, b *big.Int) float64 {
+	ta := make([]time.Duration, runs)
+	tb := make([]time.Duration, runs)
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		f(a)
+		ta[i] = time.Since(start)
+		start = time.Now()
+		f(b)
+		tb[i] = time.Since(start)
+	}
+	sort.Slice(ta, func(i, j int) bool { return ta[i] < ta[j] })
+	sort.Slice(tb, func(i, j int) bool { return tb[i] < tb[j] })
+	// average of the fastest tenth
+	var sa, sb time.Duration
+	for i := 0; i < runs/10; i++ {
+		sa += ta[i]
+		sb += tb[i]
+	}
+	return float64(sa) / float64(sb)
+}
+
+/*
+Test that the constant-time scalar multiplications run the same group operations and
+read the same entries of their tables, in the same order, for all the scalars. Unlike
+the timing test, it is deterministic and always runs.
+*/
+func TestConstantTimeTrace(t *testing.T) {
+	var (
+		trace []string
+	)
+	ctTrace = func(step string, j int) {
+		trace = append(trace, step+strconv.Itoa(j))
+	}
+	defer func() { ctTrace = nil }()
+	forEachGroup(t, func(t *testing.T, group Group) {
+		if group == BN254 {
+			t.Skip("the ConstantTime methods of BN254 are best-effort")
+		}
+		r := group.NewScalar().SetRandom()
+		H := seedPoint(group, SEEDH)
+		g, h := generators(group, 4, "TestConstantTimeTrace")
+		secrets := []Scalar{group.NewScalar(), group.NewScalar().SetInt64(1), group.NewScalar().SetInt64(-1), r}
+		cases := []struct {
+			name string
+			f    func(k Scalar)
+		}{
+			{"ConstantTimeScalarMult", func(k Scalar) { group.ConstantTimeScalarMult(g[0], k) }},
+			{"ConstantTimeScalarBaseMult", func(k Scalar) { group.ConstantTimeScalarBaseMult(k) }},
+			{"ConstantTimeMultiScalarMult", func(k Scalar) {
+				group.ConstantTimeMultiScalarMult(g, []Scalar{k, r, k, group.NewScalar()})
+			}},
+			{"CommitG1", func(k Scalar) { CommitG1(k, r, H) }},
+			{"commitVector", func(k Scalar) {
+				bits := []Scalar{k, k, k, k}
+				commitVector(nil, bits, bits, k, H, g, h, 4)
+			}},
+		}
+		for _, c := range cases {
+			var expected []string
+			for i, k := range secrets {
+				trace = nil
+				c.f(k)
+				if i == 0 {
+					expected = trace
+					if len(expected) == 0 {
+						t.Fatalf("Assert failure for %s: expected steps", c.name)
+					}
+					continue
+				}
+				if strings.Join(trace, " ") != strings.Join(expected, " ") {
+					t.Errorf("Assert failure for %s: expected the same steps for %x as for 0", c.name, k.Bytes())
+				}
+			}
+		}
+	})
+}
+
+/*
+Test that the operations on secrets take the same time for secrets with few and
+many bits set, within the noise of the measurement. The variable-time scalar
+multiplications of secp256k1 take about twice as long for the second. Wall-clock
+ratios are not reliable on a shared host, so the test only runs with ZKPROOFS_TIMING=1
+set, on an otherwise idle machine.
+*/
+func TestConstantTimeTiming(t *testing.T) {
+	if os.Getenv("ZKPROOFS_TIMING") != "1" {
+		t.Skip("set ZKPROOFS_TIMING=1 to run the timing test")
+	}
+	H := seedPoint(Secp256k1, SEEDH).(*p256)
+	P := new(p256).Multiply(H, H)
+	g, h := generators(Secp256k1, 8, "TestConstantTimeTiming")
+	r := Secp256k1.NewScalar().SetRandom()
+	low := big.NewInt(1)
+	high := Sub(ORDER, big.NewInt(1))
+	scalars := map[*big.Int]Scalar{low: Secp256k1.NewScalar().SetBigInt(low), high: Secp256k1.NewScalar().SetBigInt(high)}
+	cases := []struct {
+		name string
+		runs int
+		f    func(secret *big.Int)
+	}{
+		{"ctScalarMult", 200, func(k *big.Int) { ctScalarMult(P, scalars[k]) }},
+		{"ctScalarBaseMult", 200, func(k *big.Int) { ctScalarBaseMult(scalars[k]) }},
+		{"CommitG1", 200, func(k *big.Int) { CommitG1(scalars[k], r, H) }},
+		{"commitVector", 200, func(k *big.Int) {
+			bits := make([]Scalar, 8)
+			for i := range bits {
+				bits[i] = scalars[k]
+			}
+			commitVector(nil, bits, bits, scalars[k], H, g, h, 8)
+		}},
+		{"Decompose", 200, func(k *big.Int) { Decompose(k, 2, 256) }},
+	}
+	for _, c := range cases {
+		// retry to tell a slow host from a leak
+		ok := false
+		for attempt := 0; attempt < 3 && !ok; attempt++ {
+			ratio := timingRatio(c.runs, c.f, low, high)
+			ok = ratio > 0.9 && ratio < 1.1
+			if !ok {
+				t.Logf("%s: ratio of the times for 1 and ORDER - 1: %.3f", c.name, ratio)
+			}
+		}
+		if !ok {
+			t.Errorf("Assert failure for %s: expected the same time for all the secrets", c.name)
+		}
+	}
+}
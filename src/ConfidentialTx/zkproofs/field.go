@@ -0,0 +1,580 @@
+/*
+This file contains the arithmetic of the base field of secp256k1 on four 64-bit limbs,
+and the points of the curve in Jacobian coordinates (X, Y, Z), which stand for the
+affine point (X/Z^2, Y/Z^3). Additions and doublings of Jacobian points need no
+inversion, unlike the affine arithmetic of big.Int, so they are used where many points
+are added, as in multi-scalar multiplications.
+*/
+
+package zkproofs
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+/*
+fieldElement is an element of Z_p, with p = 2^256 - 2^32 - 977, stored as four
+little endian limbs. Every operation returns the canonical value in [0, p).
+*/
+type fieldElement [4]uint64
+
+const (
+	// fieldC = 2^256 - p
+	fieldC = 0x1000003D1
+)
+
+var (
+	fieldOne = fieldElement{1, 0, 0, 0}
+)
+
+/*
+canonical returns x + carry.2^256 reduced in [0, p), given that this value is less
+than 2p. Since 2^256 = fieldC mod p, x >= p if and only if x + fieldC overflows.
+*/
+func canonical(x fieldElement, carry uint64) fieldElement {
+	var (
+		t fieldElement
+		c uint64
+	)
+	t[0], c = bits.Add64(x[0], fieldC, 0)
+	t[1], c = bits.Add64(x[1], 0, c)
+	t[2], c = bits.Add64(x[2], 0, c)
+	t[3], c = bits.Add64(x[3], 0, c)
+	// select t when carry | c is set, without branching on the value
+	mask := -(carry | c)
+	x[0] = x[0]&^mask | t[0]&mask
+	x[1] = x[1]&^mask | t[1]&mask
+	x[2] = x[2]&^mask | t[2]&mask
+	x[3] = x[3]&^mask | t[3]&mask
+	return x
+}
+
+func (z *fieldElement) add(a, b *fieldElement) *fieldElement {
+	var (
+		x fieldElement
+		c uint64
+	)
+	x[0], c = bits.Add64(a[0], b[0], 0)
+	x[1], c = bits.Add64(a[1], b[1], c)
+	x[2], c = bits.Add64(a[2], b[2], c)
+	x[3], c = bits.Add64(a[3], b[3], c)
+	*z = canonical(x, c)
+	return z
+}
+
+func (z *fieldElement) sub(a, b *fieldElement) *fieldElement {
+	var (
+		x fieldElement
+		c uint64
+	)
+	x[0], c = bits.Sub64(a[0], b[0], 0)
+	x[1], c = bits.Sub64(a[1], b[1], c)
+	x[2], c = bits.Sub64(a[2], b[2], c)
+	x[3], c = bits.Sub64(a[3], b[3], c)
+	// on borrow x = a - b + 2^256, and a - b + p = x - fieldC
+	mask := -c
+	x[0], c = bits.Sub64(x[0], fieldC&mask, 0)
+	x[1], c = bits.Sub64(x[1], 0, c)
+	x[2], c = bits.Sub64(x[2], 0, c)
+	x[3], _ = bits.Sub64(x[3], 0, c)
+	*z = x
+	return z
+}
+
+func (z *fieldElement) neg(a *fieldElement) *fieldElement {
+	var (
+		zero fieldElement
+	)
+	return z.sub(&zero, a)
+}
+
+/*
+mac returns the two limbs hi.2^64 + lo of a.b + t + carry, which cannot overflow.
+*/
+func mac(a, b, t, carry uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var c uint64
+	lo, c = bits.Add64(lo, t, 0)
+	hi += c
+	lo, c = bits.Add64(lo, carry, 0)
+	hi += c
+	return hi, lo
+}
+
+/*
+mul sets z = a.b mod p. The 512-bit product hi.2^256 + lo is reduced as
+lo + hi.fieldC, twice, since fieldC has 33 bits.
+*/
+func (z *fieldElement) mul(a, b *fieldElement) *fieldElement {
+	var (
+		t0, t1, t2, t3, t4, t5, t6, t7 uint64
+		c                              uint64
+		r                              fieldElement
+	)
+	c, t0 = mac(a[0], b[0], 0, 0)
+	c, t1 = mac(a[0], b[1], 0, c)
+	c, t2 = mac(a[0], b[2], 0, c)
+	t4, t3 = mac(a[0], b[3], 0, c)
+
+	c, t1 = mac(a[1], b[0], t1, 0)
+	c, t2 = mac(a[1], b[1], t2, c)
+	c, t3 = mac(a[1], b[2], t3, c)
+	t5, t4 = mac(a[1], b[3], t4, c)
+
+	c, t2 = mac(a[2], b[0], t2, 0)
+	c, t3 = mac(a[2], b[1], t3, c)
+	c, t4 = mac(a[2], b[2], t4, c)
+	t6, t5 = mac(a[2], b[3], t5, c)
+
+	c, t3 = mac(a[3], b[0], t3, 0)
+	c, t4 = mac(a[3], b[1], t4, c)
+	c, t5 = mac(a[3], b[2], t5, c)
+	t7, t6 = mac(a[3], b[3], t6, c)
+
+	// lo + hi.fieldC fits in 5 limbs, the top one has at most 34 bits
+	c, r[0] = mac(t4, fieldC, t0, 0)
+	c, r[1] = mac(t5, fieldC, t1, c)
+	c, r[2] = mac(t6, fieldC, t2, c)
+	c, r[3] = mac(t7, fieldC, t3, c)
+	hi, lo := bits.Mul64(c, fieldC)
+	r[0], c = bits.Add64(r[0], lo, 0)
+	r[1], c = bits.Add64(r[1], hi, c)
+	r[2], c = bits.Add64(r[2], 0, c)
+	r[3], c = bits.Add64(r[3], 0, c)
+	*z = canonical(r, c)
+	return z
+}
+
+func (z *fieldElement) square(a *fieldElement) *fieldElement {
+	return z.mul(a, a)
+}
+
+/*
+sqrn sets z = a^(2^n).
+*/
+func (z *fieldElement) sqrn(a *fieldElement, n int) *fieldElement {
+	*z = *a
+	for i := 0; i < n; i++ {
+		z.square(z)
+	}
+	return z
+}
+
+/*
+chainPowers returns the powers x_k = a^(2^k - 1) for k = 2, 22 and 223, which start
+the addition chains of libsecp256k1 for inv and sqrtPow.
+*/
+func chainPowers(a *fieldElement) (x2, x22, x223 fieldElement) {
+	var (
+		x3, x6, x9, x11, x44, x88, x176, x220 fieldElement
+	)
+	x2.square(a)
+	x2.mul(&x2, a)
+	x3.square(&x2)
+	x3.mul(&x3, a)
+	x6.sqrn(&x3, 3)
+	x6.mul(&x6, &x3)
+	x9.sqrn(&x6, 3)
+	x9.mul(&x9, &x3)
+	x11.sqrn(&x9, 2)
+	x11.mul(&x11, &x2)
+	x22.sqrn(&x11, 11)
+	x22.mul(&x22, &x11)
+	x44.sqrn(&x22, 22)
+	x44.mul(&x44, &x22)
+	x88.sqrn(&x44, 44)
+	x88.mul(&x88, &x44)
+	x176.sqrn(&x88, 88)
+	x176.mul(&x176, &x88)
+	x220.sqrn(&x176, 44)
+	x220.mul(&x220, &x44)
+	x223.sqrn(&x220, 3)
+	x223.mul(&x223, &x3)
+	return x2, x22, x223
+}
+
+/*
+inv sets z = a^-1 mod p as a^(p-2), or 0 if a = 0. The exponent is built with the
+addition chain of libsecp256k1, 255 squarings and 15 multiplications, from the
+powers x_k = a^(2^k - 1).
+*/
+func (z *fieldElement) inv(a *fieldElement) *fieldElement {
+	var (
+		t fieldElement
+	)
+	x2, x22, x223 := chainPowers(a)
+	// p - 2 in binary is 223 ones, a zero, 22 ones, then 0000101101
+	t.sqrn(&x223, 23)
+	t.mul(&t, &x22)
+	t.sqrn(&t, 5)
+	t.mul(&t, a)
+	t.sqrn(&t, 3)
+	t.mul(&t, &x2)
+	t.sqrn(&t, 2)
+	t.mul(&t, a)
+	*z = t
+	return z
+}
+
+/*
+sqrtPow sets z = a^((p-3)/4), the exponent of the square roots of RFC 9380 for
+p = 3 mod 4, with the same chain as inv.
+*/
+func (z *fieldElement) sqrtPow(a *fieldElement) *fieldElement {
+	var (
+		t fieldElement
+	)
+	x2, x22, x223 := chainPowers(a)
+	// (p - 3) / 4 in binary is 223 ones, a zero, 22 ones, then 00001011
+	t.sqrn(&x223, 23)
+	t.mul(&t, &x22)
+	t.sqrn(&t, 5)
+	t.mul(&t, a)
+	t.sqrn(&t, 3)
+	t.mul(&t, &x2)
+	*z = t
+	return z
+}
+
+func (z *fieldElement) isZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+func (z *fieldElement) equal(a *fieldElement) bool {
+	return (z[0]^a[0])|(z[1]^a[1])|(z[2]^a[2])|(z[3]^a[3]) == 0
+}
+
+/*
+setBig sets z = x mod p.
+*/
+func (z *fieldElement) setBig(x *big.Int) *fieldElement {
+	var (
+		buf [32]byte
+	)
+	new(big.Int).Mod(x, CURVE.P).FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		z[i] = binary.BigEndian.Uint64(buf[32-8*(i+1):])
+	}
+	return z
+}
+
+func (z *fieldElement) big() *big.Int {
+	var (
+		buf [32]byte
+	)
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(buf[32-8*(i+1):], z[i])
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+/*
+jacobianPoint is a point of secp256k1 in Jacobian coordinates. Z = 0 is the point at
+infinity.
+*/
+type jacobianPoint struct {
+	X, Y, Z fieldElement
+}
+
+/*
+setAffine sets p to the affine point (x, y). Nil coordinates or (0, 0) stand for the
+point at infinity.
+*/
+func (p *jacobianPoint) setAffine(x, y *big.Int) *jacobianPoint {
+	if x == nil || y == nil || (x.Sign() == 0 && y.Sign() == 0) {
+		*p = jacobianPoint{}
+		return p
+	}
+	p.X.setBig(x)
+	p.Y.setBig(y)
+	p.Z = fieldOne
+	return p
+}
+
+/*
+affine returns the affine coordinates of p, or nil coordinates for the point at
+infinity.
+*/
+func (p *jacobianPoint) affine() (*big.Int, *big.Int) {
+	var (
+		zinv, zinv2 fieldElement
+		x, y        fieldElement
+	)
+	if p.Z.isZero() {
+		return nil, nil
+	}
+	if p.Z.equal(&fieldOne) {
+		return p.X.big(), p.Y.big()
+	}
+	zinv.inv(&p.Z)
+	zinv2.square(&zinv)
+	x.mul(&p.X, &zinv2)
+	y.mul(&p.Y, &zinv2)
+	y.mul(&y, &zinv)
+	return x.big(), y.big()
+}
+
+/*
+batchNormalize sets Z = 1 on all the points, with a single inversion for all of them
+(Montgomery's trick): the inverse of every Z is the inverse of the product of all of
+them times the product of the others. Points at infinity and points with Z = 1 are
+left as they are.
+*/
+func batchNormalize(points []*jacobianPoint) {
+	var (
+		acc, zinv, zinv2, t fieldElement
+	)
+	pending := make([]*jacobianPoint, 0, len(points))
+	for _, p := range points {
+		if !p.Z.isZero() && !p.Z.equal(&fieldOne) {
+			pending = append(pending, p)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+	// prefix[i] = Z_0 ... Z_(i-1)
+	prefix := make([]fieldElement, len(pending))
+	acc = fieldOne
+	for i, p := range pending {
+		prefix[i] = acc
+		acc.mul(&acc, &p.Z)
+	}
+	acc.inv(&acc)
+	for i := len(pending) - 1; i >= 0; i-- {
+		p := pending[i]
+		// acc = (Z_0 ... Z_i)^-1
+		zinv.mul(&acc, &prefix[i])
+		acc.mul(&acc, &p.Z)
+		zinv2.square(&zinv)
+		p.X.mul(&p.X, &zinv2)
+		t.mul(&zinv2, &zinv)
+		p.Y.mul(&p.Y, &t)
+		p.Z = fieldOne
+	}
+}
+
+/*
+equal returns true if p and q are the same point, comparing X1.Z2^2 with X2.Z1^2
+and Y1.Z2^3 with Y2.Z1^3, so that no inversion is needed.
+*/
+func (p *jacobianPoint) equal(q *jacobianPoint) bool {
+	var (
+		z1z1, z2z2, u1, u2, s1, s2 fieldElement
+	)
+	if p.Z.isZero() || q.Z.isZero() {
+		return p.Z.isZero() && q.Z.isZero()
+	}
+	z1z1.square(&p.Z)
+	z2z2.square(&q.Z)
+	u1.mul(&p.X, &z2z2)
+	u2.mul(&q.X, &z1z1)
+	s1.mul(&p.Y, &q.Z)
+	s1.mul(&s1, &z2z2)
+	s2.mul(&q.Y, &p.Z)
+	s2.mul(&s2, &z1z1)
+	return u1.equal(&u2) && s1.equal(&s2)
+}
+
+/*
+onCurve returns true if p is a finite point of y^2 = x^3 + 7, that is
+Y^2 = X^3 + 7.Z^6 in Jacobian coordinates.
+*/
+func (p *jacobianPoint) onCurve() bool {
+	var (
+		y2, x3, z6, seven fieldElement
+	)
+	if p.Z.isZero() {
+		return false
+	}
+	seven[0] = 7
+	y2.square(&p.Y)
+	x3.square(&p.X)
+	x3.mul(&x3, &p.X)
+	z6.square(&p.Z)
+	z6.mul(&z6, &p.Z)
+	z6.square(&z6)
+	z6.mul(&z6, &seven)
+	x3.add(&x3, &z6)
+	return y2.equal(&x3)
+}
+
+func (p *jacobianPoint) isInfinity() bool {
+	return p.Z.isZero()
+}
+
+/*
+neg sets p = -a, that is (X, -Y, Z).
+*/
+func (p *jacobianPoint) neg(a *jacobianPoint) *jacobianPoint {
+	if a.Z.isZero() {
+		*p = jacobianPoint{}
+		return p
+	}
+	p.X = a.X
+	p.Y.neg(&a.Y)
+	p.Z = a.Z
+	return p
+}
+
+/*
+double sets p = 2a, with the formulas dbl-2009-l for curves y^2 = x^3 + b.
+*/
+func (p *jacobianPoint) double(a *jacobianPoint) *jacobianPoint {
+	var (
+		A, B, C, D, E, F, t fieldElement
+		r                   jacobianPoint
+	)
+	if a.Z.isZero() || a.Y.isZero() {
+		*p = jacobianPoint{}
+		return p
+	}
+	A.square(&a.X)
+	B.square(&a.Y)
+	C.square(&B)
+	// D = 2.((X + B)^2 - A - C)
+	D.add(&a.X, &B)
+	D.square(&D)
+	D.sub(&D, &A)
+	D.sub(&D, &C)
+	D.add(&D, &D)
+	// E = 3.A, F = E^2
+	E.add(&A, &A)
+	E.add(&E, &A)
+	F.square(&E)
+	// X3 = F - 2.D
+	r.X.sub(&F, &D)
+	r.X.sub(&r.X, &D)
+	// Y3 = E.(D - X3) - 8.C
+	t.sub(&D, &r.X)
+	r.Y.mul(&E, &t)
+	C.add(&C, &C)
+	C.add(&C, &C)
+	C.add(&C, &C)
+	r.Y.sub(&r.Y, &C)
+	// Z3 = 2.Y.Z
+	r.Z.mul(&a.Y, &a.Z)
+	r.Z.add(&r.Z, &r.Z)
+	*p = r
+	return p
+}
+
+/*
+add sets p = a + b, with the formulas add-2007-bl. It falls back to double when
+a = b.
+*/
+func (p *jacobianPoint) add(a, b *jacobianPoint) *jacobianPoint {
+	var (
+		z1z1, z2z2, u1, u2, s1, s2, h, i, j, rr, v, t fieldElement
+		r                                             jacobianPoint
+	)
+	if a.Z.isZero() {
+		*p = *b
+		return p
+	}
+	if b.Z.isZero() {
+		*p = *a
+		return p
+	}
+	z1z1.square(&a.Z)
+	z2z2.square(&b.Z)
+	u1.mul(&a.X, &z2z2)
+	u2.mul(&b.X, &z1z1)
+	s1.mul(&a.Y, &b.Z)
+	s1.mul(&s1, &z2z2)
+	s2.mul(&b.Y, &a.Z)
+	s2.mul(&s2, &z1z1)
+	h.sub(&u2, &u1)
+	rr.sub(&s2, &s1)
+	if h.isZero() {
+		if rr.isZero() {
+			return p.double(a)
+		}
+		*p = jacobianPoint{}
+		return p
+	}
+	// I = (2H)^2, J = H.I, r = 2.(S2 - S1), V = U1.I
+	i.add(&h, &h)
+	i.square(&i)
+	j.mul(&h, &i)
+	rr.add(&rr, &rr)
+	v.mul(&u1, &i)
+	// X3 = r^2 - J - 2.V
+	r.X.square(&rr)
+	r.X.sub(&r.X, &j)
+	r.X.sub(&r.X, &v)
+	r.X.sub(&r.X, &v)
+	// Y3 = r.(V - X3) - 2.S1.J
+	t.sub(&v, &r.X)
+	r.Y.mul(&rr, &t)
+	t.mul(&s1, &j)
+	t.add(&t, &t)
+	r.Y.sub(&r.Y, &t)
+	// Z3 = ((Z1 + Z2)^2 - Z1Z1 - Z2Z2).H
+	r.Z.add(&a.Z, &b.Z)
+	r.Z.square(&r.Z)
+	r.Z.sub(&r.Z, &z1z1)
+	r.Z.sub(&r.Z, &z2z2)
+	r.Z.mul(&r.Z, &h)
+	*p = r
+	return p
+}
+
+/*
+addAffine sets p = a + b for a point b with Z = 1, with the formulas madd-2007-bl,
+which save four multiplications over add.
+*/
+func (p *jacobianPoint) addAffine(a, b *jacobianPoint) *jacobianPoint {
+	var (
+		z1z1, u2, s2, h, hh, i, j, rr, v, t fieldElement
+		r                                   jacobianPoint
+	)
+	if a.Z.isZero() {
+		*p = *b
+		return p
+	}
+	if b.Z.isZero() {
+		*p = *a
+		return p
+	}
+	z1z1.square(&a.Z)
+	u2.mul(&b.X, &z1z1)
+	s2.mul(&b.Y, &a.Z)
+	s2.mul(&s2, &z1z1)
+	h.sub(&u2, &a.X)
+	rr.sub(&s2, &a.Y)
+	if h.isZero() {
+		if rr.isZero() {
+			return p.double(a)
+		}
+		*p = jacobianPoint{}
+		return p
+	}
+	// HH = H^2, I = 4.HH, J = H.I, r = 2.(S2 - Y1), V = X1.I
+	hh.square(&h)
+	i.add(&hh, &hh)
+	i.add(&i, &i)
+	j.mul(&h, &i)
+	rr.add(&rr, &rr)
+	v.mul(&a.X, &i)
+	// X3 = r^2 - J - 2.V
+	r.X.square(&rr)
+	r.X.sub(&r.X, &j)
+	r.X.sub(&r.X, &v)
+	r.X.sub(&r.X, &v)
+	// Y3 = r.(V - X3) - 2.Y1.J
+	t.sub(&v, &r.X)
+	r.Y.mul(&rr, &t)
+	t.mul(&a.Y, &j)
+	t.add(&t, &t)
+	r.Y.sub(&r.Y, &t)
+	// Z3 = (Z1 + H)^2 - Z1Z1 - HH
+	r.Z.add(&a.Z, &h)
+	r.Z.square(&r.Z)
+	r.Z.sub(&r.Z, &z1z1)
+	r.Z.sub(&r.Z, &hh)
+	*p = r
+	return p
+}
@@ -0,0 +1,403 @@
+/*
+This file contains the arithmetic of the base field of Curve25519 on four 64-bit limbs,
+and the points of the twisted Edwards curve edwards25519, -x^2 + y^2 = 1 + d.x^2.y^2,
+in extended coordinates (X, Y, Z, T), which stand for the affine point (X/Z, Y/Z) with
+T = X.Y/Z. The addition of edwards25519 is complete, so there are no special cases for
+the identity or for doublings. The points are the representatives of the elements of
+ristretto255, see ristretto.go.
+*/
+
+package zkproofs
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+)
+
+/*
+field25519 is an element of Z_p, with p = 2^255 - 19, stored as four little endian
+limbs. Every operation returns the canonical value in [0, p).
+*/
+type field25519 [4]uint64
+
+const (
+	// field25519C = 2^256 mod p
+	field25519C = 38
+)
+
+var (
+	p25519        = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	field25519One = field25519{1, 0, 0, 0}
+)
+
+/*
+canonical25519 returns x + carry.2^256 reduced in [0, p). The carry is folded as 38,
+then the bit 255 as 19, which leaves a value below 2^255 + 19 < 2p, and x >= p if and
+only if x + 19 has the bit 255 set.
+*/
+func canonical25519(x field25519, carry uint64) field25519 {
+	var (
+		t field25519
+		c uint64
+	)
+	x[0], c = bits.Add64(x[0], field25519C&-carry, 0)
+	x[1], c = bits.Add64(x[1], 0, c)
+	x[2], c = bits.Add64(x[2], 0, c)
+	x[3], c = bits.Add64(x[3], 0, c)
+	// on overflow x < 38, so adding 38 again cannot overflow
+	x[0], c = bits.Add64(x[0], field25519C&-c, 0)
+	x[1], c = bits.Add64(x[1], 0, c)
+	x[2], c = bits.Add64(x[2], 0, c)
+	x[3], _ = bits.Add64(x[3], 0, c)
+	top := x[3] >> 63
+	x[3] &= 1<<63 - 1
+	x[0], c = bits.Add64(x[0], 19*top, 0)
+	x[1], c = bits.Add64(x[1], 0, c)
+	x[2], c = bits.Add64(x[2], 0, c)
+	x[3], _ = bits.Add64(x[3], 0, c)
+	t[0], c = bits.Add64(x[0], 19, 0)
+	t[1], c = bits.Add64(x[1], 0, c)
+	t[2], c = bits.Add64(x[2], 0, c)
+	t[3], _ = bits.Add64(x[3], 0, c)
+	// select t - 2^255 when the bit 255 of t is set, without branching on the value
+	mask := -(t[3] >> 63)
+	t[3] &= 1<<63 - 1
+	x[0] = x[0]&^mask | t[0]&mask
+	x[1] = x[1]&^mask | t[1]&mask
+	x[2] = x[2]&^mask | t[2]&mask
+	x[3] = x[3]&^mask | t[3]&mask
+	return x
+}
+
+func (z *field25519) add(a, b *field25519) *field25519 {
+	var (
+		x field25519
+		c uint64
+	)
+	x[0], c = bits.Add64(a[0], b[0], 0)
+	x[1], c = bits.Add64(a[1], b[1], c)
+	x[2], c = bits.Add64(a[2], b[2], c)
+	x[3], c = bits.Add64(a[3], b[3], c)
+	*z = canonical25519(x, c)
+	return z
+}
+
+func (z *field25519) sub(a, b *field25519) *field25519 {
+	var (
+		x field25519
+		c uint64
+	)
+	x[0], c = bits.Sub64(a[0], b[0], 0)
+	x[1], c = bits.Sub64(a[1], b[1], c)
+	x[2], c = bits.Sub64(a[2], b[2], c)
+	x[3], c = bits.Sub64(a[3], b[3], c)
+	// on borrow x = a - b + 2^256, and a - b + 2p = x - 38
+	mask := -c
+	x[0], c = bits.Sub64(x[0], field25519C&mask, 0)
+	x[1], c = bits.Sub64(x[1], 0, c)
+	x[2], c = bits.Sub64(x[2], 0, c)
+	x[3], _ = bits.Sub64(x[3], 0, c)
+	*z = canonical25519(x, 0)
+	return z
+}
+
+func (z *field25519) neg(a *field25519) *field25519 {
+	var (
+		zero field25519
+	)
+	return z.sub(&zero, a)
+}
+
+/*
+mul sets z = a.b mod p. The 512-bit product hi.2^256 + lo is reduced as lo + 38.hi,
+whose top limb has at most 6 bits, and this limb is folded again.
+*/
+func (z *field25519) mul(a, b *field25519) *field25519 {
+	var (
+		t0, t1, t2, t3, t4, t5, t6, t7 uint64
+		c                              uint64
+		r                              field25519
+	)
+	c, t0 = mac(a[0], b[0], 0, 0)
+	c, t1 = mac(a[0], b[1], 0, c)
+	c, t2 = mac(a[0], b[2], 0, c)
+	t4, t3 = mac(a[0], b[3], 0, c)
+
+	c, t1 = mac(a[1], b[0], t1, 0)
+	c, t2 = mac(a[1], b[1], t2, c)
+	c, t3 = mac(a[1], b[2], t3, c)
+	t5, t4 = mac(a[1], b[3], t4, c)
+
+	c, t2 = mac(a[2], b[0], t2, 0)
+	c, t3 = mac(a[2], b[1], t3, c)
+	c, t4 = mac(a[2], b[2], t4, c)
+	t6, t5 = mac(a[2], b[3], t5, c)
+
+	c, t3 = mac(a[3], b[0], t3, 0)
+	c, t4 = mac(a[3], b[1], t4, c)
+	c, t5 = mac(a[3], b[2], t5, c)
+	t7, t6 = mac(a[3], b[3], t6, c)
+
+	c, r[0] = mac(t4, field25519C, t0, 0)
+	c, r[1] = mac(t5, field25519C, t1, c)
+	c, r[2] = mac(t6, field25519C, t2, c)
+	c, r[3] = mac(t7, field25519C, t3, c)
+	r[0], c = bits.Add64(r[0], c*field25519C, 0)
+	r[1], c = bits.Add64(r[1], 0, c)
+	r[2], c = bits.Add64(r[2], 0, c)
+	r[3], c = bits.Add64(r[3], 0, c)
+	*z = canonical25519(r, c)
+	return z
+}
+
+func (z *field25519) square(a *field25519) *field25519 {
+	return z.mul(a, a)
+}
+
+/*
+sqrn sets z = a^(2^n).
+*/
+func (z *field25519) sqrn(a *field25519, n int) *field25519 {
+	*z = *a
+	for i := 0; i < n; i++ {
+		z.square(z)
+	}
+	return z
+}
+
+/*
+chainPowers25519 returns a^11 and a^(2^250 - 1), which start the addition chains of
+ref10 for inv and sqrtPow.
+*/
+func chainPowers25519(a *field25519) (x11, x250 field25519) {
+	var (
+		a2, a9, x5, x10, x20, x40, x50, x100, x200, t field25519
+	)
+	a2.square(a)
+	t.sqrn(&a2, 2)
+	a9.mul(&t, a)
+	x11.mul(&a9, &a2)
+	t.square(&x11)
+	x5.mul(&t, &a9)
+	t.sqrn(&x5, 5)
+	x10.mul(&t, &x5)
+	t.sqrn(&x10, 10)
+	x20.mul(&t, &x10)
+	t.sqrn(&x20, 20)
+	x40.mul(&t, &x20)
+	t.sqrn(&x40, 10)
+	x50.mul(&t, &x10)
+	t.sqrn(&x50, 50)
+	x100.mul(&t, &x50)
+	t.sqrn(&x100, 100)
+	x200.mul(&t, &x100)
+	t.sqrn(&x200, 50)
+	x250.mul(&t, &x50)
+	return x11, x250
+}
+
+/*
+inv sets z = a^-1 mod p as a^(p-2) = a^(2^255 - 21), or 0 if a = 0.
+*/
+func (z *field25519) inv(a *field25519) *field25519 {
+	x11, x250 := chainPowers25519(a)
+	z.sqrn(&x250, 5)
+	return z.mul(z, &x11)
+}
+
+/*
+sqrtPow sets z = a^((p-5)/8) = a^(2^252 - 3), the exponent of the square roots for
+p = 5 mod 8.
+*/
+func (z *field25519) sqrtPow(a *field25519) *field25519 {
+	var (
+		t field25519
+	)
+	_, x250 := chainPowers25519(a)
+	t.sqrn(&x250, 2)
+	t.mul(&t, a)
+	*z = t
+	return z
+}
+
+func (z *field25519) isZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+func (z *field25519) equal(a *field25519) bool {
+	return (z[0]^a[0])|(z[1]^a[1])|(z[2]^a[2])|(z[3]^a[3]) == 0
+}
+
+/*
+ctEqual returns 2^64 - 1 if z = a and 0 otherwise, without branching.
+*/
+func (z *field25519) ctEqual(a *field25519) uint64 {
+	return ctEqual((z[0]^a[0])|(z[1]^a[1])|(z[2]^a[2])|(z[3]^a[3]), 0)
+}
+
+/*
+cmov sets z = a if mask is 2^64 - 1 and leaves z unchanged if it is 0.
+*/
+func (z *field25519) cmov(a *field25519, mask uint64) {
+	z[0] = z[0]&^mask | a[0]&mask
+	z[1] = z[1]&^mask | a[1]&mask
+	z[2] = z[2]&^mask | a[2]&mask
+	z[3] = z[3]&^mask | a[3]&mask
+}
+
+/*
+isNegative returns 1 if z is odd, which is the sign of field elements in RFC 9496, and
+0 otherwise.
+*/
+func (z *field25519) isNegative() uint64 {
+	return z[0] & 1
+}
+
+/*
+abs sets z = |a|, i.e. -a if a is negative and a otherwise.
+*/
+func (z *field25519) abs(a *field25519) *field25519 {
+	var (
+		n field25519
+	)
+	n.neg(a)
+	*z = *a
+	z.cmov(&n, ctMask(a.isNegative()))
+	return z
+}
+
+/*
+setBytes sets z to the 32 little endian bytes reduced modulo p, and returns whether
+they were the canonical encoding of z, i.e. a value below p.
+*/
+func (z *field25519) setBytes(b []byte) bool {
+	var (
+		x field25519
+	)
+	for i := 0; i < 4; i++ {
+		x[i] = binary.LittleEndian.Uint64(b[8*i:])
+	}
+	*z = canonical25519(x, 0)
+	return z.equal(&x)
+}
+
+/*
+bytes returns the 32 little endian bytes of z.
+*/
+func (z *field25519) bytes() []byte {
+	result := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(result[8*i:], z[i])
+	}
+	return result
+}
+
+/*
+setBig sets z = x mod p.
+*/
+func (z *field25519) setBig(x *big.Int) *field25519 {
+	var (
+		buf [32]byte
+	)
+	new(big.Int).Mod(x, p25519).FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		z[i] = binary.BigEndian.Uint64(buf[32-8*(i+1):])
+	}
+	return z
+}
+
+func (z *field25519) big() *big.Int {
+	var (
+		buf [32]byte
+	)
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(buf[32-8*(i+1):], z[i])
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+/*
+edwardsPoint is a point of edwards25519 in extended coordinates. The identity is
+(0, 1, 1, 0).
+*/
+type edwardsPoint struct {
+	X, Y, Z, T field25519
+}
+
+var (
+	edwardsIdentity = edwardsPoint{Y: field25519One, Z: field25519One}
+)
+
+func (p *edwardsPoint) neg(a *edwardsPoint) *edwardsPoint {
+	p.X.neg(&a.X)
+	p.Y = a.Y
+	p.Z = a.Z
+	p.T.neg(&a.T)
+	return p
+}
+
+/*
+add sets p = a + b with the formulas add-2008-hwcd-3 of Hisil, Wong, Carter and
+Dawson for a = -1, which are complete on edwards25519.
+*/
+func (p *edwardsPoint) add(a, b *edwardsPoint) *edwardsPoint {
+	var (
+		t1, t2, A, B, C, D, E, F, G, H field25519
+	)
+	t1.sub(&a.Y, &a.X)
+	t2.sub(&b.Y, &b.X)
+	A.mul(&t1, &t2)
+	t1.add(&a.Y, &a.X)
+	t2.add(&b.Y, &b.X)
+	B.mul(&t1, &t2)
+	C.mul(&a.T, &b.T)
+	C.mul(&C, &edwardsD2)
+	D.mul(&a.Z, &b.Z)
+	D.add(&D, &D)
+	E.sub(&B, &A)
+	F.sub(&D, &C)
+	G.add(&D, &C)
+	H.add(&B, &A)
+	p.X.mul(&E, &F)
+	p.Y.mul(&G, &H)
+	p.Z.mul(&F, &G)
+	p.T.mul(&E, &H)
+	return p
+}
+
+/*
+double sets p = 2.a with the formulas dbl-2008-hwcd for a = -1.
+*/
+func (p *edwardsPoint) double(a *edwardsPoint) *edwardsPoint {
+	var (
+		A, B, C, D, E, F, G, H field25519
+	)
+	A.square(&a.X)
+	B.square(&a.Y)
+	C.square(&a.Z)
+	C.add(&C, &C)
+	D.neg(&A)
+	E.add(&a.X, &a.Y)
+	E.square(&E)
+	E.sub(&E, &A)
+	E.sub(&E, &B)
+	G.add(&D, &B)
+	F.sub(&G, &C)
+	H.sub(&D, &B)
+	p.X.mul(&E, &F)
+	p.Y.mul(&G, &H)
+	p.Z.mul(&F, &G)
+	p.T.mul(&E, &H)
+	return p
+}
+
+/*
+cmov sets p = a if mask is 2^64 - 1 and leaves p unchanged if it is 0.
+*/
+func (p *edwardsPoint) cmov(a *edwardsPoint, mask uint64) {
+	p.X.cmov(&a.X, mask)
+	p.Y.cmov(&a.Y, mask)
+	p.Z.cmov(&a.Z, mask)
+	p.T.cmov(&a.T, mask)
+}
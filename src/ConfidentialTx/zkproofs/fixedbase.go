@@ -0,0 +1,160 @@
+/*
+This file contains the precomputed tables of the fixed bases, G and the points H
+derived from a seed, which are multiplied by a new scalar for every commitment. The
+table of a base P holds the multiples j.2^(6i).P for j in [1, 63], in affine
+coordinates, so that k.P is the sum of one entry per window of 6 bits of k: 43 mixed
+additions and no doubling, instead of a full scalar multiplication.
+*/
+
+package zkproofs
+
+import (
+	"sync"
+)
+
+const (
+	// fixedWindow is the number of bits of the scalar per window of the tables
+	fixedWindow = 6
+	// fixedWindows is the number of windows of 256-bit scalars
+	fixedWindows = (256 + fixedWindow - 1) / fixedWindow
+)
+
+/*
+fixedBaseTable holds the multiples of a base, about 260 KB. Entry [i][j] is
+(j + 1).2^(6i).P, normalized.
+*/
+type fixedBaseTable [fixedWindows][1<<fixedWindow - 1]jacobianPoint
+
+/*
+fixedBaseEntry is built exactly once, like the entries of the generator registry.
+*/
+type fixedBaseEntry struct {
+	once  sync.Once
+	base  jacobianPoint
+	table *fixedBaseTable
+}
+
+/*
+fixedBaseKey identifies a normalized base by its affine coordinates.
+*/
+type fixedBaseKey [8]uint64
+
+var (
+	fixedBasesMu sync.Mutex
+	fixedBases   = make(map[fixedBaseKey]*fixedBaseEntry)
+	gTable       *fixedBaseTable
+	gTableOnce   sync.Once
+)
+
+/*
+newFixedBaseTable computes the table of the point p, with a single inversion.
+*/
+func newFixedBaseTable(p *jacobianPoint) *fixedBaseTable {
+	var (
+		base jacobianPoint
+	)
+	table := new(fixedBaseTable)
+	points := make([]*jacobianPoint, 0, fixedWindows*len(table[0]))
+	base = *p
+	for i := range table {
+		table[i][0] = base
+		points = append(points, &table[i][0])
+		for j := 1; j < len(table[i]); j++ {
+			table[i][j].add(&table[i][j-1], &base)
+			points = append(points, &table[i][j])
+		}
+		// the base of the next window is 64 times this one
+		base.add(&table[i][len(table[i])-1], &base)
+	}
+	batchNormalize(points)
+	return table
+}
+
+/*
+mult returns k.P, where P is the base of the table.
+*/
+func (table *fixedBaseTable) mult(k Scalar) *p256 {
+	var (
+		result p256
+	)
+	scalar := scalarLimbs(k)
+	for i := range table {
+		w := window(&scalar, uint(i*fixedWindow), fixedWindow)
+		if w != 0 {
+			result.jac.addAffine(&result.jac, &table[i][w-1])
+		}
+	}
+	return &result
+}
+
+/*
+baseTable returns the table of the base point G of secp256k1, computed on the first
+call. G is registered like the other fixed bases, so that the scalar multiplications
+of the point G also use it.
+*/
+func baseTable() *fixedBaseTable {
+	gTableOnce.Do(func() {
+		g := newP256(GX, GY)
+		precomputeFixedBase(g)
+		gTable = fixedBaseTableOf(g)
+	})
+	return gTable
+}
+
+/*
+fixedBaseKeyOf returns the key of p, or false if p is not normalized.
+*/
+func fixedBaseKeyOf(p *p256) (fixedBaseKey, bool) {
+	var (
+		key fixedBaseKey
+	)
+	if p.IsZero() || !p.jac.Z.equal(&fieldOne) {
+		return key, false
+	}
+	copy(key[:4], p.jac.X[:])
+	copy(key[4:], p.jac.Y[:])
+	return key, true
+}
+
+/*
+precomputeFixedBase computes the table of p, once per process, so that later scalar
+multiplications of p use it. It is called for the points derived from a seed, which
+are normalized.
+*/
+func precomputeFixedBase(p *p256) {
+	key, ok := fixedBaseKeyOf(p)
+	if !ok {
+		return
+	}
+	fixedBasesMu.Lock()
+	entry, found := fixedBases[key]
+	if !found {
+		entry = &fixedBaseEntry{base: p.jac}
+		fixedBases[key] = entry
+	}
+	fixedBasesMu.Unlock()
+	entry.once.Do(func() {
+		entry.table = newFixedBaseTable(&entry.base)
+	})
+}
+
+/*
+fixedBaseTableOf returns the table of p, or nil if precomputeFixedBase was not called
+for it.
+*/
+func fixedBaseTableOf(p *p256) *fixedBaseTable {
+	key, ok := fixedBaseKeyOf(p)
+	if !ok {
+		return nil
+	}
+	fixedBasesMu.Lock()
+	entry, found := fixedBases[key]
+	fixedBasesMu.Unlock()
+	if !found {
+		return nil
+	}
+	entry.once.Do(func() {
+		entry.table = newFixedBaseTable(&entry.base)
+	})
+	return entry.table
+}
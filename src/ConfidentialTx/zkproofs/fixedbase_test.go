@@ -0,0 +1,72 @@
+package zkproofs
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+/*
+Test the fixed-base tables of G and H against the scalar multiplication of secp256k1.
+*/
+func TestFixedBaseTable(t *testing.T) {
+	H := seedPoint(Secp256k1, SEEDH).(*p256)
+	if fixedBaseTableOf(H) == nil || baseTable() == nil {
+		t.Fatalf("Assert failure: expected the tables of G and H")
+	}
+	hx, hy := H.Affine()
+	scalars := []*big.Int{new(big.Int), big.NewInt(1), big.NewInt(63), big.NewInt(64), Sub(ORDER, big.NewInt(1)),
+		new(big.Int).Set(ORDER), Add(ORDER, big.NewInt(5)), big.NewInt(-5)}
+	for i := 0; i < 20; i++ {
+		k, _ := rand.Int(rand.Reader, ORDER)
+		scalars = append(scalars, k)
+	}
+	for _, k := range scalars {
+		s := Secp256k1.NewScalar().SetBigInt(k)
+		gx, gy := CURVE.ScalarBaseMult(Mod(k, ORDER).Bytes())
+		ex, ey := CURVE.ScalarMult(hx, hy, Mod(k, ORDER).Bytes())
+		cases := []struct {
+			name   string
+			actual Element
+			x, y   *big.Int
+		}{
+			{"ScalarBaseMult", new(p256).ScalarBaseMult(s), gx, gy},
+			{"ScalarMult(G)", new(p256).ScalarMult(newP256(GX, GY), s), gx, gy},
+			{"ScalarMult(H)", new(p256).ScalarMult(H, s), ex, ey},
+		}
+		for _, c := range cases {
+			if !c.actual.Equal(newP256(c.x, c.y)) {
+				t.Errorf("Assert failure for %s and %s: expected (%v, %v), actual: %v", c.name, k, c.x, c.y, c.actual)
+			}
+		}
+	}
+	// points that are not normalized have no table
+	P := new(p256).Multiply(H, H)
+	if fixedBaseTableOf(P) != nil {
+		t.Errorf("Assert failure: expected no table for 2H")
+	}
+	k := Secp256k1.NewScalar().SetRandom()
+	ok := new(p256).ScalarMult(P, k).Equal(new(p256).ScalarMult(H, Secp256k1.NewScalar().Add(k, k)))
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
+
+func BenchmarkCommitG1(b *testing.B) {
+	H := seedPoint(Secp256k1, SEEDH).(*p256)
+	hx, hy := H.Affine()
+	x := Secp256k1.NewScalar().SetRandom()
+	r := Secp256k1.NewScalar().SetRandom()
+	b.Run("table", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CommitG1(x, r, H)
+		}
+	})
+	b.Run("secp256k1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			gx, gy := CURVE.ScalarBaseMult(x.Bytes())
+			rx, ry := CURVE.ScalarMult(hx, hy, r.Bytes())
+			CURVE.Add(gx, gy, rx, ry)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+/*
+This file contains the interface of the prime-order groups that range proofs, the inner
+product argument and Pedersen commitments are written against, and the registry of the
+available backends. The protocol code only uses Element and Group, so that the same
+proofs can be made over any registered group, each ledger using its own curve. secp256k1
+is the default backend, see p256.go, ristretto255 is in ristretto.go and the G1 of BN254,
+whose proofs can be checked with the precompiles of Ethereum, in bn254.go.
+*/
+
+package zkproofs
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+/*
+Element of a prime-order group. The methods that return an Element set the receiver to
+the result and return it, like those of big.Int, and the arguments must belong to the
+same group as the receiver. The zero value of an implementation need not be valid,
+elements are created with Group.NewElement.
+*/
+type Element interface {
+	// Group returns the group of the element.
+	Group() Group
+	// Add sets the receiver to a + b.
+	Add(a, b Element) Element
+	// Sub sets the receiver to a - b.
+	Sub(a, b Element) Element
+	// Neg sets the receiver to -a.
+	Neg(a Element) Element
+	// Double sets the receiver to 2.a.
+	Double(a Element) Element
+	// ScalarMult sets the receiver to k.a, where k is a scalar of the group.
+	ScalarMult(a Element, k Scalar) Element
+	// ScalarBaseMult sets the receiver to k.G, where G is the generator of the group.
+	ScalarBaseMult(k Scalar) Element
+	// Set sets the receiver to a.
+	Set(a Element) Element
+	// SetIdentity sets the receiver to the identity.
+	SetIdentity() Element
+	// Equal returns true if and only if the receiver and b are the same element.
+	Equal(b Element) bool
+	// IsIdentity returns true if and only if the receiver is the identity.
+	IsIdentity() bool
+	// Bytes returns the canonical encoding of the element.
+	Bytes() []byte
+	String() string
+}
+
+/*
+Group of prime order. An implementation is safe for concurrent use.
+*/
+type Group interface {
+	// Name returns the name of the group, used to register it and to tag proofs.
+	Name() string
+	// Order returns the prime order of the group, which must not be modified.
+	Order() *big.Int
+	// NewElement returns a new element set to the identity.
+	NewElement() Element
+	// Generator returns a new element set to the generator G.
+	Generator() Element
+	// HashSuite returns the name of the hash to group suite of HashToElement.
+	HashSuite() string
+	// HashToElement hashes the message to an element with no known discrete
+	// logarithm, with the domain separation tag dst.
+	HashToElement(msg, dst []byte) Element
+	// DecodeElement decodes the encoding returned by Bytes, and rejects the
+	// invalid encodings and those of points that are not in the group.
+	DecodeElement(data []byte) (Element, error)
+	// MultiScalarMult returns sum_i k[i].a[i], with len(a) = len(k).
+	MultiScalarMult(a []Element, k []Scalar) Element
+	// ConstantTimeScalarMult returns k.a with a sequence of group operations and of
+	// table reads that does not depend on k. Whether its time does not depend on k
+	// either is up to the field arithmetic of the group: it holds for secp256k1 and
+	// ristretto255, not for bn254.
+	ConstantTimeScalarMult(a Element, k Scalar) Element
+	// ConstantTimeScalarBaseMult is ConstantTimeScalarMult for the generator G.
+	ConstantTimeScalarBaseMult(k Scalar) Element
+	// ConstantTimeMultiScalarMult returns sum_i k[i].a[i] with a sequence of
+	// operations that does not depend on the scalars k[i], as ConstantTimeScalarMult.
+	ConstantTimeMultiScalarMult(a []Element, k []Scalar) Element
+	// Normalize converts the elements in place to the representation that is the
+	// cheapest to encode, without changing their values.
+	Normalize(a []Element)
+	// Precompute prepares the scalar multiplications of a, which is multiplied by
+	// a new scalar for every proof.
+	Precompute(a Element)
+	// NewScalar returns a new scalar set to zero.
+	NewScalar() Scalar
+}
+
+/*
+Scalar is an integer modulo the order of a group. The methods that return a Scalar set
+the receiver to the result and return it, and the arguments must be scalars of the same
+group as the receiver.
+*/
+type Scalar interface {
+	// Add sets the receiver to a + b.
+	Add(a, b Scalar) Scalar
+	// Sub sets the receiver to a - b.
+	Sub(a, b Scalar) Scalar
+	// Mul sets the receiver to a.b.
+	Mul(a, b Scalar) Scalar
+	// Neg sets the receiver to -a.
+	Neg(a Scalar) Scalar
+	// Inverse sets the receiver to 1/a, or to zero if a is zero.
+	Inverse(a Scalar) Scalar
+	// Set sets the receiver to a.
+	Set(a Scalar) Scalar
+	// SetInt64 sets the receiver to k modulo the order.
+	SetInt64(k int64) Scalar
+	// SetBigInt sets the receiver to k modulo the order.
+	SetBigInt(k *big.Int) Scalar
+	// SetRandom sets the receiver to a uniformly random scalar.
+	SetRandom() Scalar
+	// SetBytes decodes the encoding returned by Bytes, which must be canonical.
+	SetBytes(data []byte) (Scalar, error)
+	// BigInt returns the scalar as an integer in [0, order).
+	BigInt() *big.Int
+	// Equal returns true if and only if the receiver and b are equal.
+	Equal(b Scalar) bool
+	// IsZero returns true if and only if the receiver is zero.
+	IsZero() bool
+	// Bytes returns the big endian encoding of the scalar, on as many bytes as the
+	// order.
+	Bytes() []byte
+}
+
+var (
+	groupsMu sync.RWMutex
+	groups   = make(map[string]Group)
+)
+
+/*
+RegisterGroup makes the group available by its name, to the tests that run against
+every backend and to the decoding of proofs. Registering two groups with the same name
+panics.
+*/
+func RegisterGroup(group Group) {
+	groupsMu.Lock()
+	defer groupsMu.Unlock()
+	if _, ok := groups[group.Name()]; ok {
+		panic("zkproofs: group " + group.Name() + " registered twice")
+	}
+	groups[group.Name()] = group
+}
+
+/*
+GroupByName returns the registered group with the given name.
+*/
+func GroupByName(name string) (Group, error) {
+	groupsMu.RLock()
+	defer groupsMu.RUnlock()
+	group, ok := groups[name]
+	if !ok {
+		return nil, errors.New("unknown group " + name)
+	}
+	return group, nil
+}
+
+/*
+Groups returns the registered groups, sorted by name.
+*/
+func Groups() []Group {
+	groupsMu.RLock()
+	defer groupsMu.RUnlock()
+	result := make([]Group, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name() < result[j].Name() })
+	return result
+}
+
+/*
+groupOrDefault returns the group named in serialized parameters, proofs or messages. A
+missing name stands for secp256k1, the group of the parameters written before groups
+were pluggable.
+*/
+func groupOrDefault(name string) (Group, error) {
+	if name == "" {
+		return Secp256k1, nil
+	}
+	return GroupByName(name)
+}
+
+/*
+sameGroup returns true if and only if every element is not nil and belongs to group.
+*/
+func sameGroup(group Group, a ...Element) bool {
+	for _, p := range a {
+		if p == nil || p.Group() != group {
+			return false
+		}
+	}
+	return true
+}
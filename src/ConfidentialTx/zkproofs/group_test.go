@@ -0,0 +1,364 @@
+package zkproofs
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+/*
+forEachGroup runs the test as a subtest over every registered group.
+*/
+func forEachGroup(t *testing.T, test func(t *testing.T, group Group)) {
+	for _, group := range Groups() {
+		group := group
+		t.Run(group.Name(), func(t *testing.T) {
+			test(t, group)
+		})
+	}
+}
+
+func randomElement(group Group) Element {
+	return group.NewElement().ScalarBaseMult(group.NewScalar().SetRandom())
+}
+
+/*
+Test the registry of groups, and that secp256k1 is registered and the default of the
+proofs that have no group.
+*/
+func TestGroupRegistry(t *testing.T) {
+	group, err := GroupByName("secp256k1")
+	if err != nil || group != Secp256k1 {
+		t.Errorf("Assert failure: expected secp256k1, actual: %v %v", group, err)
+	}
+	if group, err = groupOrDefault(""); err != nil || group != Secp256k1 {
+		t.Errorf("Assert failure: expected secp256k1 by default, actual: %v %v", group, err)
+	}
+	if _, err = GroupByName("unknown"); err == nil {
+		t.Errorf("Assert failure: expected an error for an unknown group")
+	}
+	groups := Groups()
+	for i := 1; i < len(groups); i++ {
+		if groups[i-1].Name() >= groups[i].Name() {
+			t.Errorf("Assert failure: expected the groups sorted by name")
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Assert failure: expected a panic when registering a group twice")
+		}
+	}()
+	RegisterGroup(Secp256k1)
+}
+
+/*
+Test the group axioms and that the scalar multiplications agree with each other.
+*/
+func TestGroupLaw(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		points := []Element{group.NewElement(), group.Generator()}
+		for i := 0; i < 4; i++ {
+			points = append(points, randomElement(group))
+		}
+		identity := group.NewElement()
+		if !identity.IsIdentity() || group.Generator().IsIdentity() {
+			t.Fatalf("Assert failure: expected NewElement to be the identity and G not to be")
+		}
+		for _, a := range points {
+			if a.Group() != group {
+				t.Errorf("Assert failure: expected an element of %s", group.Name())
+			}
+			if !group.NewElement().Add(a, identity).Equal(a) || !group.NewElement().Sub(a, a).IsIdentity() {
+				t.Errorf("Assert failure: expected a + 0 = a and a - a = 0 for %s", a)
+			}
+			if !group.NewElement().Add(a, group.NewElement().Neg(a)).IsIdentity() {
+				t.Errorf("Assert failure: expected a + (-a) = 0 for %s", a)
+			}
+			if !group.NewElement().Double(a).Equal(group.NewElement().Add(a, a)) {
+				t.Errorf("Assert failure: expected 2a = a + a for %s", a)
+			}
+			if !group.NewElement().ScalarMult(a, group.NewScalar().SetInt64(-1)).Equal(group.NewElement().Neg(a)) {
+				t.Errorf("Assert failure: expected (-1).a = -a for %s", a)
+			}
+			if !group.NewElement().Set(a).Equal(a) || !group.NewElement().Set(a).SetIdentity().IsIdentity() {
+				t.Errorf("Assert failure: unexpected Set or SetIdentity for %s", a)
+			}
+			for _, b := range points {
+				ab := group.NewElement().Add(a, b)
+				if !ab.Equal(group.NewElement().Add(b, a)) || !group.NewElement().Sub(ab, b).Equal(a) {
+					t.Errorf("Assert failure: expected a + b = b + a and (a + b) - b = a for %s and %s", a, b)
+				}
+				for _, c := range points[:3] {
+					left := group.NewElement().Add(ab, c)
+					right := group.NewElement().Add(a, group.NewElement().Add(b, c))
+					if !left.Equal(right) {
+						t.Errorf("Assert failure: expected (a + b) + c = a + (b + c)")
+					}
+				}
+			}
+		}
+		// receivers that alias the arguments
+		a := group.NewElement().Set(points[2])
+		a.Add(a, a)
+		if !a.Equal(group.NewElement().Double(points[2])) {
+			t.Errorf("Assert failure: expected a + a = 2a with an aliased receiver")
+		}
+		a.Sub(a, a)
+		if !a.IsIdentity() {
+			t.Errorf("Assert failure: expected a - a = 0 with an aliased receiver")
+		}
+	})
+}
+
+/*
+Test the variable-time, constant-time and multi-scalar multiplications against each
+other.
+*/
+func TestGroupScalarMult(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		P := randomElement(group)
+		group.Precompute(P)
+		scalars := []Scalar{group.NewScalar(), group.NewScalar().SetInt64(1), group.NewScalar().SetInt64(-1),
+			group.NewScalar().SetInt64(2), group.NewScalar().SetBigInt(group.Order())}
+		for i := 0; i < 4; i++ {
+			scalars = append(scalars, group.NewScalar().SetRandom())
+		}
+		for _, k := range scalars {
+			expected := group.NewElement().ScalarMult(group.Generator(), k)
+			if !group.NewElement().ScalarBaseMult(k).Equal(expected) || !group.ConstantTimeScalarBaseMult(k).Equal(expected) {
+				t.Errorf("Assert failure: expected k.G for %x", k.Bytes())
+			}
+			expected = group.NewElement()
+			expected.ScalarMult(P, k)
+			if !group.ConstantTimeScalarMult(P, k).Equal(expected) {
+				t.Errorf("Assert failure: expected k.P for %x", k.Bytes())
+			}
+		}
+		k1 := group.NewScalar().SetRandom()
+		k2 := group.NewScalar().SetRandom()
+		sum := group.NewElement().Add(group.NewElement().ScalarMult(P, k1), group.NewElement().ScalarMult(P, k2))
+		if !group.NewElement().ScalarMult(P, group.NewScalar().Add(k1, k2)).Equal(sum) {
+			t.Errorf("Assert failure: expected (k1 + k2).P = k1.P + k2.P")
+		}
+
+		points := make([]Element, 9)
+		expected := group.NewElement()
+		for i := range points {
+			points[i] = randomElement(group)
+			expected.Add(expected, group.NewElement().ScalarMult(points[i], scalars[i]))
+		}
+		if !group.MultiScalarMult(points, scalars).Equal(expected) || !group.ConstantTimeMultiScalarMult(points, scalars).Equal(expected) {
+			t.Errorf("Assert failure: expected the multi-scalar multiplication to be sum k_i.P_i")
+		}
+		copies := make([]Element, len(points))
+		for i := range points {
+			copies[i] = group.NewElement().Set(points[i])
+		}
+		group.Normalize(points)
+		for i := range points {
+			if !points[i].Equal(copies[i]) {
+				t.Errorf("Assert failure: expected Normalize to keep the value of point %d", i)
+			}
+		}
+	})
+}
+
+/*
+Test that the canonical encodings are decoded back, and that other encodings are
+rejected.
+*/
+func TestGroupEncoding(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		for i := 0; i < 8; i++ {
+			p := randomElement(group)
+			data := p.Bytes()
+			q, err := group.DecodeElement(data)
+			if err != nil || !q.Equal(p) || !bytes.Equal(q.Bytes(), data) {
+				t.Errorf("Assert failure: expected %s back from %x, actual: %v", p, data, err)
+			}
+			if _, err = group.DecodeElement(data[:len(data)-1]); err == nil {
+				t.Errorf("Assert failure: expected an error for a truncated encoding")
+			}
+			if _, err = group.DecodeElement(append(data, 0)); err == nil {
+				t.Errorf("Assert failure: expected an error for a long encoding")
+			}
+		}
+		if _, err := group.DecodeElement(nil); err == nil {
+			t.Errorf("Assert failure: expected an error for an empty encoding")
+		}
+		bad := bytes.Repeat([]byte{0xff}, len(group.Generator().Bytes()))
+		if _, err := group.DecodeElement(bad); err == nil {
+			t.Errorf("Assert failure: expected an error for %x", bad)
+		}
+		if !group.Generator().Equal(group.NewElement().ScalarBaseMult(group.NewScalar().SetInt64(1))) {
+			t.Errorf("Assert failure: expected G = 1.G")
+		}
+	})
+}
+
+/*
+Test that hashing to the group is deterministic, separated by the tag and that it does
+not return the identity.
+*/
+func TestGroupHashToElement(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		dst := []byte("zkproofs-test-" + group.HashSuite())
+		p := group.HashToElement([]byte("message"), dst)
+		if p.IsIdentity() || p.Group() != group {
+			t.Errorf("Assert failure: unexpected hash %s", p)
+		}
+		if !p.Equal(group.HashToElement([]byte("message"), dst)) {
+			t.Errorf("Assert failure: expected the hash to be deterministic")
+		}
+		if p.Equal(group.HashToElement([]byte("message"), []byte("other tag"))) || p.Equal(group.HashToElement([]byte("other"), dst)) {
+			t.Errorf("Assert failure: expected another element for another message or tag")
+		}
+		if !strings.Contains(string(generatorDST(group, "H")), group.HashSuite()) {
+			t.Errorf("Assert failure: expected the suite in the tag of the generators")
+		}
+	})
+}
+
+/*
+Test the scalars of every group against the integers modulo the order.
+*/
+func TestGroupScalars(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		order := group.Order()
+		size := (order.BitLen() + 7) / 8
+		for i := 0; i < 16; i++ {
+			x, _ := rand.Int(rand.Reader, order)
+			y, _ := rand.Int(rand.Reader, order)
+			a := group.NewScalar().SetBigInt(x)
+			b := group.NewScalar().SetBigInt(y)
+			cases := []struct {
+				name     string
+				actual   Scalar
+				expected *big.Int
+			}{
+				{"a + b", group.NewScalar().Add(a, b), Add(x, y)},
+				{"a - b", group.NewScalar().Sub(a, b), Sub(x, y)},
+				{"a.b", group.NewScalar().Mul(a, b), Multiply(x, y)},
+				{"-a", group.NewScalar().Neg(a), new(big.Int).Neg(x)},
+				{"1/a", group.NewScalar().Inverse(a), ModInverse(x, order)},
+				{"a", group.NewScalar().Set(a), x},
+			}
+			for _, c := range cases {
+				if c.actual.BigInt().Cmp(Mod(c.expected, order)) != 0 {
+					t.Errorf("Assert failure for %s: expected %s, actual: %s", c.name, Mod(c.expected, order), c.actual.BigInt())
+				}
+			}
+			data := a.Bytes()
+			c, err := group.NewScalar().SetBytes(data)
+			if len(data) != size || err != nil || !c.Equal(a) {
+				t.Errorf("Assert failure: expected %s back from %x, actual: %v", x, data, err)
+			}
+		}
+		if !group.NewScalar().IsZero() || !group.NewScalar().Inverse(group.NewScalar()).IsZero() {
+			t.Errorf("Assert failure: expected 0 and 1/0 = 0")
+		}
+		if group.NewScalar().SetRandom().BigInt().Cmp(order) >= 0 {
+			t.Errorf("Assert failure: expected a reduced random scalar")
+		}
+		if _, err := group.NewScalar().SetBytes(order.FillBytes(make([]byte, size))); err == nil {
+			t.Errorf("Assert failure: expected an error for a scalar that is not reduced")
+		}
+		if _, err := group.NewScalar().SetBytes(make([]byte, size+1)); err == nil {
+			t.Errorf("Assert failure: expected an error for a long encoding")
+		}
+	})
+}
+
+/*
+Test that the proofs keep their group when serialized, and that those serialized before
+groups were pluggable are read as secp256k1 proofs.
+*/
+func TestProofGroupJSON(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 0, 255, 1)
+		_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(42))
+		data, _ := DumpProof(&proof)
+		verifier, loaded, err := LoadProof(data)
+		if err != nil || verifier.Group() != group || loaded.V.Group() != group || !loaded.V.Equal(proof.V) {
+			t.Fatalf("Assert failure: expected the proof over %s back, actual: %v", group.Name(), err)
+		}
+		ok, _ := zkrp.Verify(*loaded)
+		if ok != true {
+			t.Errorf("Assert failure: expected true, actual: %t", ok)
+		}
+		_, plus, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(42))
+		data, _ = DumpProof(&plus)
+		_, loadedPlus, err := LoadRangeProof(data)
+		if err != nil || loadedPlus.Commitment().Group() != group {
+			t.Errorf("Assert failure: expected the Bulletproofs+ proof over %s back, actual: %v", group.Name(), err)
+		}
+	})
+
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 255)
+	_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(42))
+	data, _ := json.Marshal(&proof)
+	legacy := strings.Replace(string(data), "\"Group\":\"secp256k1\",", "", 1)
+	if legacy == string(data) {
+		t.Fatalf("Assert failure: expected the group in %s", data)
+	}
+	var loaded proofBP
+	if err := json.Unmarshal([]byte(legacy), &loaded); err != nil || loaded.V.Group() != Secp256k1 {
+		t.Errorf("Assert failure: expected a secp256k1 proof, actual: %v", err)
+	}
+	if ok, _ := zkrp.Verify(loaded); ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+	if err := json.Unmarshal([]byte(strings.Replace(string(data), "secp256k1", "unknown", 1)), &loaded); err == nil {
+		t.Errorf("Assert failure: expected an error for an unknown group")
+	}
+}
+
+/*
+Test that the proofs over one group are rejected, without panicking, by the parameters
+of another group.
+*/
+func TestProofGroupMismatch(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 0, 255, 2)
+		_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(42))
+		_, aggregate, _ := zkrp.GenerateAggregateProof([]*big.Int{big.NewInt(1), big.NewInt(2)})
+		_, plus, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(42))
+		for _, other := range Groups() {
+			if other == group {
+				continue
+			}
+			var verifier Bp
+			verifier.SetupWithGroup(other, 0, 255, 2)
+			if ok, err := verifier.Verify(proof); ok || err == nil {
+				t.Errorf("Assert failure: expected an error for a proof over %s verified over %s", group.Name(), other.Name())
+			}
+			if ok, err := verifier.VerifyAggregate(aggregate); ok || err == nil {
+				t.Errorf("Assert failure: expected an error for an aggregated proof over %s", group.Name())
+			}
+			if ok, err := verifier.VerifyPlus(plus); ok || err == nil {
+				t.Errorf("Assert failure: expected an error for a Bulletproofs+ proof over %s", group.Name())
+			}
+			if ok, failed, _ := verifier.VerifyBatch([]proofBP{proof}); ok || len(failed) != 1 {
+				t.Errorf("Assert failure: expected the proof over %s to fail the batch", group.Name())
+			}
+			if _, _, _, err := verifier.Rewind(proof, []byte("nonce")); err == nil {
+				t.Errorf("Assert failure: expected an error when rewinding a proof over %s", group.Name())
+			}
+			if VerifyPedersenCommitment([]PedersenCommitment{proof.V}, []PedersenCommitment{verifier.H}, new(big.Int)) {
+				t.Errorf("Assert failure: expected commitments over two groups not to balance")
+			}
+		}
+	})
+}
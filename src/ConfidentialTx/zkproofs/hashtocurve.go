@@ -0,0 +1,294 @@
+/*
+This file contains the hash to curve of RFC 9380, "Hashing to Elliptic Curves", with
+the suite secp256k1_XMD:SHA-256_SSWU_RO_, and the derivation of the generators built
+on it. A message is hashed to two field elements with expand_message_xmd and SHA-256,
+each of them is mapped with the simplified SWU map to the curve E' isogenous to
+secp256k1 and sent to secp256k1 by the 3-isogeny of Appendix E.1, and the two points
+are added. The cofactor of secp256k1 is 1.
+
+The generators are derived with version 1 of the following scheme, so that other
+implementations can reproduce them:
+
+	H    = hash_to_curve(seed, "ConfidentialTx-generators-V01-H-with-secp256k1_XMD:SHA-256_SSWU_RO_")
+	Gg_i = hash_to_curve(I2OSP(i, 8) || seed, "ConfidentialTx-generators-V01-Gg-with-secp256k1_XMD:SHA-256_SSWU_RO_")
+	Hh_i = hash_to_curve(I2OSP(i, 8) || seed, "ConfidentialTx-generators-V01-Hh-with-secp256k1_XMD:SHA-256_SSWU_RO_")
+
+where I2OSP(i, 8) is the index i in 8 bytes big endian. Every kind of generator has
+its own domain separation tag, as the random oracles must be independent, and a new
+derivation must come with a new version. The generators of the other groups are derived
+the same way with their own hash to group, whose suite replaces the one of secp256k1 in
+the tags.
+*/
+
+package zkproofs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math/big"
+)
+
+var (
+	H2CSUITE          = "secp256k1_XMD:SHA-256_SSWU_RO_"
+	GENERATORSVERSION = "V01"
+)
+
+var (
+	// A' and B' of E': y^2 = x^3 + A'.x + B', and Z = -11
+	sswuA = hexField("3f8731abdd661adca08a5558f0f5d272e953d363cb6f0e5d405447c01a444533")
+	sswuB = fieldElement{1771, 0, 0, 0}
+	sswuZ = new(fieldElement).neg(&fieldElement{11, 0, 0, 0})
+	// sqrt(-Z), either root
+	sswuSqrtMinusZ = new(fieldElement).setBig(new(big.Int).ModSqrt(big.NewInt(11), CURVE.P))
+	// the constants k_(i,j) of the 3-isogeny map
+	isoXNum = [4]fieldElement{
+		hexField("8e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38daaaaa8c7"),
+		hexField("07d3d4c80bc321d5b9f315cea7fd44c5d595d2fc0bf63b92dfff1044f17c6581"),
+		hexField("534c328d23f234e6e2a413deca25caece4506144037c40314ecbd0b53d9dd262"),
+		hexField("8e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38e38daaaaa88c"),
+	}
+	isoXDen = [2]fieldElement{
+		hexField("d35771193d94918a9ca34ccbb7b640dd86cd409542f8487d9fe6b745781eb49b"),
+		hexField("edadc6f64383dc1df7c4b2d51b54225406d36b641f5e41bbc52a56612a8c6d14"),
+	}
+	isoYNum = [4]fieldElement{
+		hexField("4bda12f684bda12f684bda12f684bda12f684bda12f684bda12f684b8e38e23c"),
+		hexField("c75e0c32d5cb7c0fa9d0a54b12a0a6d5647ab046d686da6fdffc90fc201d71a3"),
+		hexField("29a6194691f91a73715209ef6512e576722830a201be2018a765e85a9ecee931"),
+		hexField("2f684bda12f684bda12f684bda12f684bda12f684bda12f684bda12f38e38d84"),
+	}
+	isoYDen = [3]fieldElement{
+		hexField("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffff93b"),
+		hexField("7a06534bb8bdb49fd5e9e6632722c2989467c1bfc8e8d978dfb425d2685c2573"),
+		hexField("6484aa716545ca2cf3a70c3fa8fe337e0a3d21162f0d6299a7bf8192bfd2a76f"),
+	}
+)
+
+func hexField(s string) fieldElement {
+	x, _ := new(big.Int).SetString(s, 16)
+	return *new(fieldElement).setBig(x)
+}
+
+/*
+generatorDST returns the domain separation tag of the generators of the group of the
+given kind, "H", "Gg" or "Hh".
+*/
+func generatorDST(group Group, kind string) []byte {
+	return []byte("ConfidentialTx-generators-" + GENERATORSVERSION + "-" + kind + "-with-" + group.HashSuite())
+}
+
+/*
+deriveGenerator returns the generator of the group of the given kind and index for
+the seed, as described at the top of this file. The index of H is ignored.
+*/
+func deriveGenerator(group Group, seed, kind string, i int64) Element {
+	var (
+		msg []byte
+	)
+	if kind != "H" {
+		msg = make([]byte, 8)
+		binary.BigEndian.PutUint64(msg, uint64(i))
+	}
+	msg = append(msg, seed...)
+	return group.HashToElement(msg, generatorDST(group, kind))
+}
+
+/*
+expandMessageXMD is expand_message_xmd of RFC 9380 with SHA-256. It returns n uniform
+bytes, with n at most 255 * 32. Tags longer than 255 bytes are hashed first.
+*/
+func expandMessageXMD(msg, dst []byte, n int) ([]byte, error) {
+	return expandMessageXMDWith(sha256.New, msg, dst, n)
+}
+
+/*
+expandMessageXMDWith is expand_message_xmd of RFC 9380 with the given hash, SHA-256
+for secp256k1 and SHA-512 for ristretto255.
+*/
+func expandMessageXMDWith(newHash func() hash.Hash, msg, dst []byte, n int) ([]byte, error) {
+	var (
+		i int
+	)
+	h := newHash()
+	size := h.Size()
+	if n > 255*size || n > 65535 {
+		return nil, errors.New("expand_message_xmd: requested length too large")
+	}
+	if len(dst) > 255 {
+		h.Write([]byte("H2C-OVERSIZE-DST-"))
+		h.Write(dst)
+		dst = h.Sum(nil)
+		h.Reset()
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+	ell := (n + size - 1) / size
+	// b_0 = H(Z_pad || msg || I2OSP(n, 2) || I2OSP(0, 1) || DST_prime)
+	h.Write(make([]byte, h.BlockSize()))
+	h.Write(msg)
+	h.Write([]byte{byte(n >> 8), byte(n), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+	// b_1 = H(b_0 || I2OSP(1, 1) || DST_prime)
+	h.Reset()
+	h.Write(b0)
+	h.Write([]byte{1})
+	h.Write(dstPrime)
+	bi := h.Sum(nil)
+	result := append(make([]byte, 0, ell*size), bi...)
+	// b_i = H(strxor(b_0, b_(i - 1)) || I2OSP(i, 1) || DST_prime)
+	i = 2
+	for i <= ell {
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		h.Reset()
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		result = append(result, bi...)
+		i = i + 1
+	}
+	return result[:n], nil
+}
+
+/*
+hashToField returns count elements of the field of secp256k1, with L = 48 bytes of
+expand_message_xmd each, which makes their bias negligible.
+*/
+func hashToField(msg, dst []byte, count int) []fieldElement {
+	const L = 48
+	uniform, _ := expandMessageXMD(msg, dst, count*L)
+	result := make([]fieldElement, count)
+	for i := range result {
+		result[i].setBig(new(big.Int).SetBytes(uniform[i*L : (i+1)*L]))
+	}
+	return result
+}
+
+/*
+sqrtRatio returns whether u / v is a square and sqrt(u / v) if it is, or
+sqrt(Z.u / v) otherwise, with the method of RFC 9380 for p = 3 mod 4.
+*/
+func sqrtRatio(u, v *fieldElement) (uint64, fieldElement) {
+	var (
+		tv1, tv2, tv3, y1, y2 fieldElement
+	)
+	tv1.square(v)
+	tv2.mul(u, v)
+	tv1.mul(&tv1, &tv2)
+	y1.sqrtPow(&tv1)
+	y1.mul(&y1, &tv2)
+	y2.mul(&y1, sswuSqrtMinusZ)
+	tv3.square(&y1)
+	tv3.mul(&tv3, v)
+	isQR := ctFieldEqual(&tv3, u)
+	y2.cmov(&y1, isQR)
+	return isQR & 1, y2
+}
+
+/*
+ctFieldEqual returns 2^64 - 1 if a = b and 0 otherwise, without branching.
+*/
+func ctFieldEqual(a, b *fieldElement) uint64 {
+	d := (a[0] ^ b[0]) | (a[1] ^ b[1]) | (a[2] ^ b[2]) | (a[3] ^ b[3])
+	return ctEqual(d, 0)
+}
+
+/*
+mapToCurveSSWU is the simplified SWU map of RFC 9380 to the curve E'. It returns the
+affine coordinates of the point.
+*/
+func mapToCurveSSWU(u *fieldElement) (fieldElement, fieldElement) {
+	var (
+		tv1, tv2, tv3, tv4, tv5, tv6, x, y, negTv2 fieldElement
+	)
+	tv1.square(u)
+	tv1.mul(sswuZ, &tv1)
+	tv2.square(&tv1)
+	tv2.add(&tv2, &tv1)
+	tv3.add(&tv2, &fieldOne)
+	tv3.mul(&sswuB, &tv3)
+	// tv4 = Z if tv2 = 0, -tv2 otherwise
+	negTv2.neg(&tv2)
+	tv4 = negTv2
+	tv4.cmov(sswuZ, ctFieldEqual(&tv2, &fieldElement{}))
+	tv4.mul(&sswuA, &tv4)
+	tv2.square(&tv3)
+	tv6.square(&tv4)
+	tv5.mul(&sswuA, &tv6)
+	tv2.add(&tv2, &tv5)
+	tv2.mul(&tv2, &tv3)
+	tv6.mul(&tv6, &tv4)
+	tv5.mul(&sswuB, &tv6)
+	tv2.add(&tv2, &tv5)
+	x.mul(&tv1, &tv3)
+	isGx1Square, y1 := sqrtRatio(&tv2, &tv6)
+	y.mul(&tv1, u)
+	y.mul(&y, &y1)
+	x.cmov(&tv3, ctMask(isGx1Square))
+	y.cmov(&y1, ctMask(isGx1Square))
+	// sgn0(y) = sgn0(u)
+	negY := *new(fieldElement).neg(&y)
+	y.cmov(&negY, ctMask((u[0]^y[0])&1))
+	tv4.inv(&tv4)
+	x.mul(&x, &tv4)
+	return x, y
+}
+
+/*
+isoMap sends the point (x, y) of E' to secp256k1 with the 3-isogeny of RFC 9380. The
+point at infinity is returned, as a nil point, when a denominator is zero.
+*/
+func isoMap(x, y *fieldElement) *jacobianPoint {
+	var (
+		x2, x3, xNum, xDen, yNum, yDen, t fieldElement
+	)
+	x2.square(x)
+	x3.mul(&x2, x)
+	xNum = isoXNum[0]
+	xNum.add(&xNum, t.mul(&isoXNum[1], x))
+	xNum.add(&xNum, t.mul(&isoXNum[2], &x2))
+	xNum.add(&xNum, t.mul(&isoXNum[3], &x3))
+	xDen = isoXDen[0]
+	xDen.add(&xDen, t.mul(&isoXDen[1], x))
+	xDen.add(&xDen, &x2)
+	yNum = isoYNum[0]
+	yNum.add(&yNum, t.mul(&isoYNum[1], x))
+	yNum.add(&yNum, t.mul(&isoYNum[2], &x2))
+	yNum.add(&yNum, t.mul(&isoYNum[3], &x3))
+	yDen = isoYDen[0]
+	yDen.add(&yDen, t.mul(&isoYDen[1], x))
+	yDen.add(&yDen, t.mul(&isoYDen[2], &x2))
+	yDen.add(&yDen, &x3)
+	if xDen.isZero() || yDen.isZero() {
+		return nil
+	}
+	p := new(jacobianPoint)
+	p.X.mul(&xNum, t.inv(&xDen))
+	p.Y.mul(&yNum, t.inv(&yDen))
+	p.Y.mul(&p.Y, y)
+	p.Z = fieldOne
+	return p
+}
+
+/*
+HashToCurve is hash_to_curve of RFC 9380 with the suite secp256k1_XMD:SHA-256_SSWU_RO_
+and the domain separation tag dst. The result is normalized.
+*/
+func HashToCurve(msg, dst []byte) *p256 {
+	var (
+		result p256
+	)
+	u := hashToField(msg, dst, 2)
+	for i := range u {
+		x, y := mapToCurveSSWU(&u[i])
+		if q := isoMap(&x, &y); q != nil {
+			result.jac.add(&result.jac, q)
+		}
+	}
+	batchNormalize([]*jacobianPoint{&result.jac})
+	return &result
+}
@@ -0,0 +1,174 @@
+package zkproofs
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+var (
+	h2cMessages = []string{"", "abc", "abcdef0123456789", "q128_" + strings.Repeat("q", 128), "a512_" + strings.Repeat("a", 512)}
+)
+
+/*
+Test expand_message_xmd against the vectors of RFC 9380, Appendix K.1.
+*/
+func TestExpandMessageXMD(t *testing.T) {
+	short := "QUUX-V01-CS02-with-expander-SHA256-128"
+	long := "QUUX-V01-CS02-with-expander-SHA256-128-long-DST-" + strings.Repeat("1", 208)
+	vectors := []struct {
+		dst      string
+		msg      int
+		expected string
+	}{
+		{short, 0, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{short, 1, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+		{short, 2, "eff31487c770a893cfb36f912fbfcbff40d5661771ca4b2cb4eafe524333f5c1"},
+		{short, 3, "b23a1d2b4d97b2ef7785562a7e8bac7eed54ed6e97e29aa51bfe3f12ddad1ff9"},
+		{short, 4, "4623227bcc01293b8c130bf771da8c298dede7383243dc0993d2d94823958c4c"},
+		{short, 0, "af84c27ccfd45d41914fdff5df25293e221afc53d8ad2ac06d5e3e29485dadbee0d121587713a3e0dd4d5e69e93eb7cd4f5df4cd103e188cf60cb02edc3edf18eda8576c412b18ffb658e3dd6ec849469b979d444cf7b26911a08e63cf31f9dcc541708d3491184472c2c29bb749d4286b004ceb5ee6b9a7fa5b646c993f0ced"},
+		{short, 1, "abba86a6129e366fc877aab32fc4ffc70120d8996c88aee2fe4b32d6c7b6437a647e6c3163d40b76a73cf6a5674ef1d890f95b664ee0afa5359a5c4e07985635bbecbac65d747d3d2da7ec2b8221b17b0ca9dc8a1ac1c07ea6a1e60583e2cb00058e77b7b72a298425cd1b941ad4ec65e8afc50303a22c0f99b0509b4c895f40"},
+		{short, 4, "546aff5444b5b79aa6148bd81728704c32decb73a3ba76e9e75885cad9def1d06d6792f8a7d12794e90efed817d96920d728896a4510864370c207f99bd4a608ea121700ef01ed879745ee3e4ceef777eda6d9e5e38b90c86ea6fb0b36504ba4a45d22e86f6db5dd43d98a294bebb9125d5b794e9d2a81181066eb954966a487"},
+		{long, 0, "e8dc0c8b686b7ef2074086fbdd2f30e3f8bfbd3bdf177f73f04b97ce618a3ed3"},
+		{long, 3, "01b637612bb18e840028be900a833a74414140dde0c4754c198532c3a0ba42bc"},
+		{long, 1, "1a30a5e36fbdb87077552b9d18b9f0aee16e80181d5b951d0471d55b66684914aef87dbb3626eaabf5ded8cd0686567e503853e5c84c259ba0efc37f71c839da2129fe81afdaec7fbdc0ccd4c794727a17c0d20ff0ea55e1389d6982d1241cb8d165762dbc39fb0cee4474d2cbbd468a835ae5b2f20e4f959f56ab24cd6fe267"},
+	}
+	for _, v := range vectors {
+		actual, err := expandMessageXMD([]byte(h2cMessages[v.msg]), []byte(v.dst), len(v.expected)/2)
+		if err != nil || hex.EncodeToString(actual) != v.expected {
+			t.Errorf("Assert failure for %.20q and %.20q: expected %s, actual: %x", h2cMessages[v.msg], v.dst, v.expected, actual)
+		}
+	}
+	if _, err := expandMessageXMD(nil, []byte(short), 255*32+1); err == nil {
+		t.Errorf("Assert failure: expected an error for more than 255 blocks")
+	}
+}
+
+/*
+Test expand_message_xmd with SHA-512 against the vectors of RFC 9380, Appendix K.2.
+*/
+func TestExpandMessageXMDSHA512(t *testing.T) {
+	dst := "QUUX-V01-CS02-with-expander-SHA512-256"
+	vectors := []struct {
+		msg      int
+		expected string
+	}{
+		{0, "6b9a7312411d92f921c6f68ca0b6380730a1a4d982c507211a90964c394179ba"},
+		{1, "0da749f12fbe5483eb066a5f595055679b976e93abe9be6f0f6318bce7aca8dc"},
+	}
+	for _, v := range vectors {
+		actual, err := expandMessageXMDWith(sha512.New, []byte(h2cMessages[v.msg]), []byte(dst), len(v.expected)/2)
+		if err != nil || hex.EncodeToString(actual) != v.expected {
+			t.Errorf("Assert failure for %.20q: expected %s, actual: %x", h2cMessages[v.msg], v.expected, actual)
+		}
+	}
+}
+
+/*
+Test hash_to_curve against the vectors of RFC 9380, Appendix J.8.1, for the suite
+secp256k1_XMD:SHA-256_SSWU_RO_, with the intermediate values.
+*/
+func TestHashToCurve(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-secp256k1_XMD:SHA-256_SSWU_RO_")
+	vectors := [][8]string{
+		{"6b0f9910dd2ba71c78f2ee9f04d73b5f4c5f7fc773a701abea1e573cab002fb3",
+			"1ae6c212e08fe1a5937f6202f929a2cc8ef4ee5b9782db68b0d5799fd8f09e16",
+			"74519ef88b32b425a095e4ebcc84d81b64e9e2c2675340a720bb1a1857b99f1e",
+			"c174fa322ab7c192e11748beed45b508e9fdb1ce046dee9c2cd3a2a86b410936",
+			"44548adb1b399263ded3510554d28b4bead34b8cf9a37b4bd0bd2ba4db87ae63",
+			"96eb8e2faf05e368efe5957c6167001760233e6dd2487516b46ae725c4cce0c6",
+			"c1cae290e291aee617ebaef1be6d73861479c48b841eaba9b7b5852ddfeb1346",
+			"64fa678e07ae116126f08b022a94af6de15985c996c3a91b64c406a960e51067"},
+		{"128aab5d3679a1f7601e3bdf94ced1f43e491f544767e18a4873f397b08a2b61",
+			"5897b65da3b595a813d0fdcc75c895dc531be76a03518b044daaa0f2e4689e00",
+			"07dd9432d426845fb19857d1b3a91722436604ccbbbadad8523b8fc38a5322d7",
+			"604588ef5138cffe3277bbd590b8550bcbe0e523bbaf1bed4014a467122eb33f",
+			"e9ef9794d15d4e77dde751e06c182782046b8dac05f8491eb88764fc65321f78",
+			"cb07ce53670d5314bf236ee2c871455c562dd76314aa41f012919fe8e7f717b3",
+			"3377e01eab42db296b512293120c6cee72b6ecf9f9205760bd9ff11fb3cb2c4b",
+			"7f95890f33efebd1044d382a01b1bee0900fb6116f94688d487c6c7b9c8371f6"},
+		{"ea67a7c02f2cd5d8b87715c169d055a22520f74daeb080e6180958380e2f98b9",
+			"7434d0d1a500d38380d1f9615c021857ac8d546925f5f2355319d823a478da18",
+			"576d43ab0260275adf11af990d130a5752704f79478628761720808862544b5d",
+			"643c4a7fb68ae6cff55edd66b809087434bbaff0c07f3f9ec4d49bb3c16623c3",
+			"f89d6d261a5e00fe5cf45e827b507643e67c2a947a20fd9ad71039f8b0e29ff8",
+			"b33855e0cc34a9176ead91c6c3acb1aacb1ce936d563bc1cee1dcffc806caf57",
+			"bac54083f293f1fe08e4a70137260aa90783a5cb84d3f35848b324d0674b0e3a",
+			"4436476085d4c3c4508b60fcf4389c40176adce756b398bdee27bca19758d828"},
+		{"eda89a5024fac0a8207a87e8cc4e85aa3bce10745d501a30deb87341b05bcdf5",
+			"dfe78cd116818fc2c16f3837fedbe2639fab012c407eac9dfe9245bf650ac51d",
+			"9c91513ccfe9520c9c645588dff5f9b4e92eaf6ad4ab6f1cd720d192eb58247a",
+			"c7371dcd0134412f221e386f8d68f49e7fa36f9037676e163d4a063fbf8a1fb8",
+			"10fee3284d7be6bd5912503b972fc52bf4761f47141a0015f1c6ae36848d869b",
+			"0b163d9b4bf21887364332be3eff3c870fa053cf508732900fc69a6eb0e1b672",
+			"e2167bc785333a37aa562f021f1e881defb853839babf52a7f72b102e41890e9",
+			"f2401dd95cc35867ffed4f367cd564763719fbc6a53e969fb8496a1e6685d873"},
+		{"8d862e7e7e23d7843fe16d811d46d7e6480127a6b78838c277bca17df6900e9f",
+			"68071d2530f040f081ba818d3c7188a94c900586761e9115efa47ae9bd847938",
+			"b32b0ab55977b936f1e93fdc68cec775e13245e161dbfe556bbb1f72799b4181",
+			"2f5317098360b722f132d7156a94822641b615c91f8663be69169870a12af9e8",
+			"148f98780f19388b9fa93e7dc567b5a673e5fca7079cd9cdafd71982ec4c5e12",
+			"3989645d83a433bc0c001f3dac29af861f33a6fd1e04f4b36873f5bff497298a",
+			"e3c8d35aaaf0b9b647e88a0a0a7ee5d5bed5ad38238152e4e6fd8c1f8cb7c998",
+			"8446eeb6181bf12f56a9d24e262221cc2f0c4725c7e3803024b5888ee5823aa6"},
+	}
+	hexInt := func(s string) *big.Int {
+		x, _ := new(big.Int).SetString(s, 16)
+		return x
+	}
+	for i, v := range vectors {
+		msg := []byte(h2cMessages[i])
+		u := hashToField(msg, dst, 2)
+		for j := range u {
+			if u[j].big().Cmp(hexInt(v[j])) != 0 {
+				t.Errorf("Assert failure for u%d of %.20q: expected %s, actual: %x", j, msg, v[j], u[j].big())
+			}
+			x, y := mapToCurveSSWU(&u[j])
+			q := &p256{jac: *isoMap(&x, &y)}
+			if !q.Equal(newP256(hexInt(v[2+2*j]), hexInt(v[3+2*j]))) {
+				t.Errorf("Assert failure for Q%d of %.20q: expected (%s, %s), actual: %s", j, msg, v[2+2*j], v[3+2*j], q)
+			}
+		}
+		p := HashToCurve(msg, dst)
+		x, y := p.Affine()
+		if x.Cmp(hexInt(v[6])) != 0 || y.Cmp(hexInt(v[7])) != 0 {
+			t.Errorf("Assert failure for P of %.20q: expected (%s, %s), actual: (%x, %x)", msg, v[6], v[7], x, y)
+		}
+	}
+}
+
+/*
+Test that the generators follow the versioned derivation, so that other
+implementations reproduce them.
+*/
+func TestGeneratorDerivation(t *testing.T) {
+	g, h := generators(Secp256k1, 3, SEEDH)
+	H, _ := MapToGroup(SEEDH)
+	seed := []byte(SEEDH)
+	if !H.Equal(HashToCurve(seed, []byte("ConfidentialTx-generators-V01-H-with-secp256k1_XMD:SHA-256_SSWU_RO_"))) {
+		t.Errorf("Assert failure: expected H of version 1")
+	}
+	for i := range g {
+		msg := append([]byte{0, 0, 0, 0, 0, 0, 0, byte(i)}, seed...)
+		if !g[i].Equal(HashToCurve(msg, []byte("ConfidentialTx-generators-V01-Gg-with-secp256k1_XMD:SHA-256_SSWU_RO_"))) ||
+			!h[i].Equal(HashToCurve(msg, []byte("ConfidentialTx-generators-V01-Hh-with-secp256k1_XMD:SHA-256_SSWU_RO_"))) {
+			t.Errorf("Assert failure: expected generator %d of version 1", i)
+		}
+		if g[i].Equal(h[i]) || g[i].Equal(H) || (i > 0 && g[i].Equal(g[i-1])) {
+			t.Errorf("Assert failure: expected distinct generators")
+		}
+	}
+	// the first points of version 1 for SEEDH, compressed, for other implementations
+	expected := []string{
+		"039b065d9541a32d63e878b29d44742a9f736f12a0d77ad07d14296bc798e9a262",
+		"03f83a516a3b4e0607492e255c335d031c35b4911236e86eb95b2cbd7f968ec3bb",
+		"02d03cc5e978d8f93467f9dd120526631f5acb393e6ee6eb374ac0d83886dfe969",
+	}
+	for i, p := range []Element{H, g[0], h[0]} {
+		if actual := hex.EncodeToString(p.Bytes()); actual != expected[i] {
+			t.Errorf("Assert failure: expected %s, actual: %s", expected[i], actual)
+		}
+	}
+}
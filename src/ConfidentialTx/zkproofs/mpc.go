@@ -0,0 +1,822 @@
+/*
+This file contains the multi-party computation protocol of section 4.5 of the paper:
+Bulletproofs: Short Proofs for Confidential Transactions and More
+Benedikt Bunz, Jonathan Bootle, Dan Boneh, Andrew Poelstra, Pieter Wuille and Greg Maxwell
+Each party only knows the opening of its own commitment, and a dealer, who may also be
+one of the parties, collects their messages, computes the challenges and builds one
+aggregated range proof, verified with VerifyAggregate. The protocol runs as follows:
+
+	party j                                   dealer
+	NewParty, AssignPosition(j)  -- BitCommitment -->  ReceiveBitCommitments
+	ApplyBitChallenge           <-- BitChallenge  --
+	                            -- PolyCommitment -->  ReceivePolyCommitments
+	ApplyPolyChallenge          <-- PolyChallenge --
+	                            -- ProofShare     -->  ReceiveShares
+
+Every state accepts a single message, so that a party never answers two challenges of
+the same round, which would reveal its secret. The dealer checks the share of every
+party and names the party that cheated or dropped out.
+*/
+
+package zkproofs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+/*
+BitCommitment is sent by a party in the first round: its commitment V and the
+commitments A and S to its bits and blinding vectors.
+*/
+type BitCommitment struct {
+	Position int64
+	V        Element
+	A        Element
+	S        Element
+}
+
+/*
+BitChallenge is sent by the dealer in reply to the bit commitments.
+*/
+type BitChallenge struct {
+	Y Scalar
+	Z Scalar
+}
+
+/*
+PolyCommitment is sent by a party in the second round: its commitments to the
+coefficients t1 and t2 of t(X).
+*/
+type PolyCommitment struct {
+	T1 Element
+	T2 Element
+}
+
+/*
+PolyChallenge is sent by the dealer in reply to the polynomial commitments.
+*/
+type PolyChallenge struct {
+	X Scalar
+}
+
+/*
+ProofShare is sent by a party in the last round: its share of taux, mu and tprime and
+its part of the vectors l and r of the inner product argument.
+*/
+type ProofShare struct {
+	Taux   Scalar
+	Mu     Scalar
+	Tprime Scalar
+	L      []Scalar
+	R      []Scalar
+}
+
+/*
+party contains the state of a party. A party proves the values returned by shiftValues
+for its secret, in the k consecutive slots of n bits that start at slot position.k.
+*/
+type party struct {
+	zkrp           *Bp
+	values         []*big.Int
+	gamma          Scalar
+	V              Element
+	position       int64
+	aL, aR, sL, sR []Scalar
+	alpha, rho     Scalar
+	y, z           Scalar
+	tau1, tau2     Scalar
+	used           bool
+}
+
+type (
+	PartyAwaitingPosition      struct{ party }
+	PartyAwaitingBitChallenge  struct{ party }
+	PartyAwaitingPolyChallenge struct{ party }
+)
+
+/*
+use marks the state of the party as used, or returns an error if it already was.
+*/
+func (p *party) use() error {
+	if p.used {
+		return errors.New("party has already sent its message for this round")
+	}
+	p.used = true
+	return nil
+}
+
+/*
+slots returns the number of slots of n bits used by each party.
+*/
+func (zkrp *Bp) slots() int64 {
+	return int64(len(zkrp.shifts()))
+}
+
+/*
+NewParty returns a party that proves that the secret committed with the blinding factor
+gamma belongs to [A, B].
+*/
+func NewParty(zkrp *Bp, secret, gamma *big.Int) (*PartyAwaitingPosition, error) {
+	if secret.Cmp(new(big.Int).SetInt64(zkrp.A)) < 0 || secret.Cmp(new(big.Int).SetInt64(zkrp.B)) > 0 {
+		return nil, errors.New("secret does not belong to [A, B]")
+	}
+	group := zkrp.Group()
+	gammas := []Scalar{group.NewScalar().SetBigInt(gamma)}
+	V, _ := CommitG1(group.NewScalar().SetBigInt(secret), gammas[0], zkrp.H)
+	values, _ := zkrp.shiftValues([]*big.Int{secret}, gammas)
+	return &PartyAwaitingPosition{party{zkrp: zkrp, values: values, gamma: gammas[0], V: V}}, nil
+}
+
+/*
+AssignPosition sets the position of the party in the aggregated proof and returns the
+commitments to its bits.
+*/
+func (p *PartyAwaitingPosition) AssignPosition(position int64) (*PartyAwaitingBitChallenge, *BitCommitment, error) {
+	var (
+		i, j, k, n int64
+	)
+	group := p.zkrp.Group()
+	if position < 0 || (position+1)*p.zkrp.slots()*p.zkrp.N > int64(len(p.zkrp.Gg)) {
+		return nil, nil, errors.New("position is out of the range of the parameters")
+	}
+	if err := p.use(); err != nil {
+		return nil, nil, err
+	}
+	next := &PartyAwaitingBitChallenge{p.party}
+	next.used = false
+	next.position = position
+	n = p.zkrp.N
+	k = int64(len(p.values))
+	gg, hh := next.generators()
+
+	one := group.NewScalar().SetInt64(1)
+	next.aL = make([]Scalar, k*n)
+	next.aR = make([]Scalar, k*n)
+	j = 0
+	for j < k {
+		bits, _ := Decompose(p.values[j], 2, n)
+		i = 0
+		for i < n {
+			next.aL[j*n+i] = group.NewScalar().SetInt64(bits[i])
+			next.aR[j*n+i] = group.NewScalar().Sub(next.aL[j*n+i], one)
+			i = i + 1
+		}
+		j = j + 1
+	}
+	random, err := p.zkrp.randomScalars(2*k*n + 2)
+	if err != nil {
+		return nil, nil, err
+	}
+	next.alpha = random[0]
+	A, _ := commitVector(p.zkrp.pool, next.aL, next.aR, next.alpha, p.zkrp.H, gg, hh, k*n)
+
+	next.sL = random[2 : k*n+2]
+	next.sR = random[k*n+2:]
+	next.rho = random[1]
+	S, _ := commitVector(p.zkrp.pool, next.sL, next.sR, next.rho, p.zkrp.H, gg, hh, k*n)
+	return next, &BitCommitment{Position: position, V: p.V, A: A, S: S}, nil
+}
+
+/*
+generators returns the generators g and h of the slots of the party.
+*/
+func (p *party) generators() ([]Element, []Element) {
+	start := p.position * p.zkrp.slots() * p.zkrp.N
+	end := start + p.zkrp.slots()*p.zkrp.N
+	return p.zkrp.Gg[start:end], p.zkrp.Hh[start:end]
+}
+
+/*
+offsets returns y^i for the bits i of the party and z^(2+j).2^n for its slots j, the
+parts of the vectors y^nm and z^(1+j).2^n used by proveRange that belong to the party.
+*/
+func (p *party) offsets(y, z Scalar) ([]Scalar, []Scalar) {
+	var (
+		i, k, n int64
+	)
+	group := p.zkrp.Group()
+	n = p.zkrp.N
+	k = p.zkrp.slots()
+	first := p.position * k
+	vy := scalarPowers(group, y, (first+k)*n)[first*n:]
+	z22n := p.zkrp.powersOfTwoZ(z, k)
+	zfirst := scalarPowers(group, z, first+1)[first]
+	i = 0
+	for i < k*n {
+		z22n[i].Mul(z22n[i], zfirst)
+		i = i + 1
+	}
+	return vy, z22n
+}
+
+/*
+ApplyBitChallenge computes the coefficients t1 and t2 of the share of the party of t(X)
+and returns the commitments to them.
+*/
+func (p *PartyAwaitingBitChallenge) ApplyBitChallenge(c *BitChallenge) (*PartyAwaitingPolyChallenge, *PolyCommitment, error) {
+	var (
+		i int64
+	)
+	group := p.zkrp.Group()
+	if c == nil || !sameScalarGroup(group, c.Y, c.Z) {
+		return nil, nil, errors.New("dealer sent an invalid bit challenge")
+	}
+	y := group.NewScalar().Set(c.Y)
+	z := group.NewScalar().Set(c.Z)
+	if y.IsZero() || z.IsZero() {
+		return nil, nil, errors.New("dealer sent an invalid bit challenge")
+	}
+	if err := p.use(); err != nil {
+		return nil, nil, err
+	}
+	next := &PartyAwaitingPolyChallenge{p.party}
+	next.used = false
+	next.y = y
+	next.z = z
+	vy, z22n := next.offsets(y, z)
+
+	// t1 = < aL - z.1, y^n . sR > + < sL, y^n . (aR + z.1) + z^(1+j).2^n >
+	// t2 = < sL, y^n . sR >
+	t1 := group.NewScalar()
+	t2 := group.NewScalar()
+	l0 := group.NewScalar()
+	r0 := group.NewScalar()
+	r1 := group.NewScalar()
+	t := group.NewScalar()
+	i = 0
+	for i < int64(len(p.aL)) {
+		l0.Sub(p.aL[i], z)
+		r0.Add(p.aR[i], z)
+		r0.Mul(r0, vy[i])
+		r0.Add(r0, z22n[i])
+		r1.Mul(vy[i], p.sR[i])
+		t1.Add(t1, t.Mul(l0, r1))
+		t1.Add(t1, t.Mul(p.sL[i], r0))
+		t2.Add(t2, t.Mul(p.sL[i], r1))
+		i = i + 1
+	}
+
+	random, err := p.zkrp.randomScalars(2)
+	if err != nil {
+		return nil, nil, err
+	}
+	next.tau1, next.tau2 = random[0], random[1]
+	T1, _ := CommitG1(t1, next.tau1, p.zkrp.H)
+	T2, _ := CommitG1(t2, next.tau2, p.zkrp.H)
+	return next, &PolyCommitment{T1: T1, T2: T2}, nil
+}
+
+/*
+ApplyPolyChallenge returns the share of the party of the proof.
+*/
+func (p *PartyAwaitingPolyChallenge) ApplyPolyChallenge(c *PolyChallenge) (*ProofShare, error) {
+	var (
+		i, j int64
+	)
+	group := p.zkrp.Group()
+	if c == nil || !sameScalarGroup(group, c.X) {
+		return nil, errors.New("dealer sent an invalid polynomial challenge")
+	}
+	x := group.NewScalar().Set(c.X)
+	if x.IsZero() {
+		return nil, errors.New("dealer sent an invalid polynomial challenge")
+	}
+	if err := p.use(); err != nil {
+		return nil, err
+	}
+	vy, z22n := p.offsets(p.y, p.z)
+
+	// l = aL - z.1 + sL.x and r = y^n . (aR + z.1 + sR.x) + z^(1+j).2^n
+	l := make([]Scalar, len(p.aL))
+	r := make([]Scalar, len(p.aL))
+	t := group.NewScalar()
+	i = 0
+	for i < int64(len(p.aL)) {
+		l[i] = group.NewScalar().Sub(p.aL[i], p.z)
+		l[i].Add(l[i], t.Mul(p.sL[i], x))
+		r[i] = group.NewScalar().Add(p.aR[i], p.z)
+		r[i].Add(r[i], t.Mul(p.sR[i], x))
+		r[i].Mul(r[i], vy[i])
+		r[i].Add(r[i], z22n[i])
+		i = i + 1
+	}
+	tprime := innerProduct(group, l, r)
+
+	// taux = tau2.x^2 + tau1.x + sum_j z^(1+j).gamma
+	taux := group.NewScalar().Mul(p.tau2, x)
+	taux.Add(taux, p.tau1)
+	taux.Mul(taux, x)
+	zj := scalarPowers(group, p.z, 3+p.position*p.zkrp.slots())[2+p.position*p.zkrp.slots()]
+	j = 0
+	for j < int64(len(p.values)) {
+		taux.Add(taux, t.Mul(zj, p.gamma))
+		zj.Mul(zj, p.z)
+		j = j + 1
+	}
+
+	// mu = alpha + rho.x
+	mu := group.NewScalar().Mul(p.rho, x)
+	mu.Add(mu, p.alpha)
+
+	return &ProofShare{
+		Taux:   taux,
+		Mu:     mu,
+		Tprime: tprime,
+		L:      l,
+		R:      r,
+	}, nil
+}
+
+/*
+dealer contains the state of the dealer.
+*/
+type dealer struct {
+	zkrp       *Bp
+	transcript *Transcript
+	m          int64
+	bits       []*BitCommitment
+	polys      []*PolyCommitment
+	A, S       Element
+	T1, T2     Element
+	y, z, x    Scalar
+	used       bool
+}
+
+type (
+	DealerAwaitingBitCommitments  struct{ dealer }
+	DealerAwaitingPolyCommitments struct{ dealer }
+	DealerAwaitingProofShares     struct{ dealer }
+)
+
+/*
+use marks the state of the dealer as used, or returns an error if it already was.
+*/
+func (d *dealer) use() error {
+	if d.used {
+		return errors.New("dealer has already received the messages of this round")
+	}
+	d.used = true
+	return nil
+}
+
+/*
+NewDealer returns a dealer of the aggregated proof of the secrets of m parties, whose
+challenges are bound to the transcript.
+*/
+func NewDealer(zkrp *Bp, transcript *Transcript, m int64) (*DealerAwaitingBitCommitments, error) {
+	if m > zkrp.M {
+		return nil, errors.New("number of values must be between 1 and the M given to SetupAggregate")
+	}
+	if err := zkrp.checkRange(m * zkrp.slots()); err != nil {
+		return nil, err
+	}
+	return &DealerAwaitingBitCommitments{dealer{zkrp: zkrp, transcript: transcript, m: m}}, nil
+}
+
+/*
+commitments returns the commitments V of the parties.
+*/
+func (d *dealer) commitments() []Element {
+	V := make([]Element, d.m)
+	for j := range d.bits {
+		V[j] = d.bits[j].V
+	}
+	return V
+}
+
+/*
+ReceiveBitCommitments receives the bit commitment of each party, ordered by position,
+and returns the challenge sent back to all of them.
+*/
+func (d *DealerAwaitingBitCommitments) ReceiveBitCommitments(bits []*BitCommitment) (*DealerAwaitingPolyCommitments, *BitChallenge, error) {
+	group := d.zkrp.Group()
+	if int64(len(bits)) != d.m {
+		return nil, nil, fmt.Errorf("expected %d bit commitments, received %d", d.m, len(bits))
+	}
+	for j, bc := range bits {
+		if bc == nil || bc.V == nil || bc.A == nil || bc.S == nil {
+			return nil, nil, fmt.Errorf("party %d dropped out before sending its bit commitment", j)
+		}
+		if !sameGroup(group, bc.V, bc.A, bc.S) {
+			return nil, nil, fmt.Errorf("party %d sent a bit commitment over another group", j)
+		}
+		if bc.Position != int64(j) {
+			return nil, nil, fmt.Errorf("party %d sent the bit commitment of position %d", j, bc.Position)
+		}
+	}
+	if err := d.use(); err != nil {
+		return nil, nil, err
+	}
+	next := &DealerAwaitingPolyCommitments{d.dealer}
+	next.used = false
+	next.bits = bits
+	next.A = group.NewElement()
+	next.S = group.NewElement()
+	for _, bc := range bits {
+		next.A.Add(next.A, bc.A)
+		next.S.Add(next.S, bc.S)
+	}
+	next.zkrp.rangeDomainSep(next.transcript, next.zkrp.shiftCommitments(next.commitments()))
+	group.Normalize([]Element{next.A, next.S})
+	next.transcript.AppendPoint("A", next.A)
+	next.transcript.AppendPoint("S", next.S)
+	next.y = next.transcript.ChallengeScalar("y", group)
+	next.z = next.transcript.ChallengeScalar("z", group)
+	return next, &BitChallenge{Y: group.NewScalar().Set(next.y), Z: group.NewScalar().Set(next.z)}, nil
+}
+
+/*
+ReceivePolyCommitments receives the polynomial commitment of each party, ordered by
+position, and returns the challenge sent back to all of them.
+*/
+func (d *DealerAwaitingPolyCommitments) ReceivePolyCommitments(polys []*PolyCommitment) (*DealerAwaitingProofShares, *PolyChallenge, error) {
+	group := d.zkrp.Group()
+	if int64(len(polys)) != d.m {
+		return nil, nil, fmt.Errorf("expected %d polynomial commitments, received %d", d.m, len(polys))
+	}
+	for j, pc := range polys {
+		if pc == nil || pc.T1 == nil || pc.T2 == nil {
+			return nil, nil, fmt.Errorf("party %d dropped out before sending its polynomial commitment", j)
+		}
+		if !sameGroup(group, pc.T1, pc.T2) {
+			return nil, nil, fmt.Errorf("party %d sent a polynomial commitment over another group", j)
+		}
+	}
+	if err := d.use(); err != nil {
+		return nil, nil, err
+	}
+	next := &DealerAwaitingProofShares{d.dealer}
+	next.used = false
+	next.polys = polys
+	next.T1 = group.NewElement()
+	next.T2 = group.NewElement()
+	for _, pc := range polys {
+		next.T1.Add(next.T1, pc.T1)
+		next.T2.Add(next.T2, pc.T2)
+	}
+	group.Normalize([]Element{next.T1, next.T2})
+	next.transcript.AppendPoint("T1", next.T1)
+	next.transcript.AppendPoint("T2", next.T2)
+	next.x = next.transcript.ChallengeScalar("x", group)
+	return next, &PolyChallenge{X: group.NewScalar().Set(next.x)}, nil
+}
+
+/*
+ReceiveShares receives the proof share of each party, ordered by position, checks
+them and returns the aggregated proof of all the secrets.
+*/
+func (d *DealerAwaitingProofShares) ReceiveShares(shares []*ProofShare) (proofAggBP, error) {
+	var (
+		proof proofAggBP
+	)
+	group := d.zkrp.Group()
+	if int64(len(shares)) != d.m {
+		return proof, fmt.Errorf("expected %d proof shares, received %d", d.m, len(shares))
+	}
+	for j, share := range shares {
+		if share == nil || share.Taux == nil || share.Mu == nil || share.Tprime == nil {
+			return proof, fmt.Errorf("party %d dropped out before sending its proof share", j)
+		}
+		if !sameScalarGroup(group, share.Taux, share.Mu, share.Tprime) {
+			return proof, fmt.Errorf("party %d sent a proof share over another group", j)
+		}
+		if !d.checkShare(int64(j), share) {
+			return proof, fmt.Errorf("party %d sent an invalid proof share", j)
+		}
+	}
+	if err := d.use(); err != nil {
+		return proof, err
+	}
+
+	taux := group.NewScalar()
+	mu := group.NewScalar()
+	tprime := group.NewScalar()
+	var l, r []Scalar
+	for _, share := range shares {
+		taux.Add(taux, share.Taux)
+		mu.Add(mu, share.Mu)
+		tprime.Add(tprime, share.Tprime)
+		l = append(l, share.L...)
+		r = append(r, share.R...)
+	}
+	padded := d.zkrp.paddedSize(d.m * d.zkrp.slots())
+	proofip, err := d.zkrp.proveInnerProduct(d.transcript, d.y, taux, mu, tprime, padVector(group, l, padded), padVector(group, r, padded))
+	if err != nil {
+		return proof, err
+	}
+	proof.V = d.commitments()
+	proof.A = d.A
+	proof.S = d.S
+	proof.T1 = d.T1
+	proof.T2 = d.T2
+	proof.Taux = taux
+	proof.Mu = mu
+	proof.Tprime = tprime
+	proof.Proofip = proofip
+	return proof, nil
+}
+
+/*
+checkShare returns true if and only if the share of party j is consistent with its
+commitments, i.e. tprime = <l, r>, g^tprime.h^taux = V^(z^(1+j)).g^delta.T1^x.T2^(x^2)
+over the slots of the party and g^l.h'^r.h^mu = A.S^x.g^-z.h'^(z.y^n + z^(1+j).2^n).
+*/
+func (d *DealerAwaitingProofShares) checkShare(j int64, share *ProofShare) bool {
+	var (
+		i, k, n int64
+	)
+	group := d.zkrp.Group()
+	n = d.zkrp.N
+	k = d.zkrp.slots()
+	if int64(len(share.L)) != k*n || int64(len(share.R)) != k*n {
+		return false
+	}
+	if !sameScalarGroup(group, share.L...) || !sameScalarGroup(group, share.R...) {
+		return false
+	}
+	p := party{zkrp: d.zkrp, position: j}
+	vy, z22n := p.offsets(d.y, d.z)
+	gg, hh := p.generators()
+	l, r := share.L, share.R
+	if !innerProduct(group, l, r).Equal(share.Tprime) {
+		return false
+	}
+
+	// delta = (z - z^2).<1, y^n> - sum_j z^(2+j).<1, 2^n>, over the slots of the party
+	zz2 := group.NewScalar().Mul(d.z, d.z)
+	zz2.Sub(d.z, zz2)
+	delta := group.NewScalar()
+	t := group.NewScalar()
+	i = 0
+	for i < k*n {
+		delta.Add(delta, t.Mul(zz2, vy[i]))
+		delta.Sub(delta, t.Mul(d.z, z22n[i]))
+		i = i + 1
+	}
+	lhs, _ := CommitG1(share.Tprime, share.Taux, d.zkrp.H)
+	rhs := group.NewElement().ScalarBaseMult(delta)
+	zj := scalarPowers(group, d.z, 3+j*k)[2+j*k]
+	for _, Vs := range d.zkrp.shiftCommitments([]Element{d.bits[j].V}) {
+		rhs.Add(rhs, group.NewElement().ScalarMult(Vs, zj))
+		zj.Mul(zj, d.z)
+	}
+	rhs.Add(rhs, group.NewElement().ScalarMult(d.polys[j].T1, d.x))
+	rhs.Add(rhs, group.NewElement().ScalarMult(d.polys[j].T2, t.Mul(d.x, d.x)))
+	rhs.Add(rhs, lhs.Neg(lhs))
+	if !rhs.IsIdentity() {
+		return false
+	}
+
+	// with h'_i = h_i^(y^-i): g^l.h^(y^-i.r).h^mu.(A.S^x.g^-z.h^(z + y^-i.z^(1+j).2^n))^-1
+	yinv := inverses(group, vy)
+	points := make([]Element, 0, 2*k*n+3)
+	scalars := make([]Scalar, 0, 2*k*n+3)
+	points = append(points, d.zkrp.H, d.bits[j].A, d.bits[j].S)
+	scalars = append(scalars, share.Mu, group.NewScalar().SetInt64(-1), group.NewScalar().Neg(d.x))
+	i = 0
+	for i < k*n {
+		points = append(points, gg[i], hh[i])
+		scalars = append(scalars, group.NewScalar().Add(l[i], d.z))
+		hexp := group.NewScalar().Sub(r[i], z22n[i])
+		hexp.Mul(hexp, yinv[i])
+		scalars = append(scalars, hexp.Sub(hexp, d.z))
+		i = i + 1
+	}
+	result, _ := VectorExp(points, scalars)
+	return result.IsIdentity()
+}
+
+type (
+	bitcommitmentstring struct {
+		Position int64
+		Group    string
+		V        pstring
+		A        pstring
+		S        pstring
+	}
+	bitchallengestring struct {
+		Group string
+		Y     string
+		Z     string
+	}
+	polycommitmentstring struct {
+		Group string
+		T1    pstring
+		T2    pstring
+	}
+	polychallengestring struct {
+		Group string
+		X     string
+	}
+	proofsharestring struct {
+		Group  string
+		Taux   string
+		Mu     string
+		Tprime string
+		L      []string
+		R      []string
+	}
+)
+
+/*
+scalarStrings writes each scalar with scalarString.
+*/
+func scalarStrings(a []Scalar) []string {
+	result := make([]string, len(a))
+	for i := range a {
+		result[i] = scalarString(a[i])
+	}
+	return result
+}
+
+/*
+scalarVector decodes the scalars written by scalarStrings.
+*/
+func scalarVector(group Group, s []string) ([]Scalar, error) {
+	var (
+		err error
+	)
+	result := make([]Scalar, len(s))
+	for i := range s {
+		if result[i], err = scalar(group, s[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (c *BitCommitment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bitcommitmentstring{
+		Position: c.Position,
+		Group:    c.V.Group().Name(),
+		V:        newPstring(c.V),
+		A:        newPstring(c.A),
+		S:        newPstring(c.S),
+	})
+}
+
+func (c *BitCommitment) UnmarshalJSON(data []byte) error {
+	var (
+		err error
+		aux bitcommitmentstring
+		msg BitCommitment
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	msg.Position = aux.Position
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if msg.V, err = aux.V.point(group); err != nil {
+		return err
+	}
+	if msg.A, err = aux.A.point(group); err != nil {
+		return err
+	}
+	if msg.S, err = aux.S.point(group); err != nil {
+		return err
+	}
+	*c = msg
+	return nil
+}
+
+func (c *BitChallenge) MarshalJSON() ([]byte, error) {
+	group, err := groupOfScalar(c.Y)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(bitchallengestring{Group: group.Name(), Y: scalarString(c.Y), Z: scalarString(c.Z)})
+}
+
+func (c *BitChallenge) UnmarshalJSON(data []byte) error {
+	var (
+		err error
+		aux bitchallengestring
+		msg BitChallenge
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if msg.Y, err = scalar(group, aux.Y); err != nil {
+		return err
+	}
+	if msg.Z, err = scalar(group, aux.Z); err != nil {
+		return err
+	}
+	*c = msg
+	return nil
+}
+
+func (c *PolyCommitment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(polycommitmentstring{Group: c.T1.Group().Name(), T1: newPstring(c.T1), T2: newPstring(c.T2)})
+}
+
+func (c *PolyCommitment) UnmarshalJSON(data []byte) error {
+	var (
+		err error
+		aux polycommitmentstring
+		msg PolyCommitment
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if msg.T1, err = aux.T1.point(group); err != nil {
+		return err
+	}
+	if msg.T2, err = aux.T2.point(group); err != nil {
+		return err
+	}
+	*c = msg
+	return nil
+}
+
+func (c *PolyChallenge) MarshalJSON() ([]byte, error) {
+	group, err := groupOfScalar(c.X)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(polychallengestring{Group: group.Name(), X: scalarString(c.X)})
+}
+
+func (c *PolyChallenge) UnmarshalJSON(data []byte) error {
+	var (
+		err error
+		aux polychallengestring
+		msg PolyChallenge
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if msg.X, err = scalar(group, aux.X); err != nil {
+		return err
+	}
+	*c = msg
+	return nil
+}
+
+func (s *ProofShare) MarshalJSON() ([]byte, error) {
+	group, err := groupOfScalar(s.Taux)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(proofsharestring{
+		Group:  group.Name(),
+		Taux:   scalarString(s.Taux),
+		Mu:     scalarString(s.Mu),
+		Tprime: scalarString(s.Tprime),
+		L:      scalarStrings(s.L),
+		R:      scalarStrings(s.R),
+	})
+}
+
+func (s *ProofShare) UnmarshalJSON(data []byte) error {
+	var (
+		err error
+		aux proofsharestring
+		msg ProofShare
+	)
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.L) != len(aux.R) {
+		return errors.New("proof share must have as many l as r")
+	}
+	group, err := groupOrDefault(aux.Group)
+	if err != nil {
+		return err
+	}
+	if msg.Taux, err = scalar(group, aux.Taux); err != nil {
+		return err
+	}
+	if msg.Mu, err = scalar(group, aux.Mu); err != nil {
+		return err
+	}
+	if msg.Tprime, err = scalar(group, aux.Tprime); err != nil {
+		return err
+	}
+	if msg.L, err = scalarVector(group, aux.L); err != nil {
+		return err
+	}
+	if msg.R, err = scalarVector(group, aux.R); err != nil {
+		return err
+	}
+	*s = msg
+	return nil
+}
@@ -0,0 +1,277 @@
+package zkproofs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/google"
+)
+
+/*
+roundTrip serializes the message and decodes it into out, as if it was sent over the
+network.
+*/
+func roundTrip(t *testing.T, in, out interface{}) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err = json.Unmarshal(data, out); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+/*
+newParties returns a party in the first state for each secret.
+*/
+func newParties(t *testing.T, zkrp *Bp, secrets []int64) []*PartyAwaitingPosition {
+	parties := make([]*PartyAwaitingPosition, len(secrets))
+	for j, secret := range secrets {
+		gamma, _ := rand.Int(rand.Reader, zkrp.Group().Order())
+		p, err := NewParty(zkrp, new(big.Int).SetInt64(secret), gamma)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		parties[j] = p
+	}
+	return parties
+}
+
+/*
+runMPC runs the protocol between the dealer and the parties, with every message
+serialized, and lets tamper change the proof shares before the dealer receives them.
+*/
+func runMPC(t *testing.T, zkrp *Bp, secrets []int64, tamper func([]*ProofShare)) (proofAggBP, error) {
+	m := len(secrets)
+	dealer, err := NewDealer(zkrp, NewTranscript("test"), int64(m))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	parties := newParties(t, zkrp, secrets)
+
+	bits := make([]*BitCommitment, m)
+	waitBits := make([]*PartyAwaitingBitChallenge, m)
+	for j := range parties {
+		var bc *BitCommitment
+		waitBits[j], bc, err = parties[j].AssignPosition(int64(j))
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		bits[j] = new(BitCommitment)
+		roundTrip(t, bc, bits[j])
+	}
+	dealerPoly, bc, err := dealer.ReceiveBitCommitments(bits)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	polys := make([]*PolyCommitment, m)
+	waitPolys := make([]*PartyAwaitingPolyChallenge, m)
+	for j := range parties {
+		var challenge BitChallenge
+		var pc *PolyCommitment
+		roundTrip(t, bc, &challenge)
+		waitPolys[j], pc, err = waitBits[j].ApplyBitChallenge(&challenge)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		polys[j] = new(PolyCommitment)
+		roundTrip(t, pc, polys[j])
+	}
+	dealerShares, pc, err := dealerPoly.ReceivePolyCommitments(polys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	shares := make([]*ProofShare, m)
+	for j := range parties {
+		var challenge PolyChallenge
+		roundTrip(t, pc, &challenge)
+		share, err := waitPolys[j].ApplyPolyChallenge(&challenge)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		shares[j] = new(ProofShare)
+		roundTrip(t, share, shares[j])
+	}
+	if tamper != nil {
+		tamper(shares)
+	}
+	return dealerShares.ReceiveShares(shares)
+}
+
+/*
+Test that the proof built by the dealer from the shares of the parties is verified as
+an aggregated proof, with one and two shifts per secret.
+*/
+func TestMPCAggregateProof(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		zkrp.SetupWithGroup(group, 0, 255, 4)
+		proof, err := runMPC(t, &zkrp, []int64{0, 17, 200, 255}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		ok, _ := zkrp.VerifyAggregateWithTranscript(NewTranscript("test"), proof)
+		if ok != true {
+			t.Errorf("Assert failure: expected true, actual: %t", ok)
+		}
+		ok, _ = zkrp.VerifyAggregateWithTranscript(NewTranscript("other"), proof)
+		if ok != false {
+			t.Errorf("Assert failure: expected false, actual: %t", ok)
+		}
+
+		zkrp.SetupWithGroup(group, 18, 200, 2)
+		proof, err = runMPC(t, &zkrp, []int64{18, 150}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		ok, _ = zkrp.VerifyAggregateWithTranscript(NewTranscript("test"), proof)
+		if ok != true {
+			t.Errorf("Assert failure: expected true, actual: %t", ok)
+		}
+	})
+}
+
+/*
+Test that the dealer rejects a party that sends an invalid proof share.
+*/
+func TestMPCCheatingParty(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 255, 4)
+	one := Secp256k1.NewScalar().SetInt64(1)
+	tampers := []func([]*ProofShare){
+		func(s []*ProofShare) { s[1].Taux = Secp256k1.NewScalar().Add(s[1].Taux, one) },
+		func(s []*ProofShare) { s[1].Tprime = Secp256k1.NewScalar().Add(s[1].Tprime, one) },
+		func(s []*ProofShare) { s[1].L[3] = Secp256k1.NewScalar().Add(s[1].L[3], one) },
+		func(s []*ProofShare) { s[1].R = s[1].R[1:] },
+		func(s []*ProofShare) { s[1], s[2] = s[2], s[1] },
+	}
+	for i, tamper := range tampers {
+		_, err := runMPC(t, &zkrp, []int64{10, 20, 30, 40}, tamper)
+		if err == nil || !strings.Contains(err.Error(), "party 1 sent an invalid proof share") {
+			t.Errorf("Assert failure for case %d: expected party 1 to be rejected, actual: %v", i, err)
+		}
+	}
+}
+
+/*
+Test that a party that drops out is reported by the dealer.
+*/
+func TestMPCDropOut(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 255, 2)
+	_, err := runMPC(t, &zkrp, []int64{10, 20}, func(s []*ProofShare) { s[1] = nil })
+	if err == nil || !strings.Contains(err.Error(), "party 1 dropped out") {
+		t.Errorf("Assert failure: expected party 1 to drop out, actual: %v", err)
+	}
+	_, err = runMPC(t, &zkrp, []int64{10, 20}, func(s []*ProofShare) { s[1] = &ProofShare{} })
+	if err == nil || !strings.Contains(err.Error(), "party 1 dropped out") {
+		t.Errorf("Assert failure: expected party 1 to drop out, actual: %v", err)
+	}
+
+	dealer, _ := NewDealer(&zkrp, NewTranscript("test"), 2)
+	parties := newParties(t, &zkrp, []int64{10, 20})
+	_, bc, _ := parties[0].AssignPosition(0)
+	_, _, err = dealer.ReceiveBitCommitments([]*BitCommitment{bc})
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a missing bit commitment")
+	}
+	_, _, err = dealer.ReceiveBitCommitments([]*BitCommitment{bc, nil})
+	if err == nil || !strings.Contains(err.Error(), "party 1 dropped out") {
+		t.Errorf("Assert failure: expected party 1 to drop out, actual: %v", err)
+	}
+	_, _, err = dealer.ReceiveBitCommitments([]*BitCommitment{bc, bc})
+	if err == nil || !strings.Contains(err.Error(), "party 1 sent the bit commitment of position 0") {
+		t.Errorf("Assert failure: expected party 1 to be rejected, actual: %v", err)
+	}
+}
+
+/*
+Test that a party refuses invalid challenges, secrets out of [A, B], and to answer
+twice in the same round.
+*/
+func TestMPCPartyChecks(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 255, 2)
+	_, err := NewParty(&zkrp, new(big.Int).SetInt64(256), new(big.Int).SetInt64(1))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a secret out of [A, B]")
+	}
+	party := newParties(t, &zkrp, []int64{10})[0]
+	_, _, err = party.AssignPosition(2)
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a position out of range")
+	}
+	waitBits, _, err := party.AssignPosition(1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, _, err = party.AssignPosition(0)
+	if err == nil {
+		t.Errorf("Assert failure: expected error when assigning a second position")
+	}
+	one := Secp256k1.NewScalar().SetInt64(1)
+	_, _, err = waitBits.ApplyBitChallenge(&BitChallenge{Y: one, Z: Secp256k1.NewScalar()})
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a zero challenge")
+	}
+	_, _, err = waitBits.ApplyBitChallenge(&BitChallenge{Y: one, Z: Ristretto255.NewScalar().SetInt64(1)})
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a challenge of another group")
+	}
+	waitPoly, _, err := waitBits.ApplyBitChallenge(&BitChallenge{Y: one, Z: one})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, _, err = waitBits.ApplyBitChallenge(&BitChallenge{Y: one, Z: Secp256k1.NewScalar().SetInt64(2)})
+	if err == nil {
+		t.Errorf("Assert failure: expected error when answering a second bit challenge")
+	}
+	_, err = waitPoly.ApplyPolyChallenge(&PolyChallenge{X: one})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, err = waitPoly.ApplyPolyChallenge(&PolyChallenge{X: Secp256k1.NewScalar().SetInt64(2)})
+	if err == nil {
+		t.Errorf("Assert failure: expected error when answering a second polynomial challenge")
+	}
+}
+
+/*
+Test that malformed messages are rejected.
+*/
+func TestMPCMessageInput(t *testing.T) {
+	inputs := []struct {
+		data string
+		msg  interface{}
+	}{
+		{"{\"Position\":0,\"V\":{\"X\":\"x\",\"Y\":\"1\"}}", new(BitCommitment)},
+		{"{\"Y\":\"1\",\"Z\":\"\"}", new(BitChallenge)},
+		{"{\"T1\":{\"X\":\"1\",\"Y\":\"1\"}}", new(PolyCommitment)},
+		{"{\"X\":\"1.5\"}", new(PolyChallenge)},
+		{"{\"Taux\":\"1\",\"Mu\":\"1\",\"Tprime\":\"1\",\"L\":[\"1\"],\"R\":[]}", new(ProofShare)},
+		{"{\"Taux\":\"1\",\"Mu\":\"1\",\"Tprime\":\"1\",\"L\":[\"a\"],\"R\":[\"1\"]}", new(ProofShare)},
+		{"{\"X\":\"" + hex.EncodeToString(ORDER.Bytes()) + "\"}", new(PolyChallenge)},
+		{"{\"X\":\"-" + strings.Repeat("0", 63) + "1\"}", new(PolyChallenge)},
+		{"{\"X\":\"" + strings.Repeat("A", 64) + "\"}", new(PolyChallenge)},
+		{"{\"Group\":\"bn254\",\"Y\":\"" + hex.EncodeToString(bn256.Order.Bytes()) + "\",\"Z\":\"" + strings.Repeat("0", 63) + "1\"}", new(BitChallenge)},
+	}
+	for _, input := range inputs {
+		if err := json.Unmarshal([]byte(input.data), input.msg); err == nil {
+			t.Errorf("Assert failure: expected error for %s", input.data)
+		}
+	}
+}
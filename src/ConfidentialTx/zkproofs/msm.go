@@ -0,0 +1,172 @@
+/*
+This file contains the multi-scalar multiplication prod_i a_i^(b_i) used by VectorExp.
+Small inputs use Straus' method, which shares the doublings of all the terms and adds
+a window of 4 bits of every scalar from a table of multiples. Large inputs use
+Pippenger's method, which sorts the points in buckets by the value of a window of c
+bits of their scalar, so that every window costs about n + 2^(c+1) additions for any
+number of terms. Both work on Jacobian points, so the only inversion is the one that
+normalizes the input points.
+*/
+
+package zkproofs
+
+import (
+	"math/bits"
+)
+
+const (
+	// strausWindow is the window of Straus' method, with tables of 2^4 - 1 multiples
+	strausWindow = 4
+	// pippengerThreshold is the number of terms from which Pippenger is faster
+	pippengerThreshold = 192
+)
+
+/*
+msmTerm is a term of a multi-scalar multiplication: an affine point with Z = 1 and
+its scalar as little endian limbs.
+*/
+type msmTerm struct {
+	point  jacobianPoint
+	scalar [4]uint64
+}
+
+/*
+multiScalarMult returns prod_i a_i^(b_i).
+*/
+func multiScalarMult(a []*p256, b []Scalar) *p256 {
+	var (
+		result jacobianPoint
+	)
+	terms := msmTerms(a, b)
+	if len(terms) < pippengerThreshold {
+		result = straus(terms)
+	} else {
+		result = pippenger(terms, pippengerWindow(len(terms)))
+	}
+	return &p256{jac: result}
+}
+
+/*
+msmTerms converts the points and the scalars of a multi-scalar multiplication, and
+normalizes the points with a single inversion. Terms with a point at infinity or a
+zero scalar are skipped.
+*/
+func msmTerms(a []*p256, b []Scalar) []msmTerm {
+	var (
+		i int
+	)
+	terms := make([]msmTerm, 0, len(a))
+	i = 0
+	for i < len(a) {
+		scalar := scalarLimbs(b[i])
+		if !a[i].IsZero() && scalar != [4]uint64{} {
+			terms = append(terms, msmTerm{point: a[i].jac, scalar: scalar})
+		}
+		i = i + 1
+	}
+	points := make([]*jacobianPoint, len(terms))
+	for t := range terms {
+		points[t] = &terms[t].point
+	}
+	batchNormalize(points)
+	return terms
+}
+
+/*
+window returns the c bits of the scalar starting at bit i.
+*/
+func window(scalar *[4]uint64, i, c uint) uint64 {
+	var (
+		w uint64
+	)
+	w = scalar[i/64] >> (i % 64)
+	if i%64+c > 64 && i/64 < 3 {
+		w |= scalar[i/64+1] << (64 - i%64)
+	}
+	return w & (1<<c - 1)
+}
+
+/*
+straus computes the multi-scalar multiplication with a table of the multiples 1..15 of
+every point and 256 doublings shared by all the terms.
+*/
+func straus(terms []msmTerm) jacobianPoint {
+	var (
+		acc jacobianPoint
+		k   int
+	)
+	tables := make([][1<<strausWindow - 1]jacobianPoint, len(terms))
+	for t := range terms {
+		tables[t][0] = terms[t].point
+		k = 1
+		for k < len(tables[t]) {
+			tables[t][k].addAffine(&tables[t][k-1], &terms[t].point)
+			k = k + 1
+		}
+	}
+	for i := 256/strausWindow - 1; i >= 0; i-- {
+		for d := 0; d < strausWindow; d++ {
+			acc.double(&acc)
+		}
+		for t := range terms {
+			w := window(&terms[t].scalar, uint(i*strausWindow), strausWindow)
+			if w != 0 {
+				acc.add(&acc, &tables[t][w-1])
+			}
+		}
+	}
+	return acc
+}
+
+/*
+pippengerWindow returns the window c that minimizes the number of additions
+256/c.(n + 2^(c+1)) of Pippenger's method for n terms, about log2(n) - 2.
+*/
+func pippengerWindow(n int) uint {
+	c := bits.Len(uint(n))
+	if c > 5 {
+		c = c - 2
+	} else {
+		c = 3
+	}
+	if c > 16 {
+		c = 16
+	}
+	return uint(c)
+}
+
+/*
+pippenger computes the multi-scalar multiplication with windows of c bits. For every
+window, from the most significant one, the points are added to the bucket of their
+window value w, and sum_w w.bucket_w is computed with running sums as
+sum_w sum_(v >= w) bucket_v.
+*/
+func pippenger(terms []msmTerm, c uint) jacobianPoint {
+	var (
+		acc, running, sum jacobianPoint
+	)
+	buckets := make([]jacobianPoint, 1<<c-1)
+	windows := (256 + int(c) - 1) / int(c)
+	for i := windows - 1; i >= 0; i-- {
+		for d := uint(0); d < c; d++ {
+			acc.double(&acc)
+		}
+		for b := range buckets {
+			buckets[b] = jacobianPoint{}
+		}
+		for t := range terms {
+			w := window(&terms[t].scalar, uint(i)*c, c)
+			if w != 0 {
+				buckets[w-1].addAffine(&buckets[w-1], &terms[t].point)
+			}
+		}
+		running = jacobianPoint{}
+		sum = jacobianPoint{}
+		for b := len(buckets) - 1; b >= 0; b-- {
+			running.add(&running, &buckets[b])
+			sum.add(&sum, &running)
+		}
+		acc.add(&acc, &sum)
+	}
+	return acc
+}
@@ -0,0 +1,215 @@
+package zkproofs
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+/*
+naiveMultiExp computes prod_i a_i^(b_i) with one scalar multiplication per term.
+*/
+func naiveMultiExp(a []*p256, b []Scalar) *p256 {
+	result := new(p256).SetInfinity()
+	for i := range a {
+		result.Multiply(result, new(p256).ScalarMult(a[i], b[i]).(*p256))
+	}
+	return result
+}
+
+func randomTerms(n int) ([]*p256, []Scalar) {
+	a := make([]*p256, n)
+	b := make([]Scalar, n)
+	for i := range a {
+		a[i] = new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetRandom()).(*p256)
+		b[i] = Secp256k1.NewScalar().SetRandom()
+	}
+	return a, b
+}
+
+func samePoint(p, q *p256) bool {
+	px, py := p.Affine()
+	qx, qy := q.Affine()
+	if px == nil || qx == nil {
+		return px == nil && qx == nil
+	}
+	return px.Cmp(qx) == 0 && py.Cmp(qy) == 0
+}
+
+/*
+Test the field arithmetic against big.Int, including the values close to p.
+*/
+func TestFieldElement(t *testing.T) {
+	P := CURVE.P
+	values := []*big.Int{new(big.Int), big.NewInt(1), big.NewInt(2), new(big.Int).Sub(P, big.NewInt(1)),
+		new(big.Int).Sub(P, big.NewInt(2)), new(big.Int).Lsh(big.NewInt(1), 255), new(big.Int).Lsh(big.NewInt(1), 64)}
+	for i := 0; i < 50; i++ {
+		v, _ := rand.Int(rand.Reader, P)
+		values = append(values, v)
+	}
+	for _, x := range values {
+		for _, y := range values {
+			var a, b, r fieldElement
+			a.setBig(x)
+			b.setBig(y)
+			if r.add(&a, &b).big().Cmp(Mod(Add(x, y), P)) != 0 {
+				t.Fatalf("Assert failure: wrong sum of %s and %s", x, y)
+			}
+			if r.sub(&a, &b).big().Cmp(Mod(Sub(x, y), P)) != 0 {
+				t.Fatalf("Assert failure: wrong difference of %s and %s", x, y)
+			}
+			if r.mul(&a, &b).big().Cmp(Mod(Multiply(x, y), P)) != 0 {
+				t.Fatalf("Assert failure: wrong product of %s and %s", x, y)
+			}
+		}
+		var a, r fieldElement
+		a.setBig(x)
+		if x.Sign() != 0 && r.inv(&a).big().Cmp(ModInverse(x, P)) != 0 {
+			t.Fatalf("Assert failure: wrong inverse of %s", x)
+		}
+		e := new(big.Int).Rsh(Sub(P, big.NewInt(3)), 2)
+		if r.sqrtPow(&a).big().Cmp(new(big.Int).Exp(x, e, P)) != 0 {
+			t.Fatalf("Assert failure: wrong power (p - 3) / 4 of %s", x)
+		}
+	}
+}
+
+/*
+Test the Jacobian additions and doublings against the affine ones of secp256k1,
+including the cases P + P, P + (-P) and the point at infinity.
+*/
+func TestJacobianPoint(t *testing.T) {
+	var (
+		jp, jq, jn, o jacobianPoint
+	)
+	k, _ := rand.Int(rand.Reader, ORDER)
+	px, py := CURVE.ScalarBaseMult(k.Bytes())
+	qx, qy := CURVE.ScalarBaseMult(big.NewInt(7).Bytes())
+	nx, ny := CURVE.ScalarBaseMult(Sub(ORDER, k).Bytes())
+	jp.setAffine(px, py)
+	jq.setAffine(qx, qy)
+	jn.setAffine(nx, ny)
+	sumx, sumy := CURVE.Add(px, py, qx, qy)
+	dblx, dbly := CURVE.Double(px, py)
+	dblsumx, dblsumy := CURVE.Add(dblx, dbly, qx, qy)
+
+	point := func(r *jacobianPoint) *p256 { return &p256{jac: *r} }
+	cases := []struct {
+		name   string
+		actual *p256
+		x, y   *big.Int
+	}{
+		{"P + Q", point(new(jacobianPoint).add(&jp, &jq)), sumx, sumy},
+		{"P + Q mixed", point(new(jacobianPoint).addAffine(&jp, &jq)), sumx, sumy},
+		{"2P", point(new(jacobianPoint).double(&jp)), dblx, dbly},
+		{"P + P", point(new(jacobianPoint).add(&jp, &jp)), dblx, dbly},
+		{"P + P mixed", point(new(jacobianPoint).addAffine(&jp, &jp)), dblx, dbly},
+		{"P + -P", point(new(jacobianPoint).add(&jp, &jn)), nil, nil},
+		{"P + O", point(new(jacobianPoint).add(&jp, &o)), px, py},
+		{"O + P", point(new(jacobianPoint).add(&o, &jp)), px, py},
+		{"2O", point(new(jacobianPoint).double(&o)), nil, nil},
+		// a point with Z != 1
+		{"2P + Q", point(new(jacobianPoint).add(new(jacobianPoint).double(&jp), &jq)), dblsumx, dblsumy},
+	}
+	for _, c := range cases {
+		x, y := c.actual.Affine()
+		if (x == nil) != (c.x == nil) || (x != nil && (x.Cmp(c.x) != 0 || y.Cmp(c.y) != 0)) {
+			t.Errorf("Assert failure for %s: expected (%v, %v), actual: (%v, %v)", c.name, c.x, c.y, x, y)
+		}
+	}
+}
+
+/*
+Test that batchNormalize and equal agree with the affine coordinates of the points.
+*/
+func TestBatchNormalize(t *testing.T) {
+	var (
+		acc jacobianPoint
+	)
+	a, _ := randomTerms(10)
+	points := make([]*jacobianPoint, 0)
+	expected := make([]*p256, 0)
+	for i := range a {
+		acc.add(&acc, &a[i].jac)
+		if i%3 == 0 {
+			acc.double(&acc)
+		}
+		p := acc
+		points = append(points, &p)
+		expected = append(expected, &p256{jac: acc})
+		if i == 4 {
+			points = append(points, &jacobianPoint{}, &a[i].jac)
+			expected = append(expected, new(p256).SetInfinity(), a[i])
+		}
+	}
+	batchNormalize(points)
+	for i := range points {
+		x, y := expected[i].Affine()
+		if !points[i].isInfinity() && (!points[i].Z.equal(&fieldOne) ||
+			points[i].X.big().Cmp(x) != 0 || points[i].Y.big().Cmp(y) != 0) {
+			t.Errorf("Assert failure for point %d: expected the normalized point (%v, %v)", i, x, y)
+		}
+		if !points[i].equal(&expected[i].jac) || (i > 0 && points[i].equal(points[i-1])) {
+			t.Errorf("Assert failure for point %d: wrong comparison", i)
+		}
+	}
+}
+
+/*
+Test the multi-scalar multiplication against the naive one, for Straus and Pippenger,
+with points at infinity, zero and negated scalars, and repeated and opposite points.
+*/
+func TestMultiScalarMult(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 5, 33, pippengerThreshold - 1, pippengerThreshold, 300} {
+		a, b := randomTerms(n)
+		if n > 4 {
+			a[0] = new(p256).SetInfinity()
+			b[1] = Secp256k1.NewScalar()
+			b[2] = Secp256k1.NewScalar().SetInt64(-1)
+			b[3] = Secp256k1.NewScalar().Neg(b[3])
+			a[4] = a[3]
+		}
+		expected := naiveMultiExp(a, b)
+		actual := multiScalarMult(a, b)
+		if !samePoint(actual, expected) {
+			t.Errorf("Assert failure for n = %d: expected %v, actual: %v", n, expected, actual)
+		}
+		for _, c := range []uint{3, 5, 8} {
+			actual = pippengerMultiExp(a, b, c)
+			if !samePoint(actual, expected) {
+				t.Errorf("Assert failure for n = %d and c = %d: expected %v, actual: %v", n, c, expected, actual)
+			}
+		}
+	}
+	// P^x.(-P)^x = 1
+	a, b := randomTerms(1)
+	a = append(a, new(p256).ScalarMult(a[0], Secp256k1.NewScalar().SetInt64(-1)).(*p256))
+	b = append(b, b[0])
+	if !multiScalarMult(a, b).IsZero() {
+		t.Errorf("Assert failure: expected the point at infinity")
+	}
+}
+
+/*
+pippengerMultiExp forces Pippenger's method with the window c.
+*/
+func pippengerMultiExp(a []*p256, b []Scalar, c uint) *p256 {
+	return &p256{jac: pippenger(msmTerms(a, b), c)}
+}
+
+func BenchmarkMultiScalarMult(b *testing.B) {
+	for _, n := range []int{32, 64, 1024, 4096} {
+		points, scalars := randomTerms(n)
+		b.Run(fmt.Sprintf("msm/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				multiScalarMult(points, scalars)
+			}
+		})
+		b.Run(fmt.Sprintf("naive/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				naiveMultiExp(points, scalars)
+			}
+		})
+	}
+}
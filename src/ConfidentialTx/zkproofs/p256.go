@@ -1,5 +1,5 @@
 /*
-Encapsulates secp256k1 elliptic curve.
+Encapsulates secp256k1 elliptic curve, the default backend of Group.
 */
 
 package zkproofs
@@ -7,9 +7,10 @@ package zkproofs
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"math/big"
-	"strconv"
 
 	"../byteconversion"
 	"github.com/ethereum/go-ethereum/crypto/secp256k1"
@@ -23,142 +24,288 @@ var (
 )
 
 /*
-Elliptic Curve Point struct.
+Elliptic Curve Point struct. The point is kept in Jacobian coordinates, so that
+additions and doublings need no inversion, and converted to affine coordinates
+only to serialize it. The zero value is the point at infinity, and the group
+operations always return it as the zero value.
 */
 type p256 struct {
-	X, Y *big.Int
+	jac jacobianPoint
 }
-type PedersenCommitment = p256
 
 /*
-IsZero returns true if and only if the elliptic curve point is the point at infinity.
+PedersenCommitment is an element of the group of the parameters that made it.
 */
-func (p *p256) IsZero() bool {
-	c1 := (p.X == nil || p.Y == nil)
-	if !c1 {
-		z := new(big.Int).SetInt64(0)
-		return p.X.Cmp(z) == 0 && p.Y.Cmp(z) == 0
+type PedersenCommitment = Element
+
+/*
+newP256 returns the point with affine coordinates (x, y). Nil coordinates or (0, 0)
+stand for the point at infinity.
+*/
+func newP256(x, y *big.Int) *p256 {
+	p := new(p256)
+	p.jac.setAffine(x, y)
+	return p
+}
+
+/*
+Affine returns the affine coordinates of the point, or nil coordinates for the point
+at infinity. It needs an inversion unless the point is normalized.
+*/
+func (p *p256) Affine() (*big.Int, *big.Int) {
+	return p.jac.affine()
+}
+
+/*
+NormalizePoints converts the points to affine coordinates in place, with a single
+inversion for all of them, so that serializing them afterwards is cheap. The values
+of the points are unchanged, but they must not be read concurrently.
+*/
+func NormalizePoints(points []*p256) {
+	var (
+		i int
+	)
+	jac := make([]*jacobianPoint, len(points))
+	i = 0
+	for i < len(points) {
+		jac[i] = &points[i].jac
+		i = i + 1
 	}
-	return true
+	batchNormalize(jac)
 }
 
 /*
-Neg returns the inverse of the given elliptic curve point.
+MarshalCompressed returns the SEC1 compressed encoding of the point, i.e. the byte 2
+or 3 for an even or odd y, followed by the 32 bytes of x. The point at infinity is
+encoded as the single byte 0.
 */
-func (p *p256) Neg(a *p256) *p256 {
-	// (X, Y) -> (X, X + Y)
-	if a.IsZero() {
-		return p.SetInfinity()
+func (p *p256) MarshalCompressed() []byte {
+	x, y := p.Affine()
+	if x == nil {
+		return []byte{0}
 	}
-	one := new(big.Int).SetInt64(1)
-	mone := new(big.Int).Sub(CURVE.N, one)
-	p.ScalarMult(p, mone)
-	return p
+	result := make([]byte, 33)
+	result[0] = 2 | byte(y.Bit(0))
+	x.FillBytes(result[1:])
+	return result
 }
 
 /*
-Input points must be distinct
+MarshalUncompressed returns the SEC1 uncompressed encoding of the point, i.e. the byte
+4 followed by the 32 bytes of x and the 32 bytes of y. The point at infinity is encoded
+as the single byte 0.
 */
-func (p *p256) Add(a, b *p256) *p256 {
-	if a.IsZero() {
-		p.X = b.X
-		p.Y = b.Y
-		return p
-	} else if b.IsZero() {
-		p.X = b.X
-		p.Y = b.Y
-		return p
+func (p *p256) MarshalUncompressed() []byte {
+	x, y := p.Affine()
+	if x == nil {
+		return []byte{0}
+	}
+	result := make([]byte, 65)
+	result[0] = 4
+	x.FillBytes(result[1:33])
+	y.FillBytes(result[33:])
+	return result
+}
 
+/*
+decodePoint decodes a point in either SEC1 encoding. The coordinates must be below P
+and the point on the curve, and the point at infinity is only accepted if
+allowInfinity is set. The hybrid encodings 6 and 7 are rejected.
+*/
+func decodePoint(data []byte, allowInfinity bool) (*p256, error) {
+	switch {
+	case len(data) == 1 && data[0] == 0:
+		if !allowInfinity {
+			return nil, errors.New("unexpected point at infinity")
+		}
+		return new(p256), nil
+	case len(data) == 33 && (data[0] == 2 || data[0] == 3):
+		x := new(big.Int).SetBytes(data[1:])
+		if x.Cmp(CURVE.P) >= 0 {
+			return nil, errors.New("invalid point encoding")
+		}
+		fx, _ := F(x)
+		y := new(big.Int).ModSqrt(fx, CURVE.P)
+		if y == nil {
+			return nil, errors.New("point not on the curve")
+		}
+		if y.Bit(0) != uint(data[0]&1) {
+			y.Sub(CURVE.P, y)
+		}
+		return newP256(x, y), nil
+	case len(data) == 65 && data[0] == 4:
+		x := new(big.Int).SetBytes(data[1:33])
+		y := new(big.Int).SetBytes(data[33:])
+		if x.Cmp(CURVE.P) >= 0 || y.Cmp(CURVE.P) >= 0 {
+			return nil, errors.New("invalid point encoding")
+		}
+		p := newP256(x, y)
+		if !p.IsOnCurve() {
+			return nil, errors.New("point not on the curve")
+		}
+		return p, nil
 	}
-	if a.X.Cmp(b.X) == 0 {
-		p.X = new(big.Int)
-		p.Y = new(big.Int)
-		return p
+	return nil, errors.New("invalid point encoding")
+}
+
+/*
+MarshalBinary returns the SEC1 compressed encoding of the point.
+*/
+func (p *p256) MarshalBinary() ([]byte, error) {
+	return p.MarshalCompressed(), nil
+}
+
+/*
+UnmarshalBinary decodes a point in either SEC1 encoding with decodePoint. The point
+at infinity is rejected.
+*/
+func (p *p256) UnmarshalBinary(data []byte) error {
+	q, err := decodePoint(data, false)
+	if err != nil {
+		return err
+	}
+	p.jac = q.jac
+	return nil
+}
+
+/*
+MarshalJSON encodes the point as the hex string of its SEC1 compressed encoding.
+*/
+func (p *p256) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(p.MarshalCompressed()))
+}
+
+/*
+UnmarshalJSON decodes a point encoded by MarshalJSON, or the hex string of its SEC1
+uncompressed encoding, like UnmarshalBinary.
+*/
+func (p *p256) UnmarshalJSON(data []byte) error {
+	var (
+		s string
+	)
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
 	}
-	resx, resy := CURVE.Add(a.X, a.Y, b.X, b.Y)
-	p.X = resx
-	p.Y = resy
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalBinary(b)
+}
+
+/*
+IsZero returns true if and only if the elliptic curve point is the point at infinity.
+*/
+func (p *p256) IsZero() bool {
+	return p.jac.isInfinity()
+}
+
+/*
+IsIdentity is IsZero, for the Element interface.
+*/
+func (p *p256) IsIdentity() bool {
+	return p.IsZero()
+}
+
+/*
+Group returns Secp256k1.
+*/
+func (p *p256) Group() Group {
+	return Secp256k1
+}
+
+/*
+Bytes returns the SEC1 compressed encoding of the point.
+*/
+func (p *p256) Bytes() []byte {
+	return p.MarshalCompressed()
+}
+
+/*
+Equal returns true if and only if p and q are the same point. Elements of other
+groups are never equal to p.
+*/
+func (p *p256) Equal(q Element) bool {
+	b, ok := q.(*p256)
+	return ok && p.jac.equal(&b.jac)
+}
+
+/*
+Set sets p = a and returns p.
+*/
+func (p *p256) Set(a Element) Element {
+	p.jac = a.(*p256).jac
+	return p
+}
+
+/*
+Neg returns the inverse of the given elliptic curve point, i.e. (X, -Y).
+*/
+func (p *p256) Neg(a Element) Element {
+	p.jac.neg(&a.(*p256).jac)
+	return p
+}
+
+/*
+Add returns a + b. The Jacobian addition covers all the inputs: either point may be
+the point at infinity, a = b is a doubling and a = -b gives the point at infinity.
+*/
+func (p *p256) Add(a, b Element) Element {
+	p.jac.add(&a.(*p256).jac, &b.(*p256).jac)
+	return p
+}
+
+/*
+Sub returns a - b.
+*/
+func (p *p256) Sub(a, b Element) Element {
+	var (
+		nb jacobianPoint
+	)
+	nb.neg(&b.(*p256).jac)
+	p.jac.add(&a.(*p256).jac, &nb)
 	return p
 }
 
 /*
 Double returns 2*P, where P is the given elliptic curve point.
 */
-func (p *p256) Double(a *p256) *p256 {
-	if a.IsZero() {
-		return p.SetInfinity()
-	}
-	resx, resy := CURVE.Double(a.X, a.Y)
-	p.X = resx
-	p.Y = resy
+func (p *p256) Double(a Element) Element {
+	p.jac.double(&a.(*p256).jac)
 	return p
 }
 
 /*
-ScalarMul encapsulates the scalar Multiplication Algorithm from secp256k1.
+ScalarMult returns n.e with a window of 4 bits on Jacobian coordinates, like a
+multi-scalar multiplication of a single term, so that the only inversion normalizes e.
+Fixed bases with a precomputed table, such as G and H, use the table instead.
 */
-func (p *p256) ScalarMult(a *p256, n *big.Int) *p256 {
-	if a.IsZero() {
-		return p.SetInfinity()
-	}
-	cmp := n.Cmp(big.NewInt(0))
-	if cmp == 0 {
-		return p.SetInfinity()
+func (p *p256) ScalarMult(e Element, n Scalar) Element {
+	a := e.(*p256)
+	if table := fixedBaseTableOf(a); table != nil {
+		p.jac = table.mult(n).jac
+		return p
 	}
-	n = Mod(n, CURVE.N)
-	bn := n.Bytes()
-	resx, resy := CURVE.ScalarMult(a.X, a.Y, bn)
-	p.X = resx
-	p.Y = resy
+	p.jac = straus(msmTerms([]*p256{a}, []Scalar{n}))
 	return p
 }
 
 /*
-ScalarBaseMult returns the Scalar Multiplication by the base generator.
+ScalarBaseMult returns the Scalar Multiplication by the base generator, with its
+precomputed table.
 */
-func (p *p256) ScalarBaseMult(n *big.Int) *p256 {
-	cmp := n.Cmp(big.NewInt(0))
-	if cmp == 0 {
-		return p.SetInfinity()
-	}
-	n = Mod(n, CURVE.N)
-	bn := n.Bytes()
-	resx, resy := CURVE.ScalarBaseMult(bn)
-	p.X = resx
-	p.Y = resy
+func (p *p256) ScalarBaseMult(n Scalar) Element {
+	p.jac = baseTable().mult(n).jac
 	return p
 }
 
 /*
 Multiply actually is reponsible for the addition of elliptic curve points.
-The name here is to maintain compatibility with bn256 interface.
-This algorithm verifies if the given elliptic curve points are equal, in which case it
-returns the result of Double function, otherwise it returns the result of Add function.
+The name here is to maintain compatibility with bn256 interface. It is the same as
+Add.
 */
 func (p *p256) Multiply(a, b *p256) *p256 {
-	if a.IsZero() {
-		p.X = b.X
-		p.Y = b.Y
-		return p
-	} else if b.IsZero() {
-		p.X = a.X
-		p.Y = a.Y
-		return p
-	}
-	if a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0 {
-		resx, resy := CURVE.Double(a.X, a.Y)
-		p.X = resx
-		p.Y = resy
-		return p
-	}
-	if a.X.Cmp(b.X) == 0 {
-		p.X = new(big.Int)
-		p.Y = new(big.Int)
-		return p
-	}
-	resx, resy := CURVE.Add(a.X, a.Y, b.X, b.Y)
-	p.X = resx
-	p.Y = resy
+	p.jac.add(&a.jac, &b.jac)
 	return p
 }
 
@@ -166,17 +313,24 @@ func (p *p256) Multiply(a, b *p256) *p256 {
 SetInfinity sets the given elliptic curve point to the point at infinity.
 */
 func (p *p256) SetInfinity() *p256 {
-	p.X = nil
-	p.Y = nil
+	p.jac = jacobianPoint{}
 	return p
 }
 
+/*
+SetIdentity is SetInfinity, for the Element interface.
+*/
+func (p *p256) SetIdentity() Element {
+	return p.SetInfinity()
+}
+
 /*
 String returns the readable representation of the given elliptic curve point, i.e.
 the tuple formed by X and Y coordinates.
 */
 func (p *p256) String() string {
-	return "p256(" + p.X.String() + "," + p.Y.String() + ")"
+	x, y := p.Affine()
+	return "p256(" + x.String() + "," + y.String() + ")"
 }
 
 /*
@@ -184,35 +338,28 @@ MapToGroup is a hash function that returns a valid elliptic curve point given as
 input a string. It is also known as hash-to-point and is used to obtain a generator
 that has no discrete logarithm known relation, thus addressing the concept of
 NUMS (nothing up my sleeve).
-This implementation is based on the paper:
-Short signatures from the Weil pairing
-Boneh, Lynn and Shacham
-Journal of Cryptology, September 2004, Volume 17, Issue 4, pp 297–319
+It is hash_to_curve of RFC 9380 with the suite secp256k1_XMD:SHA-256_SSWU_RO_ and the
+domain separation tag of the generators H, see hashtocurve.go, so MapToGroup(seed) is
+the generator H of the seed. The result is never the point at infinity but with
+negligible probability.
 */
 func MapToGroup(m string) (*p256, error) {
-	var (
-		i      int
-		buffer bytes.Buffer
-	)
-	i = 0
-	for i < 256 {
-		buffer.Reset()
-		buffer.WriteString(strconv.Itoa(i))
-		buffer.WriteString(m)
-		x, _ := HashToInt(buffer)
-		x = Mod(x, CURVE.P)
-		fx, _ := F(x)
-		fx = Mod(fx, CURVE.P)
-		y := fx.ModSqrt(fx, CURVE.P)
-		if y != nil {
-			p := &p256{X: x, Y: y}
-			if p.IsOnCurve() && !p.IsZero() {
-				return p, nil
-			}
-		}
-		i = i + 1
+	p, err := mapToGroup(Secp256k1, m)
+	if err != nil {
+		return nil, err
 	}
-	return nil, errors.New("Failed to Hash-to-point.")
+	return p.(*p256), nil
+}
+
+/*
+mapToGroup is MapToGroup over any group, the generator H of the seed m.
+*/
+func mapToGroup(group Group, m string) (Element, error) {
+	p := deriveGenerator(group, m, "H", 0)
+	if p.IsIdentity() {
+		return nil, errors.New("Failed to Hash-to-point.")
+	}
+	return p, nil
 }
 
 /*
@@ -247,15 +394,109 @@ IsOnCurve returns TRUE if and only if p has coordinates X and Y that satisfy the
 Elliptic Curve equation: y^2 = x^3 + 7.
 */
 func (p *p256) IsOnCurve() bool {
-	// y² = x³ + 7
-	y2 := new(big.Int).Mul(p.Y, p.Y)
-	y2.Mod(y2, CURVE.P)
+	return p.jac.onCurve()
+}
+
+/*
+secp256k1Group is the Group of the points of secp256k1, the type p256. The scalar
+multiplications use the fixed-base tables, the multi-scalar multiplications of msm.go
+and the constant-time code of consttime.go.
+*/
+type secp256k1Group struct{}
+
+/*
+Secp256k1 is the group of secp256k1, the default group of the parameters.
+*/
+var Secp256k1 Group = secp256k1Group{}
 
-	x3 := new(big.Int).Mul(p.X, p.X)
-	x3.Mul(x3, p.X)
+/*
+secp256k1Scalars holds the constants of the scalars of secp256k1.
+*/
+var secp256k1Scalars = newScalarField(CURVE.N)
+
+func init() {
+	RegisterGroup(Secp256k1)
+}
+
+func (secp256k1Group) Name() string {
+	return "secp256k1"
+}
+
+func (secp256k1Group) Order() *big.Int {
+	return CURVE.N
+}
+
+func (secp256k1Group) NewElement() Element {
+	return new(p256)
+}
+
+func (secp256k1Group) Generator() Element {
+	return newP256(GX, GY)
+}
+
+func (secp256k1Group) HashSuite() string {
+	return H2CSUITE
+}
 
-	x3.Add(x3, new(big.Int).SetInt64(7))
-	x3.Mod(x3, CURVE.P)
+func (secp256k1Group) HashToElement(msg, dst []byte) Element {
+	return HashToCurve(msg, dst)
+}
+
+/*
+DecodeElement decodes a point in either SEC1 encoding, the point at infinity included.
+*/
+func (secp256k1Group) DecodeElement(data []byte) (Element, error) {
+	p, err := decodePoint(data, true)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+/*
+points converts the elements to points of secp256k1.
+*/
+func points(a []Element) []*p256 {
+	result := make([]*p256, len(a))
+	for i := range a {
+		result[i] = a[i].(*p256)
+	}
+	return result
+}
+
+func (secp256k1Group) MultiScalarMult(a []Element, k []Scalar) Element {
+	return multiScalarMult(points(a), k)
+}
+
+/*
+ConstantTimeScalarMult uses the table of a if it is a fixed base.
+*/
+func (secp256k1Group) ConstantTimeScalarMult(a Element, k Scalar) Element {
+	return ctScalarMultBase(a.(*p256), k)
+}
+
+func (secp256k1Group) ConstantTimeScalarBaseMult(k Scalar) Element {
+	return ctScalarBaseMult(k)
+}
+
+func (secp256k1Group) ConstantTimeMultiScalarMult(a []Element, k []Scalar) Element {
+	return ctMultiScalarMult(points(a), k)
+}
+
+/*
+Normalize converts the points to affine coordinates with NormalizePoints.
+*/
+func (secp256k1Group) Normalize(a []Element) {
+	NormalizePoints(points(a))
+}
+
+/*
+Precompute computes the fixed-base table of a, which must be normalized.
+*/
+func (secp256k1Group) Precompute(a Element) {
+	precomputeFixedBase(a.(*p256))
+}
 
-	return x3.Cmp(y2) == 0
+func (secp256k1Group) NewScalar() Scalar {
+	return newMontScalar(secp256k1Scalars)
 }
@@ -2,7 +2,10 @@
 package zkproofs
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"testing"
 	"math/big"
 	"github.com/ing-bank/zkproofs/go-ethereum/crypto/secp256k1"
@@ -15,7 +18,7 @@ func TestIsZero(t *testing.T) {
 	a := make([]byte, 32)
 	a = curve.N.Bytes()
 	Ax, Ay := curve.ScalarBaseMult(a)
-	p1 := p256{X:Ax, Y:Ay}
+	p1 := newP256(Ax, Ay)
 	res := p1.IsZero()
 	if res != true {
 		t.Errorf("Assert failure: expected true, actual: %t", res)
@@ -26,37 +29,153 @@ func TestAdd(t * testing.T) {
 	curve := secp256k1.S256()
 	a1 := new(big.Int).SetInt64(71).Bytes()
 	A1x, A1y := curve.ScalarBaseMult(a1)
-	p1 := &p256{X:A1x, Y:A1y}
+	p1 := newP256(A1x, A1y)
 	a2 := new(big.Int).SetInt64(17).Bytes()
 	A2x, A2y := curve.ScalarBaseMult(a2)
-	p2 := &p256{X:A2x, Y:A2y}
+	p2 := newP256(A2x, A2y)
 	p3 := p1.Add(p1, p2)
-	sa := new(big.Int).SetInt64(-88).Bytes()
+	// Bytes drops the sign, -88 is N - 88
+	sa := new(big.Int).Sub(curve.N, big.NewInt(88)).Bytes()
 	sAx, sAy := curve.ScalarBaseMult(sa)
-	sp := &p256{X:sAx, Y:sAy}
+	sp := newP256(sAx, sAy)
 	p4 := p3.Add(p3, sp)
-	res := p4.IsZero()
+	res := p4.IsIdentity()
 	if res != true {
 		t.Errorf("Assert failure: expected true, actual: %t", res)
 	}
 }
 
+/*
+Test the edge cases of the group law: the point at infinity on either side, a = b,
+a = -b and receivers aliasing the arguments.
+*/
+func TestGroupLawEdgeCases(t *testing.T) {
+	p := new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(71))
+	q := new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(17))
+	inf := new(p256).SetInfinity()
+	cases := []struct {
+		name     string
+		actual   Element
+		expected Element
+	}{
+		{"p + 0", new(p256).Add(p, inf), p},
+		{"0 + p", new(p256).Add(inf, p), p},
+		{"0 + 0", new(p256).Add(inf, inf), inf},
+		{"p + p", new(p256).Add(p, p), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(142))},
+		{"p + (-p)", new(p256).Add(p, new(p256).Neg(p)), inf},
+		{"p - p", new(p256).Sub(p, p), inf},
+		{"p - q", new(p256).Sub(p, q), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(54))},
+		{"q - p", new(p256).Sub(q, p), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(-54))},
+		{"-p", new(p256).Set(q).Neg(p), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(-71))},
+		{"-0", new(p256).Set(p).Neg(inf), inf},
+		{"2p", new(p256).Double(p), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(142))},
+		{"2.0", new(p256).Double(inf), inf},
+		{"p + p aliased", new(p256).Set(p).Add(p, p), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(142))},
+		{"p + q multiply", new(p256).Multiply(p.(*p256), q.(*p256)), new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(88))},
+	}
+	for _, c := range cases {
+		if !c.actual.Equal(c.expected) {
+			t.Errorf("Assert failure for %s: expected %s, actual: %s", c.name, c.expected, c.actual)
+		}
+		if c.expected.IsIdentity() && c.actual.(*p256).jac != (jacobianPoint{}) {
+			t.Errorf("Assert failure for %s: expected the zero value for the point at infinity", c.name)
+		}
+	}
+	r := new(p256).Set(p).(*p256)
+	r.Add(r, r)
+	r.Sub(r, r)
+	if r.jac != (jacobianPoint{}) || !r.Equal(inf) {
+		t.Errorf("Assert failure: expected the point at infinity, actual: %s", r)
+	}
+}
+
+/*
+Test the group axioms on random points and the point at infinity: associativity,
+commutativity, identity, inverse, and that Double agrees with Add and with the
+scalar multiplication by 2.
+*/
+func TestGroupAxioms(t *testing.T) {
+	points := []Element{new(p256).SetInfinity()}
+	for i := 0; i < 6; i++ {
+		points = append(points, new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetRandom()))
+	}
+	// keep some points in Jacobian coordinates with Z != 1
+	points = append(points, new(p256).Add(points[1], points[2]), new(p256).Double(points[3]))
+	inf := new(p256).SetInfinity()
+	for _, a := range points {
+		if !new(p256).Add(a, inf).Equal(a) || !new(p256).Add(inf, a).Equal(a) {
+			t.Errorf("Assert failure: expected 0 to be the identity for %s", a)
+		}
+		if !new(p256).Add(a, new(p256).Neg(a)).IsIdentity() || !new(p256).Sub(a, a).IsIdentity() {
+			t.Errorf("Assert failure: expected a + (-a) = 0 for %s", a)
+		}
+		if !new(p256).Neg(new(p256).Neg(a)).Equal(a) {
+			t.Errorf("Assert failure: expected -(-a) = a for %s", a)
+		}
+		if !new(p256).Double(a).Equal(new(p256).Add(a, a)) || !new(p256).Double(a).Equal(new(p256).ScalarMult(a, Secp256k1.NewScalar().SetInt64(2))) {
+			t.Errorf("Assert failure: expected 2a = a + a for %s", a)
+		}
+		for _, b := range points {
+			ab := new(p256).Add(a, b)
+			if !ab.Equal(new(p256).Add(b, a)) {
+				t.Errorf("Assert failure: expected a + b = b + a for %s and %s", a, b)
+			}
+			if !new(p256).Sub(ab, b).Equal(a) {
+				t.Errorf("Assert failure: expected (a + b) - b = a for %s and %s", a, b)
+			}
+			if ab.IsIdentity() != a.Equal(new(p256).Neg(b)) || !(ab.IsIdentity() || ab.(*p256).IsOnCurve()) {
+				t.Errorf("Assert failure: unexpected sum %s of %s and %s", ab, a, b)
+			}
+			for _, c := range points {
+				left := new(p256).Add(ab, c)
+				right := new(p256).Add(a, new(p256).Add(b, c))
+				if !left.Equal(right) {
+					t.Errorf("Assert failure: expected (a + b) + c = a + (b + c) for %s, %s and %s", a, b, c)
+				}
+			}
+		}
+	}
+}
+
 func TestScalarMultp256(t *testing.T) {
 	curve := secp256k1.S256()
 	a1 := new(big.Int).SetInt64(71).Bytes()
 	Ax, Ay := curve.ScalarBaseMult(a1)
-	p1 := &p256{X:Ax, Y:Ay}
-	pr := p1.ScalarMult(p1, curve.N)
-	res := pr.IsZero()
+	p1 := newP256(Ax, Ay)
+	pr := p1.ScalarMult(p1, Secp256k1.NewScalar().SetBigInt(curve.N))
+	res := pr.IsIdentity()
 	if res != true {
 		t.Errorf("Assert failure: expected true, actual: %t", res)
 	}
 }
 
+/*
+Test the windowed scalar multiplication against the affine one of go-ethereum, for
+scalars that are negative, not reduced, or have high windows of 0 and 15.
+*/
+func TestScalarMultWindowed(t *testing.T) {
+	curve := secp256k1.S256()
+	p, _ := MapToGroup("TestScalarMultWindowed")
+	px, py := p.Affine()
+	random, _ := rand.Int(rand.Reader, curve.N)
+	scalars := []*big.Int{big.NewInt(1), big.NewInt(15), big.NewInt(16), big.NewInt(-3), random,
+		new(big.Int).Sub(curve.N, big.NewInt(1)), new(big.Int).Add(curve.N, big.NewInt(5)),
+		new(big.Int).Lsh(big.NewInt(15), 252), new(big.Int).Lsh(big.NewInt(1), 255)}
+	for _, k := range scalars {
+		ex, ey := curve.ScalarMult(px, py, Mod(k, curve.N).Bytes())
+		if !new(p256).ScalarMult(p, Secp256k1.NewScalar().SetBigInt(k)).Equal(newP256(ex, ey)) {
+			t.Errorf("Assert failure: wrong multiple %s", k)
+		}
+	}
+	if !new(p256).ScalarMult(p, Secp256k1.NewScalar().SetInt64(0)).IsIdentity() || !new(p256).ScalarMult(new(p256).SetInfinity(), Secp256k1.NewScalar().SetBigInt(random)).IsIdentity() {
+		t.Errorf("Assert failure: expected the identity")
+	}
+}
+
 func TestScalarBaseMult(t *testing.T) {
-	a1 := new(big.Int).SetInt64(71)
+	a1 := Secp256k1.NewScalar().SetInt64(71)
 	p1 := new(p256).ScalarBaseMult(a1)
-	res := p1.IsZero()
+	res := p1.IsIdentity()
 	if res != false {
 		t.Errorf("Assert failure: expected false, actual: %t", res)
 	}
@@ -66,7 +185,7 @@ func TestMapToGroup(t *testing.T) {
 	curve := secp256k1.S256()
 	m := "Testing Hash-to-point function:"
 	p, _ := MapToGroup(m)
-	p.ScalarMult(p, curve.N)
+	p.ScalarMult(p, Secp256k1.NewScalar().SetBigInt(curve.N))
 }
 
 func BenchmarkScalarMultp256(b *testing.B) {
@@ -74,9 +193,110 @@ func BenchmarkScalarMultp256(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		rand.Read(a)
-		_ = new(p256).ScalarBaseMult(new(big.Int).SetBytes(a))
+		_ = new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetBigInt(new(big.Int).SetBytes(a)))
 	}
 }
 
 
 
+
+func TestNormalizePoints(t *testing.T) {
+	p := new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(71)).(*p256)
+	q := new(p256).Multiply(p, p)
+	r := new(p256).Multiply(q, p)
+	points := []*p256{q, new(p256).SetInfinity(), r}
+	expected := make([][2]*big.Int, len(points))
+	for i := range points {
+		expected[i][0], expected[i][1] = points[i].Affine()
+	}
+	NormalizePoints(points)
+	for i := range points {
+		x, y := points[i].Affine()
+		if (x == nil) != (expected[i][0] == nil) || (x != nil && (x.Cmp(expected[i][0]) != 0 || y.Cmp(expected[i][1]) != 0)) {
+			t.Errorf("Assert failure for point %d: expected (%v, %v), actual: (%v, %v)", i, expected[i][0], expected[i][1], x, y)
+		}
+	}
+	if !r.Equal(new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(213))) {
+		t.Errorf("Assert failure: expected 3P")
+	}
+}
+
+func TestJSONp256(t *testing.T) {
+	p := new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(71)).(*p256)
+	p.Double(p)
+	data, _ := json.Marshal(p)
+	var q p256
+	err := json.Unmarshal(data, &q)
+	if err != nil || !q.Equal(p) || !q.IsOnCurve() {
+		t.Errorf("Assert failure: expected the point back, actual: %v", err)
+	}
+	if string(data) != "\""+hex.EncodeToString(p.MarshalCompressed())+"\"" {
+		t.Errorf("Assert failure: expected the compressed encoding, actual: %s", data)
+	}
+	for _, bad := range []string{"{\"X\":1,\"Y\":2}", "\"00\"", "\"02\"", "\"zz\"", "\"02" + hex.EncodeToString(CURVE.P.Bytes()) + "\""} {
+		if err = json.Unmarshal([]byte(bad), &q); err == nil {
+			t.Errorf("Assert failure: expected an error for %s", bad)
+		}
+	}
+}
+
+/*
+Test the SEC1 encodings against the points they encode, and that invalid encodings
+are rejected.
+*/
+func TestSEC1(t *testing.T) {
+	for i := 0; i < 16; i++ {
+		p := new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetRandom()).(*p256)
+		x, y := p.Affine()
+		compressed := p.MarshalCompressed()
+		uncompressed := p.MarshalUncompressed()
+		if len(compressed) != 33 || compressed[0] != byte(2+y.Bit(0)) || new(big.Int).SetBytes(compressed[1:]).Cmp(x) != 0 {
+			t.Fatalf("Assert failure: unexpected compressed encoding %x of %s", compressed, p)
+		}
+		if len(uncompressed) != 65 || uncompressed[0] != 4 || new(big.Int).SetBytes(uncompressed[33:]).Cmp(y) != 0 {
+			t.Fatalf("Assert failure: unexpected uncompressed encoding %x of %s", uncompressed, p)
+		}
+		for _, data := range [][]byte{compressed, uncompressed} {
+			var q p256
+			if err := q.UnmarshalBinary(data); err != nil || !q.Equal(p) {
+				t.Errorf("Assert failure: expected %s back from %x, actual: %s, %v", p, data, &q, err)
+			}
+		}
+	}
+	inf := new(p256).SetInfinity()
+	if !bytes.Equal(inf.MarshalCompressed(), []byte{0}) || !bytes.Equal(inf.MarshalUncompressed(), []byte{0}) {
+		t.Errorf("Assert failure: expected the single byte 0 for the point at infinity")
+	}
+	if q, err := decodePoint([]byte{0}, true); err != nil || !q.IsZero() {
+		t.Errorf("Assert failure: expected the point at infinity, actual: %v", err)
+	}
+	// x = 5 is not the abscissa of a point, as 5^3 + 7 is not a square
+	notOnCurve := make([]byte, 33)
+	notOnCurve[0], notOnCurve[32] = 2, 5
+	g := new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(1)).(*p256).MarshalUncompressed()
+	offCurve := append([]byte{}, g...)
+	offCurve[64] ^= 1
+	hybrid := append([]byte{}, g...)
+	hybrid[0] = 6
+	aboveP := append([]byte{2}, CURVE.P.Bytes()...)
+	yAboveP := append([]byte{}, g...)
+	CURVE.P.FillBytes(yAboveP[33:])
+	inputs := map[string][]byte{
+		"empty":         nil,
+		"infinity":      {0},
+		"truncated":     g[:64],
+		"bad prefix":    append([]byte{5}, g[1:33]...),
+		"hybrid":        hybrid,
+		"not on curve":  notOnCurve,
+		"off curve":     offCurve,
+		"x above P":     aboveP,
+		"y above P":     yAboveP,
+		"long infinity": make([]byte, 33),
+	}
+	for kind, data := range inputs {
+		var q p256
+		if err := q.UnmarshalBinary(data); err == nil {
+			t.Errorf("Assert failure: expected an error for the %s encoding", kind)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+/*
+This file contains the optional parallel mode of the vector operations. The scalar
+multiplications of a vector are independent, so they are split in chunks computed by
+the workers of a Pool, which the parameters get with SetPool. Every entry is computed
+exactly as in the sequential mode and the partial sums of the multi-scalar
+multiplications are points of the same group, so the proofs are byte-identical
+whatever the pool.
+*/
+
+package zkproofs
+
+/*
+minChunk is the smallest number of scalar multiplications given to a worker, below
+which handing a chunk to a worker costs more than it saves.
+*/
+const minChunk = 4
+
+/*
+Pool is a fixed set of goroutines that compute the chunks of the vector operations. A
+pool can be shared by several parameters and by concurrent proofs and verifications:
+a chunk that finds no idle worker is computed by the caller, so calls never wait for
+each other. The nil pool computes everything in the caller.
+*/
+type Pool struct {
+	size  int
+	tasks chan func()
+}
+
+/*
+NewPool returns a pool that splits the vector operations in up to size chunks. The
+caller of an operation computes one of them, so the pool starts size - 1 goroutines,
+which run until Close. Sizes below 1 are treated as 1.
+*/
+func NewPool(size int) *Pool {
+	var (
+		i int
+	)
+	if size < 1 {
+		size = 1
+	}
+	pool := &Pool{size: size, tasks: make(chan func())}
+	i = 1
+	for i < size {
+		go pool.work()
+		i = i + 1
+	}
+	return pool
+}
+
+func (pool *Pool) work() {
+	for task := range pool.tasks {
+		task()
+	}
+}
+
+/*
+Size returns the number of chunks of the pool, 1 for the nil pool.
+*/
+func (pool *Pool) Size() int {
+	if pool == nil {
+		return 1
+	}
+	return pool.size
+}
+
+/*
+Close stops the goroutines of the pool. The pool must not be used afterwards.
+*/
+func (pool *Pool) Close() {
+	if pool != nil {
+		close(pool.tasks)
+	}
+}
+
+/*
+workers returns the number of chunks of n scalar multiplications.
+*/
+func (pool *Pool) workers(n int64) int64 {
+	w := int64(pool.Size())
+	if w > n/minChunk {
+		w = n / minChunk
+	}
+	if w < 1 {
+		w = 1
+	}
+	return w
+}
+
+/*
+parallelFor splits [0, n) in w consecutive chunks [lo, hi), calls f on each of them,
+and returns when all of them are done. The chunks are handed to the idle workers of
+the pool and the others are computed by the caller. They are numbered by their
+position, so that callers combine their results in order.
+*/
+func (pool *Pool) parallelFor(n, w int64, f func(chunk, lo, hi int64)) {
+	var (
+		chunk int64
+	)
+	if pool == nil || w <= 1 {
+		f(0, 0, n)
+		return
+	}
+	done := make(chan struct{}, w)
+	size := (n + w - 1) / w
+	chunk = 0
+	for chunk < w {
+		lo := chunk * size
+		hi := lo + size
+		if lo > n {
+			lo = n
+		}
+		if hi > n {
+			hi = n
+		}
+		task := func(chunk, lo, hi int64) func() {
+			return func() {
+				f(chunk, lo, hi)
+				done <- struct{}{}
+			}
+		}(chunk, lo, hi)
+		select {
+		case pool.tasks <- task:
+		default:
+			task()
+		}
+		chunk = chunk + 1
+	}
+	chunk = 0
+	for chunk < w {
+		<-done
+		chunk = chunk + 1
+	}
+}
@@ -0,0 +1,124 @@
+package zkproofs
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	mrand "math/rand"
+	"runtime"
+	"testing"
+	"testing/iotest"
+)
+
+/*
+Test that the vector operations give the same results whatever the pool.
+*/
+func TestParallelVectorOps(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 4294967295)
+	n := int64(len(zkrp.Gg))
+	a := make([]Scalar, n)
+	b := make([]Scalar, n)
+	for i := range a {
+		a[i] = Secp256k1.NewScalar().SetRandom()
+		b[i] = Secp256k1.NewScalar().SetRandom()
+	}
+	y := Secp256k1.NewScalar().SetRandom()
+	results := make([][]byte, 0)
+	for _, pool := range []*Pool{nil, NewPool(1), NewPool(3), NewPool(8)} {
+		exp, _ := vectorExp(pool, zkrp.Gg, a)
+		scalar, _ := vectorScalarExp(pool, zkrp.Gg, y)
+		commit, _ := commitVector(pool, a, b, y, zkrp.H, zkrp.Gg, zkrp.Hh, n)
+		hprime := switchGenerators(pool, zkrp.Hh, y)
+		data, _ := json.Marshal([]interface{}{exp, scalar, commit, hprime})
+		results = append(results, data)
+		pool.Close()
+	}
+	for i := range results {
+		if string(results[i]) != string(results[0]) {
+			t.Errorf("Assert failure: expected the same results for case %d", i)
+		}
+	}
+}
+
+/*
+Test that proofs built with the same randomness are byte-identical whatever the
+pool.
+*/
+func TestParallelProofsIdentical(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(18, 200, 2)
+	secrets := []*big.Int{new(big.Int).SetInt64(42), new(big.Int).SetInt64(200)}
+
+	results := make([][]byte, 0)
+	for _, workers := range []int{1, 4, 16} {
+		pool := NewPool(workers)
+		defer pool.Close()
+		zkrp.SetPool(pool)
+		zkrp.SetRandom(mrand.New(mrand.NewSource(1)))
+		_, proof, _ := zkrp.GenerateProof(secrets[0])
+		_, plus, _ := zkrp.GenerateProofPlus(secrets[0])
+		_, agg, _ := zkrp.GenerateAggregateProof(secrets)
+		data, _ := json.Marshal([]interface{}{&proof, &plus, &agg})
+		results = append(results, data)
+
+		ok, _ := zkrp.Verify(proof)
+		okPlus, _ := zkrp.VerifyPlus(plus)
+		okAgg, _ := zkrp.VerifyAggregate(agg)
+		if !ok || !okPlus || !okAgg {
+			t.Errorf("Assert failure with %d workers: expected true, actual: %t %t %t", workers, ok, okPlus, okAgg)
+		}
+	}
+	for i := range results {
+		if string(results[i]) != string(results[0]) {
+			t.Errorf("Assert failure: expected byte-identical proofs for case %d", i)
+		}
+	}
+}
+
+/*
+Test that the provers return the error of their randomness source.
+*/
+func TestRandomSourceError(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(18, 200, 2)
+	zkrp.SetRandom(iotest.ErrReader(errors.New("no entropy")))
+	secret := new(big.Int).SetInt64(42)
+	if _, _, err := zkrp.GenerateProof(secret); err == nil {
+		t.Errorf("Assert failure: expected error for Bulletproofs")
+	}
+	if _, _, err := zkrp.GenerateProofPlus(secret); err == nil {
+		t.Errorf("Assert failure: expected error for Bulletproofs+")
+	}
+	if _, _, err := zkrp.GenerateAggregateProof([]*big.Int{secret, secret}); err == nil {
+		t.Errorf("Assert failure: expected error for aggregate proofs")
+	}
+	if _, _, err := zkrp.GenerateRewindableProof(secret, []byte("nonce"), nil); err == nil {
+		t.Errorf("Assert failure: expected error for rewindable proofs")
+	}
+}
+
+func BenchmarkParallelBulletproofs(b *testing.B) {
+	var (
+		zkrp Bp
+	)
+	zkrp.SetupAggregate(0, 4294967295, 8)
+	pool := NewPool(runtime.NumCPU())
+	defer pool.Close()
+	zkrp.SetPool(pool)
+	secrets := make([]*big.Int, 8)
+	for i := range secrets {
+		secrets[i] = new(big.Int).SetInt64(int64(1000 * i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, proof, _ := zkrp.GenerateAggregateProof(secrets)
+		zkrp.VerifyAggregate(proof)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+This file contains the process-wide registry of generators. Deriving a generator
+hashes to the curve, that is two square roots and three inversions, so the
+generators of every (group, n, seed) triple are derived once and shared by all the
+parameters set up afterwards. Parameters loaded from a file written by SaveToDisk keep
+the generators of the file, which the caller authenticates with their digest, and are
+never added to the registry.
+*/
+
+package zkproofs
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+)
+
+/*
+generatorSet holds the point H = MapToGroup(seed), which gets a fixed-base table,
+and the vectors of n generators derived from the same seed. The sets are shared and
+must never be modified.
+*/
+type generatorSet struct {
+	H  Element
+	Gg []Element
+	Hh []Element
+}
+
+type generatorKey struct {
+	group string
+	n     int64
+	seed  string
+}
+
+/*
+generatorEntry is filled exactly once, so that concurrent callers asking for the
+same generators wait for a single derivation.
+*/
+type generatorEntry struct {
+	once sync.Once
+	set  *generatorSet
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[generatorKey]*generatorEntry)
+)
+
+/*
+getGenerators returns the n generators of the group derived from seed, deriving them
+on the first call only. It is safe for concurrent use.
+*/
+func getGenerators(group Group, n int64, seed string) *generatorSet {
+	key := generatorKey{group.Name(), n, seed}
+	registryMu.Lock()
+	entry, ok := registry[key]
+	if !ok {
+		entry = new(generatorEntry)
+		registry[key] = entry
+	}
+	registryMu.Unlock()
+	entry.once.Do(func() {
+		var set generatorSet
+		set.H, _ = mapToGroup(group, seed)
+		group.Precompute(set.H)
+		set.Gg, set.Hh = generators(group, n, seed)
+		entry.set = &set
+	})
+	return entry.set
+}
+
+/*
+seedPoint returns the generator H of the seed in the group, derived once per process.
+*/
+func seedPoint(group Group, seed string) Element {
+	return getGenerators(group, 0, seed).H
+}
+
+/*
+paramFile is the content of the files written by SaveToDisk. Hash is the hex encoded
+digest of the parameters and Seed the seed of their generators.
+*/
+type paramFile struct {
+	Seed   string
+	Hash   string
+	Params *Bp
+}
+
+/*
+paramsHash returns the digest of the parameters and the seed of their generators.
+*/
+func (zkrp *Bp) paramsHash(seed string) []byte {
+	var (
+		i int
+	)
+	t := NewTranscript("zkrp params v1")
+	t.AppendMessage("group", []byte(zkrp.Group().Name()))
+	t.AppendMessage("seed", []byte(seed))
+	t.AppendUint64("N", uint64(zkrp.N))
+	t.AppendUint64("M", uint64(zkrp.M))
+	t.AppendUint64("A", uint64(zkrp.A))
+	t.AppendUint64("B", uint64(zkrp.B))
+	t.AppendPoint("G", zkrp.G)
+	t.AppendPoint("H", zkrp.H)
+	t.AppendPoint("U", zkrp.Zkip.Uu)
+	t.AppendUint64("n", uint64(len(zkrp.Gg)))
+	i = 0
+	for i < len(zkrp.Gg) {
+		t.AppendPoint("Gg", zkrp.Gg[i])
+		t.AppendPoint("Hh", zkrp.Hh[i])
+		i = i + 1
+	}
+	return t.ChallengeBytes("hash", 32)
+}
+
+/*
+checkParams verifies that the parameters are consistent and that all the points are
+elements of the group other than the identity.
+*/
+func (zkrp *Bp) checkParams() error {
+	if zkrp.A > zkrp.B || zkrp.M < 1 || zkrp.M > int64(len(zkrp.Gg)) || zkrp.N != RangeBits(zkrp.A, zkrp.B) {
+		return errors.New("invalid parameters")
+	}
+	if len(zkrp.Gg) != len(zkrp.Hh) || zkrp.paddedSize(zkrp.M*int64(len(zkrp.shifts()))) > int64(len(zkrp.Gg)) {
+		return errors.New("invalid number of generators")
+	}
+	points := []Element{zkrp.G, zkrp.H, zkrp.Zkip.Uu}
+	points = append(points, zkrp.Gg...)
+	points = append(points, zkrp.Hh...)
+	if !sameGroup(zkrp.Group(), points...) {
+		return errors.New("invalid generator")
+	}
+	for _, p := range points {
+		if p.IsIdentity() {
+			return errors.New("invalid generator")
+		}
+	}
+	return nil
+}
+
+/*
+generatorSeed returns the seed of the generators of the parameters.
+*/
+func (zkrp *Bp) generatorSeed() string {
+	if zkrp.seed == "" {
+		return SEEDH
+	}
+	return zkrp.seed
+}
+
+/*
+Digest returns the digest of the parameters: the group, the interval, the seed and all
+the generators. Callers pin it, for instance in their configuration, and give it to
+LoadParamFromDisk, which only accepts files with the same digest.
+*/
+func (zkrp *Bp) Digest() []byte {
+	return zkrp.paramsHash(zkrp.generatorSeed())
+}
+
+/*
+SaveToDisk is responsible for saving the parameters to disk, together with their
+digest, such that they can be loaded without deriving the generators again. If p is
+not nil the proof is saved to proof.dat.
+*/
+func (zkrp *Bp) SaveToDisk(s string, p *proofBP) error {
+	seed := zkrp.generatorSeed()
+	file := paramFile{Seed: seed, Hash: hex.EncodeToString(zkrp.paramsHash(seed)), Params: zkrp}
+	data, err := json.Marshal(&file)
+	errw := ioutil.WriteFile(s, data, 0644)
+	if p != nil {
+		datap, errp := json.Marshal(p)
+		errpw := ioutil.WriteFile("proof.dat", datap, 0644)
+		if errp != nil || errpw != nil {
+			return errors.New("proof not saved to disk.")
+		}
+	}
+	if err != nil || errw != nil {
+		return errors.New("parameters not saved to disk.")
+	}
+	return nil
+}
+
+/*
+LoadParamFromDisk reads parameters written by SaveToDisk, whose digest must be the
+given one. Anyone who can write the file can also compute the hash it contains, so the
+digest is pinned by the caller: the generators are then the ones of the file and are
+not derived again. The hash of the file is only checked to report corrupted files.
+*/
+func LoadParamFromDisk(s string, digest []byte) (*Bp, error) {
+	var (
+		file paramFile
+	)
+	c, err := ioutil.ReadFile(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(c) == 0 {
+		return nil, errors.New("Could not load generators.")
+	}
+	if err = json.Unmarshal(c, &file); err != nil {
+		return nil, err
+	}
+	if file.Params == nil || file.Seed == "" {
+		return nil, errors.New("Could not load generators.")
+	}
+	zkrp := file.Params
+	if err = zkrp.checkParams(); err != nil {
+		return nil, err
+	}
+	computed := zkrp.paramsHash(file.Seed)
+	hash, err := hex.DecodeString(file.Hash)
+	if err != nil || subtle.ConstantTimeCompare(hash, computed) != 1 {
+		return nil, errors.New("parameters do not match their hash")
+	}
+	if subtle.ConstantTimeCompare(digest, computed) != 1 {
+		return nil, errors.New("parameters do not match the pinned digest")
+	}
+	// the inner product parameters of the file are not covered by the digest
+	padded := zkrp.paddedSize(zkrp.M * int64(len(zkrp.shifts())))
+	zkrp.Zkip = bip{N: padded, Uu: zkrp.Zkip.Uu, H: zkrp.H, Gg: zkrp.Gg[:padded], Hh: zkrp.Hh[:padded]}
+	zkrp.seed = file.Seed
+	zkrp.Group().Normalize([]Element{zkrp.H})
+	zkrp.Group().Precompute(zkrp.H)
+	return zkrp, nil
+}
@@ -0,0 +1,232 @@
+package zkproofs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/*
+Test that the registry derives the same generators as generators, once for concurrent
+callers.
+*/
+func TestGeneratorRegistry(t *testing.T) {
+	var (
+		wg sync.WaitGroup
+	)
+	sets := make([]*generatorSet, 8)
+	for i := range sets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sets[i] = getGenerators(Secp256k1, 4, "TestGeneratorRegistry")
+		}(i)
+	}
+	wg.Wait()
+	g, h := generators(Secp256k1, 4, "TestGeneratorRegistry")
+	H, _ := MapToGroup("TestGeneratorRegistry")
+	for i, set := range sets {
+		if set != sets[0] {
+			t.Errorf("Assert failure: expected set %d to be shared", i)
+		}
+	}
+	ok := sets[0].H.Equal(H) && len(sets[0].Gg) == 4
+	for i := range g {
+		ok = ok && sets[0].Gg[i].Equal(g[i]) && sets[0].Hh[i].Equal(h[i])
+	}
+	if ok != true {
+		t.Errorf("Assert failure: expected the derived generators, actual: %t", ok)
+	}
+	if GetZkrp().Gg[0] != GetZkrp().Gg[0] {
+		t.Errorf("Assert failure: expected GetZkrp to share the generators")
+	}
+}
+
+/*
+Test that parameters saved to disk are loaded back with their pinned digest and verify
+proofs, and that they are rejected with another digest.
+*/
+func TestSaveLoadParams(t *testing.T) {
+	var (
+		zkrp, other Bp
+	)
+	name := filepath.Join(t.TempDir(), "setup.json")
+	zkrp.SetupAggregate(18, 200, 2)
+	if err := zkrp.SaveToDisk(name, nil); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	loaded, err := LoadParamFromDisk(name, zkrp.Digest())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if loaded.N != zkrp.N || loaded.M != zkrp.M || len(loaded.Gg) != len(zkrp.Gg) {
+		t.Errorf("Assert failure: expected the saved parameters, actual: %d %d %d", loaded.N, loaded.M, len(loaded.Gg))
+	}
+	if !bytes.Equal(loaded.Digest(), zkrp.Digest()) || loaded.Zkip.N != zkrp.Zkip.N {
+		t.Errorf("Assert failure: expected the digest and the inner product parameters of the saved parameters")
+	}
+	_, proof, _ := zkrp.GenerateAggregateProof([]*big.Int{new(big.Int).SetInt64(18), new(big.Int).SetInt64(200)})
+	ok, _ := loaded.VerifyAggregate(proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+	other.SetupAggregate(18, 200, 1)
+	for _, digest := range [][]byte{nil, other.Digest(), zkrp.Digest()[:16]} {
+		if _, err := LoadParamFromDisk(name, digest); err == nil {
+			t.Errorf("Assert failure: expected error for the digest %x", digest)
+		}
+	}
+}
+
+/*
+Test that parameters whose generators were derived from another seed are loaded with
+their digest, without deriving them again.
+*/
+func TestLoadParamsSeed(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	name := filepath.Join(t.TempDir(), "setup.json")
+	zkrp.Setup(0, 255)
+	zkrp.seed = "TestLoadParamsSeed"
+	zkrp.H = seedPoint(Secp256k1, zkrp.seed)
+	zkrp.Gg, zkrp.Hh = generators(Secp256k1, int64(len(zkrp.Gg)), zkrp.seed)
+	zkrp.Zkip.Setup(zkrp.H, zkrp.Gg, zkrp.Hh)
+	zkrp.SaveToDisk(name, nil)
+	loaded, err := LoadParamFromDisk(name, zkrp.Digest())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if loaded.generatorSeed() != zkrp.seed || !loaded.Gg[0].Equal(zkrp.Gg[0]) {
+		t.Errorf("Assert failure: expected the generators of the seed %s", zkrp.seed)
+	}
+	_, proof, _ := loaded.GenerateProof(new(big.Int).SetInt64(42))
+	ok, _ := zkrp.Verify(proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
+
+func pointJSON(p *p256) string {
+	data, _ := p.MarshalJSON()
+	return string(data)
+}
+
+/*
+Test that tampered, truncated and unhashed parameter files are rejected.
+*/
+func TestLoadTamperedParams(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "setup.json")
+	zkrp.Setup(0, 255)
+	zkrp.SaveToDisk(name, nil)
+	data, _ := ioutil.ReadFile(name)
+	other, _ := MapToGroup("other")
+	legacy, _ := zkrp.MarshalJSON()
+
+	files := map[string]string{
+		"generator": strings.Replace(string(data), pointJSON(zkrp.Gg[3].(*p256)), pointJSON(other), -1),
+		"hash":      strings.Replace(string(data), "\"Hash\":\"", "\"Hash\":\"00", 1),
+		"truncated": string(data[:len(data)/2]),
+		"unhashed":  string(legacy),
+		"empty":     "",
+	}
+	for kind, content := range files {
+		if content == string(data) {
+			t.Fatalf("Assert failure: the %s file was not changed", kind)
+		}
+		ioutil.WriteFile(name, []byte(content), 0644)
+		_, err := LoadParamFromDisk(name, zkrp.Digest())
+		if err == nil {
+			t.Errorf("Assert failure: expected error for a %s file", kind)
+		}
+	}
+}
+
+/*
+Test that parameters whose generators were replaced are rejected with the pinned digest
+even when the file is hashed again, and that they do not replace the generators of
+later setups.
+*/
+func TestLoadRehashedParams(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	name := filepath.Join(t.TempDir(), "setup.json")
+	zkrp.SetupAggregate(0, 255, 2)
+	other, _ := MapToGroup("other")
+	cases := map[string]func(p *Bp){
+		"Gg": func(p *Bp) {
+			p.Gg = append([]Element{}, zkrp.Gg...)
+			p.Gg[1] = Secp256k1.NewElement().Double(zkrp.Gg[0])
+			p.Gg[8] = zkrp.Gg[0]
+		},
+		"Hh": func(p *Bp) {
+			p.Hh = append([]Element{}, zkrp.Hh...)
+			p.Hh[5] = other
+		},
+		"G": func(p *Bp) { p.G = other },
+		"H": func(p *Bp) { p.H = other },
+		"U": func(p *Bp) { p.Zkip.Uu = other },
+	}
+	for kind, tamper := range cases {
+		tampered := zkrp
+		tamper(&tampered)
+		if err := tampered.SaveToDisk(name, nil); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if _, err := LoadParamFromDisk(name, zkrp.Digest()); err == nil {
+			t.Errorf("Assert failure: expected error for a rehashed file with another %s", kind)
+		}
+	}
+
+	var fresh Bp
+	fresh.SetupAggregate(0, 255, 2)
+	g, h := generators(Secp256k1, int64(len(fresh.Gg)), SEEDH)
+	for i := range g {
+		if !fresh.Gg[i].Equal(g[i]) || !fresh.Hh[i].Equal(h[i]) {
+			t.Fatalf("Assert failure: expected the derived generator %d after loading a tampered file", i)
+		}
+	}
+	_, proof, _ := fresh.GenerateAggregateProof([]*big.Int{new(big.Int).SetInt64(0), new(big.Int).SetInt64(255)})
+	if ok, _ := fresh.VerifyAggregate(proof); ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
+
+/*
+Test that SetupPre uses the parameters saved in setup.json.
+*/
+func TestSetupPre(t *testing.T) {
+	var (
+		zkrp, pre Bp
+	)
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	zkrp.Setup(0, 255)
+	if err := pre.SetupPre(0, 255, zkrp.Digest()); err == nil {
+		t.Errorf("Assert failure: expected error without setup.json")
+	}
+	zkrp.SaveToDisk("setup.json", nil)
+	if err := pre.SetupPre(0, 65535, zkrp.Digest()); err == nil {
+		t.Errorf("Assert failure: expected error for another interval")
+	}
+	if err := pre.SetupPre(0, 255, zkrp.Digest()); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	_, proof, _ := pre.GenerateProof(new(big.Int).SetInt64(42))
+	ok, _ := zkrp.Verify(proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
@@ -0,0 +1,657 @@
+/*
+This file contains the Bulletproofs proof system for arithmetic circuits, described as a
+rank-1 constraint system (R1CS). A circuit is made of multiplication gates aL.aR = aO and
+of linear constraints over the inputs and outputs of the gates and the committed values
+V, that must all be equal to zero. It follows section 5 of the paper:
+Bulletproofs: Short Proofs for Confidential Transactions and More
+Benedikt Bunz, Jonathan Bootle, Dan Boneh, Andrew Poelstra, Pieter Wuille and Greg Maxwell
+and the constraint system API of the dalek-cryptography bulletproofs library.
+*/
+
+package zkproofs
+
+import (
+	"errors"
+	"math/big"
+)
+
+type variableKind int
+
+const (
+	variableOne variableKind = iota
+	variableCommitted
+	variableLeft
+	variableRight
+	variableOutput
+)
+
+/*
+Variable of a constraint system: the constant 1, a committed value or the left input,
+right input or output of a multiplication gate.
+*/
+type Variable struct {
+	kind  variableKind
+	index int64
+}
+
+/*
+One returns the variable whose value is always 1, used to write constants.
+*/
+func One() Variable {
+	return Variable{kind: variableOne}
+}
+
+/*
+Term of a linear combination: coeff.variable.
+*/
+type Term struct {
+	Variable Variable
+	Coeff    *big.Int
+}
+
+/*
+LinearCombination is the sum of its terms.
+*/
+type LinearCombination []Term
+
+/*
+LC returns the linear combination 1.v.
+*/
+func (v Variable) LC() LinearCombination {
+	return LinearCombination{Term{Variable: v, Coeff: new(big.Int).SetInt64(1)}}
+}
+
+/*
+Constant returns the linear combination c.1.
+*/
+func Constant(c *big.Int) LinearCombination {
+	return LinearCombination{Term{Variable: One(), Coeff: c}}
+}
+
+/*
+Add returns lc + other.
+*/
+func (lc LinearCombination) Add(other LinearCombination) LinearCombination {
+	result := make(LinearCombination, 0, len(lc)+len(other))
+	result = append(result, lc...)
+	return append(result, other...)
+}
+
+/*
+Sub returns lc - other.
+*/
+func (lc LinearCombination) Sub(other LinearCombination) LinearCombination {
+	return lc.Add(other.Scale(new(big.Int).SetInt64(-1)))
+}
+
+/*
+Scale returns c.lc.
+*/
+func (lc LinearCombination) Scale(c *big.Int) LinearCombination {
+	var (
+		i int
+	)
+	result := make(LinearCombination, len(lc))
+	i = 0
+	for i < len(lc) {
+		result[i] = Term{Variable: lc[i].Variable, Coeff: Multiply(lc[i].Coeff, c)}
+		i = i + 1
+	}
+	return result
+}
+
+/*
+ConstraintSystem is implemented by both the prover and the verifier, so that a circuit
+(gadget) is written once and used to prove and to verify.
+*/
+type ConstraintSystem interface {
+	// Multiply adds a multiplication gate left.right = output and returns the
+	// variables of its left input, right input and output.
+	Multiply(left, right LinearCombination) (Variable, Variable, Variable)
+	// Constrain adds the constraint lc = 0.
+	Constrain(lc LinearCombination)
+}
+
+/*
+paramsR1CS contains the generators of arithmetic circuit proofs. The vectors Gg and Hh
+are the same as the ones of range proofs.
+*/
+type paramsR1CS struct {
+	group Group
+	G     Element
+	H     Element
+	U     Element
+	Gg    []Element
+	Hh    []Element
+}
+
+/*
+proofR1CS contains the necessary elements for the arithmetic circuit proof.
+*/
+type proofR1CS struct {
+	AI         Element
+	AO         Element
+	S          Element
+	T1         Element
+	T3         Element
+	T4         Element
+	T5         Element
+	T6         Element
+	Tx         Scalar
+	TxBlinding Scalar
+	EBlinding  Scalar
+	Proofip    proofBip
+}
+
+/*
+constraintSystem contains what the prover and the verifier have in common.
+*/
+type constraintSystem struct {
+	params      *paramsR1CS
+	transcript  *Transcript
+	constraints []LinearCombination
+	m           int64
+	n           int64
+}
+
+/*
+R1CSProver builds the circuit together with the values of its variables.
+*/
+type R1CSProver struct {
+	constraintSystem
+	v, gammas  []Scalar
+	aL, aR, aO []Scalar
+}
+
+/*
+R1CSVerifier builds the circuit from the commitments of the prover.
+*/
+type R1CSVerifier struct {
+	constraintSystem
+	V []Element
+}
+
+/*
+SetupR1CS generates the parameters of circuits with at most n multiplication gates.
+*/
+func SetupR1CS(n int64) (paramsR1CS, error) {
+	return SetupR1CSWithGroup(Secp256k1, n)
+}
+
+/*
+SetupR1CSWithGroup is SetupR1CS over the given group instead of secp256k1.
+*/
+func SetupR1CSWithGroup(group Group, n int64) (paramsR1CS, error) {
+	var (
+		p paramsR1CS
+	)
+	if n < 1 {
+		return p, errors.New("n must be at least 1")
+	}
+	p.group = group
+	p.G = group.Generator()
+	set := getGenerators(group, nextPowerOfTwo(n), SEEDH)
+	p.H, p.Gg, p.Hh = set.H, set.Gg, set.Hh
+	p.U = seedPoint(group, SEEDU)
+	return p, nil
+}
+
+/*
+NewR1CSProver returns a prover of circuits whose challenges are bound to the transcript.
+*/
+func NewR1CSProver(params *paramsR1CS, transcript *Transcript) *R1CSProver {
+	transcript.AppendMessage("dom-sep", []byte("r1cs v1"))
+	return &R1CSProver{constraintSystem: constraintSystem{params: params, transcript: transcript}}
+}
+
+/*
+NewR1CSVerifier returns a verifier of circuits whose challenges are bound to the transcript.
+*/
+func NewR1CSVerifier(params *paramsR1CS, transcript *Transcript) *R1CSVerifier {
+	transcript.AppendMessage("dom-sep", []byte("r1cs v1"))
+	return &R1CSVerifier{constraintSystem: constraintSystem{params: params, transcript: transcript}}
+}
+
+/*
+Constrain adds the constraint lc = 0.
+*/
+func (cs *constraintSystem) Constrain(lc LinearCombination) {
+	cs.constraints = append(cs.constraints, lc)
+}
+
+/*
+allocate adds a multiplication gate with the given inputs and returns its variables.
+*/
+func (cs *constraintSystem) allocate(left, right LinearCombination) (Variable, Variable, Variable) {
+	l := Variable{kind: variableLeft, index: cs.n}
+	r := Variable{kind: variableRight, index: cs.n}
+	o := Variable{kind: variableOutput, index: cs.n}
+	cs.n = cs.n + 1
+	cs.Constrain(left.Sub(l.LC()))
+	cs.Constrain(right.Sub(r.LC()))
+	return l, r, o
+}
+
+/*
+Commit computes the commitment V = g^v.h^gamma, appends it to the transcript and returns
+it with the variable that holds v in the circuit.
+*/
+func (prover *R1CSProver) Commit(v, gamma *big.Int) (Element, Variable) {
+	group := prover.params.group
+	prover.v = append(prover.v, group.NewScalar().SetBigInt(v))
+	prover.gammas = append(prover.gammas, group.NewScalar().SetBigInt(gamma))
+	V, _ := CommitG1(prover.v[prover.m], prover.gammas[prover.m], prover.params.H)
+	prover.transcript.AppendPoint("V", V)
+	prover.m = prover.m + 1
+	return V, Variable{kind: variableCommitted, index: prover.m - 1}
+}
+
+/*
+Commit appends the commitment V to the transcript and returns the variable that holds
+the committed value in the circuit.
+*/
+func (verifier *R1CSVerifier) Commit(V Element) Variable {
+	verifier.transcript.AppendPoint("V", V)
+	verifier.V = append(verifier.V, V)
+	verifier.m = verifier.m + 1
+	return Variable{kind: variableCommitted, index: verifier.m - 1}
+}
+
+/*
+Multiply adds a multiplication gate left.right = output and returns its variables.
+*/
+func (prover *R1CSProver) Multiply(left, right LinearCombination) (Variable, Variable, Variable) {
+	l := prover.eval(left)
+	r := prover.eval(right)
+	prover.aL = append(prover.aL, l)
+	prover.aR = append(prover.aR, r)
+	prover.aO = append(prover.aO, prover.params.group.NewScalar().Mul(l, r))
+	return prover.allocate(left, right)
+}
+
+/*
+Multiply adds a multiplication gate left.right = output and returns its variables.
+*/
+func (verifier *R1CSVerifier) Multiply(left, right LinearCombination) (Variable, Variable, Variable) {
+	return verifier.allocate(left, right)
+}
+
+/*
+eval returns the value of the linear combination.
+*/
+func (prover *R1CSProver) eval(lc LinearCombination) Scalar {
+	var (
+		value Scalar
+	)
+	group := prover.params.group
+	one := group.NewScalar().SetInt64(1)
+	result := group.NewScalar()
+	t := group.NewScalar()
+	for _, term := range lc {
+		switch term.Variable.kind {
+		case variableOne:
+			value = one
+		case variableCommitted:
+			value = prover.v[term.Variable.index]
+		case variableLeft:
+			value = prover.aL[term.Variable.index]
+		case variableRight:
+			value = prover.aR[term.Variable.index]
+		case variableOutput:
+			value = prover.aO[term.Variable.index]
+		}
+		result.Add(result, t.Mul(t.SetBigInt(term.Coeff), value))
+	}
+	return result
+}
+
+/*
+flatten combines the constraints with the powers of z into the vectors wL, wR, wO, wV
+and the scalar wc, such that the circuit is satisfied if and only if
+<wL, aL> + <wR, aR> + <wO, aO> = <wV, v> + wc.
+*/
+func (cs *constraintSystem) flatten(z Scalar) ([]Scalar, []Scalar, []Scalar, []Scalar, Scalar) {
+	var (
+		i int64
+	)
+	group := cs.params.group
+	wL := make([]Scalar, cs.n)
+	wR := make([]Scalar, cs.n)
+	wO := make([]Scalar, cs.n)
+	wV := make([]Scalar, cs.m)
+	wc := group.NewScalar()
+	i = 0
+	for i < cs.n {
+		wL[i] = group.NewScalar()
+		wR[i] = group.NewScalar()
+		wO[i] = group.NewScalar()
+		i = i + 1
+	}
+	i = 0
+	for i < cs.m {
+		wV[i] = group.NewScalar()
+		i = i + 1
+	}
+	expz := group.NewScalar().Set(z)
+	c := group.NewScalar()
+	for _, lc := range cs.constraints {
+		for _, term := range lc {
+			c.Mul(expz, c.SetBigInt(term.Coeff))
+			k := term.Variable.index
+			switch term.Variable.kind {
+			case variableOne:
+				wc.Sub(wc, c)
+			case variableCommitted:
+				wV[k].Sub(wV[k], c)
+			case variableLeft:
+				wL[k].Add(wL[k], c)
+			case variableRight:
+				wR[k].Add(wR[k], c)
+			case variableOutput:
+				wO[k].Add(wO[k], c)
+			}
+		}
+		expz.Mul(expz, z)
+	}
+	return wL, wR, wO, wV, wc
+}
+
+/*
+domainSep appends the size of the circuit to the transcript and returns the number of
+generators used by the proof, n rounded up to a power of two.
+*/
+func (cs *constraintSystem) domainSep() (int64, error) {
+	padded := nextPowerOfTwo(cs.n)
+	if padded > int64(len(cs.params.Gg)) {
+		return 0, errors.New("circuit has more multiplication gates than the parameters support")
+	}
+	cs.transcript.AppendUint64("m", uint64(cs.m))
+	cs.transcript.AppendUint64("n", uint64(cs.n))
+	cs.transcript.AppendUint64("q", uint64(len(cs.constraints)))
+	return padded, nil
+}
+
+/*
+Prove computes the ZK proof that the values committed with Commit satisfy the circuit.
+*/
+func (prover *R1CSProver) Prove() (proofR1CS, error) {
+	var (
+		i, n  int64
+		proof proofR1CS
+	)
+	group := prover.params.group
+	padded, err := prover.domainSep()
+	if err != nil {
+		return proof, err
+	}
+	n = prover.n
+	for _, lc := range prover.constraints {
+		if !prover.eval(lc).IsZero() {
+			return proof, errors.New("the values do not satisfy the constraints")
+		}
+	}
+	gg := prover.params.Gg[:padded]
+	hh := prover.params.Hh[:padded]
+
+	// A_I = h^alpha.g^aL.h^aR, A_O = h^beta.g^aO and S = h^rho.g^sL.h^sR
+	alpha := group.NewScalar().SetRandom()
+	beta := group.NewScalar().SetRandom()
+	rho := group.NewScalar().SetRandom()
+	sL := make([]Scalar, n)
+	sR := make([]Scalar, n)
+	zero := make([]Scalar, n)
+	i = 0
+	for i < n {
+		sL[i] = group.NewScalar().SetRandom()
+		sR[i] = group.NewScalar().SetRandom()
+		zero[i] = group.NewScalar()
+		i = i + 1
+	}
+	AI, _ := commitVector(nil, prover.aL, prover.aR, alpha, prover.params.H, gg, hh, n)
+	AO, _ := commitVector(nil, prover.aO, zero, beta, prover.params.H, gg, hh, n)
+	S, _ := commitVector(nil, sL, sR, rho, prover.params.H, gg, hh, n)
+
+	group.Normalize([]Element{AI, AO, S})
+	prover.transcript.AppendPoint("A_I", AI)
+	prover.transcript.AppendPoint("A_O", AO)
+	prover.transcript.AppendPoint("S", S)
+	y := prover.transcript.ChallengeScalar("y", group)
+	z := prover.transcript.ChallengeScalar("z", group)
+	wL, wR, wO, wV, _ := prover.flatten(z)
+
+	// l(X) = l1.X + l2.X^2 + l3.X^3, r(X) = r0 + r1.X + r3.X^3 with
+	// l1 = aL + y^-n.wR, l2 = aO, l3 = sL, r0 = wO - y^n, r1 = y^n.aR + wL, r3 = y^n.sR
+	vy := scalarPowers(group, y, padded)
+	vyinv := scalarPowers(group, group.NewScalar().Inverse(y), n)
+	l1 := make([]Scalar, n)
+	r0 := make([]Scalar, n)
+	r1 := make([]Scalar, n)
+	r3 := make([]Scalar, n)
+	i = 0
+	for i < n {
+		l1[i] = group.NewScalar().Mul(vyinv[i], wR[i])
+		l1[i].Add(prover.aL[i], l1[i])
+		r0[i] = group.NewScalar().Sub(wO[i], vy[i])
+		r1[i] = group.NewScalar().Mul(vy[i], prover.aR[i])
+		r1[i].Add(r1[i], wL[i])
+		r3[i] = group.NewScalar().Mul(vy[i], sR[i])
+		i = i + 1
+	}
+	l2 := prover.aO
+	l3 := sL
+
+	// t(X) = <l(X), r(X)> = t1.X + ... + t6.X^6
+	t := make([]Scalar, 7)
+	t[1] = innerProduct(group, l1, r0)
+	t[2] = group.NewScalar().Add(innerProduct(group, l1, r1), innerProduct(group, l2, r0))
+	t[3] = group.NewScalar().Add(innerProduct(group, l2, r1), innerProduct(group, l3, r0))
+	t[4] = group.NewScalar().Add(innerProduct(group, l1, r3), innerProduct(group, l3, r1))
+	t[5] = innerProduct(group, l2, r3)
+	t[6] = innerProduct(group, l3, r3)
+
+	// The blinding factor of t2 is fixed by the commitments: <wV, gamma>
+	tau := make([]Scalar, 7)
+	T := make([]Element, 7)
+	for _, k := range []int{1, 3, 4, 5, 6} {
+		tau[k] = group.NewScalar().SetRandom()
+		T[k], _ = CommitG1(t[k], tau[k], prover.params.H)
+	}
+	tau[2] = innerProduct(group, wV, prover.gammas)
+
+	group.Normalize([]Element{T[1], T[3], T[4], T[5], T[6]})
+	for _, k := range []int{1, 3, 4, 5, 6} {
+		prover.transcript.AppendPoint("T", T[k])
+	}
+	x := prover.transcript.ChallengeScalar("x", group)
+
+	// tx = t(x), txBlinding = tau(x) and eBlinding = x.(alpha + x.(beta + x.rho))
+	vx := scalarPowers(group, x, 7)
+	tx := group.NewScalar()
+	txBlinding := group.NewScalar()
+	s := group.NewScalar()
+	for k := 1; k <= 6; k++ {
+		tx.Add(tx, s.Mul(t[k], vx[k]))
+		txBlinding.Add(txBlinding, s.Mul(tau[k], vx[k]))
+	}
+	eBlinding := group.NewScalar().Mul(x, rho)
+	eBlinding.Add(beta, eBlinding)
+	eBlinding.Mul(x, eBlinding)
+	eBlinding.Add(alpha, eBlinding)
+	eBlinding.Mul(x, eBlinding)
+
+	prover.transcript.AppendScalar("t_x", tx)
+	prover.transcript.AppendScalar("t_x_blinding", txBlinding)
+	prover.transcript.AppendScalar("e_blinding", eBlinding)
+	w := prover.transcript.ChallengeScalar("w", group)
+
+	// l = l(x) and r = r(x), padded with l_i = 0 and r_i = -y^i
+	l := make([]Scalar, padded)
+	r := make([]Scalar, padded)
+	i = 0
+	for i < n {
+		l[i] = group.NewScalar().Mul(l1[i], x)
+		l[i].Add(l[i], s.Mul(l2[i], vx[2]))
+		l[i].Add(l[i], s.Mul(l3[i], vx[3]))
+		r[i] = group.NewScalar().Mul(r1[i], x)
+		r[i].Add(r0[i], r[i])
+		r[i].Add(r[i], s.Mul(r3[i], vx[3]))
+		i = i + 1
+	}
+	for i < padded {
+		l[i] = group.NewScalar()
+		r[i] = group.NewScalar().Neg(vy[i])
+		i = i + 1
+	}
+
+	// Inner Product over (g, h', P, tx), with u' = u^w
+	ux := group.NewElement().ScalarMult(prover.params.U, w)
+	hprime := switchGenerators(nil, hh, y)
+	P, _ := vectorExp(nil, append(append([]Element{}, gg...), hprime...), append(append([]Scalar{}, l...), r...))
+	P.Add(P, group.NewElement().ScalarMult(ux, tx))
+	proofip, err := proveBIP(nil, prover.transcript, l, r, gg, hprime, ux, P, padded, nil, nil)
+	if err != nil {
+		return proof, err
+	}
+
+	proof.AI = AI
+	proof.AO = AO
+	proof.S = S
+	proof.T1 = T[1]
+	proof.T3 = T[3]
+	proof.T4 = T[4]
+	proof.T5 = T[5]
+	proof.T6 = T[6]
+	proof.Tx = tx
+	proof.TxBlinding = txBlinding
+	proof.EBlinding = eBlinding
+	proof.Proofip = proofBip{
+		Ls: proofip.Ls,
+		Rs: proofip.Rs,
+		A:  proofip.A,
+		B:  proofip.B,
+		N:  proofip.N,
+	}
+	return proof, nil
+}
+
+/*
+Verify returns true if and only if the proof shows that the values committed in the
+commitments given to Commit satisfy the circuit. Both conditions of the protocol, the
+check of t(x) and the inner product argument, are combined with a random weight into a
+single multi-exponentiation.
+*/
+func (verifier *R1CSVerifier) Verify(proof proofR1CS) (bool, error) {
+	var (
+		i, n, padded int64
+	)
+	padded, err := verifier.domainSep()
+	if err != nil {
+		return false, err
+	}
+	n = verifier.n
+	if int64(len(proof.Proofip.Ls)) != log2(padded) || int64(len(proof.Proofip.Rs)) != log2(padded) {
+		return false, errors.New("inner product proof has the wrong number of rounds")
+	}
+	group := verifier.params.group
+	if !sameGroup(group, verifier.V...) || !sameGroup(group, proof.AI, proof.AO, proof.S, proof.T1, proof.T3, proof.T4, proof.T5, proof.T6) ||
+		!sameGroup(group, proof.Proofip.Ls...) || !sameGroup(group, proof.Proofip.Rs...) {
+		return false, errors.New("proof is not over the group of the parameters")
+	}
+	if !sameScalarGroup(group, proof.Tx, proof.TxBlinding, proof.EBlinding, proof.Proofip.A, proof.Proofip.B) {
+		return false, errors.New("proof is incomplete")
+	}
+	gg := verifier.params.Gg[:padded]
+	hh := verifier.params.Hh[:padded]
+
+	verifier.transcript.AppendPoint("A_I", proof.AI)
+	verifier.transcript.AppendPoint("A_O", proof.AO)
+	verifier.transcript.AppendPoint("S", proof.S)
+	y := verifier.transcript.ChallengeScalar("y", group)
+	z := verifier.transcript.ChallengeScalar("z", group)
+	wL, wR, wO, wV, wc := verifier.flatten(z)
+	T := []Element{proof.T1, proof.T3, proof.T4, proof.T5, proof.T6}
+	for _, Tk := range T {
+		verifier.transcript.AppendPoint("T", Tk)
+	}
+	x := verifier.transcript.ChallengeScalar("x", group)
+	verifier.transcript.AppendScalar("t_x", proof.Tx)
+	verifier.transcript.AppendScalar("t_x_blinding", proof.TxBlinding)
+	verifier.transcript.AppendScalar("e_blinding", proof.EBlinding)
+	w := verifier.transcript.ChallengeScalar("w", group)
+
+	xs := innerProductChallenges(verifier.transcript, proof.Proofip, group)
+	xinvs := inverses(group, xs)
+	s := innerProductScalars(group, xs, xinvs)
+	a, b, tx := proof.Proofip.A, proof.Proofip.B, proof.Tx
+	c := randomWeight(group)
+	vx := scalarPowers(group, x, 7)
+
+	points := []Element{proof.AI, proof.AO, proof.S, verifier.params.G, verifier.params.H, verifier.params.U}
+	scalars := make([]Scalar, 0, 2*padded+int64(len(xs))*2+int64(len(verifier.V))+11)
+
+	// delta = <y^-n.wR, wL>
+	one := group.NewScalar().SetInt64(1)
+	vyinv := scalarPowers(group, group.NewScalar().Inverse(y), padded)
+	delta := group.NewScalar()
+	gexp := make([]Scalar, padded)
+	hexp := make([]Scalar, padded)
+	t := group.NewScalar()
+	i = 0
+	for i < padded {
+		// g_i^(x.y^-i.wR_i - a.s_i) and h_i^(y^-i.(x.wL_i + wO_i - b/s_i) - 1)
+		ywR := group.NewScalar()
+		hi := group.NewScalar().Mul(b, s[padded-1-i])
+		if i < n {
+			ywR.Mul(vyinv[i], wR[i])
+			delta.Add(delta, t.Mul(ywR, wL[i]))
+			hi.Sub(t.Add(t.Mul(x, wL[i]), wO[i]), hi)
+		} else {
+			hi.Neg(hi)
+		}
+		gexp[i] = group.NewScalar().Mul(x, ywR)
+		gexp[i].Sub(gexp[i], t.Mul(a, s[i]))
+		hexp[i] = group.NewScalar().Mul(vyinv[i], hi)
+		hexp[i].Sub(hexp[i], one)
+		i = i + 1
+	}
+
+	// A_I^x.A_O^(x^2).S^(x^3)
+	scalars = append(scalars, x, vx[2], vx[3])
+	// g^(c.(x^2.(wc + delta) - tx)), h^(-eBlinding - c.txBlinding), u^(w.(tx - a.b))
+	gs := group.NewScalar().Add(wc, delta)
+	gs.Mul(vx[2], gs)
+	gs.Sub(gs, tx)
+	scalars = append(scalars, gs.Mul(c, gs))
+	hs := group.NewScalar().Mul(c, proof.TxBlinding)
+	hs.Add(hs, proof.EBlinding)
+	scalars = append(scalars, hs.Neg(hs))
+	us := group.NewScalar().Mul(a, b)
+	us.Sub(tx, us)
+	scalars = append(scalars, us.Mul(w, us))
+	// V^(c.x^2.wV)
+	cx2 := group.NewScalar().Mul(c, vx[2])
+	for k := range verifier.V {
+		points = append(points, verifier.V[k])
+		scalars = append(scalars, group.NewScalar().Mul(cx2, wV[k]))
+	}
+	// T1^(c.x).T3^(c.x^3)...T6^(c.x^6)
+	for k, e := range []int{1, 3, 4, 5, 6} {
+		points = append(points, T[k])
+		scalars = append(scalars, group.NewScalar().Mul(c, vx[e]))
+	}
+	// L^(x^2).R^(x^-2)
+	for k := range xs {
+		points = append(points, proof.Proofip.Ls[k], proof.Proofip.Rs[k])
+		scalars = append(scalars, group.NewScalar().Mul(xs[k], xs[k]), group.NewScalar().Mul(xinvs[k], xinvs[k]))
+	}
+	points = append(points, gg...)
+	scalars = append(scalars, gexp...)
+	points = append(points, hh...)
+	scalars = append(scalars, hexp...)
+
+	result, err := VectorExp(points, scalars)
+	if err != nil {
+		return false, err
+	}
+	return result.IsIdentity(), nil
+}
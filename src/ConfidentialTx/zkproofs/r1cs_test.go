@@ -0,0 +1,216 @@
+package zkproofs
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+/*
+multiplyGadget constrains out = in.rate.
+*/
+func multiplyGadget(cs ConstraintSystem, in, rate, out Variable) {
+	_, _, o := cs.Multiply(in.LC(), rate.LC())
+	cs.Constrain(o.LC().Sub(out.LC()))
+}
+
+/*
+tiersGadget constrains v to be one of the tiers, with (v - t_1)...(v - t_k) = 0.
+*/
+func tiersGadget(cs ConstraintSystem, v Variable, tiers []int64) {
+	product := v.LC().Sub(Constant(new(big.Int).SetInt64(tiers[0])))
+	for _, tier := range tiers[1:] {
+		_, _, o := cs.Multiply(product, v.LC().Sub(Constant(new(big.Int).SetInt64(tier))))
+		product = o.LC()
+	}
+	cs.Constrain(product)
+}
+
+/*
+sumGadget constrains the sum of the items to be equal to total.
+*/
+func sumGadget(cs ConstraintSystem, items []Variable, total Variable) {
+	lc := total.LC().Scale(new(big.Int).SetInt64(-1))
+	for _, item := range items {
+		lc = lc.Add(item.LC())
+	}
+	cs.Constrain(lc)
+}
+
+func setupR1CS(t testing.TB, group Group, n int64) *paramsR1CS {
+	params, err := SetupR1CSWithGroup(group, n)
+	if err != nil {
+		t.Fatalf("Assert failure: unexpected error: %v", err)
+	}
+	return &params
+}
+
+/*
+commitValues commits to the values with the prover and returns the commitments and
+the variables.
+*/
+func commitValues(prover *R1CSProver, values []int64) ([]Element, []Variable) {
+	V := make([]Element, len(values))
+	vars := make([]Variable, len(values))
+	for i, value := range values {
+		gamma, _ := rand.Int(rand.Reader, prover.params.group.Order())
+		V[i], vars[i] = prover.Commit(new(big.Int).SetInt64(value), gamma)
+	}
+	return V, vars
+}
+
+/*
+proveMultiply proves that in.rate = out and returns the commitments with the proof.
+*/
+func proveMultiply(t *testing.T, params *paramsR1CS, in, rate, out int64) ([]Element, proofR1CS) {
+	prover := NewR1CSProver(params, NewTranscript("test"))
+	V, vars := commitValues(prover, []int64{in, rate, out})
+	multiplyGadget(prover, vars[0], vars[1], vars[2])
+	proof, err := prover.Prove()
+	if err != nil {
+		t.Fatalf("Assert failure: unexpected error: %v", err)
+	}
+	return V, proof
+}
+
+func verifyMultiply(params *paramsR1CS, V []Element, proof proofR1CS) (bool, error) {
+	verifier := NewR1CSVerifier(params, NewTranscript("test"))
+	in := verifier.Commit(V[0])
+	rate := verifier.Commit(V[1])
+	out := verifier.Commit(V[2])
+	multiplyGadget(verifier, in, rate, out)
+	return verifier.Verify(proof)
+}
+
+/*
+Test that output = input.rate is proven, and that the proof does not verify against
+other commitments.
+*/
+func TestR1CSMultiply(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		params := setupR1CS(t, group, 4)
+		V, proof := proveMultiply(t, params, 120, 3, 360)
+		ok, err := verifyMultiply(params, V, proof)
+		if ok != true || err != nil {
+			t.Errorf("Assert failure: expected true, actual: %t %v", ok, err)
+		}
+		W, _ := proveMultiply(t, params, 120, 4, 480)
+		ok, _ = verifyMultiply(params, []Element{V[0], V[1], W[2]}, proof)
+		if ok != false {
+			t.Errorf("Assert failure: expected false, actual: %t", ok)
+		}
+	})
+}
+
+/*
+Test that a value is proven to be one of several tiers.
+*/
+func TestR1CSTiers(t *testing.T) {
+	tiers := []int64{10, 20, 50}
+	params := setupR1CS(t, Secp256k1, 4)
+	prover := NewR1CSProver(params, NewTranscript("test"))
+	V, vars := commitValues(prover, []int64{20})
+	tiersGadget(prover, vars[0], tiers)
+	proof, err := prover.Prove()
+	if err != nil {
+		t.Fatalf("Assert failure: unexpected error: %v", err)
+	}
+	verifier := NewR1CSVerifier(params, NewTranscript("test"))
+	tiersGadget(verifier, verifier.Commit(V[0]), tiers)
+	ok, err := verifier.Verify(proof)
+	if ok != true || err != nil {
+		t.Errorf("Assert failure: expected true, actual: %t %v", ok, err)
+	}
+
+	prover = NewR1CSProver(params, NewTranscript("test"))
+	_, vars = commitValues(prover, []int64{30})
+	tiersGadget(prover, vars[0], tiers)
+	_, err = prover.Prove()
+	if err == nil {
+		t.Errorf("Assert failure: expected an error for a value that is not a tier")
+	}
+}
+
+/*
+Test that the sum of line items is proven to be equal to the total, with linear
+constraints only.
+*/
+func TestR1CSSum(t *testing.T) {
+	params := setupR1CS(t, Secp256k1, 1)
+	prover := NewR1CSProver(params, NewTranscript("test"))
+	V, vars := commitValues(prover, []int64{15, 25, 60, 100})
+	sumGadget(prover, vars[:3], vars[3])
+	proof, err := prover.Prove()
+	if err != nil {
+		t.Fatalf("Assert failure: unexpected error: %v", err)
+	}
+	verify := func(V []Element) bool {
+		verifier := NewR1CSVerifier(params, NewTranscript("test"))
+		items := []Variable{verifier.Commit(V[0]), verifier.Commit(V[1]), verifier.Commit(V[2])}
+		sumGadget(verifier, items, verifier.Commit(V[3]))
+		ok, _ := verifier.Verify(proof)
+		return ok
+	}
+	if verify(V) != true {
+		t.Errorf("Assert failure: expected true, actual: false")
+	}
+	if verify([]Element{V[1], V[0], V[2], V[3]}) != false {
+		t.Errorf("Assert failure: expected false, actual: true")
+	}
+}
+
+/*
+Test that tampered proofs, or proofs with the wrong number of rounds, are rejected.
+*/
+func TestR1CSInvalidProof(t *testing.T) {
+	params := setupR1CS(t, Secp256k1, 4)
+	V, proof := proveMultiply(t, params, 7, 6, 42)
+
+	tampered := proof
+	tampered.Tx = Secp256k1.NewScalar().Add(proof.Tx, Secp256k1.NewScalar().SetInt64(1))
+	ok, _ := verifyMultiply(params, V, tampered)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+	tampered = proof
+	tampered.AO = new(p256).Add(proof.AO, params.G)
+	ok, _ = verifyMultiply(params, V, tampered)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+	tampered = proof
+	tampered.Proofip.Ls = append(proof.Proofip.Ls, params.G)
+	tampered.Proofip.Rs = append(proof.Proofip.Rs, params.G)
+	ok, err := verifyMultiply(params, V, tampered)
+	if ok != false || err == nil {
+		t.Errorf("Assert failure: expected an error, actual: %t %v", ok, err)
+	}
+}
+
+/*
+Test that circuits larger than the parameters are rejected.
+*/
+func TestR1CSCapacity(t *testing.T) {
+	params := setupR1CS(t, Secp256k1, 2)
+	prover := NewR1CSProver(params, NewTranscript("test"))
+	_, vars := commitValues(prover, []int64{10})
+	tiersGadget(prover, vars[0], []int64{10, 20, 50, 100})
+	_, err := prover.Prove()
+	if err == nil {
+		t.Errorf("Assert failure: expected an error for too many gates")
+	}
+}
+
+func BenchmarkR1CSMultiply(b *testing.B) {
+	params, _ := SetupR1CS(1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		prover := NewR1CSProver(&params, NewTranscript("test"))
+		V, vars := commitValues(prover, []int64{120, 3, 360})
+		multiplyGadget(prover, vars[0], vars[1], vars[2])
+		proof, _ := prover.Prove()
+		verifier := NewR1CSVerifier(&params, NewTranscript("test"))
+		multiplyGadget(verifier, verifier.Commit(V[0]), verifier.Commit(V[1]), verifier.Commit(V[2]))
+		verifier.Verify(proof)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+This file contains rewindable range proofs, in the style of secp256k1-zkp. The blinding
+factors alpha, rho, tau1 and tau2 are derived from a nonce shared by the sender and the
+recipient, and the amount and a short memo are added to alpha. The recipient recomputes
+the blinding factors and the challenges from the proof, and recovers the message from
+mu = alpha + rho.x and the blinding factor gamma of V from taux, so that no other
+channel is needed to send them.
+*/
+
+package zkproofs
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+)
+
+/*
+RewindMemoSize is the maximum size of the memo embedded in a rewindable proof.
+*/
+const RewindMemoSize = 22
+
+/*
+rewindBlinds derives the blinding factors of the proof of V from the nonce, and adds the
+message to alpha. Binding them to V makes them differ for each commitment.
+*/
+func rewindBlinds(nonce []byte, V Element, message Scalar) rangeBlinds {
+	var (
+		blinds rangeBlinds
+	)
+	group := V.Group()
+	t := NewTranscript("rewind v1")
+	t.AppendMessage("nonce", nonce)
+	t.AppendPoint("V", V)
+	blinds.alpha = t.ChallengeScalar("alpha", group)
+	blinds.rho = t.ChallengeScalar("rho", group)
+	blinds.tau1 = t.ChallengeScalar("tau1", group)
+	blinds.tau2 = t.ChallengeScalar("tau2", group)
+	blinds.alpha.Add(blinds.alpha, message)
+	return blinds
+}
+
+/*
+encodeRewindMessage writes 0 || amount || len(memo) || memo in 32 bytes, which is less
+than the order of every group as a big endian integer.
+*/
+func encodeRewindMessage(amount *big.Int, memo []byte) (*big.Int, error) {
+	var (
+		buf [32]byte
+	)
+	if !amount.IsInt64() {
+		return nil, errors.New("amount does not fit in 64 bits")
+	}
+	if len(memo) > RewindMemoSize {
+		return nil, errors.New("memo is longer than RewindMemoSize")
+	}
+	binary.BigEndian.PutUint64(buf[1:9], uint64(amount.Int64()))
+	buf[9] = byte(len(memo))
+	copy(buf[10:], memo)
+	return new(big.Int).SetBytes(buf[:]), nil
+}
+
+/*
+decodeRewindMessage reads the amount and the memo written by encodeRewindMessage.
+*/
+func decodeRewindMessage(message *big.Int) (*big.Int, []byte, error) {
+	var (
+		buf [32]byte
+	)
+	if message.BitLen() > 8*31 {
+		return nil, nil, errors.New("proof was not made with this nonce")
+	}
+	message.FillBytes(buf[:])
+	size := int(buf[9])
+	if size > RewindMemoSize {
+		return nil, nil, errors.New("proof was not made with this nonce")
+	}
+	for _, b := range buf[10+size:] {
+		if b != 0 {
+			return nil, nil, errors.New("proof was not made with this nonce")
+		}
+	}
+	amount := new(big.Int).SetInt64(int64(binary.BigEndian.Uint64(buf[1:9])))
+	memo := make([]byte, size)
+	copy(memo, buf[10:10+size])
+	return amount, memo, nil
+}
+
+/*
+GenerateRewindableProof computes the ZK proof that the secret belongs to [A, B], such
+that the recipient who knows the nonce recovers the secret, the blinding factor gamma
+and the memo with Rewind. The nonce must be secret, e.g. derived with Diffie-Hellman
+from the keys of the sender and the recipient, and must only be used for a single
+proof: the scalars mu and taux of two proofs made with the same nonce and commitment
+reveal the blinding factors.
+*/
+func (zkrp *Bp) GenerateRewindableProof(secret *big.Int, nonce, memo []byte) (*big.Int, proofBP, error) {
+	return zkrp.GenerateRewindableProofWithTranscript(NewTranscript(DOMAIN), secret, nonce, memo)
+}
+
+/*
+GenerateRewindableProofWithTranscript is GenerateRewindableProof with the Fiat-Shamir
+challenges bound to the given transcript.
+*/
+func (zkrp *Bp) GenerateRewindableProofWithTranscript(transcript *Transcript, secret *big.Int, nonce, memo []byte) (*big.Int, proofBP, error) {
+	var (
+		proof proofBP
+	)
+	message, err := encodeRewindMessage(secret, memo)
+	if err != nil {
+		return nil, proof, err
+	}
+	gammas, err := zkrp.randomScalars(1)
+	if err != nil {
+		return nil, proof, err
+	}
+	group := zkrp.Group()
+	gamma := gammas[0]
+	V, _ := CommitG1(group.NewScalar().SetBigInt(secret), gamma, zkrp.H)
+
+	proof, err = zkrp.proveSecret(transcript, V, secret, gamma, rewindBlinds(nonce, V, group.NewScalar().SetBigInt(message)))
+	if err != nil {
+		return nil, proof, err
+	}
+	return gamma.BigInt(), proof, nil
+}
+
+/*
+Rewind returns the secret, the blinding factor gamma and the memo of a proof made by
+GenerateRewindableProof with the same nonce. It fails if the proof was made with another
+nonce, but it does not verify the proof, which must be checked with Verify.
+*/
+func (zkrp *Bp) Rewind(proof proofBP, nonce []byte) (*big.Int, *big.Int, []byte, error) {
+	return zkrp.RewindWithTranscript(NewTranscript(DOMAIN), proof, nonce)
+}
+
+/*
+RewindWithTranscript is Rewind for a proof whose Fiat-Shamir challenges are bound to
+the given transcript.
+*/
+func (zkrp *Bp) RewindWithTranscript(transcript *Transcript, proof proofBP, nonce []byte) (*big.Int, *big.Int, []byte, error) {
+	group := zkrp.Group()
+	if proof.V == nil || proof.A == nil || proof.S == nil || proof.T1 == nil || proof.T2 == nil ||
+		!sameScalarGroup(group, proof.Taux, proof.Mu, proof.Tprime) {
+		return nil, nil, nil, errors.New("proof is incomplete")
+	}
+	if !sameGroup(zkrp.Group(), proof.V, proof.A, proof.S, proof.T1, proof.T2) {
+		return nil, nil, nil, errors.New("proof is not over the group of the parameters")
+	}
+	V := zkrp.shiftCommitments([]Element{proof.V})
+	if err := zkrp.checkRange(int64(len(V))); err != nil {
+		return nil, nil, nil, err
+	}
+	_, z, x, _ := zkrp.rangeChallenges(transcript, V, proof.aggregate())
+	blinds := rewindBlinds(nonce, proof.V, group.NewScalar())
+	t := group.NewScalar()
+
+	// message = mu - rho.x - alpha
+	message := group.NewScalar().Sub(proof.Mu, t.Mul(blinds.rho, x))
+	message.Sub(message, blinds.alpha)
+	amount, memo, err := decodeRewindMessage(message.BigInt())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// gamma = (taux - tau2.x^2 - tau1.x) / sum_j z^(1+j)
+	sumz := group.NewScalar()
+	zj := group.NewScalar().Mul(z, z)
+	for range V {
+		sumz.Add(sumz, zj)
+		zj.Mul(zj, z)
+	}
+	gamma := group.NewScalar().Mul(blinds.tau2, x)
+	gamma.Add(gamma, blinds.tau1)
+	gamma.Mul(gamma, x)
+	gamma.Sub(proof.Taux, gamma)
+	gamma.Mul(gamma, sumz.Inverse(sumz))
+
+	C, _ := CommitG1(group.NewScalar().SetBigInt(amount), gamma, zkrp.H)
+	if !C.Equal(proof.V) {
+		return nil, nil, nil, errors.New("proof was not made with this nonce")
+	}
+	return amount, gamma.BigInt(), memo, nil
+}
@@ -0,0 +1,89 @@
+package zkproofs
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+/*
+Test that the recipient recovers the secret, gamma and the memo from the proof alone,
+and that the proof is still valid.
+*/
+func TestRewind(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp Bp
+		)
+		nonce := []byte("shared secret of sender and recipient")
+		memo := []byte("invoice 2018-42")
+		intervals := [][3]int64{{0, 255, 200}, {18, 200, 18}, {-50, 50, -7}}
+		for _, c := range intervals {
+			zkrp.SetupWithGroup(group, c[0], c[1], 1)
+			secret := new(big.Int).SetInt64(c[2])
+			gamma, proof, err := zkrp.GenerateRewindableProof(secret, nonce, memo)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			ok, _ := zkrp.Verify(proof)
+			if ok != true {
+				t.Errorf("Assert failure for %d: expected true, actual: %t", c[2], ok)
+			}
+			// the recipient only has the serialized proof
+			data, _ := DumpProof(&proof)
+			_, loaded, _ := LoadProof(data)
+			amount, blind, rewound, err := zkrp.Rewind(*loaded, nonce)
+			if err != nil {
+				t.Fatalf("Unexpected error for %d: %s", c[2], err)
+			}
+			if amount.Cmp(secret) != 0 || blind.Cmp(gamma) != 0 || !bytes.Equal(rewound, memo) {
+				t.Errorf("Assert failure: expected %d %s %q, actual: %d %s %q", secret, gamma, memo, amount, blind, rewound)
+			}
+		}
+	})
+}
+
+/*
+Test that a proof is not rewound with another nonce or transcript, nor when it was not
+made to be rewound.
+*/
+func TestRewindWrongNonce(t *testing.T) {
+	zkrp := GetZkrp()
+	secret := new(big.Int).SetInt64(30)
+	_, proof, _ := zkrp.GenerateRewindableProof(secret, []byte("nonce"), nil)
+	_, _, _, err := zkrp.Rewind(proof, []byte("other nonce"))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for another nonce")
+	}
+	_, _, _, err = zkrp.RewindWithTranscript(NewTranscript("other"), proof, []byte("nonce"))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for another transcript")
+	}
+	_, _, memo, err := zkrp.Rewind(proof, []byte("nonce"))
+	if err != nil || len(memo) != 0 {
+		t.Errorf("Assert failure: expected an empty memo, actual: %q %v", memo, err)
+	}
+	_, proof, _ = zkrp.GenerateProof(secret)
+	_, _, _, err = zkrp.Rewind(proof, []byte("nonce"))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a proof that is not rewindable")
+	}
+}
+
+/*
+Test the limits of the embedded message.
+*/
+func TestRewindMessage(t *testing.T) {
+	zkrp := GetZkrp()
+	secret := new(big.Int).SetInt64(30)
+	_, _, err := zkrp.GenerateRewindableProof(secret, []byte("nonce"), make([]byte, RewindMemoSize+1))
+	if err == nil {
+		t.Errorf("Assert failure: expected error for a memo longer than RewindMemoSize")
+	}
+	memo := bytes.Repeat([]byte{0xff}, RewindMemoSize)
+	_, proof, _ := zkrp.GenerateRewindableProof(secret, []byte("nonce"), memo)
+	_, _, rewound, err := zkrp.Rewind(proof, []byte("nonce"))
+	if err != nil || !bytes.Equal(rewound, memo) {
+		t.Errorf("Assert failure: expected %q, actual: %q %v", memo, rewound, err)
+	}
+}
@@ -0,0 +1,519 @@
+/*
+This file contains ristretto255 of RFC 9496, the prime-order group built on the points
+of edwards25519, as a backend of Group. An element is a class of four points that
+differ by a point of order 4, so the cofactor of edwards25519 never shows through:
+elements are encoded to the 32 bytes of a canonical field element, which identify the
+class, and two elements are equal if their points are in the same class. Messages are
+hashed to the group with the one-way map of RFC 9496, built on the Elligator 2 map,
+applied to 64 bytes of expand_message_xmd with SHA-512, as the suite
+ristretto255_XMD:SHA-512_R255MAP_RO_ of RFC 9380.
+
+The encodings, the generator and the hash to group are those of the other
+implementations of ristretto255, which lets the proofs be checked against them.
+*/
+
+package zkproofs
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"math/big"
+)
+
+var (
+	// the order l = 2^252 + 27742317777372353535851937790883648493 of ristretto255
+	RISTRETTOORDER, _ = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	R255SUITE         = "ristretto255_XMD:SHA-512_R255MAP_RO_"
+)
+
+var (
+	// d = -121665/121666 of edwards25519, and 2.d
+	edwardsD  = decField25519("37095705934669439343138083508754565189542113879843219016388785533085940283555")
+	edwardsD2 = *new(field25519).add(&edwardsD, &edwardsD)
+	// the constants of RFC 9496, section 4.1
+	sqrtM1         = decField25519("19681161376707505956807079304988542015446066515923890162744021073123829784752")
+	sqrtADMinusOne = decField25519("25063068953384623474111414158702152701244531502492656460079210482610430750235")
+	invsqrtAMinusD = decField25519("54469307008909316920995813868745141605393597292927456921205312896311721017578")
+	oneMinusDSq    = decField25519("1159843021668779879193775521855586647937357759715417654439879720876111806838")
+	dMinusOneSq    = decField25519("40440834346308536858101042469323190826248399146238708352240133220865137265952")
+	ristrettoBase  = mustDecodeRistretto("e2f2ae0a6abc4e71a884a961c500515f58e30b6aa582dd8db6a65945e08d2d76")
+)
+
+func decField25519(s string) field25519 {
+	x, _ := new(big.Int).SetString(s, 10)
+	return *new(field25519).setBig(x)
+}
+
+func mustDecodeRistretto(s string) edwardsPoint {
+	b, _ := hex.DecodeString(s)
+	p, err := decodeRistretto(b)
+	if err != nil {
+		panic("zkproofs: invalid ristretto255 constant " + s)
+	}
+	return p.p
+}
+
+/*
+r255 is an element of ristretto255, represented by any point of its class.
+*/
+type r255 struct {
+	p edwardsPoint
+}
+
+/*
+sqrtRatioM1 returns whether u / v is a square and the nonnegative sqrt(u / v) if it
+is, or sqrt(i.u / v) otherwise, where i = sqrt(-1). It is SQRT_RATIO_M1 of RFC 9496.
+*/
+func sqrtRatioM1(u, v *field25519) (uint64, field25519) {
+	var (
+		v3, v7, r, t, check, negU, negUI, rPrime field25519
+	)
+	v3.square(v)
+	v3.mul(&v3, v)
+	v7.square(&v3)
+	v7.mul(&v7, v)
+	t.mul(u, &v7)
+	t.sqrtPow(&t)
+	r.mul(u, &v3)
+	r.mul(&r, &t)
+	check.square(&r)
+	check.mul(&check, v)
+	negU.neg(u)
+	negUI.mul(&negU, &sqrtM1)
+	correctSign := check.ctEqual(u)
+	flippedSign := check.ctEqual(&negU)
+	flippedSignI := check.ctEqual(&negUI)
+	rPrime.mul(&r, &sqrtM1)
+	r.cmov(&rPrime, flippedSign|flippedSignI)
+	r.abs(&r)
+	return (correctSign | flippedSign) & 1, r
+}
+
+/*
+decodeRistretto decodes an element with the decoding of RFC 9496, section 4.3.1. The
+encoding must be the canonical encoding of a nonnegative field element s, and s must
+be the encoding of an element.
+*/
+func decodeRistretto(data []byte) (*r255, error) {
+	var (
+		s, ss, u1, u2, u2s, v, t, dx, dy, x, y field25519
+	)
+	if len(data) != 32 {
+		return nil, errors.New("invalid ristretto255 encoding")
+	}
+	if !s.setBytes(data) || s.isNegative() == 1 {
+		return nil, errors.New("invalid ristretto255 encoding")
+	}
+	ss.square(&s)
+	u1.sub(&field25519One, &ss)
+	u2.add(&field25519One, &ss)
+	u2s.square(&u2)
+	// v = -(d.u1^2) - u2^2
+	v.square(&u1)
+	v.mul(&v, &edwardsD)
+	v.neg(&v)
+	v.sub(&v, &u2s)
+	t.mul(&v, &u2s)
+	wasSquare, invsqrt := sqrtRatioM1(&field25519One, &t)
+	dx.mul(&invsqrt, &u2)
+	dy.mul(&invsqrt, &dx)
+	dy.mul(&dy, &v)
+	x.add(&s, &s)
+	x.mul(&x, &dx)
+	x.abs(&x)
+	y.mul(&u1, &dy)
+	t.mul(&x, &y)
+	if wasSquare == 0 || t.isNegative() == 1 || y.isZero() {
+		return nil, errors.New("invalid ristretto255 encoding")
+	}
+	return &r255{p: edwardsPoint{X: x, Y: y, Z: field25519One, T: t}}, nil
+}
+
+/*
+encode returns the encoding of the element of RFC 9496, section 4.3.2, the same for
+all the points of the class.
+*/
+func (e *r255) encode() []byte {
+	var (
+		u1, u2, t, den1, den2, zInv, ix, iy, enchanted, x, y, negY, denInv, s field25519
+	)
+	p := &e.p
+	// u1 = (Z + Y).(Z - Y), u2 = X.Y
+	u1.add(&p.Z, &p.Y)
+	t.sub(&p.Z, &p.Y)
+	u1.mul(&u1, &t)
+	u2.mul(&p.X, &p.Y)
+	t.square(&u2)
+	t.mul(&t, &u1)
+	_, invsqrt := sqrtRatioM1(&field25519One, &t)
+	den1.mul(&invsqrt, &u1)
+	den2.mul(&invsqrt, &u2)
+	zInv.mul(&den1, &den2)
+	zInv.mul(&zInv, &p.T)
+	ix.mul(&p.X, &sqrtM1)
+	iy.mul(&p.Y, &sqrtM1)
+	enchanted.mul(&den1, &invsqrtAMinusD)
+	t.mul(&p.T, &zInv)
+	rotate := ctMask(t.isNegative())
+	x, y, denInv = p.X, p.Y, den2
+	x.cmov(&iy, rotate)
+	y.cmov(&ix, rotate)
+	denInv.cmov(&enchanted, rotate)
+	// y = -y if x.zInv is negative
+	t.mul(&x, &zInv)
+	negY.neg(&y)
+	y.cmov(&negY, ctMask(t.isNegative()))
+	s.sub(&p.Z, &y)
+	s.mul(&s, &denInv)
+	s.abs(&s)
+	return s.bytes()
+}
+
+/*
+elligator maps the field element t to a point with the MAP function of RFC 9496,
+section 4.3.4, built on the Elligator 2 map.
+*/
+func elligator(t *field25519) edwardsPoint {
+	var (
+		r, u, v, tmp, sPrime, c, n, w0, w1, w2, w3, ss field25519
+		p                                              edwardsPoint
+	)
+	r.square(t)
+	r.mul(&r, &sqrtM1)
+	// u = (r + 1).ONE_MINUS_D_SQ, v = (-1 - r.d).(r + d)
+	u.add(&r, &field25519One)
+	u.mul(&u, &oneMinusDSq)
+	v.mul(&r, &edwardsD)
+	v.add(&v, &field25519One)
+	v.neg(&v)
+	tmp.add(&r, &edwardsD)
+	v.mul(&v, &tmp)
+	wasSquare, s := sqrtRatioM1(&u, &v)
+	sPrime.mul(&s, t)
+	sPrime.abs(&sPrime)
+	sPrime.neg(&sPrime)
+	notSquare := ctMask(wasSquare ^ 1)
+	s.cmov(&sPrime, notSquare)
+	c.neg(&field25519One)
+	c.cmov(&r, notSquare)
+	// N = c.(r - 1).D_MINUS_ONE_SQ - v
+	n.sub(&r, &field25519One)
+	n.mul(&n, &c)
+	n.mul(&n, &dMinusOneSq)
+	n.sub(&n, &v)
+	ss.square(&s)
+	w0.add(&s, &s)
+	w0.mul(&w0, &v)
+	w1.mul(&n, &sqrtADMinusOne)
+	w2.sub(&field25519One, &ss)
+	w3.add(&field25519One, &ss)
+	p.X.mul(&w0, &w3)
+	p.Y.mul(&w2, &w1)
+	p.Z.mul(&w1, &w3)
+	p.T.mul(&w0, &w2)
+	return p
+}
+
+/*
+fromUniformBytes returns the element of 64 uniform bytes with the one-way map of RFC
+9496, section 4.3.4: each half is mapped with elligator to a point, after clearing
+its bit 255, and the two points are added.
+*/
+func fromUniformBytes(b []byte) *r255 {
+	var (
+		buf    [32]byte
+		t1, t2 field25519
+		result r255
+	)
+	copy(buf[:], b[:32])
+	buf[31] &= 0x7f
+	t1.setBytes(buf[:])
+	copy(buf[:], b[32:64])
+	buf[31] &= 0x7f
+	t2.setBytes(buf[:])
+	p1 := elligator(&t1)
+	p2 := elligator(&t2)
+	result.p.add(&p1, &p2)
+	return &result
+}
+
+/*
+IsIdentity returns true if and only if the element is the identity, whose class is
+the points with X = 0 or Y = 0.
+*/
+func (e *r255) IsIdentity() bool {
+	return e.p.X.isZero() || e.p.Y.isZero()
+}
+
+/*
+Group returns Ristretto255.
+*/
+func (e *r255) Group() Group {
+	return Ristretto255
+}
+
+/*
+Bytes returns the 32-byte encoding of the element.
+*/
+func (e *r255) Bytes() []byte {
+	return e.encode()
+}
+
+/*
+Equal returns true if and only if e and b are the same element, i.e. X1.Y2 = Y1.X2 or
+Y1.Y2 = X1.X2, which holds for the points of the same class. Elements of other groups
+are never equal to e.
+*/
+func (e *r255) Equal(b Element) bool {
+	var (
+		t1, t2 field25519
+	)
+	q, ok := b.(*r255)
+	if !ok {
+		return false
+	}
+	t1.mul(&e.p.X, &q.p.Y)
+	t2.mul(&e.p.Y, &q.p.X)
+	if t1.equal(&t2) {
+		return true
+	}
+	t1.mul(&e.p.Y, &q.p.Y)
+	t2.mul(&e.p.X, &q.p.X)
+	return t1.equal(&t2)
+}
+
+func (e *r255) Set(a Element) Element {
+	e.p = a.(*r255).p
+	return e
+}
+
+func (e *r255) SetIdentity() Element {
+	e.p = edwardsIdentity
+	return e
+}
+
+func (e *r255) Neg(a Element) Element {
+	e.p.neg(&a.(*r255).p)
+	return e
+}
+
+func (e *r255) Add(a, b Element) Element {
+	e.p.add(&a.(*r255).p, &b.(*r255).p)
+	return e
+}
+
+func (e *r255) Sub(a, b Element) Element {
+	var (
+		nb edwardsPoint
+	)
+	nb.neg(&b.(*r255).p)
+	e.p.add(&a.(*r255).p, &nb)
+	return e
+}
+
+func (e *r255) Double(a Element) Element {
+	e.p.double(&a.(*r255).p)
+	return e
+}
+
+/*
+ScalarMult sets e = k.a with a window of 4 bits, in time that depends on k.
+*/
+func (e *r255) ScalarMult(a Element, k Scalar) Element {
+	e.p = edwardsMultiScalarMult([]*edwardsPoint{&a.(*r255).p}, []Scalar{k})
+	return e
+}
+
+func (e *r255) ScalarBaseMult(k Scalar) Element {
+	e.p = edwardsMultiScalarMult([]*edwardsPoint{&ristrettoBase}, []Scalar{k})
+	return e
+}
+
+/*
+String returns the hex string of the encoding of the element.
+*/
+func (e *r255) String() string {
+	return "r255(" + hex.EncodeToString(e.encode()) + ")"
+}
+
+/*
+edwardsTable returns the multiples 0.P, ..., 15.P of the point p.
+*/
+func edwardsTable(p *edwardsPoint) [1 << strausWindow]edwardsPoint {
+	var (
+		table [1 << strausWindow]edwardsPoint
+	)
+	table[0] = edwardsIdentity
+	table[1] = *p
+	for j := 2; j < len(table); j++ {
+		table[j].add(&table[j-1], p)
+	}
+	return table
+}
+
+/*
+edwardsMultiScalarMult returns sum_i b_i.a_i with Straus' method, as straus in msm.go:
+the 256 doublings are shared by all the terms and every nonzero window of 4 bits of a
+scalar adds a multiple read from the table of its point.
+*/
+func edwardsMultiScalarMult(a []*edwardsPoint, b []Scalar) edwardsPoint {
+	var (
+		acc edwardsPoint
+	)
+	tables := make([][1 << strausWindow]edwardsPoint, len(a))
+	scalars := make([][4]uint64, len(a))
+	for t := range a {
+		tables[t] = edwardsTable(a[t])
+		scalars[t] = scalarLimbs(b[t])
+	}
+	acc = edwardsIdentity
+	for i := 256/strausWindow - 1; i >= 0; i-- {
+		for d := 0; d < strausWindow; d++ {
+			acc.double(&acc)
+		}
+		for t := range tables {
+			w := window(&scalars[t], uint(i*strausWindow), strausWindow)
+			if w != 0 {
+				acc.add(&acc, &tables[t][w])
+			}
+		}
+	}
+	return acc
+}
+
+/*
+ctEdwardsMultiScalarMult is edwardsMultiScalarMult in constant time with respect to the
+scalars: every window adds the entry of the table read with ctLookup's method, the
+identity for a zero window, since the addition is complete.
+*/
+func ctEdwardsMultiScalarMult(a []*edwardsPoint, b []Scalar) edwardsPoint {
+	var (
+		acc, sel edwardsPoint
+	)
+	tables := make([][1 << strausWindow]edwardsPoint, len(a))
+	scalars := make([][4]uint64, len(a))
+	for t := range a {
+		tables[t] = edwardsTable(a[t])
+		scalars[t] = scalarLimbs(b[t])
+	}
+	acc = edwardsIdentity
+	for i := 256/strausWindow - 1; i >= 0; i-- {
+		for d := 0; d < strausWindow; d++ {
+			ctStep("double", d)
+			acc.double(&acc)
+		}
+		for t := range tables {
+			w := window(&scalars[t], uint(i*strausWindow), strausWindow)
+			for j := range tables[t] {
+				ctStep("read", j)
+				sel.cmov(&tables[t][j], ctEqual(w, uint64(j)))
+			}
+			ctStep("add", t)
+			acc.add(&acc, &sel)
+		}
+	}
+	return acc
+}
+
+/*
+ristretto255Group is the Group of ristretto255, the type r255.
+*/
+type ristretto255Group struct{}
+
+/*
+Ristretto255 is the group ristretto255 of RFC 9496.
+*/
+var Ristretto255 Group = ristretto255Group{}
+
+/*
+ristretto255Scalars holds the constants of the scalars of ristretto255.
+*/
+var ristretto255Scalars = newScalarField(RISTRETTOORDER)
+
+func init() {
+	RegisterGroup(Ristretto255)
+}
+
+func (ristretto255Group) Name() string {
+	return "ristretto255"
+}
+
+func (ristretto255Group) Order() *big.Int {
+	return RISTRETTOORDER
+}
+
+func (ristretto255Group) NewElement() Element {
+	return &r255{p: edwardsIdentity}
+}
+
+func (ristretto255Group) Generator() Element {
+	return &r255{p: ristrettoBase}
+}
+
+func (ristretto255Group) HashSuite() string {
+	return R255SUITE
+}
+
+/*
+HashToElement is hash_to_ristretto255 of RFC 9380: the one-way map of 64 bytes of
+expand_message_xmd with SHA-512.
+*/
+func (ristretto255Group) HashToElement(msg, dst []byte) Element {
+	uniform, _ := expandMessageXMDWith(sha512.New, msg, dst, 64)
+	return fromUniformBytes(uniform)
+}
+
+/*
+DecodeElement decodes the 32-byte encoding of an element, the identity included.
+*/
+func (ristretto255Group) DecodeElement(data []byte) (Element, error) {
+	e, err := decodeRistretto(data)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+/*
+edwardsPoints returns the points of the elements of ristretto255.
+*/
+func edwardsPoints(a []Element) []*edwardsPoint {
+	result := make([]*edwardsPoint, len(a))
+	for i := range a {
+		result[i] = &a[i].(*r255).p
+	}
+	return result
+}
+
+func (ristretto255Group) MultiScalarMult(a []Element, k []Scalar) Element {
+	return &r255{p: edwardsMultiScalarMult(edwardsPoints(a), k)}
+}
+
+func (ristretto255Group) ConstantTimeScalarMult(a Element, k Scalar) Element {
+	return &r255{p: ctEdwardsMultiScalarMult([]*edwardsPoint{&a.(*r255).p}, []Scalar{k})}
+}
+
+func (ristretto255Group) ConstantTimeScalarBaseMult(k Scalar) Element {
+	return &r255{p: ctEdwardsMultiScalarMult([]*edwardsPoint{&ristrettoBase}, []Scalar{k})}
+}
+
+func (ristretto255Group) ConstantTimeMultiScalarMult(a []Element, k []Scalar) Element {
+	return &r255{p: ctEdwardsMultiScalarMult(edwardsPoints(a), k)}
+}
+
+/*
+Normalize does nothing: the encoding needs an inverse square root whatever Z is.
+*/
+func (ristretto255Group) Normalize(a []Element) {
+}
+
+/*
+Precompute does nothing, ristretto255 has no fixed-base tables.
+*/
+func (ristretto255Group) Precompute(a Element) {
+}
+
+func (ristretto255Group) NewScalar() Scalar {
+	return newMontScalar(ristretto255Scalars)
+}
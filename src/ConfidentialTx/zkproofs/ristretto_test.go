@@ -0,0 +1,162 @@
+package zkproofs
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+/*
+Test the field arithmetic of Curve25519 against big.Int, around 0 and p.
+*/
+func TestField25519(t *testing.T) {
+	values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(19), new(big.Int).Sub(p25519, big.NewInt(1)), new(big.Int).Sub(p25519, big.NewInt(19)), new(big.Int).Rsh(p25519, 1)}
+	for i := 0; i < 8; i++ {
+		v, _ := rand.Int(rand.Reader, p25519)
+		values = append(values, v)
+	}
+	for _, x := range values {
+		var a, b, r field25519
+		a.setBig(x)
+		for _, y := range values {
+			b.setBig(y)
+			if r.add(&a, &b).big().Cmp(new(big.Int).Mod(new(big.Int).Add(x, y), p25519)) != 0 {
+				t.Errorf("Assert failure: wrong sum of %v and %v", x, y)
+			}
+			if r.sub(&a, &b).big().Cmp(new(big.Int).Mod(new(big.Int).Sub(x, y), p25519)) != 0 {
+				t.Errorf("Assert failure: wrong difference of %v and %v", x, y)
+			}
+			if r.mul(&a, &b).big().Cmp(new(big.Int).Mod(new(big.Int).Mul(x, y), p25519)) != 0 {
+				t.Errorf("Assert failure: wrong product of %v and %v", x, y)
+			}
+		}
+		expected := new(big.Int).ModInverse(x, p25519)
+		if expected == nil {
+			expected = new(big.Int)
+		}
+		if r.inv(&a).big().Cmp(expected) != 0 {
+			t.Errorf("Assert failure: wrong inverse of %v", x)
+		}
+	}
+	var x field25519
+	ok := x.setBytes([]byte{0xed, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f})
+	if ok || !x.isZero() {
+		t.Errorf("Assert failure: expected p to be a non canonical encoding of 0")
+	}
+}
+
+/*
+Test the encodings of the multiples 0.G to 15.G of RFC 9496, Appendix A.1.
+*/
+func TestRistrettoMultiples(t *testing.T) {
+	multiples := []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"e2f2ae0a6abc4e71a884a961c500515f58e30b6aa582dd8db6a65945e08d2d76",
+		"6a493210f7499cd17fecb510ae0cea23a110e8d5b901f8acadd3095c73a3b919",
+		"94741f5d5d52755ece4f23f044ee27d5d1ea1e2bd196b462166b16152a9d0259",
+		"da80862773358b466ffadfe0b3293ab3d9fd53c5ea6c955358f568322daf6a57",
+		"e882b131016b52c1d3337080187cf768423efccbb517bb495ab812c4160ff44e",
+		"f64746d3c92b13050ed8d80236a7f0007c3b3f962f5ba793d19a601ebb1df403",
+		"44f53520926ec81fbd5a387845beb7df85a96a24ece18738bdcfa6a7822a176d",
+		"903293d8f2287ebe10e2374dc1a53e0bc887e592699f02d077d5263cdd55601c",
+		"02622ace8f7303a31cafc63f8fc48fdc16e1c8c8d234b2f0d6685282a9076031",
+		"20706fd788b2720a1ed2a5dad4952b01f413bcf0e7564de8cdc816689e2db95f",
+		"bce83f8ba5dd2fa572864c24ba1810f9522bc6004afe95877ac73241cafdab42",
+		"e4549ee16b9aa03099ca208c67adafcafa4c3f3e4e5303de6026e3ca8ff84460",
+		"aa52e000df2e16f55fb1032fc33bc42742dad6bd5a8fc0be0167436c5948501f",
+		"46376b80f409b29dc2b5f6f0c52591990896e5716f41477cd30085ab7f10301e",
+		"e0c418f7c8d9c4cdd7395b93ea124f3ad99021bb681dfc3302a9d99a2e53e64e",
+	}
+	p := Ristretto255.NewElement()
+	for i, expected := range multiples {
+		k := Ristretto255.NewScalar().SetInt64(int64(i))
+		actual := []Element{
+			p,
+			Ristretto255.NewElement().ScalarBaseMult(k),
+			Ristretto255.ConstantTimeScalarBaseMult(k),
+			Ristretto255.NewElement().ScalarBaseMult(Ristretto255.NewScalar().SetBigInt(new(big.Int).Add(big.NewInt(int64(i)), RISTRETTOORDER))),
+		}
+		for _, q := range actual {
+			if hex.EncodeToString(q.Bytes()) != expected {
+				t.Errorf("Assert failure: expected %s for %d.G, actual: %x", expected, i, q.Bytes())
+			}
+		}
+		data, _ := hex.DecodeString(expected)
+		q, err := Ristretto255.DecodeElement(data)
+		if err != nil || !q.Equal(p) {
+			t.Errorf("Assert failure: expected %d.G back from %s, actual: %v", i, expected, err)
+		}
+		p = Ristretto255.NewElement().Add(p, Ristretto255.Generator())
+	}
+}
+
+/*
+Test that the invalid encodings of RFC 9496, Appendix A.2, are rejected: non canonical
+field elements, negative field elements and values that are not encodings.
+*/
+func TestRistrettoInvalidEncodings(t *testing.T) {
+	encodings := []string{
+		"00ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"f3ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"edffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"01ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"26948d35ca62e643e26a83177332e6b6afeb9d08e4268b650f1f5bbd8d81d371",
+		"4eac077a713c57b4f4397629a4145982c661f48044dd3f96427d40b147d9742f",
+		"de6a7b00deadbeef2d2e6b03b5a57b80c1eb24fef3f19ed6cbab5d1bbd8ba62a",
+	}
+	for _, s := range encodings {
+		data, _ := hex.DecodeString(s)
+		if _, err := Ristretto255.DecodeElement(data); err == nil {
+			t.Errorf("Assert failure: expected an error for %s", s)
+		}
+	}
+}
+
+/*
+Test the one-way map against the vectors of RFC 9496, Appendix A.3, which map the
+SHA-512 of the labels.
+*/
+func TestRistrettoFromUniformBytes(t *testing.T) {
+	vectors := []struct {
+		label    string
+		expected string
+	}{
+		{"Ristretto is traditionally a short shot of espresso coffee", "3066f82a1a747d45120d1740f14358531a8f04bbffe6a819f86dfe50f44a0a46"},
+		{"made with the normal amount of ground coffee but extracted with", "f26e5b6f7d362d2d2a94c5d0e7602cb4773c95a2e5c31a64f133189fa76ed61b"},
+		{"about half the amount of water in the same amount of time", "006ccd2a9e6867e6a2c5cea83d3302cc9de128dd2a9a57dd8ee7b9d7ffe02826"},
+		{"by using a finer grind.", "f8f0c87cf237953c5890aec3998169005dae3eca1fbb04548c635953c817f92a"},
+	}
+	for _, v := range vectors {
+		sum := sha512.Sum512([]byte(v.label))
+		actual := fromUniformBytes(sum[:])
+		if hex.EncodeToString(actual.Bytes()) != v.expected {
+			t.Errorf("Assert failure for %q: expected %s, actual: %x", v.label, v.expected, actual.Bytes())
+		}
+	}
+}
+
+/*
+Test that the four points of a class have the same encoding and are equal: adding a
+point of order 4 of edwards25519 does not change the element.
+*/
+func TestRistrettoClass(t *testing.T) {
+	// (sqrt(-1), 0) has order 4 on edwards25519
+	torsion := edwardsPoint{X: sqrtM1, Z: field25519One}
+	p := randomElement(Ristretto255).(*r255)
+	q := &r255{p: p.p}
+	for i := 0; i < 4; i++ {
+		if !q.Equal(p) || hex.EncodeToString(q.Bytes()) != hex.EncodeToString(p.Bytes()) {
+			t.Errorf("Assert failure: expected %s, actual: %s", p, q)
+		}
+		q.p.add(&q.p, &torsion)
+	}
+	if !(&r255{p: torsion}).IsIdentity() {
+		t.Errorf("Assert failure: expected the points of order 4 to be the identity")
+	}
+}
@@ -0,0 +1,424 @@
+/*
+This file contains the Scalar of the groups, an integer modulo the order on four 64-bit
+limbs in Montgomery form, and the vector operations that the protocols compute on
+scalars. Every operation returns a reduced value, so that no step of a proof depends on
+a caller remembering to reduce modulo the order, and unlike big.Int the arithmetic does
+not allocate.
+*/
+
+package zkproofs
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+/*
+scalarField holds the constants of the Montgomery arithmetic modulo an odd order m
+below 2^256, with R = 2^256.
+*/
+type scalarField struct {
+	order *big.Int
+	m     [4]uint64
+	// minv = -1/m mod 2^64
+	minv uint64
+	// r2 = R^2 mod m
+	r2 [4]uint64
+	// one = R mod m, the Montgomery form of 1
+	one [4]uint64
+}
+
+/*
+newScalarField computes the constants of the arithmetic modulo order, which must be odd
+and less than 2^256.
+*/
+func newScalarField(order *big.Int) *scalarField {
+	var (
+		f scalarField
+	)
+	if order.Bit(0) == 0 || order.BitLen() > 256 {
+		panic("zkproofs: the order of scalars must be odd and less than 2^256")
+	}
+	f.order = order
+	f.m = limbs(order)
+	// Newton's iteration doubles the number of correct low bits of 1/m
+	inv := uint64(1)
+	for i := 0; i < 6; i++ {
+		inv = inv * (2 - f.m[0]*inv)
+	}
+	f.minv = -inv
+	r := new(big.Int).Lsh(big.NewInt(1), 256)
+	f.one = limbs(new(big.Int).Mod(r, order))
+	f.r2 = limbs(new(big.Int).Mod(new(big.Int).Mul(r, r), order))
+	return &f
+}
+
+/*
+limbs returns the little endian limbs of x, which must be in [0, 2^256).
+*/
+func limbs(x *big.Int) [4]uint64 {
+	var (
+		buf [32]byte
+		z   [4]uint64
+	)
+	x.FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		z[i] = binary.BigEndian.Uint64(buf[32-8*(i+1):])
+	}
+	return z
+}
+
+/*
+reduce returns x + carry.2^256 - m if this value is not negative, and x otherwise. It
+is called on values less than 2m.
+*/
+func (f *scalarField) reduce(x [4]uint64, carry uint64) [4]uint64 {
+	var (
+		t [4]uint64
+		b uint64
+	)
+	t[0], b = bits.Sub64(x[0], f.m[0], 0)
+	t[1], b = bits.Sub64(x[1], f.m[1], b)
+	t[2], b = bits.Sub64(x[2], f.m[2], b)
+	t[3], b = bits.Sub64(x[3], f.m[3], b)
+	// keep x when the subtraction borrows beyond the carry
+	_, b = bits.Sub64(carry, 0, b)
+	mask := -b
+	x[0] = t[0]&^mask | x[0]&mask
+	x[1] = t[1]&^mask | x[1]&mask
+	x[2] = t[2]&^mask | x[2]&mask
+	x[3] = t[3]&^mask | x[3]&mask
+	return x
+}
+
+func (f *scalarField) add(a, b *[4]uint64) [4]uint64 {
+	var (
+		x [4]uint64
+		c uint64
+	)
+	x[0], c = bits.Add64(a[0], b[0], 0)
+	x[1], c = bits.Add64(a[1], b[1], c)
+	x[2], c = bits.Add64(a[2], b[2], c)
+	x[3], c = bits.Add64(a[3], b[3], c)
+	return f.reduce(x, c)
+}
+
+func (f *scalarField) sub(a, b *[4]uint64) [4]uint64 {
+	var (
+		x [4]uint64
+		c uint64
+	)
+	x[0], c = bits.Sub64(a[0], b[0], 0)
+	x[1], c = bits.Sub64(a[1], b[1], c)
+	x[2], c = bits.Sub64(a[2], b[2], c)
+	x[3], c = bits.Sub64(a[3], b[3], c)
+	// on borrow add m back
+	mask := -c
+	x[0], c = bits.Add64(x[0], f.m[0]&mask, 0)
+	x[1], c = bits.Add64(x[1], f.m[1]&mask, c)
+	x[2], c = bits.Add64(x[2], f.m[2]&mask, c)
+	x[3], _ = bits.Add64(x[3], f.m[3]&mask, c)
+	return x
+}
+
+/*
+mul returns a.b/R mod m, the Montgomery product, interleaving the multiplication by
+every limb of b with one step of the reduction.
+*/
+func (f *scalarField) mul(a, b *[4]uint64) [4]uint64 {
+	var (
+		t       [4]uint64
+		t4, t5  uint64
+		c, k, x uint64
+	)
+	for i := 0; i < 4; i++ {
+		// t = t + a.b[i]
+		c = 0
+		for j := 0; j < 4; j++ {
+			c, t[j] = mac(a[j], b[i], t[j], c)
+		}
+		t4, c = bits.Add64(t4, c, 0)
+		t5 = c
+		// t = (t + k.m) / 2^64, with k such that the low limb vanishes
+		k = t[0] * f.minv
+		c, _ = mac(k, f.m[0], t[0], 0)
+		for j := 1; j < 4; j++ {
+			c, t[j-1] = mac(k, f.m[j], t[j], c)
+		}
+		t[3], x = bits.Add64(t4, c, 0)
+		t4 = t5 + x
+	}
+	return f.reduce(t, t4)
+}
+
+func (f *scalarField) toMont(x *big.Int) [4]uint64 {
+	v := limbs(new(big.Int).Mod(x, f.order))
+	return f.mul(&v, &f.r2)
+}
+
+func (f *scalarField) fromMont(v *[4]uint64) *big.Int {
+	var (
+		buf [32]byte
+	)
+	one := [4]uint64{1, 0, 0, 0}
+	x := f.mul(v, &one)
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(buf[32-8*(i+1):], x[i])
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+/*
+montScalar is the Scalar of the groups whose order is less than 2^256, stored in
+Montgomery form v = s.R mod m.
+*/
+type montScalar struct {
+	v [4]uint64
+	f *scalarField
+}
+
+func newMontScalar(f *scalarField) *montScalar {
+	return &montScalar{f: f}
+}
+
+func (s *montScalar) Add(a, b Scalar) Scalar {
+	s.v = s.f.add(&a.(*montScalar).v, &b.(*montScalar).v)
+	return s
+}
+
+func (s *montScalar) Sub(a, b Scalar) Scalar {
+	s.v = s.f.sub(&a.(*montScalar).v, &b.(*montScalar).v)
+	return s
+}
+
+func (s *montScalar) Mul(a, b Scalar) Scalar {
+	s.v = s.f.mul(&a.(*montScalar).v, &b.(*montScalar).v)
+	return s
+}
+
+func (s *montScalar) Neg(a Scalar) Scalar {
+	var (
+		zero [4]uint64
+	)
+	s.v = s.f.sub(&zero, &a.(*montScalar).v)
+	return s
+}
+
+/*
+Inverse computes a^(m-2), by Fermat's little theorem since the order is prime. The
+exponent is public, and a = 0 gives 0.
+*/
+func (s *montScalar) Inverse(a Scalar) Scalar {
+	e := new(big.Int).Sub(s.f.order, big.NewInt(2))
+	x := a.(*montScalar).v
+	r := s.f.one
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = s.f.mul(&r, &r)
+		if e.Bit(i) == 1 {
+			r = s.f.mul(&r, &x)
+		}
+	}
+	s.v = r
+	return s
+}
+
+func (s *montScalar) Set(a Scalar) Scalar {
+	s.v = a.(*montScalar).v
+	return s
+}
+
+func (s *montScalar) SetInt64(k int64) Scalar {
+	if k >= 0 {
+		v := [4]uint64{uint64(k), 0, 0, 0}
+		s.v = s.f.mul(&v, &s.f.r2)
+		return s
+	}
+	return s.SetBigInt(big.NewInt(k))
+}
+
+func (s *montScalar) SetBigInt(k *big.Int) Scalar {
+	s.v = s.f.toMont(k)
+	return s
+}
+
+func (s *montScalar) SetRandom() Scalar {
+	k, _ := rand.Int(rand.Reader, s.f.order)
+	return s.SetBigInt(k)
+}
+
+func (s *montScalar) SetBytes(data []byte) (Scalar, error) {
+	if len(data) != (s.f.order.BitLen()+7)/8 {
+		return nil, errors.New("invalid scalar encoding")
+	}
+	k := new(big.Int).SetBytes(data)
+	if k.Cmp(s.f.order) >= 0 {
+		return nil, errors.New("scalar is not reduced")
+	}
+	return s.SetBigInt(k), nil
+}
+
+func (s *montScalar) BigInt() *big.Int {
+	return s.f.fromMont(&s.v)
+}
+
+func (s *montScalar) Equal(b Scalar) bool {
+	t, ok := b.(*montScalar)
+	return ok && s.f.order.Cmp(t.f.order) == 0 && s.v == t.v
+}
+
+func (s *montScalar) IsZero() bool {
+	return s.v == [4]uint64{}
+}
+
+func (s *montScalar) Bytes() []byte {
+	return s.BigInt().FillBytes(make([]byte, (s.f.order.BitLen()+7)/8))
+}
+
+/*
+scalarLimbs returns the scalar k as little endian limbs of its value in [0, order). It
+converts k out of the Montgomery form with a Montgomery multiplication by 1, so unlike
+BigInt it does not allocate and its time does not depend on k.
+*/
+func scalarLimbs(k Scalar) [4]uint64 {
+	s := k.(*montScalar)
+	one := [4]uint64{1, 0, 0, 0}
+	return s.f.mul(&s.v, &one)
+}
+
+/*
+sameScalarGroup returns true if and only if every scalar is not nil and is a scalar of
+group, like sameGroup for elements.
+*/
+func sameScalarGroup(group Group, k ...Scalar) bool {
+	f := group.NewScalar().(*montScalar).f
+	for _, s := range k {
+		m, ok := s.(*montScalar)
+		if !ok || m == nil || m.f != f {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+groupOfScalar returns the registered group of the scalar k, which messages that only
+hold scalars are tagged with.
+*/
+func groupOfScalar(k Scalar) (Group, error) {
+	for _, group := range Groups() {
+		if sameScalarGroup(group, k) {
+			return group, nil
+		}
+	}
+	return nil, errors.New("scalar does not belong to a registered group")
+}
+
+/*
+BatchInvert sets every scalar of s, which belong to group, to its inverse with a single
+inversion, by Montgomery's trick: the inverse of the product of all the scalars is
+multiplied back by the prefix products. Zeros are left unchanged, like Inverse does.
+*/
+func BatchInvert(group Group, s []Scalar) {
+	var (
+		i int
+	)
+	if len(s) == 0 {
+		return
+	}
+	// prefix[i] is the product of the non zero scalars before s[i]
+	prefix := make([]Scalar, len(s))
+	acc := group.NewScalar().SetInt64(1)
+	i = 0
+	for i < len(s) {
+		prefix[i] = group.NewScalar().Set(acc)
+		if !s[i].IsZero() {
+			acc.Mul(acc, s[i])
+		}
+		i = i + 1
+	}
+	acc.Inverse(acc)
+	i = len(s) - 1
+	for i >= 0 {
+		if !s[i].IsZero() {
+			// acc = 1/(s[0]...s[i]), so prefix[i].acc = 1/s[i]
+			inv := group.NewScalar().Mul(prefix[i], acc)
+			acc.Mul(acc, s[i])
+			s[i].Set(inv)
+		}
+		i = i - 1
+	}
+}
+
+/*
+bigInts returns the scalars of a as integers in [0, order).
+*/
+func bigInts(a []Scalar) []*big.Int {
+	var (
+		i      int
+		result []*big.Int
+	)
+	result = make([]*big.Int, len(a))
+	i = 0
+	for i < len(a) {
+		result[i] = a[i].BigInt()
+		i = i + 1
+	}
+	return result
+}
+
+/*
+scalarPowers returns the vector 1, x, x^2, ..., x^(n-1).
+*/
+func scalarPowers(group Group, x Scalar, n int64) []Scalar {
+	var (
+		i      int64
+		result []Scalar
+	)
+	result = make([]Scalar, n)
+	current := group.NewScalar().SetInt64(1)
+	i = 0
+	for i < n {
+		result[i] = group.NewScalar().Set(current)
+		current.Mul(current, x)
+		i = i + 1
+	}
+	return result
+}
+
+/*
+innerProduct returns <a, b>. The vectors must have the same length.
+*/
+func innerProduct(group Group, a, b []Scalar) Scalar {
+	var (
+		i int
+	)
+	result := group.NewScalar()
+	t := group.NewScalar()
+	i = 0
+	for i < len(a) {
+		result.Add(result, t.Mul(a[i], b[i]))
+		i = i + 1
+	}
+	return result
+}
+
+/*
+inverses returns the inverses of the scalars of a, computed with BatchInvert.
+*/
+func inverses(group Group, a []Scalar) []Scalar {
+	var (
+		i      int
+		result []Scalar
+	)
+	result = make([]Scalar, len(a))
+	i = 0
+	for i < len(a) {
+		result[i] = group.NewScalar().Set(a[i])
+		i = i + 1
+	}
+	BatchInvert(group, result)
+	return result
+}
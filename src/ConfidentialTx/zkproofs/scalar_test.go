@@ -0,0 +1,162 @@
+package zkproofs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+/*
+Test the Montgomery arithmetic around 0 and the order, where the final reductions of
+the sums and products are needed.
+*/
+func TestScalarEdgeCases(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		order := group.Order()
+		values := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), new(big.Int).Sub(order, big.NewInt(1)),
+			new(big.Int).Sub(order, big.NewInt(2)), new(big.Int).Rsh(order, 1), new(big.Int).Lsh(big.NewInt(1), 255)}
+		for _, x := range values {
+			a := group.NewScalar().SetBigInt(x)
+			for _, y := range values {
+				b := group.NewScalar().SetBigInt(y)
+				if group.NewScalar().Add(a, b).BigInt().Cmp(Mod(Add(x, y), order)) != 0 {
+					t.Errorf("Assert failure: wrong sum of %v and %v", x, y)
+				}
+				if group.NewScalar().Sub(a, b).BigInt().Cmp(Mod(Sub(x, y), order)) != 0 {
+					t.Errorf("Assert failure: wrong difference of %v and %v", x, y)
+				}
+				if group.NewScalar().Mul(a, b).BigInt().Cmp(Mod(Multiply(x, y), order)) != 0 {
+					t.Errorf("Assert failure: wrong product of %v and %v", x, y)
+				}
+			}
+		}
+		for _, k := range []int64{0, 1, 2, -1, -2} {
+			if group.NewScalar().SetInt64(k).BigInt().Cmp(Mod(big.NewInt(k), order)) != 0 {
+				t.Errorf("Assert failure: wrong scalar for %d", k)
+			}
+		}
+	})
+}
+
+/*
+Test that BatchInvert gives the inverses of Inverse, and leaves the zeros unchanged.
+*/
+func TestBatchInvert(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		s := make([]Scalar, 9)
+		for i := range s {
+			s[i] = group.NewScalar().SetRandom()
+		}
+		s[0] = group.NewScalar()
+		s[4] = group.NewScalar()
+		s[5] = group.NewScalar().SetInt64(1)
+		expected := make([]Scalar, len(s))
+		for i := range s {
+			expected[i] = group.NewScalar().Inverse(s[i])
+		}
+		BatchInvert(group, s)
+		for i := range s {
+			if !s[i].Equal(expected[i]) {
+				t.Errorf("Assert failure for %d: expected %s, actual: %s", i, expected[i].BigInt(), s[i].BigInt())
+			}
+		}
+		BatchInvert(group, nil)
+	})
+}
+
+/*
+Test that the scalars of the proofs are reduced even when the blinding factor is given
+as an integer that is not, and that the proof is valid.
+*/
+func TestProofScalarsReduced(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var zkrp Bp
+		if err := zkrp.SetupWithGroup(group, 0, 4294967295, 1); err != nil {
+			t.Fatal(err)
+		}
+		order := group.Order()
+		secret, _ := rand.Int(rand.Reader, big.NewInt(4294967296))
+		gamma := group.NewScalar().SetBigInt(new(big.Int).Add(order, big.NewInt(7)))
+		V, _ := CommitG1(group.NewScalar().SetBigInt(secret), gamma, zkrp.H)
+		blinds, _ := zkrp.randomBlinds()
+		blinds.alpha.Neg(blinds.alpha)
+		proof, err := zkrp.proveSecret(NewTranscript(DOMAIN), V, secret, gamma, blinds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range []Scalar{proof.Taux, proof.Mu, proof.Tprime, proof.Proofip.A, proof.Proofip.B} {
+			if k := s.BigInt(); k.Sign() < 0 || k.Cmp(order) >= 0 {
+				t.Errorf("Assert failure: expected a reduced scalar, actual: %s", k)
+			}
+		}
+		if ok, _ := zkrp.Verify(proof); !ok {
+			t.Errorf("Assert failure: expected the proof to be valid")
+		}
+	})
+}
+
+/*
+Test that the scalars of serialized proofs only have their canonical encoding: values
+that are not reduced, negative, in base 10 or in uppercase hex are rejected.
+*/
+func TestProofScalarsCanonical(t *testing.T) {
+	forEachGroup(t, func(t *testing.T, group Group) {
+		var (
+			zkrp       Bp
+			bp         proofBP
+			plus       proofBPPlus
+			aggregated proofAggBP
+		)
+		if err := zkrp.SetupWithGroup(group, 0, 255, 2); err != nil {
+			t.Fatal(err)
+		}
+		_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(42))
+		_, proofPlus, _ := zkrp.GenerateProofPlus(new(big.Int).SetInt64(42))
+		_, proofAgg, _ := zkrp.GenerateAggregateProof([]*big.Int{new(big.Int).SetInt64(42), new(big.Int).SetInt64(255)})
+		cases := []struct {
+			name  string
+			k     Scalar
+			proof interface{}
+			out   interface{}
+		}{
+			{"Bulletproofs", proof.Taux, &proof, &bp},
+			{"Bulletproofs+", proofPlus.R1, &proofPlus, &plus},
+			{"aggregated", proofAgg.Mu, &proofAgg, &aggregated},
+		}
+		for _, c := range cases {
+			data, _ := json.Marshal(c.proof)
+			if err := json.Unmarshal(data, c.out); err != nil {
+				t.Fatalf("Unexpected error for %s: %s", c.name, err)
+			}
+			encoded := scalarString(c.k)
+			if len(encoded) != 2*len(group.NewScalar().Bytes()) || !strings.Contains(string(data), "\""+encoded+"\"") {
+				t.Fatalf("Assert failure for %s: expected the scalar %s in %s", c.name, encoded, data)
+			}
+			variants := []string{"-" + encoded, strings.ToUpper(encoded), c.k.BigInt().String(), encoded[2:]}
+			if unreduced := new(big.Int).Add(c.k.BigInt(), group.Order()); unreduced.BitLen() <= 8*len(encoded)/2 {
+				variants = append(variants, hex.EncodeToString(unreduced.FillBytes(make([]byte, len(encoded)/2))))
+			}
+			for _, v := range variants {
+				if v == encoded {
+					continue
+				}
+				tampered := strings.Replace(string(data), "\""+encoded+"\"", "\""+v+"\"", 1)
+				if err := json.Unmarshal([]byte(tampered), c.out); err == nil {
+					t.Errorf("Assert failure for %s: expected error for the scalar %s", c.name, v)
+				}
+			}
+		}
+		if ok, _ := zkrp.Verify(bp); !ok {
+			t.Errorf("Assert failure: expected the decoded Bulletproofs proof to be valid")
+		}
+		if ok, _ := zkrp.VerifyPlus(plus); !ok {
+			t.Errorf("Assert failure: expected the decoded Bulletproofs+ proof to be valid")
+		}
+		if ok, _ := zkrp.VerifyAggregate(aggregated); !ok {
+			t.Errorf("Assert failure: expected the decoded aggregated proof to be valid")
+		}
+	})
+}
@@ -0,0 +1,131 @@
+/*
+This file lets callers choose the range proof scheme. Bulletproofs and Bulletproofs+
+share the parameters Bp, so the same verifier checks proofs of both schemes, and every
+serialized proof is tagged with the scheme that made it. Aggregated Bulletproofs have a
+tag of their own, since they prove several commitments at once.
+*/
+
+package zkproofs
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+/*
+Scheme identifies a range proof scheme.
+*/
+type Scheme string
+
+const (
+	SchemeBulletproofs          Scheme = "bulletproofs"
+	SchemeBulletproofsPlus      Scheme = "bulletproofs+"
+	SchemeBulletproofsAggregate Scheme = "bulletproofs-aggregate"
+)
+
+/*
+RangeProof is a range proof of any scheme, either *proofBP or *proofBPPlus.
+*/
+type RangeProof interface {
+	// Scheme returns the scheme that made the proof.
+	Scheme() Scheme
+	// Commitment returns the commitment V to the secret.
+	Commitment() Element
+}
+
+func (p *proofBP) Scheme() Scheme {
+	return SchemeBulletproofs
+}
+
+func (p *proofBP) Commitment() Element {
+	return p.V
+}
+
+func (p *proofBPPlus) Scheme() Scheme {
+	return SchemeBulletproofsPlus
+}
+
+func (p *proofBPPlus) Commitment() Element {
+	return p.V
+}
+
+/*
+GenerateRangeProof computes the ZK proof that the secret belongs to [A, B] with the
+given scheme. It returns the blinding factor gamma of the commitment and the proof.
+*/
+func (zkrp *Bp) GenerateRangeProof(scheme Scheme, secret *big.Int) (*big.Int, RangeProof, error) {
+	return zkrp.GenerateRangeProofWithTranscript(NewTranscript(DOMAIN), scheme, secret)
+}
+
+/*
+GenerateRangeProofWithTranscript is GenerateRangeProof with the Fiat-Shamir challenges
+bound to the given transcript.
+*/
+func (zkrp *Bp) GenerateRangeProofWithTranscript(transcript *Transcript, scheme Scheme, secret *big.Int) (*big.Int, RangeProof, error) {
+	switch scheme {
+	case SchemeBulletproofs:
+		gamma, proof, err := zkrp.GenerateProofWithTranscript(transcript, secret)
+		return gamma, &proof, err
+	case SchemeBulletproofsPlus:
+		gamma, proof, err := zkrp.GenerateProofPlusWithTranscript(transcript, secret)
+		return gamma, &proof, err
+	}
+	return nil, nil, errors.New("unknown range proof scheme")
+}
+
+/*
+VerifyRangeProof returns true if and only if the proof is valid for its scheme.
+*/
+func (zkrp *Bp) VerifyRangeProof(proof RangeProof) (bool, error) {
+	return zkrp.VerifyRangeProofWithTranscript(NewTranscript(DOMAIN), proof)
+}
+
+/*
+VerifyRangeProofWithTranscript is VerifyRangeProof with the Fiat-Shamir challenges
+bound to the given transcript, which must be in the same state as the prover's one.
+*/
+func (zkrp *Bp) VerifyRangeProofWithTranscript(transcript *Transcript, proof RangeProof) (bool, error) {
+	switch p := proof.(type) {
+	case *proofBP:
+		return zkrp.VerifyWithTranscript(transcript, *p)
+	case *proofBPPlus:
+		return zkrp.VerifyPlusWithTranscript(transcript, *p)
+	}
+	return false, errors.New("unknown range proof scheme")
+}
+
+/*
+unmarshalRangeProof decodes a proof of the scheme given by its Scheme field. A proof
+without a Scheme is rejected, since the scheme of its fields cannot be told apart, and
+so are aggregated proofs, which are not the proof of a single commitment.
+*/
+func unmarshalRangeProof(data []byte) (RangeProof, error) {
+	var (
+		tag struct {
+			Scheme Scheme `json:"Scheme"`
+		}
+	)
+	if err := json.Unmarshal(data, &tag); err != nil {
+		return nil, err
+	}
+	switch tag.Scheme {
+	case "":
+		return nil, errors.New("range proof has no scheme")
+	case SchemeBulletproofsAggregate:
+		return nil, errors.New("aggregated proofs must be verified with VerifyAggregate")
+	case SchemeBulletproofs:
+		var proof proofBP
+		if err := json.Unmarshal(data, &proof); err != nil {
+			return nil, err
+		}
+		return &proof, nil
+	case SchemeBulletproofsPlus:
+		var proof proofBPPlus
+		if err := json.Unmarshal(data, &proof); err != nil {
+			return nil, err
+		}
+		return &proof, nil
+	}
+	return nil, errors.New("unknown range proof scheme")
+}
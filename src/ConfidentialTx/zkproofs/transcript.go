@@ -0,0 +1,137 @@
+/*
+This file contains a proof transcript in the style of Merlin (https://merlin.cool),
+used to compute every Fiat-Shamir challenge of the package. The prover and the verifier
+append the same labeled messages in the same order, so each challenge depends on the
+whole protocol run so far, including the context given to NewTranscript.
+Merlin is built on STROBE, here the state is a SHA-256 hash chain instead.
+*/
+
+package zkproofs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+)
+
+const (
+	transcriptAppend    = 1
+	transcriptChallenge = 2
+	transcriptRatchet   = 3
+	transcriptOutput    = 4
+)
+
+/*
+Transcript of a protocol run. A Transcript must not be reused for another proof.
+*/
+type Transcript struct {
+	state [sha256.Size]byte
+}
+
+/*
+NewTranscript returns a transcript bound to the given domain separator, e.g. a chain id
+or a transaction hash, so that a proof made in one context is rejected in any other.
+*/
+func NewTranscript(label string) *Transcript {
+	t := &Transcript{state: sha256.Sum256([]byte("zkproofs transcript v1"))}
+	t.AppendMessage("dom-sep", []byte(label))
+	return t
+}
+
+/*
+Clone returns an independent copy of the transcript.
+*/
+func (t *Transcript) Clone() *Transcript {
+	c := *t
+	return &c
+}
+
+/*
+frame writes the operation, the current state and each length prefixed input.
+*/
+func (t *Transcript) frame(op byte, inputs ...[]byte) []byte {
+	var (
+		length [4]byte
+	)
+	digest := sha256.New()
+	digest.Write([]byte{op})
+	digest.Write(t.state[:])
+	for _, input := range inputs {
+		binary.LittleEndian.PutUint32(length[:], uint32(len(input)))
+		digest.Write(length[:])
+		digest.Write(input)
+	}
+	return digest.Sum(nil)
+}
+
+/*
+AppendMessage appends a labeled message to the transcript.
+*/
+func (t *Transcript) AppendMessage(label string, message []byte) {
+	copy(t.state[:], t.frame(transcriptAppend, []byte(label), message))
+}
+
+/*
+AppendUint64 appends a labeled integer to the transcript.
+*/
+func (t *Transcript) AppendUint64(label string, n uint64) {
+	var (
+		buf [8]byte
+	)
+	binary.LittleEndian.PutUint64(buf[:], n)
+	t.AppendMessage(label, buf[:])
+}
+
+/*
+AppendPoint appends a labeled element of a group, in its canonical encoding.
+*/
+func (t *Transcript) AppendPoint(label string, p Element) {
+	t.AppendMessage(label, p.Bytes())
+}
+
+/*
+AppendScalar appends a labeled scalar of a group, encoded in 32 bytes.
+*/
+func (t *Transcript) AppendScalar(label string, s Scalar) {
+	buf := make([]byte, 32)
+	b := s.Bytes()
+	copy(buf[32-len(b):], b)
+	t.AppendMessage(label, buf)
+}
+
+/*
+ChallengeBytes returns n pseudo-random bytes that depend on every message appended
+so far. The challenge itself is absorbed into the transcript.
+*/
+func (t *Transcript) ChallengeBytes(label string, n int) []byte {
+	var (
+		j       uint32
+		size    [4]byte
+		counter [4]byte
+		result  []byte
+	)
+	binary.LittleEndian.PutUint32(size[:], uint32(n))
+	seed := t.frame(transcriptChallenge, []byte(label), size[:])
+	result = make([]byte, 0, n+sha256.Size)
+	j = 0
+	for len(result) < n {
+		binary.LittleEndian.PutUint32(counter[:], j)
+		digest := sha256.New()
+		digest.Write([]byte{transcriptOutput})
+		digest.Write(seed)
+		digest.Write(counter[:])
+		result = digest.Sum(result)
+		j = j + 1
+	}
+	copy(t.state[:], t.frame(transcriptRatchet, seed))
+	return result[:n]
+}
+
+/*
+ChallengeScalar returns a challenge among the scalars of group. It is computed from 64
+bytes, so that the reduction modulo the order is statistically close to uniform.
+*/
+func (t *Transcript) ChallengeScalar(label string, group Group) Scalar {
+	b := t.ChallengeBytes(label, 64)
+	return group.NewScalar().SetBigInt(new(big.Int).SetBytes(b))
+}
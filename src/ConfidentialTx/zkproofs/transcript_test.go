@@ -0,0 +1,142 @@
+package zkproofs
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+/*
+Test that the same messages produce the same challenges.
+*/
+func TestTranscriptDeterministic(t *testing.T) {
+	t1 := NewTranscript("test")
+	t2 := NewTranscript("test")
+	t1.AppendPoint("G", newP256(GX, GY))
+	t2.AppendPoint("G", newP256(GX, GY))
+	c1 := t1.ChallengeBytes("c", 100)
+	c2 := t2.ChallengeBytes("c", 100)
+	if len(c1) != 100 || !bytes.Equal(c1, c2) {
+		t.Errorf("Assert failure: expected equal challenges of 100 bytes")
+	}
+	// the challenge is absorbed, so the next one is different
+	c1 = t1.ChallengeBytes("c", 100)
+	if bytes.Equal(c1, c2) {
+		t.Errorf("Assert failure: expected a different challenge")
+	}
+}
+
+/*
+Test that the challenges depend on the domain separator, labels, messages and order.
+*/
+func TestTranscriptSeparation(t *testing.T) {
+	one := Secp256k1.NewScalar().SetInt64(1)
+	two := Secp256k1.NewScalar().SetInt64(2)
+	transcripts := []func() *Transcript{
+		func() *Transcript {
+			t := NewTranscript("test")
+			t.AppendScalar("a", one)
+			t.AppendScalar("b", two)
+			return t
+		},
+		func() *Transcript {
+			t := NewTranscript("other")
+			t.AppendScalar("a", one)
+			t.AppendScalar("b", two)
+			return t
+		},
+		func() *Transcript {
+			t := NewTranscript("test")
+			t.AppendScalar("b", one)
+			t.AppendScalar("a", two)
+			return t
+		},
+		func() *Transcript {
+			t := NewTranscript("test")
+			t.AppendScalar("a", two)
+			t.AppendScalar("b", one)
+			return t
+		},
+		func() *Transcript {
+			t := NewTranscript("test")
+			t.AppendMessage("a", []byte{1, 2})
+			t.AppendMessage("b", nil)
+			return t
+		},
+		func() *Transcript {
+			t := NewTranscript("test")
+			t.AppendMessage("a", []byte{1})
+			t.AppendMessage("b", []byte{2})
+			return t
+		},
+	}
+	seen := make(map[string]int)
+	for i, f := range transcripts {
+		c := string(f().ChallengeScalar("c", Secp256k1).Bytes())
+		if j, ok := seen[c]; ok {
+			t.Errorf("Assert failure: transcripts %d and %d give the same challenge", j, i)
+		}
+		seen[c] = i
+	}
+}
+
+/*
+Test that a clone evolves independently of the original transcript.
+*/
+func TestTranscriptClone(t *testing.T) {
+	t1 := NewTranscript("test")
+	t1.AppendUint64("n", 64)
+	t2 := t1.Clone()
+	c1 := t1.ChallengeScalar("c", Secp256k1)
+	t2.AppendUint64("m", 1)
+	c2 := t2.ChallengeScalar("c", Secp256k1)
+	c3 := t1.Clone().ChallengeScalar("c", Secp256k1)
+	if c1.Equal(c2) || c1.Equal(c3) {
+		t.Errorf("Assert failure: expected different challenges")
+	}
+}
+
+/*
+Test that a range proof made for one domain is rejected in another one.
+*/
+func TestBulletproofsDomainSeparation(t *testing.T) {
+	var (
+		zkrp Bp
+	)
+	zkrp.Setup(0, 255)
+	_, proof, _ := zkrp.GenerateProofWithTranscript(NewTranscript("chain 1"), new(big.Int).SetInt64(42))
+	ok, _ := zkrp.VerifyWithTranscript(NewTranscript("chain 1"), proof)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+	ok, _ = zkrp.VerifyWithTranscript(NewTranscript("chain 2"), proof)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+	ok, _ = zkrp.Verify(proof)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+	ok, failed, _ := zkrp.VerifyBatchWithTranscripts([]*Transcript{NewTranscript("chain 2")}, []proofBP{proof})
+	if ok != false || len(failed) != 1 {
+		t.Errorf("Assert failure: expected false, actual: %t %v", ok, failed)
+	}
+}
+
+/*
+Test that a range proof is bound to the interval it was made for.
+*/
+func TestBulletproofsIntervalBinding(t *testing.T) {
+	var (
+		zkrp, other Bp
+	)
+	zkrp.Setup(10, 265)
+	other.Setup(0, 255)
+	_, proof, _ := zkrp.GenerateProof(new(big.Int).SetInt64(42))
+	// V.g^-10 commits to 32 in both cases, but the challenges differ
+	proof.V = new(p256).Add(proof.V, new(p256).ScalarBaseMult(Secp256k1.NewScalar().SetInt64(-10)))
+	ok, _ := other.Verify(proof)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+}
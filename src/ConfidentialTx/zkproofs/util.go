@@ -17,11 +17,11 @@
 package zkproofs
 
 import (
-	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"math/big"
 
-	"../byteconversion"
 	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/google"
 	// "../crypto/bn256"
 )
@@ -35,18 +35,33 @@ var (
 
 /*
 Decompose receives as input a bigint x and outputs an array of integers such that
-x = sum(xi.u^i), i.e. it returns the decomposition of x into base u.
+x = sum(xi.u^i), i.e. it returns the decomposition of x into base u. The digits are
+computed on a fixed number of limbs, with as many steps for every value of x, since
+x is the secret of the range proofs.
 */
 func Decompose(x *big.Int, u int64, l int64) ([]int64, error) {
 	var (
 		result []int64
 		i      int64
 	)
+	if u < 2 || l < 0 {
+		return nil, errors.New("invalid base or number of digits")
+	}
+	// x mod u^l has the same l digits as x and fits in the limbs of u^l
+	ul := new(big.Int).Exp(big.NewInt(u), big.NewInt(l), nil)
+	if x.Sign() < 0 || x.BitLen() > ul.BitLen() {
+		x = Mod(x, ul)
+	}
+	limbs := make([]uint64, (ul.BitLen()+63)/64)
+	buf := make([]byte, 8*len(limbs))
+	x.FillBytes(buf)
+	for j := range limbs {
+		limbs[j] = binary.BigEndian.Uint64(buf[len(buf)-8*(j+1):])
+	}
 	result = make([]int64, l, l)
 	i = 0
 	for i < l {
-		result[i] = Mod(x, new(big.Int).SetInt64(u)).Int64()
-		x = new(big.Int).Div(x, new(big.Int).SetInt64(u))
+		result[i] = int64(ctDivSmall(limbs, uint64(u)))
 		i = i + 1
 	}
 	return result, nil
@@ -54,59 +69,36 @@ func Decompose(x *big.Int, u int64, l int64) ([]int64, error) {
 
 /*
 Commit method corresponds to the Pedersen commitment scheme. Namely, given input
-message x, and randomness r, it outputs g^x.h^r.
+message x, and randomness r, it outputs g^x.h^r. Both exponents are applied together,
+with the same sequence of operations for all of them, so that no partial result depends
+on x alone. The arithmetic of bn256 is not constant time.
 */
 func Commit(x, r *big.Int, h *bn256.G2) (*bn256.G2, error) {
 	var (
 		C *bn256.G2
 	)
-	C = new(bn256.G2).ScalarBaseMult(x)
-	C.Add(C, new(bn256.G2).ScalarMult(h, r))
+	C = regularMultiScalarMultG2([]*bn256.G2{G2, h}, []*big.Int{x, r})
 	return C, nil
 }
 
 /*
 CommitG1 method corresponds to the Pedersen commitment scheme. Namely, given input
-message x, and randomness r, it outputs g^x.h^r.
+message x, and randomness r, scalars of the group of h, it outputs g^x.h^r. The scalar
+multiplications are constant-time.
 */
-func CommitG1(x, r *big.Int, h *p256) (*p256, error) {
+func CommitG1(x, r Scalar, h Element) (Element, error) {
 	var (
-		C *p256
+		C Element
 	)
-	C = new(p256).ScalarBaseMult(x)
-	Hr := new(p256).ScalarMult(h, r)
+	group := h.Group()
+	C = group.ConstantTimeScalarBaseMult(x)
+	Hr := group.ConstantTimeScalarMult(h, r)
 	C.Add(C, Hr)
 	return C, nil
 }
 
-func Mult(a *p256, n *big.Int) *p256 {
-	return new(p256).ScalarMult(a, n)
-}
-
-/*
-HashSet is responsible for the computing a Zp element given elements from GT and G2.
-*/
-func HashSet(a *bn256.GT, D *bn256.G2) (*big.Int, error) {
-	digest := sha256.New()
-	digest.Write([]byte(a.String()))
-	digest.Write([]byte(D.String()))
-	output := digest.Sum(nil)
-	tmp := output[0:len(output)]
-	return byteconversion.FromByteArray(tmp)
-}
-
-/*
-Hash is responsible for the computing a Zp element given elements from GT and G2.
-*/
-func Hash(a []*bn256.GT, D *bn256.G2) (*big.Int, error) {
-	digest := sha256.New()
-	for i := range a {
-		digest.Write([]byte(a[i].String()))
-	}
-	digest.Write([]byte(D.String()))
-	output := digest.Sum(nil)
-	tmp := output[0:len(output)]
-	return byteconversion.FromByteArray(tmp)
+func Mult(a Element, n Scalar) Element {
+	return a.Group().NewElement().ScalarMult(a, n)
 }
 
 /*
@@ -119,31 +111,47 @@ func GetBigInt(value string) *big.Int {
 }
 
 /*
-Get common base
+Get common base. The generators are derived on the first call only, later calls
+share them.
 */
 func GetZkrp() *Bp {
 	var zkrp Bp
-	zkrp.Setup(0, 4294967296)
+	zkrp.Setup(0, 4294967295)
 	return &zkrp
 }
 
 /*
-Get zkrp verifier
+Get zkrp verifier. It only depends on the public parameters, everything else is
+recomputed from the proof.
 */
-func GetVerifier(t *big.Int, h []*p256, p *p256) *Bp {
-	zkrp := GetZkrp()
-	zkrp.Zkip.Cc = t
-	zkrp.Zkip.Hh = h
-	zkrp.Zkip.P = p
-	return zkrp
+func GetVerifier() *Bp {
+	return GetZkrp()
 }
 
 /*
-Pedersen Commitment verification, check input_sum == output_sum ?
+getVerifierOf returns the verifier of GetVerifier over the given group.
 */
-func VerifyPedersenCommitment(input, output []*PedersenCommitment, blindDiff *big.Int) bool {
-	inputCommitment := new(PedersenCommitment)
-	outputCommitment := new(PedersenCommitment)
+func getVerifierOf(group Group) *Bp {
+	var zkrp Bp
+	zkrp.SetupWithGroup(group, 0, 4294967295, 1)
+	return &zkrp
+}
+
+/*
+Pedersen Commitment verification, check input_sum == output_sum ? The commitments
+must all belong to the same group, secp256k1 if there are none.
+*/
+func VerifyPedersenCommitment(input, output []PedersenCommitment, blindDiff *big.Int) bool {
+	group := Secp256k1
+	all := append(append([]PedersenCommitment{}, input...), output...)
+	if len(all) > 0 && all[0] != nil {
+		group = all[0].Group()
+	}
+	if !sameGroup(group, all...) {
+		return false
+	}
+	inputCommitment := group.NewElement()
+	outputCommitment := group.NewElement()
 	for _, p := range input {
 		inputCommitment = inputCommitment.Add(inputCommitment, p)
 	}
@@ -153,31 +161,41 @@ func VerifyPedersenCommitment(input, output []*PedersenCommitment, blindDiff *bi
 	// 计算佩德森承诺输入输出之差
 	diffCommitment := inputCommitment.Add(inputCommitment, outputCommitment.Neg(outputCommitment))
 	// 根据盲因子计算理论值
-	H, _ := MapToGroup(SEEDH)
-	checkCommitment := Mult(H, blindDiff)
+	H := seedPoint(group, SEEDH)
+	checkCommitment := Mult(H, group.NewScalar().SetBigInt(blindDiff))
 	// 比较是否相等
-	return checkCommitment.X.Cmp(diffCommitment.X) == 0 && checkCommitment.Y.Cmp(diffCommitment.Y) == 0
-
-}
+	return checkCommitment.Equal(diffCommitment)
 
-type ProofData struct {
-	Proof *proofBP
-	T     *big.Int
-	Hh    []*p256
-	P     *p256
 }
 
-func DumpProof(t *big.Int, h []*p256, p *p256, proof *proofBP) ([]byte, error) {
-	zkrproof := &ProofData{proof, t, h, p}
-	data, err := json.Marshal(zkrproof)
-	return data, err
+/*
+DumpProof serializes the proof of any scheme. It only contains the scheme, the
+commitment V and what the protocol sends to the verifier.
+*/
+func DumpProof(proof RangeProof) ([]byte, error) {
+	return json.Marshal(proof)
 }
 
+/*
+LoadProof deserializes a proof and returns it together with a verifier.
+*/
 func LoadProof(data []byte) (*Bp, *proofBP, error) {
-	var p ProofData
+	var p proofBP
 	err := json.Unmarshal(data, &p)
 	if err != nil {
 		return nil, nil, err
 	}
-	return GetVerifier(p.T, p.Hh, p.P), p.Proof, nil
+	return getVerifierOf(p.V.Group()), &p, nil
+}
+
+/*
+LoadRangeProof deserializes a proof of the scheme it is tagged with and returns it
+together with a verifier.
+*/
+func LoadRangeProof(data []byte) (*Bp, RangeProof, error) {
+	p, err := unmarshalRangeProof(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return getVerifierOf(p.Commitment().Group()), p, nil
 }
@@ -16,3 +16,68 @@
 
 package zkproofs
 
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+/*
+Test that a serialized proof is verified without any state from the prover.
+*/
+func TestDumpLoadProof(t *testing.T) {
+	_, proof, _ := GetZkrp().GenerateProof(new(big.Int).SetInt64(30))
+	data, err := DumpProof(&proof)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	for _, field := range []string{"Commit", "\"U\"", "\"P\"", "\"Gg\"", "\"Hh\""} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("Assert failure: serialized proof contains %s", field)
+		}
+	}
+	verifier, loaded, err := LoadProof(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	ok, _ := verifier.Verify(*loaded)
+	if ok != true {
+		t.Errorf("Assert failure: expected true, actual: %t", ok)
+	}
+}
+
+/*
+Test that a loaded proof is rejected when it is moved to another commitment.
+*/
+func TestLoadProofOtherCommitment(t *testing.T) {
+	_, proof, _ := GetZkrp().GenerateProof(new(big.Int).SetInt64(30))
+	_, other, _ := GetZkrp().GenerateProof(new(big.Int).SetInt64(40))
+	proof.V = other.V
+	data, _ := DumpProof(&proof)
+	verifier, loaded, _ := LoadProof(data)
+	ok, _ := verifier.Verify(*loaded)
+	if ok != false {
+		t.Errorf("Assert failure: expected false, actual: %t", ok)
+	}
+}
+
+/*
+Test that malformed proofs are rejected by LoadProof.
+*/
+func TestLoadProofInput(t *testing.T) {
+	_, proof, _ := GetZkrp().GenerateProof(new(big.Int).SetInt64(30))
+	data, _ := DumpProof(&proof)
+	inputs := []string{
+		"",
+		"{}",
+		strings.Replace(string(data), "\"Taux\":\"", "\"Taux\":\"x", 1),
+		strings.Replace(string(data), "\"Rs\":[\"", "\"Rs\":[\"04"+strings.Repeat("0", 63)+"1"+strings.Repeat("0", 63)+"2\",\"", 1),
+		strings.Replace(string(data), "\"A\":\"0", "\"A\":\"00\",\"X\":\"0", 1),
+	}
+	for _, input := range inputs {
+		_, _, err := LoadProof([]byte(input))
+		if err == nil {
+			t.Errorf("Assert failure: expected error for %.40q", input)
+		}
+	}
+}